@@ -25,6 +25,15 @@ type Attachment struct {
 	// and might be modified by the pdf reader.
 	Description string
 
+	// MimeType is the media type of the content, such as "text/xml". It is
+	// recorded with the embedded file if not empty.
+	MimeType string
+
+	// Relationship is the relationship of the content to the document recorded
+	// in PDF/A-3 documents: "Source", "Data", "Alternative", "Supplement" or
+	// "Unspecified", the default. See SetPDFAConformance().
+	Relationship string
+
 	objectNumber int // filled when content is included
 }
 
@@ -35,8 +44,9 @@ func checksum(data []byte) string {
 }
 
 // Writes a compressed file like object as "/EmbeddedFile". Compressing is
-// done with deflate. Includes length, compressed length and MD5 checksum.
-func (f *DocPDF) writeCompressedFileObject(content []byte) {
+// done with deflate. Includes length, compressed length, MD5 checksum and,
+// when known, media type and modification date.
+func (f *DocPDF) writeCompressedFileObject(content []byte, mimeType string) {
 	lenUncompressed := len(content)
 	sum := checksum(content)
 	mem := xmem.compress(content)
@@ -44,8 +54,22 @@ func (f *DocPDF) writeCompressedFileObject(content []byte) {
 	compressed := mem.bytes()
 	lenCompressed := len(compressed)
 	f.newobj()
-	f.outf("<< /Type /EmbeddedFile /Length %d /Filter /FlateDecode /Params << /CheckSum <%s> /Size %d >> >>\n",
-		lenCompressed, sum, lenUncompressed)
+	sumStr := "<" + sum + ">"
+	if f.protect.encrypted {
+		raw, _ := hex.DecodeString(sum)
+		sumStr = f.textstring(string(raw))
+	}
+	subtypeStr := ""
+	if mimeType != "" {
+		subtypeStr = "/Subtype /" + strings.ReplaceAll(mimeType, "/", "#2F") + " "
+	}
+	modStr := ""
+	if f.pdfaPart == 3 {
+		_, mod := f.documentDates()
+		modStr = "/ModDate " + f.textstring(f.infoDate(mod)) + " "
+	}
+	f.outf("<< /Type /EmbeddedFile %s/Length %d /Filter /FlateDecode /Params << /CheckSum %s %s/Size %d >> >>\n",
+		subtypeStr, f.protect.encryptedLen(lenCompressed), sumStr, modStr, lenUncompressed)
 	f.putstream(compressed)
 	f.out("endobj")
 }
@@ -57,13 +81,19 @@ func (f *DocPDF) embed(a *Attachment) {
 	}
 	oldState := f.state
 	f.state = 1 // we write file content in the main buffer
-	f.writeCompressedFileObject(a.Content)
+	f.writeCompressedFileObject(a.Content, a.MimeType)
 	streamID := f.n
 	f.newobj()
-	f.outf("<< /Type /Filespec /F () /UF %s /EF << /F %d 0 R >> /Desc %s\n>>",
+	relStr := ""
+	if f.pdfaPart == 3 {
+		relStr = " /AFRelationship /" + afRelationship(a.Relationship)
+	}
+	f.outf("<< /Type /Filespec /F %s /UF %s /EF << /F %d 0 R >> /Desc %s%s\n>>",
+		f.textstring(""),
 		f.textstring(utf8toutf16(a.Filename)),
 		streamID,
-		f.textstring(utf8toutf16(a.Description)))
+		f.textstring(utf8toutf16(a.Description)),
+		relStr)
 	f.out("endobj")
 	a.objectNumber = f.n
 	f.state = oldState
@@ -92,12 +122,38 @@ func (f *DocPDF) putAttachments() {
 func (f DocPDF) getEmbeddedFiles() string {
 	names := make([]string, len(f.attachments))
 	for i, as := range f.attachments {
-		names[i] = fmt.Sprintf("(Attachement%d) %d 0 R ", i+1, as.objectNumber)
+		names[i] = fmt.Sprintf("%s %d 0 R ", f.textstring(fmt.Sprintf("Attachement%d", i+1)), as.objectNumber)
 	}
 	nameTree := fmt.Sprintf("<< /Names [\n %s \n] >>", strings.Join(names, "\n"))
 	return nameTree
 }
 
+// write the /AF catalog entry associating the embedded files with the
+// document, required by PDF/A-3.
+func (f *DocPDF) putAssociatedFiles() {
+	if f.pdfaPart != 3 {
+		return
+	}
+	list := f.pdfaAttachments()
+	if len(list) == 0 {
+		return
+	}
+	refs := make([]string, len(list))
+	for i, a := range list {
+		refs[i] = fmt.Sprintf("%d 0 R", a.objectNumber)
+	}
+	f.outf("/AF [%s]", strings.Join(refs, " "))
+}
+
+// return the /F annotation flags entry, which makes annotations printable as
+// PDF/A requires.
+func (f *DocPDF) annotFlags() string {
+	if f.pdfaPart > 0 {
+		return "/F 4 "
+	}
+	return ""
+}
+
 // ---------------------------------- Annotations ----------------------------------
 
 type annotationAttach struct {
@@ -151,6 +207,7 @@ func (f *DocPDF) putAttachmentAnnotationLinks(out *fmtBuffer, page int) {
 
 		out.printf("<< /Type /Annot /Subtype /FileAttachment /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0]\n",
 			x1, y1, x2, y2)
+		out.printf("%s", f.annotFlags())
 		out.printf("/Contents %s ", f.textstring(utf8toutf16(an.Description)))
 		out.printf("/T %s ", f.textstring(utf8toutf16(an.Filename)))
 		out.printf("/AP << /N %s>>", as)
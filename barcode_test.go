@@ -0,0 +1,98 @@
+package docpdf_test
+
+import (
+	"testing"
+
+	"github.com/cdvelop/docpdf/contrib/barcode"
+)
+
+// Test_Barcode demonstrates the vector barcodes of the barcode package.
+func Test_Barcode(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Courier", "", 10)
+	pdf.AddPage()
+	draw := func(code *barcode.Code, err error, x, y, w, h float64) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		pdf.SetXY(x, y-6)
+		pdf.Cell(w, 5, code.Kind)
+		barcode.Draw(pdf, code, x, y, w, h, true)
+	}
+	code, err := barcode.NewCode128("Shipping label 0123456789")
+	draw(code, err, 15, 20, 90, 20)
+	code, err = barcode.NewCode39("INVOICE-42", true, false)
+	draw(code, err, 115, 20, 80, 20)
+	code, err = barcode.NewEAN13("400638133393")
+	draw(code, err, 15, 55, 50, 25)
+	code, err = barcode.NewEAN8("9638507")
+	draw(code, err, 75, 55, 35, 25)
+	code, err = barcode.NewUPCA("03600029145")
+	draw(code, err, 120, 55, 50, 25)
+	code, err = barcode.NewInterleaved2of5("1234567", true)
+	draw(code, err, 15, 95, 60, 20)
+	code, err = barcode.NewQR("https://github.com/cdvelop/docpdf", barcode.QRLevelM)
+	draw(code, err, 15, 130, 40, 40)
+	code, err = barcode.NewDataMatrix("Invoice 2024-0042")
+	draw(code, err, 70, 130, 30, 30)
+	code, err = barcode.NewPDF417("Ship to: Jane Doe, 42 Example Road, Springfield", -1, 0)
+	draw(code, err, 115, 130, 80, 30)
+	fileStr := Filename("contrib_barcode_Draw")
+	err = pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	// Output:
+	// Successfully generated pdf/contrib_barcode_Draw.pdf
+}
+
+// Test_BarcodeEncoding checks check digits, automatic sizing and input
+// validation of the barcode encoders.
+func Test_BarcodeEncoding(t *testing.T) {
+	code, err := barcode.NewEAN13("400638133393")
+	if err != nil || code.Text != "4006381333931" {
+		t.Errorf("EAN-13 check digit: got %v, %v", code, err)
+	}
+	if _, err = barcode.NewEAN13("4006381333932"); err == nil {
+		t.Errorf("EAN-13 with invalid check digit was accepted")
+	}
+	if code, err = barcode.NewUPCA("03600029145"); err != nil || code.Text != "036000291452" {
+		t.Errorf("UPC-A check digit: got %v, %v", code, err)
+	}
+	// Start C, 4 digit pairs, code B, 3 characters, check and stop symbols
+	if code, err = barcode.NewCode128("12345678abc"); err != nil {
+		t.Fatal(err)
+	} else if cols, _ := code.Size(); cols != 11*11+2 {
+		t.Errorf("Code 128 width: got %d modules", cols)
+	}
+	if _, err = barcode.NewCode39("lower", false, false); err == nil {
+		t.Errorf("Code 39 accepted lower case without full ASCII")
+	}
+	if _, err = barcode.NewCode39("lower", false, true); err != nil {
+		t.Errorf("Code 39 full ASCII: %v", err)
+	}
+	for level, size := range []int{21, 21, 21, 25} {
+		code, err = barcode.NewQR("HELLO WORLD", barcode.QRLevel(level))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cols, rows := code.Size(); cols != size || rows != size {
+			t.Errorf("QR level %d size: got %dx%d", level, cols, rows)
+		}
+	}
+	// Finder pattern in the upper left corner
+	if !code.Dark(0, 0) || code.Dark(1, 1) || !code.Dark(2, 2) || code.Dark(7, 7) {
+		t.Errorf("QR finder pattern is not in place")
+	}
+	if code, err = barcode.NewDataMatrix("123456"); err != nil {
+		t.Fatal(err)
+	} else if cols, rows := code.Size(); cols != 10 || rows != 10 {
+		t.Errorf("DataMatrix size: got %dx%d", cols, rows)
+	}
+	if code, err = barcode.NewPDF417("PDF417", 2, 3); err != nil {
+		t.Fatal(err)
+	} else if cols, _ := code.Size(); cols != 17*7+1 {
+		t.Errorf("PDF417 width: got %d modules", cols)
+	}
+	if _, err = barcode.NewPDF417("x", 9, 0); err == nil {
+		t.Errorf("PDF417 accepted security level 9")
+	}
+}
@@ -0,0 +1,275 @@
+package docpdf
+
+import "unicode"
+
+// Bidirectional character types of the Unicode bidirectional algorithm
+const (
+	bidiL = iota
+	bidiR
+	bidiAL
+	bidiEN
+	bidiES
+	bidiET
+	bidiAN
+	bidiCS
+	bidiNSM
+	bidiBN
+	bidiB
+	bidiS
+	bidiWS
+	bidiON
+)
+
+// bidiClass returns the bidirectional type of r. The types are derived from
+// the Unicode blocks and general categories, which matches the Unicode
+// character database for the scripts supported by the shaper.
+func bidiClass(r rune) int {
+	switch {
+	case r >= '0' && r <= '9', r == 0xB2, r == 0xB3, r == 0xB9,
+		r >= 0x06F0 && r <= 0x06F9, r >= 0x2070 && r <= 0x2079 && r != 0x2071 && r != 0x2072 && r != 0x2073,
+		r >= 0x2080 && r <= 0x2089, r >= 0xFF10 && r <= 0xFF19:
+		return bidiEN
+	case r == '+', r == '-', r == 0x207A, r == 0x207B, r == 0x208A, r == 0x208B, r == 0x2212,
+		r == 0xFB29, r == 0xFE62, r == 0xFE63, r == 0xFF0B, r == 0xFF0D:
+		return bidiES
+	case r == '#', r == '$', r == '%', r >= 0xA2 && r <= 0xA5, r == 0xB0, r == 0xB1,
+		r == 0x0609, r == 0x060A, r == 0x066A, r >= 0x2030 && r <= 0x2034,
+		r >= 0x20A0 && r <= 0x20CF, r == 0x212E, r == 0x2213:
+		return bidiET
+	case r == ',', r == '.', r == '/', r == ':', r == 0xA0, r == 0x060C, r == 0x202F,
+		r == 0x2044, r == 0xFE50, r == 0xFE52, r == 0xFE55, r == 0xFF0C, r == 0xFF0E,
+		r == 0xFF0F, r == 0xFF1A:
+		return bidiCS
+	case r >= 0x0660 && r <= 0x0669, r == 0x066B, r == 0x066C, r >= 0x0600 && r <= 0x0605, r == 0x06DD:
+		return bidiAN
+	case r == '\n', r == '\r', r >= 0x1C && r <= 0x1E, r == 0x85, r == 0x2029:
+		return bidiB
+	case r == '\t', r == 0x0B, r == 0x1F:
+		return bidiS
+	case r == ' ', r == 0x0C, r == 0x1680, r >= 0x2000 && r <= 0x200A, r == 0x2028, r == 0x205F, r == 0x3000:
+		return bidiWS
+	case r < 0x20, r >= 0x7F && r <= 0x9F, r == 0xAD, r >= 0x200B && r <= 0x200D,
+		r >= 0x2060 && r <= 0x2064, r == 0xFEFF:
+		return bidiBN
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return bidiNSM
+	case r >= 0x0590 && r <= 0x05FF, r >= 0x07C0 && r <= 0x085F, r >= 0xFB1D && r <= 0xFB4F,
+		r >= 0x10800 && r <= 0x10FFF, r == 0x200F:
+		return bidiR
+	case r >= 0x0600 && r <= 0x07BF, r >= 0x0860 && r <= 0x08FF, r >= 0xFB50 && r <= 0xFDFF,
+		r >= 0xFE70 && r <= 0xFEFF:
+		return bidiAL
+	case unicode.In(r, unicode.P, unicode.S):
+		return bidiON
+	}
+	return bidiL
+}
+
+// bidiMirror holds the mirrored glyphs of paired characters, which are
+// displayed mirrored in right-to-left text.
+var bidiMirror = map[rune]rune{
+	'(': ')', ')': '(', '<': '>', '>': '<', '[': ']', ']': '[', '{': '}', '}': '{',
+	0xAB: 0xBB, 0xBB: 0xAB, 0x2039: 0x203A, 0x203A: 0x2039, 0x2045: 0x2046, 0x2046: 0x2045,
+	0x207D: 0x207E, 0x207E: 0x207D, 0x208D: 0x208E, 0x208E: 0x208D, 0x2264: 0x2265,
+	0x2265: 0x2264, 0x2329: 0x232A, 0x232A: 0x2329, 0x3008: 0x3009, 0x3009: 0x3008,
+	0x300A: 0x300B, 0x300B: 0x300A, 0x300C: 0x300D, 0x300D: 0x300C,
+}
+
+// bidiLevels returns the resolved embedding level of each rune of a line
+// of text. The paragraph level is right-to-left if rtl is true, otherwise it
+// is given by the first strong character. Explicit embedding and isolate
+// controls are not supported and are treated as boundary neutrals.
+func bidiLevels(text []rune, rtl bool) (levels []int, base int) {
+	n := len(text)
+	types := make([]int, n)
+	for j, r := range text {
+		types[j] = bidiClass(r)
+	}
+	if rtl {
+		base = 1
+	} else {
+		for _, t := range types {
+			if t == bidiL {
+				break
+			}
+			if t == bidiR || t == bidiAL {
+				base = 1
+				break
+			}
+		}
+	}
+	sos := bidiL
+	if base == 1 {
+		sos = bidiR
+	}
+	// W1: non-spacing marks take the type of the previous character
+	prev := sos
+	for j, t := range types {
+		switch t {
+		case bidiNSM:
+			types[j] = prev
+		case bidiBN:
+		default:
+			prev = t
+		}
+	}
+	// W2, W3: European numbers after Arabic letters are Arabic numbers
+	strong := sos
+	for j, t := range types {
+		switch t {
+		case bidiL, bidiR, bidiAL:
+			strong = t
+			if t == bidiAL {
+				types[j] = bidiR
+			}
+		case bidiEN:
+			if strong == bidiAL {
+				types[j] = bidiAN
+			}
+		}
+	}
+	// W4: a single separator between two numbers of the same type
+	for j := 1; j < n-1; j++ {
+		switch {
+		case types[j] == bidiES && types[j-1] == bidiEN && types[j+1] == bidiEN:
+			types[j] = bidiEN
+		case types[j] == bidiCS && types[j-1] == bidiEN && types[j+1] == bidiEN:
+			types[j] = bidiEN
+		case types[j] == bidiCS && types[j-1] == bidiAN && types[j+1] == bidiAN:
+			types[j] = bidiAN
+		}
+	}
+	// W5: terminators adjacent to European numbers
+	for j := 0; j < n; j++ {
+		if types[j] != bidiET {
+			continue
+		}
+		k := j
+		for k < n && (types[k] == bidiET || types[k] == bidiBN) {
+			k++
+		}
+		if (j > 0 && types[j-1] == bidiEN) || (k < n && types[k] == bidiEN) {
+			for ; j < k; j++ {
+				types[j] = bidiEN
+			}
+		}
+		j = k - 1
+	}
+	// W6: remaining separators and terminators are neutrals
+	for j, t := range types {
+		if t == bidiES || t == bidiET || t == bidiCS {
+			types[j] = bidiON
+		}
+	}
+	// W7: European numbers after left-to-right text
+	strong = sos
+	for j, t := range types {
+		switch t {
+		case bidiL, bidiR:
+			strong = t
+		case bidiEN:
+			if strong == bidiL {
+				types[j] = bidiL
+			}
+		}
+	}
+	// N1, N2: neutrals take the direction of the surrounding strong text if
+	// both sides agree, and the embedding direction otherwise
+	dir := func(t int) int {
+		switch t {
+		case bidiL:
+			return bidiL
+		case bidiR, bidiEN, bidiAN:
+			return bidiR
+		}
+		return -1
+	}
+	for j := 0; j < n; j++ {
+		if dir(types[j]) >= 0 {
+			continue
+		}
+		k := j
+		for k < n && dir(types[k]) < 0 {
+			k++
+		}
+		before, after := sos, sos
+		if j > 0 {
+			before = dir(types[j-1])
+		}
+		if k < n {
+			after = dir(types[k])
+		}
+		d := sos
+		if before == after {
+			d = before
+		}
+		for ; j < k; j++ {
+			types[j] = d
+		}
+		j = k - 1
+	}
+	// I1, I2: implicit levels
+	levels = make([]int, n)
+	for j, t := range types {
+		switch {
+		case base == 0 && t == bidiR:
+			levels[j] = 1
+		case base == 0 && (t == bidiAN || t == bidiEN):
+			levels[j] = 2
+		case base == 1 && (t == bidiL || t == bidiEN || t == bidiAN):
+			levels[j] = 2
+		default:
+			levels[j] = base
+		}
+	}
+	// L1: separators and trailing white space are reset to the paragraph
+	// level
+	trailing := true
+	for j := n - 1; j >= 0; j-- {
+		switch bidiClass(text[j]) {
+		case bidiS, bidiB:
+			levels[j] = base
+			trailing = true
+		case bidiWS, bidiBN:
+			if trailing {
+				levels[j] = base
+			}
+		default:
+			trailing = false
+		}
+	}
+	return levels, base
+}
+
+// bidiReorder returns the visual order of items with the given levels, as
+// indices into levels (rule L2).
+func bidiReorder(levels []int) []int {
+	order := make([]int, len(levels))
+	maxLevel, minOdd := 0, 1<<30
+	for j, l := range levels {
+		order[j] = j
+		if l > maxLevel {
+			maxLevel = l
+		}
+		if l%2 == 1 && l < minOdd {
+			minOdd = l
+		}
+	}
+	for level := maxLevel; level >= minOdd; level-- {
+		for j := 0; j < len(order); {
+			if levels[order[j]] < level {
+				j++
+				continue
+			}
+			k := j
+			for k < len(order) && levels[order[k]] >= level {
+				k++
+			}
+			for a, b := j, k-1; a < b; a, b = a+1, b-1 {
+				order[a], order[b] = order[b], order[a]
+			}
+			j = k
+		}
+	}
+	return order
+}
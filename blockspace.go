@@ -0,0 +1,38 @@
+package docpdf
+
+import (
+	"math"
+)
+
+// blockSpacing holds the vertical space pending before the next block of the
+// HTML and Markdown renderers. The space is only applied once content
+// follows, so that it is dropped at the top of a page or of a text frame and
+// after the last block.
+type blockSpacing struct {
+	space float64 // pending vertical space before the next block
+}
+
+// addSpace requests vertical space before the next content. Adjoining spaces
+// collapse to the largest one.
+func (bs *blockSpacing) addSpace(ht float64) {
+	bs.space = math.Max(bs.space, ht)
+}
+
+// useSpace moves the current position of f down by the pending vertical
+// space, except at the top of a page or of a text frame
+func (bs *blockSpacing) useSpace(f *DocPDF) {
+	if bs.space > 0 && f.y > f.frameTop() {
+		f.y += bs.space
+	}
+	bs.space = 0
+}
+
+// breakIfNeeded performs an automatic page break if the pending space and
+// content of height ht do not fit below the current position of f. The
+// pending space is dropped on the new page.
+func (bs *blockSpacing) breakIfNeeded(f *DocPDF, ht float64) {
+	if f.y+bs.space+ht > f.pageBreakTrigger && !f.inHeader && !f.inFooter && f.acceptPageBreak() {
+		f.AddPageFormat(f.curOrientation, f.curPageSize)
+		bs.space = 0
+	}
+}
@@ -0,0 +1,597 @@
+package docpdf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// CFF DICT operators, escaped operators are 1200 plus the second byte
+const (
+	cffFontBBox     = 5
+	cffCharset      = 15
+	cffCharStrings  = 17
+	cffPrivate      = 18
+	cffSubrs        = 19
+	cffFontMatrix   = 1207
+	cffROS          = 1230
+	cffCIDCount     = 1234
+	cffFDArray      = 1236
+	cffFDSelect     = 1237
+	cffStdStrings   = 391
+	cffEndchar      = 14
+	cffReturn       = 11
+	cffCallSubr     = 10
+	cffCallGSubr    = 29
+	cffMaxSubrDepth = 10
+)
+
+// cffDictEntry is an operator of a CFF DICT with its operands, which are
+// kept in their original encoding to be copied unchanged.
+type cffDictEntry struct {
+	op       int
+	operands []float64
+	raw      []byte
+}
+
+type cffDict []cffDictEntry
+
+// cffFontDict holds the private DICT and the local subroutines of a font
+// DICT of a CID-keyed CFF font, or of the top DICT of other fonts.
+type cffFontDict struct {
+	dict    cffDict
+	private cffDict
+	subrs   [][]byte
+}
+
+// cffFont holds the parts of a CFF table needed to subset it.
+type cffFont struct {
+	name        []byte
+	top         cffDict
+	gsubrs      [][]byte
+	charStrings [][]byte
+	fds         []cffFontDict
+	fdSelect    []int
+}
+
+// get returns the operands of the operator op, or nil if the DICT does not
+// have it.
+func (d cffDict) get(op int) []float64 {
+	for _, e := range d {
+		if e.op == op {
+			return e.operands
+		}
+	}
+	return nil
+}
+
+// parseCFFDict parses the operators of a CFF DICT.
+func parseCFFDict(d []byte) (dict cffDict, err error) {
+	var operands []float64
+	start := 0
+	for j := 0; j < len(d); {
+		b := int(d[j])
+		switch {
+		case b <= 21:
+			op := b
+			raw := d[start:j]
+			j++
+			if b == 12 {
+				if j >= len(d) {
+					return nil, fmt.Errorf("truncated CFF DICT")
+				}
+				op = 1200 + int(d[j])
+				j++
+			}
+			dict = append(dict, cffDictEntry{op: op, operands: operands, raw: raw})
+			operands = nil
+			start = j
+		case b == 28 && j+2 < len(d):
+			operands = append(operands, float64(int16(binary.BigEndian.Uint16(d[j+1:]))))
+			j += 3
+		case b == 29 && j+4 < len(d):
+			operands = append(operands, float64(int32(binary.BigEndian.Uint32(d[j+1:]))))
+			j += 5
+		case b == 30:
+			// real number, kept as raw bytes only
+			operands = append(operands, 0)
+			for j++; j < len(d) && d[j]&0x0F != 0x0F && d[j]&0xF0 != 0xF0; j++ {
+			}
+			j++
+		case b >= 32 && b <= 246:
+			operands = append(operands, float64(b-139))
+			j++
+		case b >= 247 && b <= 250 && j+1 < len(d):
+			operands = append(operands, float64((b-247)*256+int(d[j+1])+108))
+			j += 2
+		case b >= 251 && b <= 254 && j+1 < len(d):
+			operands = append(operands, float64(-(b-251)*256-int(d[j+1])-108))
+			j += 2
+		default:
+			return nil, fmt.Errorf("invalid CFF DICT operand %d", b)
+		}
+	}
+	return dict, nil
+}
+
+// readCFFIndex returns the items of the CFF INDEX at offset off of d, and
+// the offset of its end.
+func readCFFIndex(d []byte, off int) (items [][]byte, end int, err error) {
+	if off < 0 || off+2 > len(d) {
+		return nil, 0, fmt.Errorf("invalid CFF INDEX offset %d", off)
+	}
+	count := int(binary.BigEndian.Uint16(d[off:]))
+	if count == 0 {
+		return nil, off + 2, nil
+	}
+	if off+3 > len(d) {
+		return nil, 0, fmt.Errorf("truncated CFF INDEX")
+	}
+	offSize := int(d[off+2])
+	if offSize < 1 || offSize > 4 || off+3+(count+1)*offSize > len(d) {
+		return nil, 0, fmt.Errorf("invalid CFF INDEX")
+	}
+	offset := func(j int) int {
+		v := 0
+		for _, b := range d[off+3+j*offSize : off+3+(j+1)*offSize] {
+			v = v<<8 | int(b)
+		}
+		return v
+	}
+	base := off + 2 + (count+1)*offSize
+	items = make([][]byte, count)
+	for j := range items {
+		a, b := base+offset(j), base+offset(j+1)
+		if a > b || b > len(d) {
+			return nil, 0, fmt.Errorf("invalid CFF INDEX offsets")
+		}
+		items[j] = d[a:b]
+	}
+	return items, base + offset(count), nil
+}
+
+// parseCFF parses the CFF table of an OpenType font with PostScript
+// outlines.
+func parseCFF(d []byte) (*cffFont, error) {
+	if len(d) < 4 || d[0] != 1 {
+		return nil, fmt.Errorf("unsupported CFF table version")
+	}
+	names, off, err := readCFFIndex(d, int(d[2]))
+	if err != nil {
+		return nil, err
+	}
+	tops, off, err := readCFFIndex(d, off)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) != 1 || len(tops) != 1 {
+		return nil, fmt.Errorf("CFF table must hold a single font")
+	}
+	_, off, err = readCFFIndex(d, off) // strings
+	if err != nil {
+		return nil, err
+	}
+	cff := &cffFont{name: names[0]}
+	if cff.gsubrs, _, err = readCFFIndex(d, off); err != nil {
+		return nil, err
+	}
+	if cff.top, err = parseCFFDict(tops[0]); err != nil {
+		return nil, err
+	}
+	if v := cff.top.get(1206); len(v) == 1 && v[0] != 2 {
+		return nil, fmt.Errorf("unsupported CFF charstring type %v", v[0])
+	}
+	cs := cff.top.get(cffCharStrings)
+	if len(cs) != 1 {
+		return nil, fmt.Errorf("CFF font has no CharStrings")
+	}
+	if cff.charStrings, _, err = readCFFIndex(d, int(cs[0])); err != nil {
+		return nil, err
+	}
+	if cff.top.get(cffROS) == nil {
+		fd, err := parseCFFPrivate(d, cff.top)
+		if err != nil {
+			return nil, err
+		}
+		cff.fds = []cffFontDict{fd}
+		return cff, nil
+	}
+	// CID-keyed font
+	fdArray, fdSelect := cff.top.get(cffFDArray), cff.top.get(cffFDSelect)
+	if len(fdArray) != 1 || len(fdSelect) != 1 {
+		return nil, fmt.Errorf("CID-keyed CFF font has no FDArray")
+	}
+	items, _, err := readCFFIndex(d, int(fdArray[0]))
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		dict, err := parseCFFDict(item)
+		if err != nil {
+			return nil, err
+		}
+		fd, err := parseCFFPrivate(d, dict)
+		if err != nil {
+			return nil, err
+		}
+		cff.fds = append(cff.fds, fd)
+	}
+	if cff.fdSelect, err = parseFDSelect(d, int(fdSelect[0]), len(cff.charStrings)); err != nil {
+		return nil, err
+	}
+	for _, fd := range cff.fdSelect {
+		if fd >= len(cff.fds) {
+			return nil, fmt.Errorf("invalid CFF FDSelect")
+		}
+	}
+	return cff, nil
+}
+
+// parseCFFPrivate returns the private DICT and local subroutines referenced
+// by a top or font DICT.
+func parseCFFPrivate(d []byte, dict cffDict) (fd cffFontDict, err error) {
+	fd.dict = dict
+	p := dict.get(cffPrivate)
+	if len(p) != 2 {
+		return fd, nil
+	}
+	size, off := int(p[0]), int(p[1])
+	if size < 0 || off < 0 || off+size > len(d) {
+		return fd, fmt.Errorf("invalid CFF Private DICT")
+	}
+	if fd.private, err = parseCFFDict(d[off : off+size]); err != nil {
+		return fd, err
+	}
+	if subrs := fd.private.get(cffSubrs); len(subrs) == 1 {
+		fd.subrs, _, err = readCFFIndex(d, off+int(subrs[0]))
+	}
+	return fd, err
+}
+
+// parseFDSelect returns the font DICT index of each glyph of a CID-keyed
+// font.
+func parseFDSelect(d []byte, off, numGlyphs int) ([]int, error) {
+	if off < 0 || off >= len(d) {
+		return nil, fmt.Errorf("invalid CFF FDSelect offset")
+	}
+	sel := make([]int, numGlyphs)
+	switch d[off] {
+	case 0:
+		if off+1+numGlyphs > len(d) {
+			return nil, fmt.Errorf("truncated CFF FDSelect")
+		}
+		for j := range sel {
+			sel[j] = int(d[off+1+j])
+		}
+	case 3:
+		if off+3 > len(d) {
+			return nil, fmt.Errorf("truncated CFF FDSelect")
+		}
+		n := int(binary.BigEndian.Uint16(d[off+1:]))
+		if off+5+3*n > len(d) {
+			return nil, fmt.Errorf("truncated CFF FDSelect")
+		}
+		for j := 0; j < n; j++ {
+			rec := off + 3 + 3*j
+			first, next := int(binary.BigEndian.Uint16(d[rec:])), int(binary.BigEndian.Uint16(d[rec+3:]))
+			for gid := first; gid < next && gid < numGlyphs; gid++ {
+				sel[gid] = int(d[rec+2])
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported CFF FDSelect format %d", d[off])
+	}
+	return sel, nil
+}
+
+// fdIndex returns the index of the font DICT of the glyph gid.
+func (cff *cffFont) fdIndex(gid int) int {
+	if cff.fdSelect == nil {
+		return 0
+	}
+	return cff.fdSelect[gid]
+}
+
+// cffSubrBias returns the bias added to the subroutine numbers of Type 2
+// charstrings.
+func cffSubrBias(count int) int {
+	switch {
+	case count < 1240:
+		return 107
+	case count < 33900:
+		return 1131
+	}
+	return 32768
+}
+
+// cffSubrUse records the subroutines called by a set of Type 2 charstrings.
+type cffSubrUse struct {
+	gsubrs, subrs [][]byte
+	gUsed, lUsed  map[int]bool
+	stack         []float64
+	stems         int
+}
+
+// run interprets the charstring cs far enough to follow subroutine calls,
+// and reports whether it ends the glyph.
+func (u *cffSubrUse) run(cs []byte, depth int) bool {
+	if depth > cffMaxSubrDepth {
+		return true
+	}
+	for j := 0; j < len(cs); {
+		b := int(cs[j])
+		switch {
+		case b == 28 && j+2 < len(cs):
+			u.stack = append(u.stack, float64(int16(binary.BigEndian.Uint16(cs[j+1:]))))
+			j += 3
+		case b >= 32 && b <= 246:
+			u.stack = append(u.stack, float64(b-139))
+			j++
+		case b >= 247 && b <= 250 && j+1 < len(cs):
+			u.stack = append(u.stack, float64((b-247)*256+int(cs[j+1])+108))
+			j += 2
+		case b >= 251 && b <= 254 && j+1 < len(cs):
+			u.stack = append(u.stack, float64(-(b-251)*256-int(cs[j+1])-108))
+			j += 2
+		case b == 255 && j+4 < len(cs):
+			u.stack = append(u.stack, float64(int32(binary.BigEndian.Uint32(cs[j+1:])))/65536)
+			j += 5
+		case b == cffCallSubr || b == cffCallGSubr:
+			j++
+			if len(u.stack) == 0 {
+				return true
+			}
+			subrs, used := u.subrs, u.lUsed
+			if b == cffCallGSubr {
+				subrs, used = u.gsubrs, u.gUsed
+			}
+			n := int(u.stack[len(u.stack)-1]) + cffSubrBias(len(subrs))
+			u.stack = u.stack[:len(u.stack)-1]
+			if n < 0 || n >= len(subrs) {
+				return true
+			}
+			used[n] = true
+			if u.run(subrs[n], depth+1) {
+				return true
+			}
+		case b == cffReturn:
+			return false
+		case b == cffEndchar:
+			return true
+		case b == 1 || b == 3 || b == 18 || b == 23: // stem hints
+			u.stems += len(u.stack) / 2
+			u.stack = u.stack[:0]
+			j++
+		case b == 19 || b == 20: // hintmask, cntrmask
+			u.stems += len(u.stack) / 2
+			u.stack = u.stack[:0]
+			j += 1 + (u.stems+7)/8
+		case b == 12:
+			u.stack = u.stack[:0]
+			j += 2
+		default:
+			u.stack = u.stack[:0]
+			j++
+		}
+	}
+	return false
+}
+
+// cffInt returns the five byte encoding of the DICT integer operand v.
+func cffInt(v int) []byte {
+	return []byte{29, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+// cffOp returns the encoding of the DICT operator op.
+func cffOp(op int) []byte {
+	if op >= 1200 {
+		return []byte{12, byte(op - 1200)}
+	}
+	return []byte{byte(op)}
+}
+
+// cffIndex returns the encoding of a CFF INDEX.
+func cffIndex(items [][]byte) []byte {
+	if len(items) == 0 {
+		return []byte{0, 0}
+	}
+	size := 1
+	for _, item := range items {
+		size += len(item)
+	}
+	offSize := 1
+	for size>>(8*offSize) > 0 {
+		offSize++
+	}
+	buf := []byte{byte(len(items) >> 8), byte(len(items)), byte(offSize)}
+	putOffset := func(v int) {
+		for k := offSize - 1; k >= 0; k-- {
+			buf = append(buf, byte(v>>(8*k)))
+		}
+	}
+	off := 1
+	putOffset(off)
+	for _, item := range items {
+		off += len(item)
+		putOffset(off)
+	}
+	for _, item := range items {
+		buf = append(buf, item...)
+	}
+	return buf
+}
+
+// keepSubrs returns the subroutines with the unused ones emptied, which
+// keeps the numbers of the used ones.
+func keepSubrs(subrs [][]byte, used map[int]bool) [][]byte {
+	kept := make([][]byte, len(subrs))
+	for j, subr := range subrs {
+		if used[j] {
+			kept[j] = subr
+		} else {
+			kept[j] = []byte{cffReturn}
+		}
+	}
+	return kept
+}
+
+// subset returns a CID-keyed CFF font holding the glyphs gids, whose
+// character identifiers are cids. The first glyph must be the .notdef
+// glyph, with character identifier 0. Subroutines are kept with their
+// numbers, but the unused ones are emptied.
+func (cff *cffFont) subset(gids, cids []int) []byte {
+	// font DICTs used by the glyphs
+	fdMap := make(map[int]int)
+	var fdOrder []int
+	for _, gid := range gids {
+		if _, ok := fdMap[cff.fdIndex(gid)]; !ok {
+			fdMap[cff.fdIndex(gid)] = len(fdOrder)
+			fdOrder = append(fdOrder, cff.fdIndex(gid))
+		}
+	}
+	gUsed := make(map[int]bool)
+	lUsed := make(map[int]map[int]bool)
+	charStrings := make([][]byte, len(gids))
+	for j, gid := range gids {
+		fd := cff.fdIndex(gid)
+		if lUsed[fd] == nil {
+			lUsed[fd] = make(map[int]bool)
+		}
+		u := cffSubrUse{gsubrs: cff.gsubrs, subrs: cff.fds[fd].subrs, gUsed: gUsed, lUsed: lUsed[fd]}
+		u.run(cff.charStrings[gid], 0)
+		charStrings[j] = cff.charStrings[gid]
+	}
+
+	// charset and FDSelect, format 0
+	charset := []byte{0}
+	for _, cid := range cids[1:] {
+		charset = append(charset, byte(cid>>8), byte(cid))
+	}
+	fdSelect := []byte{0}
+	for _, gid := range gids {
+		fdSelect = append(fdSelect, byte(fdMap[cff.fdIndex(gid)]))
+	}
+	maxCID := 0
+	for _, cid := range cids {
+		maxCID = max(maxCID, cid)
+	}
+
+	// private DICTs, each followed by its subroutines
+	privates := make([][]byte, len(fdOrder))
+	privateSizes := make([]int, len(fdOrder))
+	for j, fd := range fdOrder {
+		src := cff.fds[fd]
+		p := src.private.encodeWithout(cffSubrs)
+		if len(src.subrs) > 0 {
+			p = append(append(p, cffInt(len(p)+6)...), cffOp(cffSubrs)...)
+			privateSizes[j] = len(p)
+			p = append(p, cffIndex(keepSubrs(src.subrs, lUsed[fd]))...)
+		} else {
+			privateSizes[j] = len(p)
+		}
+		privates[j] = p
+	}
+
+	copyOps := func(dict cffDict, ops ...int) (buf []byte) {
+		for _, e := range dict {
+			for _, op := range ops {
+				if e.op == op {
+					buf = append(append(buf, e.raw...), cffOp(op)...)
+				}
+			}
+		}
+		return
+	}
+	topDict := func(charsetOff, fdSelectOff, charStringsOff, fdArrayOff int) []byte {
+		buf := append(cffInt(cffStdStrings), cffInt(cffStdStrings+1)...)
+		buf = append(append(buf, cffInt(0)...), cffOp(cffROS)...)
+		buf = append(buf, copyOps(cff.top, cffFontMatrix, cffFontBBox)...)
+		buf = append(append(buf, cffInt(maxCID+1)...), cffOp(cffCIDCount)...)
+		buf = append(append(buf, cffInt(charsetOff)...), cffOp(cffCharset)...)
+		buf = append(append(buf, cffInt(fdSelectOff)...), cffOp(cffFDSelect)...)
+		buf = append(append(buf, cffInt(charStringsOff)...), cffOp(cffCharStrings)...)
+		return append(append(buf, cffInt(fdArrayOff)...), cffOp(cffFDArray)...)
+	}
+	fdArray := func(privateOff int) []byte {
+		items := make([][]byte, len(fdOrder))
+		for j, fd := range fdOrder {
+			var buf []byte
+			if cff.fdSelect != nil {
+				buf = copyOps(cff.fds[fd].dict, cffFontMatrix)
+			}
+			buf = append(append(buf, cffInt(privateSizes[j])...), cffInt(privateOff)...)
+			items[j] = append(buf, cffOp(cffPrivate)...)
+			privateOff += len(privates[j])
+		}
+		return cffIndex(items)
+	}
+
+	head := []byte{1, 0, 4, 4}
+	head = append(head, cffIndex([][]byte{cff.name})...)
+	strs := cffIndex([][]byte{[]byte("Adobe"), []byte("Identity")})
+	gsubrs := cffIndex(keepSubrs(cff.gsubrs, gUsed))
+	charStringsIndex := cffIndex(charStrings)
+	// the sizes of the top DICT and of the FDArray do not depend on the
+	// offsets, which are written with five bytes
+	charsetOff := len(head) + len(cffIndex([][]byte{topDict(0, 0, 0, 0)})) + len(strs) + len(gsubrs)
+	fdSelectOff := charsetOff + len(charset)
+	charStringsOff := fdSelectOff + len(fdSelect)
+	fdArrayOff := charStringsOff + len(charStringsIndex)
+	privateOff := fdArrayOff + len(fdArray(0))
+
+	out := append(head, cffIndex([][]byte{topDict(charsetOff, fdSelectOff, charStringsOff, fdArrayOff)})...)
+	out = append(out, strs...)
+	out = append(out, gsubrs...)
+	out = append(out, charset...)
+	out = append(out, fdSelect...)
+	out = append(out, charStringsIndex...)
+	out = append(out, fdArray(privateOff)...)
+	for _, p := range privates {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// encodeWithout returns the encoding of the DICT without the operator op.
+func (d cffDict) encodeWithout(op int) (buf []byte) {
+	for _, e := range d {
+		if e.op != op {
+			buf = append(append(buf, e.raw...), cffOp(e.op)...)
+		}
+	}
+	return
+}
+
+// generateCutCFF returns a CID-keyed CFF font with the glyphs of the runes
+// usedRunes and of the glyphs produced by text shaping. The character
+// identifiers of the glyphs are their Unicode values, as for the TrueType
+// fonts, so the same content stream codes can be used.
+func (utf *utf8FontFile) generateCutCFF(usedRunes map[int]int) []byte {
+	utf.fileReader.readerPosition = int64(utf.faceOffset)
+	utf.skip(4)
+	utf.generateTableDescriptions()
+	if utf.generateCMAP() == nil {
+		return nil
+	}
+	if utf.shaperState != nil {
+		for cid, gid := range utf.shaperState.cidGlyphs {
+			utf.charSymbolDictionary[cid] = gid
+		}
+	}
+	utf.LastRune = 0
+	cids := []int{0}
+	for _, char := range usedRunes {
+		utf.LastRune = max(utf.LastRune, char)
+		if gid, ok := utf.charSymbolDictionary[char]; ok && gid > 0 && gid < len(utf.cff.charStrings) && char < 0x10000 {
+			cids = append(cids, char)
+		}
+	}
+	sort.Ints(cids[1:])
+	gids := make([]int, len(cids))
+	utf.CodeSymbolDictionary = make(map[int]int)
+	for j, cid := range cids[1:] {
+		gids[j+1] = utf.charSymbolDictionary[cid]
+		utf.CodeSymbolDictionary[cid] = j + 1
+	}
+	return utf.cff.subset(gids, cids)
+}
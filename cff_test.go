@@ -0,0 +1,490 @@
+package docpdf_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// sfntTables returns the tables of the font of a TrueType or OpenType file
+// whose table directory is at offset off.
+func sfntTables(data []byte, off int) map[string][]byte {
+	tables := make(map[string][]byte)
+	n := int(binary.BigEndian.Uint16(data[off+4:]))
+	for j := 0; j < n; j++ {
+		rec := data[off+12+16*j:]
+		pos, size := binary.BigEndian.Uint32(rec[8:]), binary.BigEndian.Uint32(rec[12:])
+		tables[string(rec[:4])] = data[pos : pos+size]
+	}
+	return tables
+}
+
+// sfntChecksum returns the checksum of a TrueType table.
+func sfntChecksum(table []byte) (sum uint32) {
+	padded := append(append([]byte{}, table...), 0, 0, 0)
+	for j := 0; j+4 <= len(padded); j += 4 {
+		sum += binary.BigEndian.Uint32(padded[j:])
+	}
+	return
+}
+
+// sfntFonts returns a font file with the given tables, or a font collection
+// if there are several fonts.
+func sfntFonts(version string, fonts ...map[string][]byte) []byte {
+	var head, body []byte
+	dirSize := 0
+	for _, tables := range fonts {
+		dirSize += 12 + 16*len(tables)
+	}
+	base := 0
+	if len(fonts) > 1 {
+		base = 12 + 4*len(fonts)
+		head = append([]byte("ttcf"), 0, 1, 0, 0)
+		head = binary.BigEndian.AppendUint32(head, uint32(len(fonts)))
+		off := base
+		for _, tables := range fonts {
+			head = binary.BigEndian.AppendUint32(head, uint32(off))
+			off += 12 + 16*len(tables)
+		}
+	}
+	for _, tables := range fonts {
+		tags := make([]string, 0, len(tables))
+		for tag := range tables {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		sel := 0
+		for 1<<(sel+1) <= len(tags) {
+			sel++
+		}
+		dir := []byte(version)
+		dir = binary.BigEndian.AppendUint16(dir, uint16(len(tags)))
+		dir = binary.BigEndian.AppendUint16(dir, uint16(16<<sel))
+		dir = binary.BigEndian.AppendUint16(dir, uint16(sel))
+		dir = binary.BigEndian.AppendUint16(dir, uint16(16*len(tags)-16<<sel))
+		for _, tag := range tags {
+			dir = append(dir, tag...)
+			dir = binary.BigEndian.AppendUint32(dir, sfntChecksum(tables[tag]))
+			dir = binary.BigEndian.AppendUint32(dir, uint32(base+dirSize+len(body)))
+			dir = binary.BigEndian.AppendUint32(dir, uint32(len(tables[tag])))
+			body = append(body, tables[tag]...)
+			for len(body)%4 != 0 {
+				body = append(body, 0)
+			}
+		}
+		head = append(head, dir...)
+	}
+	return append(head, body...)
+}
+
+type ttfPoint struct {
+	x, y float64
+	on   bool
+}
+
+// ttfContours returns the contours of the glyph gid of a TrueType font.
+func ttfContours(glyf []byte, loca []int, gid, depth int) (contours [][]ttfPoint) {
+	if depth > 8 || loca[gid] == loca[gid+1] {
+		return nil
+	}
+	g := glyf[loca[gid]:loca[gid+1]]
+	u16 := func(off int) int { return int(binary.BigEndian.Uint16(g[off:])) }
+	n := int(int16(u16(0)))
+	if n < 0 {
+		// composite glyph
+		for off, flags := 10, 0x20; flags&0x20 != 0; {
+			flags = u16(off)
+			component := u16(off + 2)
+			off += 4
+			var dx, dy float64
+			if flags&1 != 0 {
+				dx, dy = float64(int16(u16(off))), float64(int16(u16(off+2)))
+				off += 4
+			} else {
+				dx, dy = float64(int8(g[off])), float64(int8(g[off+1]))
+				off += 2
+			}
+			a, b, c, d := 1.0, 0.0, 0.0, 1.0
+			f2dot14 := func(off int) float64 { return float64(int16(u16(off))) / 16384 }
+			switch {
+			case flags&0x08 != 0:
+				a, d = f2dot14(off), f2dot14(off)
+				off += 2
+			case flags&0x40 != 0:
+				a, d = f2dot14(off), f2dot14(off+2)
+				off += 4
+			case flags&0x80 != 0:
+				a, b, c, d = f2dot14(off), f2dot14(off+2), f2dot14(off+4), f2dot14(off+6)
+				off += 8
+			}
+			if flags&2 == 0 {
+				dx, dy = 0, 0
+			}
+			for _, contour := range ttfContours(glyf, loca, component, depth+1) {
+				for j, p := range contour {
+					contour[j] = ttfPoint{a*p.x + c*p.y + dx, b*p.x + d*p.y + dy, p.on}
+				}
+				contours = append(contours, contour)
+			}
+		}
+		return
+	}
+	ends := make([]int, n)
+	for j := range ends {
+		ends[j] = u16(10 + 2*j)
+	}
+	if n == 0 {
+		return nil
+	}
+	count := ends[n-1] + 1
+	off := 10 + 2*n
+	off += 2 + u16(off)
+	flags := make([]byte, 0, count)
+	for len(flags) < count {
+		fl := g[off]
+		off++
+		flags = append(flags, fl)
+		if fl&8 != 0 {
+			for k := int(g[off]); k > 0; k-- {
+				flags = append(flags, fl)
+			}
+			off++
+		}
+	}
+	coords := func(short, same byte) []float64 {
+		v, values := 0, make([]float64, count)
+		for j, fl := range flags {
+			switch {
+			case fl&short != 0:
+				if fl&same != 0 {
+					v += int(g[off])
+				} else {
+					v -= int(g[off])
+				}
+				off++
+			case fl&same == 0:
+				v += int(int16(u16(off)))
+				off += 2
+			}
+			values[j] = float64(v)
+		}
+		return values
+	}
+	xs := coords(2, 16)
+	ys := coords(4, 32)
+	start := 0
+	for _, end := range ends {
+		var contour []ttfPoint
+		for j := start; j <= end; j++ {
+			contour = append(contour, ttfPoint{xs[j], ys[j], flags[j]&1 != 0})
+		}
+		contours = append(contours, contour)
+		start = end + 1
+	}
+	return
+}
+
+// type2Num returns the Type 2 charstring encoding of the integer v.
+func type2Num(v int) []byte {
+	switch {
+	case v >= -107 && v <= 107:
+		return []byte{byte(v + 139)}
+	case v >= 108 && v <= 1131:
+		v -= 108
+		return []byte{byte(v>>8 + 247), byte(v)}
+	case v >= -1131 && v <= -108:
+		v = -v - 108
+		return []byte{byte(v>>8 + 251), byte(v)}
+	}
+	return []byte{28, byte(v >> 8), byte(v)}
+}
+
+// type2Charstring returns a Type 2 charstring drawing the contours. The
+// first moveto calls the local subroutine 0 and the glyph is ended by the
+// global subroutine 0.
+func type2Charstring(contours [][]ttfPoint) []byte {
+	var cs []byte
+	cx, cy := 0, 0
+	first := true
+	rel := func(pts ...ttfPoint) {
+		for _, p := range pts {
+			x, y := int(math.Round(p.x)), int(math.Round(p.y))
+			cs = append(append(cs, type2Num(x-cx)...), type2Num(y-cy)...)
+			cx, cy = x, y
+		}
+	}
+	mid := func(a, b ttfPoint) ttfPoint { return ttfPoint{(a.x + b.x) / 2, (a.y + b.y) / 2, true} }
+	for _, contour := range contours {
+		if len(contour) < 2 {
+			continue
+		}
+		k := 0
+		for k < len(contour) && !contour[k].on {
+			k++
+		}
+		var pts []ttfPoint
+		if k == len(contour) {
+			pts = append([]ttfPoint{mid(contour[0], contour[1])}, contour[1:]...)
+			pts = append(pts, contour[0])
+		} else {
+			pts = append(append([]ttfPoint{}, contour[k:]...), contour[:k]...)
+		}
+		pts = append(pts, pts[0])
+		rel(pts[0])
+		if first {
+			cs = append(append(cs, type2Num(-107)...), 10)
+			first = false
+		} else {
+			cs = append(cs, 21)
+		}
+		cur := pts[0]
+		for j := 1; j < len(pts); j++ {
+			p := pts[j]
+			if p.on {
+				rel(p)
+				cs = append(cs, 5)
+				cur = p
+				continue
+			}
+			next := pts[j+1]
+			if !next.on {
+				next = mid(p, next)
+			} else {
+				j++
+			}
+			rel(ttfPoint{cur.x + 2*(p.x-cur.x)/3, cur.y + 2*(p.y-cur.y)/3, false},
+				ttfPoint{next.x + 2*(p.x-next.x)/3, next.y + 2*(p.y-next.y)/3, false}, next)
+			cs = append(cs, 8)
+			cur = next
+		}
+	}
+	if first {
+		return []byte{14}
+	}
+	return append(append(cs, type2Num(-107)...), 29)
+}
+
+// cffTestIndex returns the encoding of a CFF INDEX with four byte offsets.
+func cffTestIndex(items ...[]byte) []byte {
+	buf := []byte{byte(len(items) >> 8), byte(len(items)), 4}
+	off := 1
+	buf = binary.BigEndian.AppendUint32(buf, uint32(off))
+	for _, item := range items {
+		off += len(item)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(off))
+	}
+	return append(buf, bytes.Join(items, nil)...)
+}
+
+// cffTestDict returns the encoding of CFF DICT operators, whose integer
+// operands are written with five bytes and string operands as reals.
+func cffTestDict(entries ...interface{}) (buf []byte) {
+	for _, e := range entries {
+		switch v := e.(type) {
+		case int:
+			buf = append(buf, 29)
+			buf = binary.BigEndian.AppendUint32(buf, uint32(v))
+		case string:
+			nibbles := []byte{}
+			for _, c := range v {
+				switch c {
+				case '.':
+					nibbles = append(nibbles, 0xa)
+				case '-':
+					nibbles = append(nibbles, 0xe)
+				default:
+					nibbles = append(nibbles, byte(c-'0'))
+				}
+			}
+			nibbles = append(nibbles, 0xf)
+			if len(nibbles)%2 != 0 {
+				nibbles = append(nibbles, 0xf)
+			}
+			buf = append(buf, 30)
+			for j := 0; j < len(nibbles); j += 2 {
+				buf = append(buf, nibbles[j]<<4|nibbles[j+1])
+			}
+		case []byte:
+			buf = append(buf, v...)
+		}
+	}
+	return
+}
+
+// otfFromTrueType returns an OpenType font with PostScript outlines with the
+// glyphs, metrics and layout tables of the TrueType font ttf. The font is
+// CID-keyed, with two font DICTs split at glyph 100, if cidKeyed is true.
+func otfFromTrueType(ttf []byte, cidKeyed bool) []byte {
+	tables := sfntTables(ttf, 0)
+	head := tables["head"]
+	upem := float64(binary.BigEndian.Uint16(head[18:]))
+	numGlyphs := int(binary.BigEndian.Uint16(tables["maxp"][4:]))
+	loca := make([]int, numGlyphs+1)
+	for j := range loca {
+		if binary.BigEndian.Uint16(head[50:]) == 0 {
+			loca[j] = 2 * int(binary.BigEndian.Uint16(tables["loca"][2*j:]))
+		} else {
+			loca[j] = int(binary.BigEndian.Uint32(tables["loca"][4*j:]))
+		}
+	}
+	charStrings := make([][]byte, numGlyphs)
+	for gid := range charStrings {
+		charStrings[gid] = type2Charstring(ttfContours(tables["glyf"], loca, gid, 0))
+	}
+
+	scale := fmt.Sprintf("%g", 1/upem)
+	bbox := make([]interface{}, 4)
+	for j := range bbox {
+		bbox[j] = int(int16(binary.BigEndian.Uint16(head[36+2*j:])))
+	}
+	private := cffTestDict(0, []byte{19}) // Subrs, with the offset patched below
+	binary.BigEndian.PutUint32(private[1:], uint32(len(private)))
+	private = append(private, cffTestIndex([]byte{21, 11})...) // rmoveto return
+	privateSize := len(private) - len(cffTestIndex([]byte{21, 11}))
+
+	var strs [][]byte
+	var charset, fdSelect []byte
+	if cidKeyed {
+		strs = [][]byte{[]byte("Adobe"), []byte("Identity")}
+		charset = []byte{2, 0, 1, byte((numGlyphs - 2) >> 8), byte(numGlyphs - 2)}
+		fdSelect = []byte{3, 0, 2, 0, 0, 0, 0, 100, 1,
+			byte(numGlyphs >> 8), byte(numGlyphs)}
+	} else {
+		for gid := 1; gid < numGlyphs; gid++ {
+			strs = append(strs, []byte(fmt.Sprintf("g%d", gid)))
+		}
+		charset = []byte{2, 1, 0x87, byte((numGlyphs - 2) >> 8), byte(numGlyphs - 2)}
+	}
+	topDict := func(offsets ...int) []byte {
+		top := cffTestDict(scale, 0, 0, scale, 0, 0, []byte{12, 7})
+		top = append(top, cffTestDict(bbox[0], bbox[1], bbox[2], bbox[3], []byte{5})...)
+		top = append(top, cffTestDict(offsets[0], []byte{15}, offsets[1], []byte{17})...)
+		if cidKeyed {
+			top = append(cffTestDict(391, 392, 0, []byte{12, 30}), top...)
+			return append(top, cffTestDict(numGlyphs, []byte{12, 34}, offsets[2], []byte{12, 36},
+				offsets[3], []byte{12, 37})...)
+		}
+		return append(top, cffTestDict(privateSize, offsets[2], []byte{18})...)
+	}
+	start := 4 + len(cffTestIndex([]byte("TestCFF"))) + len(cffTestIndex(topDict(0, 0, 0, 0))) +
+		len(cffTestIndex(strs...)) + len(cffTestIndex([]byte{14}))
+	charStringsOff := start + len(charset)
+	fdSelectOff := charStringsOff + len(cffTestIndex(charStrings...))
+	fdArrayOff := fdSelectOff + len(fdSelect)
+	privateOff := fdArrayOff
+	var fdArray []byte
+	if cidKeyed {
+		fd := cffTestDict(privateSize, 0, []byte{18})
+		privateOff += len(cffTestIndex(fd, fd))
+		fd = cffTestDict(privateSize, privateOff, []byte{18})
+		fdArray = cffTestIndex(fd, fd)
+	}
+	var top []byte
+	if cidKeyed {
+		top = topDict(start, charStringsOff, fdArrayOff, fdSelectOff)
+	} else {
+		top = topDict(start, charStringsOff, privateOff)
+	}
+	cff := []byte{1, 0, 4, 4}
+	cff = append(cff, cffTestIndex([]byte("TestCFF"))...)
+	cff = append(cff, cffTestIndex(top)...)
+	cff = append(cff, cffTestIndex(strs...)...)
+	cff = append(cff, cffTestIndex([]byte{14})...) // endchar
+	cff = append(cff, charset...)
+	cff = append(cff, cffTestIndex(charStrings...)...)
+	cff = append(cff, fdSelect...)
+	cff = append(cff, fdArray...)
+	cff = append(cff, private...)
+
+	otf := map[string][]byte{"CFF ": cff}
+	for _, tag := range []string{"cmap", "head", "hhea", "hmtx", "name", "OS/2", "GSUB", "GPOS", "GDEF", "kern"} {
+		if table, ok := tables[tag]; ok {
+			otf[tag] = table
+		}
+	}
+	otf["maxp"] = append([]byte{0, 0, 0x50, 0}, tables["maxp"][4:6]...)
+	otf["post"] = append([]byte{0, 3, 0, 0}, tables["post"][4:32]...)
+	return sfntFonts("OTTO", otf)
+}
+
+func Test_AddUTF8FontCFF(t *testing.T) {
+	ttf, err := os.ReadFile(FontFile("DejaVuSansCondensed.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bold, err := os.ReadFile(FontFile("DejaVuSansCondensed-Bold.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otf := otfFromTrueType(ttf, false)
+	ttc := sfntFonts("\x00\x01\x00\x00", sfntTables(ttf, 0), sfntTables(bold, 0))
+	otc := sfntFonts("OTTO", sfntTables(otfFromTrueType(ttf, true), 0), sfntTables(otfFromTrueType(bold, true), 0))
+
+	pdf := NewDocPdfTest()
+	pdf.AddUTF8Font("dejavu", "", FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddUTF8Font("dejavu", "B", FontFile("DejaVuSansCondensed-Bold.ttf"))
+	pdf.AddUTF8FontFromBytes("cff", "", otf)
+	pdf.AddUTF8FontFaceFromBytes("ttc", "", ttc, 1)
+	pdf.AddUTF8FontFaceFromBytes("cid", "", otc, 0)
+	pdf.AddUTF8FontFaceFromBytes("cid", "B", otc, 1)
+	if pdf.Err() {
+		t.Fatal(pdf.Error())
+	}
+	pdf.AddPage()
+	const txt = "Überprüfung: ΑΒΓ Привет, Ǻ office"
+	for _, fonts := range [][3]string{{"dejavu", "", "cff"}, {"dejavu", "B", "ttc"}, {"dejavu", "", "cid"}, {"dejavu", "B", "cid"}} {
+		pdf.SetFont(fonts[0], fonts[1], 16)
+		want := pdf.GetStringWidth(txt)
+		style := fonts[1]
+		if fonts[2] == "ttc" {
+			style = ""
+		}
+		pdf.SetFont(fonts[2], style, 16)
+		if got := pdf.GetStringWidth(txt); got != want {
+			t.Errorf("%s %s: width %.2f, want %.2f", fonts[2], style, got, want)
+		}
+		pdf.Cell(0, 10, txt)
+		pdf.Ln(10)
+	}
+	pdf.SetFont("cff", "", 16)
+	pdf.SetTextShaping(true)
+	pdf.Cell(0, 10, "Shaped: office, ffi, é")
+	pdf.Ln(10)
+	pdf.SetTextShaping(false)
+	pdf.SetKerning(true)
+	pdf.MultiCell(0, 8, "Kerned: AVATAR Type WAVE. "+lorem(), "", "J", false)
+	var buf bytes.Buffer
+	err = pdf.Output(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if n := strings.Count(out, "/Subtype /CIDFontType0C"); n != 3 {
+		t.Errorf("%d CFF font programs embedded, want 3", n)
+	}
+	if len(out) > len(otf)/4 {
+		t.Errorf("document size %d, the CFF font programs are not subset", len(out))
+	}
+
+	pdf = NewDocPdfTest()
+	pdf.AddUTF8FontFaceFromBytes("ttc", "", ttc, 2)
+	if !pdf.Err() || !strings.Contains(pdf.Error().Error(), "no face 2") {
+		t.Errorf("missing face of a collection not reported")
+	}
+	pdf = NewDocPdfTest()
+	pdf.AddUTF8FontFace("dejavu", "", FontFile("DejaVuSansCondensed.ttf"), 1)
+	if !pdf.Err() {
+		t.Errorf("face index of a single font not reported")
+	}
+
+	fileStr := Filename("Test_AddUTF8FontCFF")
+	err = os.WriteFile(fileStr, buf.Bytes(), 0644)
+	SummaryCompare(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Test_AddUTF8FontCFF.pdf
+}
@@ -0,0 +1,762 @@
+package docpdf
+
+import (
+	"math"
+)
+
+// ChartSeriesType is a named series of values drawn by the methods of
+// ChartType.
+type ChartSeriesType struct {
+	// Name identifies the series in the legend
+	Name string
+	// Values holds one value per category. For scatter charts it holds the Y
+	// coordinates of the points and for histograms the observations.
+	Values []float64
+	// X holds the X coordinates of the points of scatter and line charts. If
+	// it is empty, the values of a line chart are plotted against the
+	// categories and those of a scatter chart against their position.
+	X []float64
+}
+
+// ChartDataType is the data model of a chart.
+type ChartDataType struct {
+	// Title is printed centered above the chart
+	Title string
+	// XTitle and YTitle are printed along the horizontal and vertical axes
+	XTitle, YTitle string
+	// Categories label the groups of bar, line and area charts and the slices
+	// of pie and donut charts
+	Categories []string
+	// Series holds the data. Pie and donut charts use only the first series.
+	Series []ChartSeriesType
+}
+
+// ChartType assists with the drawing of bar, line, area, scatter, pie, donut
+// and histogram charts. Axes are drawn with GridType, scaled with tickmarks
+// chosen by Tickmarks(). A chart occupies a rectangle of the page that holds
+// the title, the legend and the axis labels as well as the plot itself.
+type ChartType struct {
+	// Chart rectangle in page units
+	x, y, w, h float64
+	// Colors assigned in turn to series, or to the slices of pie charts
+	Palette []RGBType
+	// Legend shows the series names, or pie slice categories, below the chart
+	Legend bool
+	// ValueLabels prints the value of each bar, point or slice
+	ValueLabels bool
+	// ValueStr formats value labels. If nil, values are printed with the
+	// precision of the vertical axis and pie slices as a percentage.
+	ValueStr TickFormatFncType
+	// Markers draws a dot at each point of line charts
+	Markers bool
+	// Radius of markers and scatter points, and width of lines, in points
+	MarkerSize, LineWd float64
+	// Fraction of each category taken up by its bars
+	BarRatio float64
+	// Radius of the hole of donut charts relative to the outer radius
+	HoleRatio float64
+	// Number of histogram bins; 0 selects a number based on the count of
+	// observations
+	Bins int
+	// Label height in points
+	TextSize float64
+	// Title and label color
+	ClrText RGBType
+	// GridFnc, if not nil, is called with the grid of bar, line, area,
+	// scatter and histogram charts after its tickmarks have been set and
+	// before it is drawn. It may be used to change colors and formatters.
+	GridFnc func(grid *GridType)
+}
+
+// chartPalette holds the default series colors.
+var chartPalette = []RGBType{
+	{78, 121, 167}, {242, 142, 43}, {225, 87, 89}, {118, 183, 178}, {89, 161, 79},
+	{237, 201, 72}, {176, 122, 161}, {255, 157, 167}, {156, 117, 95}, {186, 176, 172},
+}
+
+// NewChart returns a variable of type ChartType that draws charts in a
+// rectangle of width w and height h with the upper left corner positioned at
+// point (x, y). The coordinates are in page units, that is, the same as those
+// specified in New(). The returned chart shows a legend, uses a palette of ten
+// colors and may be customized by changing its fields before one of its
+// drawing methods is called.
+func NewChart(x, y, w, h float64) (chart ChartType) {
+	chart.x = x
+	chart.y = y
+	chart.w = w
+	chart.h = h
+	chart.Palette = chartPalette
+	chart.Legend = true
+	chart.MarkerSize = 1.5
+	chart.LineWd = 1
+	chart.BarRatio = 0.7
+	chart.HoleRatio = 0.5
+	chart.TextSize = 7 // Points
+	return
+}
+
+// chartState holds the page state needed while drawing a chart.
+type chartState struct {
+	st            StateType
+	autoBreak     bool
+	bMargin       float64
+	x, y          float64
+	textSz, space float64
+}
+
+func (c ChartType) begin(pdf *DocPDF) (cs chartState) {
+	cs.st = StateGet(pdf)
+	cs.autoBreak, cs.bMargin = pdf.GetAutoPageBreak()
+	cs.x, cs.y = pdf.GetXY()
+	cs.textSz = pdf.PointToUnitConvert(c.TextSize)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetFontUnitSize(cs.textSz)
+	pdf.SetCellMargin(0)
+	pdf.SetTextColor(c.ClrText.R, c.ClrText.G, c.ClrText.B)
+	cs.space = pdf.GetStringWidth("0")
+	return
+}
+
+func (c ChartType) end(pdf *DocPDF, cs chartState) {
+	cs.st.Put(pdf)
+	pdf.SetAutoPageBreak(cs.autoBreak, cs.bMargin)
+	pdf.SetXY(cs.x, cs.y)
+}
+
+func (c ChartType) color(j int) RGBType {
+	if len(c.Palette) == 0 {
+		return chartPalette[j%len(chartPalette)]
+	}
+	return c.Palette[j%len(c.Palette)]
+}
+
+// text prints str in the current font with its top at y. The position x is
+// the left edge, center or right edge of the text depending on alignStr,
+// which is "L", "C" or "R".
+func (c ChartType) text(pdf *DocPDF, str string, x, y float64, alignStr string) {
+	wd := pdf.GetStringWidth(str)
+	switch alignStr {
+	case "C":
+		x -= wd / 2
+	case "R":
+		x -= wd
+	}
+	_, ht := pdf.GetFontSize()
+	pdf.SetXY(x, y)
+	pdf.CellFormat(wd, ht, str, "", 0, "L", false, 0, "")
+}
+
+// frame draws the title and the legend of a chart and returns the rectangle
+// that remains for the plot and its axis labels.
+func (c ChartType) frame(pdf *DocPDF, cs chartState, title string, names []string) (x, y, w, h float64) {
+	x, y, w, h = c.x, c.y, c.w, c.h
+	if title != "" {
+		pdf.SetFontUnitSize(1.3 * cs.textSz)
+		c.text(pdf, title, x+w/2, y, "C")
+		pdf.SetFontUnitSize(cs.textSz)
+		y += 2 * cs.textSz
+		h -= 2 * cs.textSz
+	}
+	if !c.Legend || len(names) == 0 {
+		return
+	}
+	// Legend entries are laid out in as many centered lines as needed
+	swatch := cs.textSz
+	gap := 2 * cs.space
+	var lines [][]int
+	var lineWds []float64
+	var wd float64
+	for j, name := range names {
+		entryWd := swatch + cs.space + pdf.GetStringWidth(name)
+		if len(lines) == 0 || wd+gap+entryWd > w {
+			lines = append(lines, nil)
+			lineWds = append(lineWds, 0)
+			wd = -gap
+		}
+		wd += gap + entryWd
+		lines[len(lines)-1] = append(lines[len(lines)-1], j)
+		lineWds[len(lines)-1] = wd
+	}
+	lineHt := 1.5 * cs.textSz
+	h -= float64(len(lines)) * lineHt
+	top := y + h + 0.5*cs.textSz
+	for k, line := range lines {
+		lf := x + (w-lineWds[k])/2
+		for _, j := range line {
+			clr := c.color(j)
+			pdf.SetFillColor(clr.R, clr.G, clr.B)
+			pdf.Rect(lf, top, swatch, swatch, "F")
+			lf += swatch + cs.space
+			c.text(pdf, names[j], lf, top, "L")
+			lf += pdf.GetStringWidth(names[j]) + gap
+		}
+		top += lineHt
+	}
+	return
+}
+
+// axes lays out the axis titles and labels of a chart, draws its grid and
+// returns the grid for plotting. setX establishes the horizontal tickmarks.
+// If categories is not empty, the horizontal axis shows one labeled slot for
+// each category instead of numeric tickmarks. ok is false, and the error state
+// of pdf is set, if the values are too large or too close to each other to be
+// scaled to an axis.
+func (c ChartType) axes(pdf *DocPDF, cs chartState, data ChartDataType, names []string,
+	categories []string, setX func(gr *GridType), yMin, yMax float64) (gr GridType, ok bool) {
+	x, y, w, h := c.frame(pdf, cs, data.Title, names)
+	if yMax <= yMin {
+		yMax = yMin + 1
+	}
+	if c.ValueLabels {
+		// Leave room for the labels of the outermost values
+		pad := (yMax - yMin) * 0.08
+		yMax += pad
+		if yMin < 0 {
+			yMin -= pad
+		}
+	}
+	gr = NewGrid(x, y, w, h)
+	gr.TextSize = c.TextSize
+	gr.ClrText = RGBAType{R: c.ClrText.R, G: c.ClrText.G, B: c.ClrText.B, Alpha: 1}
+	gr.ClrMain = RGBAType{R: 192, G: 192, B: 192, Alpha: 1}
+	gr.ClrSub = RGBAType{R: 224, G: 224, B: 224, Alpha: 1}
+	gr.XDiv = 1
+	gr.YDiv = 1
+	setX(&gr)
+	gr.TickmarksContainY(yMin, yMax)
+	if len(gr.xTicks) < 2 || len(gr.yTicks) < 2 {
+		pdf.SetErrorf("chart %q: values cannot be scaled to an axis", data.Title)
+		return
+	}
+	if len(categories) > 0 {
+		gr.XTickStr = nil
+	}
+	if c.GridFnc != nil {
+		c.GridFnc(&gr)
+	}
+	// Reserve room for the axis titles and tickmark labels
+	bt := y + h
+	if data.XTitle != "" {
+		bt -= 1.5 * cs.textSz
+	}
+	if gr.XTickStr != nil || len(categories) > 0 {
+		bt -= cs.textSz + cs.space
+	}
+	lf := x + cs.space
+	if data.YTitle != "" {
+		lf += 1.5 * cs.textSz
+	}
+	if gr.YTickStr != nil {
+		var labelWd float64
+		for _, v := range gr.yTicks {
+			labelWd = math.Max(labelWd, pdf.GetStringWidth(gr.YTickStr(v, gr.yPrecision)))
+		}
+		lf += labelWd + cs.space
+	}
+	rt := x + w - cs.space
+	if gr.XTickStr != nil {
+		last := gr.xTicks[len(gr.xTicks)-1]
+		rt -= pdf.GetStringWidth(gr.XTickStr(last, gr.xPrecision)) / 2
+	}
+	top := y + cs.textSz/2
+	gr.place(lf, top, rt-lf, bt-top)
+	gr.Grid(pdf)
+	pdf.SetFontUnitSize(cs.textSz)
+	pdf.SetTextColor(c.ClrText.R, c.ClrText.G, c.ClrText.B)
+	for j, cat := range categories {
+		c.text(pdf, cat, gr.X(float64(j)+0.5), bt+cs.space, "C")
+	}
+	if data.XTitle != "" {
+		c.text(pdf, data.XTitle, lf+(rt-lf)/2, y+h-1.2*cs.textSz, "C")
+	}
+	if data.YTitle != "" {
+		cx, cy := x+0.6*cs.textSz, top+(bt-top)/2
+		pdf.TransformBegin()
+		pdf.TransformRotate(90, cx, cy)
+		c.text(pdf, data.YTitle, cx, cy-cs.textSz/2, "C")
+		pdf.TransformEnd()
+	}
+	ok = true
+	return
+}
+
+// valueStr formats a value label.
+func (c ChartType) valueStr(gr GridType, v float64) string {
+	if c.ValueStr != nil {
+		return c.ValueStr(v, gr.yPrecision)
+	}
+	return defaultFormatter(v, gr.yPrecision)
+}
+
+// seriesNames returns the names of the series of data.
+func seriesNames(data ChartDataType) (names []string) {
+	for _, s := range data.Series {
+		names = append(names, s.Name)
+	}
+	return
+}
+
+// categoryCount returns the number of categories of data, which is at least
+// the length of its longest series.
+func categoryCount(data ChartDataType) (n int) {
+	n = len(data.Categories)
+	for _, s := range data.Series {
+		if len(s.Values) > n {
+			n = len(s.Values)
+		}
+	}
+	return
+}
+
+// valueRange returns the smallest and largest value of data, including zero.
+func valueRange(data ChartDataType) (lo, hi float64) {
+	for _, s := range data.Series {
+		for _, v := range s.Values {
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+	}
+	return
+}
+
+// chartDataValid reports whether the values and X coordinates of data are
+// all finite. Otherwise the error state of pdf is set, since neither
+// tickmarks nor coordinates can be derived from NaN or infinite values.
+func chartDataValid(pdf *DocPDF, data ChartDataType) bool {
+	if pdf.Err() {
+		return false
+	}
+	for _, s := range data.Series {
+		for _, list := range [][]float64{s.Values, s.X} {
+			for j, v := range list {
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					pdf.SetErrorf("chart series %q: value %d is not a finite number", s.Name, j)
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// clampY returns v limited to the vertical range of the grid.
+func clampY(gr GridType, v float64) float64 {
+	lo, hi := gr.YRange()
+	return math.Max(lo, math.Min(hi, v))
+}
+
+// Bar draws a bar chart with the bars of the series grouped side by side
+// within each category.
+func (c ChartType) Bar(pdf *DocPDF, data ChartDataType) {
+	c.bar(pdf, data, false)
+}
+
+// StackedBar draws a bar chart with the values of the series stacked on top
+// of each other within each category. Negative values are stacked downward.
+func (c ChartType) StackedBar(pdf *DocPDF, data ChartDataType) {
+	c.bar(pdf, data, true)
+}
+
+func (c ChartType) bar(pdf *DocPDF, data ChartDataType, stacked bool) {
+	n := categoryCount(data)
+	if n == 0 || len(data.Series) == 0 || !chartDataValid(pdf, data) {
+		return
+	}
+	lo, hi := valueRange(data)
+	if stacked {
+		lo, hi = 0, 0
+		for j := 0; j < n; j++ {
+			var pos, neg float64
+			for _, s := range data.Series {
+				if j < len(s.Values) {
+					if s.Values[j] > 0 {
+						pos += s.Values[j]
+					} else {
+						neg += s.Values[j]
+					}
+				}
+			}
+			lo = math.Min(lo, neg)
+			hi = math.Max(hi, pos)
+		}
+	}
+	cs := c.begin(pdf)
+	gr, ok := c.axes(pdf, cs, data, seriesNames(data), chartCategories(data, n),
+		func(gr *GridType) { gr.TickmarksExtentX(0, 1, n) }, lo, hi)
+	if !ok {
+		c.end(pdf, cs)
+		return
+	}
+	base := clampY(gr, 0)
+	barWd := c.BarRatio
+	if !stacked {
+		barWd /= float64(len(data.Series))
+	}
+	for j := 0; j < n; j++ {
+		var pos, neg float64
+		for k, s := range data.Series {
+			if j >= len(s.Values) {
+				continue
+			}
+			v := s.Values[j]
+			from := base
+			if stacked {
+				if v > 0 {
+					from = pos
+					pos += v
+				} else {
+					from = neg
+					neg += v
+				}
+			}
+			x := float64(j) + (1-c.BarRatio)/2
+			if !stacked {
+				x += float64(k) * barWd
+			}
+			y0, y1 := gr.Y(from), gr.Y(clampY(gr, from+v))
+			clr := c.color(k)
+			pdf.SetFillColor(clr.R, clr.G, clr.B)
+			pdf.Rect(gr.X(x), math.Min(y0, y1), gr.Wd(barWd), math.Abs(y1-y0), "F")
+			if c.ValueLabels {
+				str := c.valueStr(gr, v)
+				cx := gr.X(x + barWd/2)
+				switch {
+				case stacked:
+					c.text(pdf, str, cx, (y0+y1-cs.textSz)/2, "C")
+				case v < 0:
+					c.text(pdf, str, cx, y1+cs.space/2, "C")
+				default:
+					c.text(pdf, str, cx, y1-cs.textSz-cs.space/2, "C")
+				}
+			}
+		}
+	}
+	c.end(pdf, cs)
+}
+
+// chartCategories returns the n category labels of data, padded with empty
+// strings.
+func chartCategories(data ChartDataType, n int) (cats []string) {
+	cats = make([]string, n)
+	copy(cats, data.Categories)
+	return
+}
+
+// Line draws a line chart with one line for each series. Series with X
+// coordinates are plotted against a numeric horizontal axis, others against
+// the categories.
+func (c ChartType) Line(pdf *DocPDF, data ChartDataType) {
+	c.points(pdf, data, true)
+}
+
+// Scatter draws a scatter chart with the points of each series shown as dots.
+func (c ChartType) Scatter(pdf *DocPDF, data ChartDataType) {
+	c.points(pdf, data, false)
+}
+
+func (c ChartType) points(pdf *DocPDF, data ChartDataType, lines bool) {
+	if len(data.Series) == 0 || !chartDataValid(pdf, data) {
+		return
+	}
+	// Series are plotted against categories unless X coordinates are given
+	// or this is a scatter chart
+	numeric := !lines
+	for _, s := range data.Series {
+		if len(s.X) > 0 {
+			numeric = true
+		}
+	}
+	xOf := func(s ChartSeriesType, j int) float64 {
+		if numeric {
+			if j < len(s.X) {
+				return s.X[j]
+			}
+			return float64(j + 1)
+		}
+		return float64(j) + 0.5
+	}
+	var setX func(gr *GridType)
+	var categories []string
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range data.Series {
+		for _, v := range s.Values {
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+	}
+	if math.IsInf(lo, 0) {
+		return
+	}
+	if numeric {
+		xLo, xHi := math.Inf(1), math.Inf(-1)
+		for _, s := range data.Series {
+			for j := range s.Values {
+				xLo = math.Min(xLo, xOf(s, j))
+				xHi = math.Max(xHi, xOf(s, j))
+			}
+		}
+		if xHi <= xLo {
+			xHi = xLo + 1
+		}
+		setX = func(gr *GridType) { gr.TickmarksContainX(xLo, xHi) }
+	} else {
+		n := categoryCount(data)
+		categories = chartCategories(data, n)
+		setX = func(gr *GridType) { gr.TickmarksExtentX(0, 1, n) }
+	}
+	cs := c.begin(pdf)
+	gr, ok := c.axes(pdf, cs, data, seriesNames(data), categories, setX, lo, hi)
+	if !ok {
+		c.end(pdf, cs)
+		return
+	}
+	radius := pdf.PointToUnitConvert(c.MarkerSize)
+	pdf.SetLineWidth(pdf.PointToUnitConvert(c.LineWd))
+	pdf.SetLineJoinStyle("round")
+	for k, s := range data.Series {
+		clr := c.color(k)
+		pdf.SetDrawColor(clr.R, clr.G, clr.B)
+		pdf.SetFillColor(clr.R, clr.G, clr.B)
+		if lines && len(s.Values) > 1 {
+			for j, v := range s.Values {
+				if j == 0 {
+					pdf.MoveTo(gr.XY(xOf(s, j), v))
+				} else {
+					pdf.LineTo(gr.XY(xOf(s, j), v))
+				}
+			}
+			pdf.DrawPath("D")
+		}
+		for j, v := range s.Values {
+			x, y := gr.XY(xOf(s, j), v)
+			if !lines || c.Markers {
+				pdf.Circle(x, y, radius, "F")
+			}
+			if c.ValueLabels {
+				c.text(pdf, c.valueStr(gr, v), x, y-radius-cs.textSz-cs.space/2, "C")
+			}
+		}
+	}
+	pdf.SetLineJoinStyle("miter")
+	c.end(pdf, cs)
+}
+
+// Area draws an area chart with the areas of the series stacked on top of each
+// other.
+func (c ChartType) Area(pdf *DocPDF, data ChartDataType) {
+	n := categoryCount(data)
+	if n == 0 || len(data.Series) == 0 || !chartDataValid(pdf, data) {
+		return
+	}
+	// Cumulative sums of the series
+	sums := make([][]float64, len(data.Series)+1)
+	sums[0] = make([]float64, n)
+	lo, hi := 0.0, 0.0
+	for k, s := range data.Series {
+		sums[k+1] = make([]float64, n)
+		for j := 0; j < n; j++ {
+			sums[k+1][j] = sums[k][j]
+			if j < len(s.Values) {
+				sums[k+1][j] += s.Values[j]
+			}
+			lo = math.Min(lo, sums[k+1][j])
+			hi = math.Max(hi, sums[k+1][j])
+		}
+	}
+	cs := c.begin(pdf)
+	gr, ok := c.axes(pdf, cs, data, seriesNames(data), chartCategories(data, n),
+		func(gr *GridType) { gr.TickmarksExtentX(0, 1, n) }, lo, hi)
+	if !ok {
+		c.end(pdf, cs)
+		return
+	}
+	pdf.SetLineWidth(pdf.PointToUnitConvert(c.LineWd))
+	for k := range data.Series {
+		clr := c.color(k)
+		pdf.SetFillColor(clr.R, clr.G, clr.B)
+		var pts []PointType
+		for j := 0; j < n; j++ {
+			x, y := gr.XY(float64(j)+0.5, sums[k+1][j])
+			pts = append(pts, PointType{x, y})
+		}
+		for j := n - 1; j >= 0; j-- {
+			x, y := gr.XY(float64(j)+0.5, sums[k][j])
+			pts = append(pts, PointType{x, y})
+		}
+		pdf.Polygon(pts, "F")
+		if c.ValueLabels {
+			s := data.Series[k]
+			for j := 0; j < n && j < len(s.Values); j++ {
+				y := gr.Y((sums[k][j] + sums[k+1][j]) / 2)
+				c.text(pdf, c.valueStr(gr, s.Values[j]), gr.X(float64(j)+0.5), y-cs.textSz/2, "C")
+			}
+		}
+	}
+	c.end(pdf, cs)
+}
+
+// Histogram draws the distribution of the values of each series as bars
+// counting the values that fall into bins of equal width. The range of the
+// bins is chosen by Tickmarks().
+func (c ChartType) Histogram(pdf *DocPDF, data ChartDataType) {
+	if !chartDataValid(pdf, data) {
+		return
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	count := 0
+	for _, s := range data.Series {
+		for _, v := range s.Values {
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+			count++
+		}
+	}
+	if count == 0 {
+		return
+	}
+	if hi <= lo {
+		hi = lo + 1
+	}
+	bins := c.Bins
+	if bins <= 0 {
+		// Sturges' rule
+		bins = int(math.Ceil(math.Log2(float64(count)))) + 1
+	}
+	ticks, _ := Tickmarks(lo, hi)
+	if len(ticks) < 2 || math.IsInf(ticks[len(ticks)-1]-ticks[0], 0) {
+		pdf.SetErrorf("chart %q: values cannot be divided into bins", data.Title)
+		return
+	}
+	lo, hi = ticks[0], ticks[len(ticks)-1]
+	binWd := (hi - lo) / float64(bins)
+	counts := make([][]float64, len(data.Series))
+	var top float64
+	for k, s := range data.Series {
+		counts[k] = make([]float64, bins)
+		for _, v := range s.Values {
+			j := min(int((v-lo)/binWd), bins-1)
+			counts[k][j]++
+			top = math.Max(top, counts[k][j])
+		}
+	}
+	cs := c.begin(pdf)
+	gr, ok := c.axes(pdf, cs, data, seriesNames(data), nil,
+		func(gr *GridType) { gr.TickmarksExtentX(lo, binWd, bins) }, 0, top)
+	if !ok {
+		c.end(pdf, cs)
+		return
+	}
+	barWd := binWd / float64(len(data.Series))
+	pdf.SetDrawColor(255, 255, 255)
+	pdf.SetLineWidth(pdf.PointToUnitConvert(0.5))
+	for k := range data.Series {
+		clr := c.color(k)
+		pdf.SetFillColor(clr.R, clr.G, clr.B)
+		for j, n := range counts[k] {
+			if n == 0 {
+				continue
+			}
+			x := lo + float64(j)*binWd + float64(k)*barWd
+			y := gr.Y(clampY(gr, n))
+			pdf.Rect(gr.X(x), y, gr.Wd(barWd), gr.Y(0)-y, "FD")
+			if c.ValueLabels {
+				var str string
+				if c.ValueStr != nil {
+					str = c.ValueStr(n, 0)
+				} else {
+					str = defaultFormatter(n, 0)
+				}
+				c.text(pdf, str, gr.X(x+barWd/2), y-cs.textSz-cs.space/2, "C")
+			}
+		}
+	}
+	c.end(pdf, cs)
+}
+
+// Pie draws a pie chart of the values of the first series, with one slice for
+// each category.
+func (c ChartType) Pie(pdf *DocPDF, data ChartDataType) {
+	c.pie(pdf, data, 0)
+}
+
+// Donut draws a pie chart with a hole in the middle, the size of which is
+// given by HoleRatio.
+func (c ChartType) Donut(pdf *DocPDF, data ChartDataType) {
+	c.pie(pdf, data, math.Max(0, math.Min(c.HoleRatio, 0.95)))
+}
+
+func (c ChartType) pie(pdf *DocPDF, data ChartDataType, hole float64) {
+	if len(data.Series) == 0 || !chartDataValid(pdf, data) {
+		return
+	}
+	values := data.Series[0].Values
+	var total float64
+	for _, v := range values {
+		if v > 0 {
+			total += v
+		}
+	}
+	if total == 0 {
+		return
+	}
+	cs := c.begin(pdf)
+	x, y, w, h := c.frame(pdf, cs, data.Title, chartCategories(data, len(values)))
+	r := math.Min(w, h)/2 - cs.textSz/2
+	if r <= 0 {
+		c.end(pdf, cs)
+		return
+	}
+	cx, cy := x+w/2, y+h/2
+	pdf.SetDrawColor(255, 255, 255)
+	pdf.SetLineWidth(pdf.PointToUnitConvert(0.5))
+	// Slices run clockwise from twelve o'clock
+	angle := 90.0
+	for j, v := range values {
+		if v <= 0 {
+			continue
+		}
+		sweep := 360 * v / total
+		clr := c.color(j)
+		pdf.SetFillColor(clr.R, clr.G, clr.B)
+		if hole > 0 {
+			sin, cos := math.Sincos(angle * math.Pi / 180)
+			pdf.MoveTo(cx+r*cos, cy-r*sin)
+			chartArc(pdf, cx, cy, r, angle, angle-sweep)
+			chartArc(pdf, cx, cy, r*hole, angle-sweep, angle)
+		} else {
+			pdf.MoveTo(cx, cy)
+			chartArc(pdf, cx, cy, r, angle, angle-sweep)
+		}
+		pdf.ClosePath()
+		pdf.DrawPath("FD")
+		if c.ValueLabels {
+			var str string
+			if c.ValueStr != nil {
+				str = c.ValueStr(v, 0)
+			} else {
+				str = defaultFormatter(100*v/total, 0) + "%"
+			}
+			mid := (angle - sweep/2) * math.Pi / 180
+			lr := r * 0.65
+			if hole > 0 {
+				lr = r * (1 + hole) / 2
+			}
+			sin, cos := math.Sincos(mid)
+			c.text(pdf, str, cx+lr*cos, cy-lr*sin-cs.textSz/2, "C")
+		}
+		angle -= sweep
+	}
+	c.end(pdf, cs)
+}
+
+// chartArc adds a circular arc from angle a0 to a1, in degrees measured
+// counter-clockwise from three o'clock, to the current path. The arc is
+// split into short pieces so that it is drawn accurately in either direction.
+func chartArc(pdf *DocPDF, cx, cy, r, a0, a1 float64) {
+	steps := int(math.Ceil(math.Abs(a1-a0) / 45))
+	for j := 0; j < steps; j++ {
+		from := a0 + (a1-a0)*float64(j)/float64(steps)
+		to := a0 + (a1-a0)*float64(j+1)/float64(steps)
+		pdf.ArcTo(cx, cy, r, r, 0, from, to)
+	}
+}
@@ -0,0 +1,434 @@
+package docpdf
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"math"
+	"strings"
+)
+
+// SetDrawColorCMYK defines the color used for all drawing operations (lines,
+// rectangles and cell borders) with cyan, magenta, yellow and black ink
+// components. The components specify percentages ranging from 0 to 100.
+// Values above this are quietly capped to 100. The method can be called
+// before the first page is created. The value is retained from page to page.
+func (f *DocPDF) SetDrawColorCMYK(c, m, y, k byte) {
+	f.color.draw = f.cmykColorValue(c, m, y, k, true)
+	if f.page > 0 {
+		f.out(f.color.draw.str)
+	}
+}
+
+// SetFillColorCMYK defines the color used for all filling operations (filled
+// rectangles and cell backgrounds) with cyan, magenta, yellow and black ink
+// components. See SetDrawColorCMYK() for the range of the components.
+//
+// The SetDrawColorCMYK() example demonstrates this method.
+func (f *DocPDF) SetFillColorCMYK(c, m, y, k byte) {
+	f.color.fill = f.cmykColorValue(c, m, y, k, false)
+	f.colorFlag = f.color.fill.str != f.color.text.str
+	if f.page > 0 {
+		f.out(f.color.fill.str)
+	}
+}
+
+// SetTextColorCMYK defines the color used for text with cyan, magenta, yellow
+// and black ink components. See SetDrawColorCMYK() for the range of the
+// components.
+//
+// The SetDrawColorCMYK() example demonstrates this method.
+func (f *DocPDF) SetTextColorCMYK(c, m, y, k byte) {
+	f.color.text = f.cmykColorValue(c, m, y, k, false)
+	f.colorFlag = f.color.fill.str != f.color.text.str
+}
+
+// GetDrawColorCMYK returns the most recently set draw color as CMYK
+// components (0 - 100). This will not be the current value if a draw color of
+// some other type (for example, RGB) has been more recently set.
+func (f *DocPDF) GetDrawColorCMYK() (c, m, y, k byte) {
+	clr := f.color.draw.cmyk
+	return clr.c, clr.m, clr.y, clr.k
+}
+
+// GetFillColorCMYK returns the most recently set fill color as CMYK
+// components (0 - 100). This will not be the current value if a fill color of
+// some other type (for example, RGB) has been more recently set.
+func (f *DocPDF) GetFillColorCMYK() (c, m, y, k byte) {
+	clr := f.color.fill.cmyk
+	return clr.c, clr.m, clr.y, clr.k
+}
+
+// GetTextColorCMYK returns the most recently set text color as CMYK
+// components (0 - 100). This will not be the current value if a text color of
+// some other type (for example, RGB) has been more recently set.
+func (f *DocPDF) GetTextColorCMYK() (c, m, y, k byte) {
+	clr := f.color.text.cmyk
+	return clr.c, clr.m, clr.y, clr.k
+}
+
+// SetDrawColorGray defines the color used for all drawing operations (lines,
+// rectangles and cell borders) as a level of gray ranging from 0 (black) to
+// 255 (white). The color is painted in the gray color space, and
+// GetDrawColor() returns the level as each of its components.
+func (f *DocPDF) SetDrawColorGray(level int) {
+	f.color.draw = f.grayColorValue(level, true)
+	if f.page > 0 {
+		f.out(f.color.draw.str)
+	}
+}
+
+// SetFillColorGray defines the color used for all filling operations (filled
+// rectangles and cell backgrounds) as a level of gray ranging from 0 (black)
+// to 255 (white).
+//
+// The SetDrawColorGray() example demonstrates this method.
+func (f *DocPDF) SetFillColorGray(level int) {
+	f.color.fill = f.grayColorValue(level, false)
+	f.colorFlag = f.color.fill.str != f.color.text.str
+	if f.page > 0 {
+		f.out(f.color.fill.str)
+	}
+}
+
+// SetTextColorGray defines the color used for text as a level of gray ranging
+// from 0 (black) to 255 (white).
+//
+// The SetDrawColorGray() example demonstrates this method.
+func (f *DocPDF) SetTextColorGray(level int) {
+	f.color.text = f.grayColorValue(level, false)
+	f.colorFlag = f.color.fill.str != f.color.text.str
+}
+
+// SetCMYKConversion specifies whether the colors specified with red, green
+// and blue components are converted to cyan, magenta, yellow and black ink
+// components, for output devices that do not accept RGB colors. When enabled,
+// the colors set afterwards with SetDrawColor(), SetFillColor() and
+// SetTextColor(), and the gradients drawn afterwards, are converted. The RGB
+// images of the document, including the colors of the palettes of indexed
+// images, are converted when the document is output if the conversion is
+// enabled at that time; the images compressed with JPEG are then stored
+// without loss, which increases their size.
+//
+// The conversion is a simple complement of the RGB components with the black
+// component extracted. Use SetDrawColorCMYK() and the related methods to
+// control the inks directly.
+func (f *DocPDF) SetCMYKConversion(enabled bool) {
+	f.cmykConversion = enabled
+}
+
+// SetICCBasedColors specifies whether colors are painted in the color space
+// defined by the ICC profile of the first output intent added with
+// AddOutputIntent(), rather than in a device color space. When enabled, the
+// colors set afterwards whose number of components matches the profile
+// (three for an RGB profile, four for a CMYK profile and one for a gray
+// profile) are specified in the ICCBased color space of the profile. The
+// gradients and the RGB and CMYK images of that number of components are
+// specified in it when the document is output if the option is enabled at
+// that time.
+//
+// The SetCMYKConversion() option makes the RGB colors match a CMYK profile.
+func (f *DocPDF) SetICCBasedColors(enabled bool) {
+	f.iccColors = enabled
+}
+
+// iccProfileComps returns the number of color components of the ICC profile
+func iccProfileComps(profile []byte) int {
+	if len(profile) >= 20 {
+		switch string(profile[16:20]) {
+		case "GRAY":
+			return 1
+		case "CMYK":
+			return 4
+		}
+	}
+	return 3
+}
+
+// iccComps returns the number of components of the colors specified in the
+// ICCBased color space of the output intent, or zero if colors are specified
+// in device color spaces
+func (f *DocPDF) iccComps() int {
+	if !f.iccColors || len(f.outputIntents) == 0 {
+		return 0
+	}
+	return iccProfileComps(f.outputIntents[0].ICCProfile)
+}
+
+// iccColorSpace returns the ICCBased color space of the output intent
+func (f *DocPDF) iccColorSpace() string {
+	return sprintf("[/ICCBased %d 0 R]", f.outputIntentStartN)
+}
+
+// compsColorStr returns the color operation that sets the stroking or
+// nonstroking color to the components comps, ranging from 0 to 1. The color
+// space is selected by the number of components.
+func (f *DocPDF) compsColorStr(comps []float64, stroke bool) string {
+	var s strings.Builder
+	icc := f.iccComps() == len(comps)
+	if icc {
+		s.WriteString(strIf(stroke, "/ICC CS ", "/ICC cs "))
+	}
+	for j, v := range comps {
+		if j > 0 {
+			s.WriteByte(' ')
+		}
+		s.WriteString(f.fmtF64(v, 3))
+	}
+	var opStr string
+	switch {
+	case icc:
+		opStr = " scn"
+	case len(comps) == 1:
+		opStr = " g"
+	case len(comps) == 4:
+		opStr = " k"
+	default:
+		opStr = " rg"
+	}
+	if stroke {
+		opStr = strings.ToUpper(opStr)
+	}
+	s.WriteString(opStr)
+	return s.String()
+}
+
+// strokeColorStr returns the stroking version of the nonstroking color
+// operation str
+func strokeColorStr(str string) string {
+	fields := strings.Fields(str)
+	for j, s := range fields {
+		switch s {
+		case "g", "rg", "k", "cs", "scn":
+			fields[j] = strings.ToUpper(s)
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// rgbToCMYK converts the RGB components, ranging from 0 to 1, to CMYK
+// components
+func rgbToCMYK(r, g, b float64) (c, m, y, k float64) {
+	k = 1 - math.Max(r, math.Max(g, b))
+	if k < 1 {
+		c = (1 - r - k) / (1 - k)
+		m = (1 - g - k) / (1 - k)
+		y = (1 - b - k) / (1 - k)
+	}
+	return
+}
+
+func (f *DocPDF) cmykColorValue(c, m, y, k byte, stroke bool) (clr colorType) {
+	clr.cmyk = cmykColorType{c: byteBound(c), m: byteBound(m), y: byteBound(y), k: byteBound(k)}
+	comps := []float64{float64(clr.cmyk.c) / 100, float64(clr.cmyk.m) / 100,
+		float64(clr.cmyk.y) / 100, float64(clr.cmyk.k) / 100}
+	// Approximate RGB value returned by GetDrawColor() and the like
+	clr.ir, clr.r = colorComp(int(math.Round(255 * (1 - comps[0]) * (1 - comps[3]))))
+	clr.ig, clr.g = colorComp(int(math.Round(255 * (1 - comps[1]) * (1 - comps[3]))))
+	clr.ib, clr.b = colorComp(int(math.Round(255 * (1 - comps[2]) * (1 - comps[3]))))
+	clr.mode = colorModeCMYK
+	clr.str = f.compsColorStr(comps, stroke)
+	return
+}
+
+func (f *DocPDF) grayColorValue(level int, stroke bool) (clr colorType) {
+	clr.ir, clr.r = colorComp(level)
+	clr.ig, clr.g, clr.ib, clr.b = clr.ir, clr.r, clr.ir, clr.r
+	clr.mode = colorModeGray
+	clr.gray = true
+	clr.str = f.compsColorStr([]float64{clr.r}, stroke)
+	return
+}
+
+// rgbColorStr returns the color string of the RGB components when they are
+// converted to CMYK or specified in an ICCBased color space, or false
+// otherwise
+func (f *DocPDF) rgbColorStr(clr colorType, operator, stroke bool) (str string, ok bool) {
+	var comps []float64
+	switch {
+	case f.cmykConversion:
+		c, m, y, k := rgbToCMYK(clr.r, clr.g, clr.b)
+		comps = []float64{c, m, y, k}
+	case operator && f.iccComps() == 3:
+		comps = []float64{clr.r, clr.g, clr.b}
+	default:
+		return
+	}
+	if operator {
+		return f.compsColorStr(comps, stroke), true
+	}
+	strs := make([]string, len(comps))
+	for j, v := range comps {
+		strs[j] = f.fmtF64(v, 3)
+	}
+	return strings.Join(strs, " "), true
+}
+
+// gradientColor returns clr converted to CMYK if RGB colors are converted
+func (f *DocPDF) gradientColor(clr GradientColorType) GradientColorType {
+	if f.cmykConversion && clr.spotStr == "" && len(clr.comps) == 3 {
+		c, m, y, k := rgbToCMYK(clr.comps[0], clr.comps[1], clr.comps[2])
+		clr.comps = []float64{c, m, y, k}
+	}
+	return clr
+}
+
+// rgbComps returns the number of components of the RGB colors of gradients
+func (f *DocPDF) rgbComps() int {
+	if f.cmykConversion {
+		return 4
+	}
+	return 3
+}
+
+// imageColorSpace returns the color space of an image of the device color
+// space cs
+func (f *DocPDF) imageColorSpace(cs string) string {
+	n := 0
+	switch cs {
+	case "DeviceRGB":
+		n = 3
+	case "DeviceCMYK":
+		n = 4
+	}
+	if n > 0 && f.iccComps() == n {
+		return f.iccColorSpace()
+	}
+	return "/" + cs
+}
+
+// imageCMYK returns a copy of the RGB image info with its colors converted to
+// CMYK. The pixels of the copy are compressed with FlateDecode.
+func (f *DocPDF) imageCMYK(info *ImageInfoType) (img ImageInfoType, err error) {
+	img = *info
+	if info.cs == "Indexed" {
+		img.pal = make([]byte, 0, len(info.pal)/3*4)
+		for j := 0; j+2 < len(info.pal); j += 3 {
+			img.pal = append(img.pal, cmykBytes(info.pal[j], info.pal[j+1], info.pal[j+2])...)
+		}
+		return
+	}
+	w, h := int(info.w), int(info.h)
+	var pix []byte // 8 bits per component
+	switch info.f {
+	case "DCTDecode":
+		src, err := jpeg.Decode(bytes.NewReader(info.data))
+		if err != nil {
+			return img, err
+		}
+		bounds := src.Bounds()
+		pix = make([]byte, 0, 3*w*h)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, b, _ := src.At(x, y).RGBA()
+				pix = append(pix, byte(r>>8), byte(g>>8), byte(b>>8))
+			}
+		}
+	case "FlateDecode":
+		mem, err := xmem.uncompress(info.data)
+		if err != nil {
+			return img, err
+		}
+		pix = mem.copy()
+		mem.release()
+		if strings.Contains(info.dp, "/Predictor") {
+			pix, err = pngUnfilter(pix, 3*info.bpc/8, 3*info.bpc/8*w, h)
+			if err != nil {
+				return img, err
+			}
+		}
+	case "":
+		pix = info.data
+	default:
+		return img, fmt.Errorf("unable to convert image with filter %s to CMYK", info.f)
+	}
+	if info.bpc == 16 {
+		// Keep the most significant byte of the components
+		for j := 0; 2*j < len(pix); j++ {
+			pix[j] = pix[2*j]
+		}
+		pix = pix[:len(pix)/2]
+	}
+	if len(pix) < 3*w*h {
+		return img, fmt.Errorf("image data is too short to be converted to CMYK")
+	}
+	data := make([]byte, 0, 4*w*h)
+	for j := 0; j < 3*w*h; j += 3 {
+		data = append(data, cmykBytes(pix[j], pix[j+1], pix[j+2])...)
+	}
+	mem := xmem.compress(data)
+	img.data = mem.copy()
+	mem.release()
+	img.cs = "DeviceCMYK"
+	img.f = "FlateDecode"
+	img.dp = ""
+	img.bpc = 8
+	if len(info.trns) == 3 {
+		key := cmykBytes(byte(info.trns[0]), byte(info.trns[1]), byte(info.trns[2]))
+		img.trns = []int{int(key[0]), int(key[1]), int(key[2]), int(key[3])}
+	}
+	return
+}
+
+// cmykBytes converts the RGB components to CMYK components, all ranging from
+// 0 to 255
+func cmykBytes(r, g, b byte) []byte {
+	c, m, y, k := rgbToCMYK(float64(r)/255, float64(g)/255, float64(b)/255)
+	return []byte{byte(math.Round(c * 255)), byte(math.Round(m * 255)),
+		byte(math.Round(y * 255)), byte(math.Round(k * 255))}
+}
+
+// pngUnfilter reverses the PNG filters of the rows of data, of rowLen bytes
+// and bpp bytes per pixel
+func pngUnfilter(data []byte, bpp, rowLen, rows int) ([]byte, error) {
+	if len(data) < rows*(rowLen+1) {
+		return nil, fmt.Errorf("PNG image data is too short")
+	}
+	out := make([]byte, rows*rowLen)
+	prev := make([]byte, rowLen)
+	for j := 0; j < rows; j++ {
+		filter := data[j*(rowLen+1)]
+		src := data[j*(rowLen+1)+1 : (j+1)*(rowLen+1)]
+		cur := out[j*rowLen : (j+1)*rowLen]
+		for x := range cur {
+			var a, c int
+			if x >= bpp {
+				a, c = int(cur[x-bpp]), int(prev[x-bpp])
+			}
+			b := int(prev[x])
+			switch filter {
+			case 0:
+				cur[x] = src[x]
+			case 1:
+				cur[x] = src[x] + byte(a)
+			case 2:
+				cur[x] = src[x] + byte(b)
+			case 3:
+				cur[x] = src[x] + byte((a+b)/2)
+			case 4:
+				// Paeth predictor
+				p := a + b - c
+				pa, pb, pc := absInt(p-a), absInt(p-b), absInt(p-c)
+				switch {
+				case pa <= pb && pa <= pc:
+					cur[x] = src[x] + byte(a)
+				case pb <= pc:
+					cur[x] = src[x] + byte(b)
+				default:
+					cur[x] = src[x] + byte(c)
+				}
+			default:
+				return nil, fmt.Errorf("unknown PNG filter type %d", filter)
+			}
+		}
+		prev = cur
+	}
+	return out, nil
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
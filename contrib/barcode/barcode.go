@@ -0,0 +1,164 @@
+// Package barcode draws one- and two-dimensional barcodes as vector graphics.
+//
+// Symbols are encoded into a module matrix by one of the New* functions and
+// then rendered on the page with Draw, which paints each run of dark modules
+// with a filled rectangle in the current fill color. No image rasterisation is
+// involved, so the symbols stay sharp at any zoom level and print resolution.
+//
+// Supported symbologies are Code 128 (subsets A, B and C with automatic
+// switching), Code 39, EAN-13, EAN-8, UPC-A, Interleaved 2 of 5, QR Code,
+// DataMatrix (ECC 200) and PDF417.
+//
+// Quiet zones are not painted; leave enough blank space around the symbol
+// when positioning it on the page.
+package barcode
+
+import (
+	"strings"
+)
+
+// barcodePdf is a partial interface that only implements the functions we need
+// from the PDF generator to draw barcodes.
+type barcodePdf interface {
+	CellFormat(w, h float64, txtStr, borderStr string, ln int, alignStr string, fill bool, link int, linkStr string)
+	GetFontSize() (ptSize, unitSize float64)
+	GetXY() (float64, float64)
+	Rect(x, y, w, h float64, styleStr string)
+	SetXY(x, y float64)
+}
+
+// Code is an encoded barcode symbol. It is a matrix of modules, each of which
+// is either dark or light. One-dimensional symbols have a single row.
+type Code struct {
+	// Kind names the symbology, for example "Code 128" or "QR Code".
+	Kind string
+	// Text is the human-readable interpretation printed as caption.
+	Text    string
+	cols    int
+	rows    int
+	modules []bool
+}
+
+func newCode(kind, text string, cols, rows int) *Code {
+	return &Code{Kind: kind, Text: text, cols: cols, rows: rows, modules: make([]bool, cols*rows)}
+}
+
+// Size returns the number of module columns and rows of the symbol.
+func (c *Code) Size() (cols, rows int) {
+	return c.cols, c.rows
+}
+
+// Dark reports whether the module at column x and row y is dark. Modules
+// outside of the symbol are light.
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.cols || y >= c.rows {
+		return false
+	}
+	return c.modules[y*c.cols+x]
+}
+
+// Is2D reports whether the symbol is a two-dimensional matrix or stacked code.
+func (c *Code) Is2D() bool {
+	return c.rows > 1
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y*c.cols+x] = dark
+}
+
+// appendWidths appends alternating dark and light runs of the given module
+// widths to a one-dimensional code, starting with a dark run, and returns the
+// new position.
+func (c *Code) appendWidths(pos int, widths string) int {
+	dark := true
+	for _, r := range widths {
+		n := int(r - '0')
+		for j := 0; j < n; j++ {
+			c.modules[pos] = dark
+			pos++
+		}
+		dark = !dark
+	}
+	return pos
+}
+
+// appendBits appends the modules described by a string of '1' (dark) and '0'
+// (light) characters to a one-dimensional code and returns the new position.
+func (c *Code) appendBits(pos int, bits string) int {
+	for _, r := range bits {
+		c.modules[pos] = r == '1'
+		pos++
+	}
+	return pos
+}
+
+// Draw renders the barcode with its upper left corner at (x, y), in user
+// units, scaled to fill width w and height h. The bars are painted with the
+// current fill color. If caption is true, the human-readable text of the code
+// is printed centered below the symbol using the current font, and the height
+// of the symbol is reduced to make room for it. Nothing is drawn if code is
+// nil.
+func Draw(pdf barcodePdf, code *Code, x, y, w, h float64, caption bool) {
+	if code == nil || code.cols == 0 || code.rows == 0 {
+		return
+	}
+	var captionHt float64
+	if caption && code.Text != "" {
+		_, captionHt = pdf.GetFontSize()
+		captionHt *= 1.2
+		if captionHt > h {
+			captionHt = h
+		}
+	}
+	barHt := h - captionHt
+	mw := w / float64(code.cols)
+	mh := barHt / float64(code.rows)
+	for row := 0; row < code.rows; row++ {
+		// Vertically adjacent rows with the same pattern are merged so that
+		// linear and stacked codes are drawn with as few rectangles as possible
+		span := 1
+		for row+span < code.rows && code.sameRow(row, row+span) {
+			span++
+		}
+		for col := 0; col < code.cols; {
+			if !code.Dark(col, row) {
+				col++
+				continue
+			}
+			start := col
+			for col < code.cols && code.Dark(col, row) {
+				col++
+			}
+			pdf.Rect(x+float64(start)*mw, y+float64(row)*mh, float64(col-start)*mw, float64(span)*mh, "F")
+		}
+		row += span - 1
+	}
+	if captionHt > 0 {
+		saveX, saveY := pdf.GetXY()
+		pdf.SetXY(x, y+barHt)
+		pdf.CellFormat(w, captionHt, captionText(code.Text), "", 0, "CM", false, 0, "")
+		pdf.SetXY(saveX, saveY)
+	}
+}
+
+func (c *Code) sameRow(a, b int) bool {
+	ra := c.modules[a*c.cols : (a+1)*c.cols]
+	rb := c.modules[b*c.cols : (b+1)*c.cols]
+	for j := range ra {
+		if ra[j] != rb[j] {
+			return false
+		}
+	}
+	return true
+}
+
+// captionText replaces control characters, which cannot be printed, with
+// spaces.
+func captionText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 32 || r == 127 {
+			return ' '
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,173 @@
+package barcode
+
+import (
+	"fmt"
+)
+
+// code128Widths holds the bar and space widths, in modules, of the 106 Code
+// 128 symbols followed by the stop pattern.
+var code128Widths = [107]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312",
+	"132212", "221213", "221312", "231212", "112232", "122132", "122231", "113222",
+	"123122", "123221", "223211", "221132", "221231", "213212", "223112", "312131",
+	"311222", "321122", "321221", "312212", "322112", "322211", "212123", "212321",
+	"232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121",
+	"313121", "211331", "231131", "213113", "213311", "213131", "311123", "311321",
+	"331121", "312113", "312311", "332111", "314111", "221411", "431111", "111224",
+	"111422", "121124", "121421", "141122", "141221", "112214", "112412", "122114",
+	"122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112",
+	"421211", "212141", "214121", "412121", "111143", "111341", "131141", "114113",
+	"114311", "411113", "411311", "113141", "114131", "311141", "411131", "211412",
+	"211214", "211232", "2331112",
+}
+
+const (
+	code128Shift  = 98
+	code128CodeC  = 99
+	code128CodeB  = 100
+	code128CodeA  = 101
+	code128StartA = 103
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = 106
+)
+
+// code128Set identifies one of the three Code 128 character sets.
+type code128Set int
+
+const (
+	code128A code128Set = iota
+	code128B
+	code128C
+)
+
+// NewCode128 encodes content, which may contain any ASCII character, as a Code
+// 128 symbol. The encoder switches automatically between the character sets
+// A (upper case and control characters), B (upper and lower case) and C
+// (pairs of digits) to produce a short symbol.
+func NewCode128(content string) (code *Code, err error) {
+	if content == "" {
+		return nil, fmt.Errorf("barcode: Code 128 content is empty")
+	}
+	for _, r := range content {
+		if r > 127 {
+			return nil, fmt.Errorf("barcode: Code 128 cannot encode character %q", r)
+		}
+	}
+	symbols := code128Symbols(content)
+	sum := symbols[0]
+	for j := 1; j < len(symbols); j++ {
+		sum += j * symbols[j]
+	}
+	symbols = append(symbols, sum%103, code128Stop)
+	code = newCode("Code 128", content, 11*len(symbols)+2, 1)
+	pos := 0
+	for _, s := range symbols {
+		pos = code.appendWidths(pos, code128Widths[s])
+	}
+	return
+}
+
+// code128Symbols returns the symbol values, starting with the start symbol
+// but without check symbol and stop pattern, that encode s.
+func code128Symbols(s string) (symbols []int) {
+	var set code128Set
+	n := digitRun(s, 0)
+	if n >= 4 || (n == 2 && len(s) == 2) {
+		set = code128C
+		symbols = append(symbols, code128StartC)
+	} else {
+		set = code128ChooseAB(s, 0)
+		symbols = append(symbols, code128StartA+int(set))
+	}
+	for j := 0; j < len(s); {
+		if set == code128C {
+			if digitRun(s, j) >= 2 {
+				symbols = append(symbols, int(s[j]-'0')*10+int(s[j+1]-'0'))
+				j += 2
+				continue
+			}
+			set = code128ChooseAB(s, j)
+			if set == code128A {
+				symbols = append(symbols, code128CodeA)
+			} else {
+				symbols = append(symbols, code128CodeB)
+			}
+			continue
+		}
+		// Long runs of digits are cheaper in set C. An odd leading digit is
+		// encoded in the current set first.
+		n = digitRun(s, j)
+		if n >= 6 || (n >= 4 && j+n == len(s)) {
+			if n%2 == 1 {
+				symbols = append(symbols, code128Value(set, s[j]))
+				j++
+			}
+			symbols = append(symbols, code128CodeC)
+			set = code128C
+			continue
+		}
+		ch := s[j]
+		if code128InSet(set, ch) {
+			symbols = append(symbols, code128Value(set, ch))
+			j++
+			continue
+		}
+		other := code128A
+		if set == code128A {
+			other = code128B
+		}
+		if j+1 < len(s) && code128InSet(set, s[j+1]) {
+			// A single character of the other set is shifted
+			symbols = append(symbols, code128Shift, code128Value(other, ch))
+			j++
+			continue
+		}
+		if other == code128A {
+			symbols = append(symbols, code128CodeA)
+		} else {
+			symbols = append(symbols, code128CodeB)
+		}
+		set = other
+	}
+	return
+}
+
+// code128ChooseAB returns set A if a control character appears in s, starting
+// at position j, before the first lower case character, and set B otherwise.
+func code128ChooseAB(s string, j int) code128Set {
+	for ; j < len(s); j++ {
+		if s[j] < 32 {
+			return code128A
+		}
+		if s[j] >= 96 {
+			return code128B
+		}
+	}
+	return code128B
+}
+
+func code128InSet(set code128Set, ch byte) bool {
+	if set == code128A {
+		return ch < 96
+	}
+	return ch >= 32
+}
+
+func code128Value(set code128Set, ch byte) int {
+	if set == code128A && ch < 32 {
+		return int(ch) + 64
+	}
+	return int(ch) - 32
+}
+
+// digitRun returns the number of consecutive decimal digits in s starting at
+// position j.
+func digitRun(s string, j int) (n int) {
+	for j+n < len(s) && s[j+n] >= '0' && s[j+n] <= '9' {
+		n++
+	}
+	return
+}
@@ -0,0 +1,108 @@
+package barcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+const code39Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ-. $/+%"
+
+// code39Wide flags the wide elements, bars and spaces alternating, of the 43
+// Code 39 characters followed by the start/stop character '*'.
+var code39Wide = [44]string{
+	"000110100", "100100001", "001100001", "101100000", "000110001", "100110000",
+	"001110000", "000100101", "100100100", "001100100", "100001001", "001001001",
+	"101001000", "000011001", "100011000", "001011000", "000001101", "100001100",
+	"001001100", "000011100", "100000011", "001000011", "101000010", "000010011",
+	"100010010", "001010010", "000000111", "100000110", "001000110", "000010110",
+	"110000001", "011000001", "111000000", "010010001", "110010000", "011010000",
+	"010000101", "110000100", "011000100", "010101000", "010100010", "010001010",
+	"000101010", "010010100",
+}
+
+// NewCode39 encodes content as a Code 39 symbol. Without fullASCII, content
+// may contain upper case letters, digits, space and the characters "-.$/+%".
+// With fullASCII, any ASCII character is accepted and characters outside of
+// this set are encoded as pairs. If checksum is true, a modulo 43 check
+// character is appended.
+func NewCode39(content string, checksum, fullASCII bool) (code *Code, err error) {
+	if content == "" {
+		return nil, fmt.Errorf("barcode: Code 39 content is empty")
+	}
+	data := content
+	if fullASCII {
+		var sb strings.Builder
+		for _, r := range content {
+			if r > 127 {
+				return nil, fmt.Errorf("barcode: Code 39 cannot encode character %q", r)
+			}
+			sb.WriteString(code39Extended(byte(r)))
+		}
+		data = sb.String()
+	}
+	values := make([]int, 0, len(data)+3)
+	values = append(values, 43)
+	sum := 0
+	for _, r := range data {
+		v := strings.IndexRune(code39Chars, r)
+		if v < 0 {
+			return nil, fmt.Errorf("barcode: Code 39 cannot encode character %q", r)
+		}
+		values = append(values, v)
+		sum += v
+	}
+	if checksum {
+		values = append(values, sum%43)
+	}
+	values = append(values, 43)
+	// Each character has six narrow and three wide elements, wide being
+	// three modules, and is followed by a narrow gap
+	code = newCode("Code 39", content, 16*len(values)-1, 1)
+	pos := 0
+	for j, v := range values {
+		if j > 0 {
+			pos++
+		}
+		for k, w := range code39Wide[v] {
+			n := 1
+			if w == '1' {
+				n = 3
+			}
+			for ; n > 0; n-- {
+				code.modules[pos] = k%2 == 0
+				pos++
+			}
+		}
+	}
+	return
+}
+
+// code39Extended returns the Code 39 character or pair of characters that
+// represent ch in Full ASCII mode.
+func code39Extended(ch byte) string {
+	switch {
+	case ch == 0:
+		return "%U"
+	case ch <= 26:
+		return "$" + string(rune('A'+ch-1))
+	case ch <= 31:
+		return "%" + string(rune('A'+ch-27))
+	case ch == ' ' || ch == '-' || ch == '.' || (ch >= '0' && ch <= '9') || (ch >= 'A' && ch <= 'Z'):
+		return string(rune(ch))
+	case ch <= 47:
+		return "/" + string(rune('A'+ch-33))
+	case ch == 58:
+		return "/Z"
+	case ch <= 63:
+		return "%" + string(rune('F'+ch-59))
+	case ch == 64:
+		return "%V"
+	case ch <= 95:
+		return "%" + string(rune('K'+ch-91))
+	case ch == 96:
+		return "%W"
+	case ch <= 122:
+		return "+" + string(rune('A'+ch-97))
+	}
+	return "%" + string(rune('P'+ch-123))
+}
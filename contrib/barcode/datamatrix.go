@@ -0,0 +1,219 @@
+package barcode
+
+import (
+	"fmt"
+)
+
+// dataMatrixSize describes a square ECC 200 symbol.
+type dataMatrixSize struct {
+	size    int // modules per side
+	regions int // data regions per side
+	ecc     int // error correction codewords
+	blocks  int // interleaved blocks
+}
+
+var dataMatrixSizes = []dataMatrixSize{
+	{10, 1, 5, 1}, {12, 1, 7, 1}, {14, 1, 10, 1}, {16, 1, 12, 1},
+	{18, 1, 14, 1}, {20, 1, 18, 1}, {22, 1, 20, 1}, {24, 1, 24, 1},
+	{26, 1, 28, 1}, {32, 2, 36, 1}, {36, 2, 42, 1}, {40, 2, 48, 1},
+	{44, 2, 56, 1}, {48, 2, 68, 1}, {52, 2, 84, 2}, {64, 4, 112, 2},
+	{72, 4, 144, 4}, {80, 4, 192, 4}, {88, 4, 224, 4}, {96, 4, 272, 4},
+	{104, 4, 336, 6}, {120, 6, 408, 6}, {132, 6, 496, 8}, {144, 6, 620, 10},
+}
+
+// mapping returns the number of modules per side of the data region area,
+// that is the symbol without finder and alignment patterns.
+func (s dataMatrixSize) mapping() int {
+	return s.size - 2*s.regions
+}
+
+func (s dataMatrixSize) dataCodewords() int {
+	n := s.mapping()
+	return n*n/8 - s.ecc
+}
+
+var dataMatrixField = newGaloisField(0x12d)
+
+// NewDataMatrix encodes content as a square ECC 200 DataMatrix symbol. The
+// smallest symbol that holds the content is selected automatically. Content
+// is encoded as ISO 8859-1 text, with pairs of digits packed into a single
+// codeword.
+func NewDataMatrix(content string) (code *Code, err error) {
+	var data []byte
+	data, err = dataMatrixEncode(content)
+	if err != nil {
+		return
+	}
+	var sz dataMatrixSize
+	for _, s := range dataMatrixSizes {
+		if s.dataCodewords() >= len(data) {
+			sz = s
+			break
+		}
+	}
+	if sz.size == 0 {
+		return nil, fmt.Errorf("barcode: content of %d codewords is too long for a DataMatrix", len(data))
+	}
+	// The first pad codeword is 129, the following ones are randomized by
+	// their position
+	if len(data) < sz.dataCodewords() {
+		data = append(data, 129)
+	}
+	for len(data) < sz.dataCodewords() {
+		pad := 129 + 149*(len(data)+1)%253 + 1
+		if pad > 254 {
+			pad -= 254
+		}
+		data = append(data, byte(pad))
+	}
+	code = newCode("DataMatrix", content, sz.size, sz.size)
+	dataMatrixLayout(code, sz, dataMatrixCodewords(data, sz))
+	return
+}
+
+// dataMatrixEncode returns the ASCII encodation of content.
+func dataMatrixEncode(content string) (data []byte, err error) {
+	runes := []rune(content)
+	for j := 0; j < len(runes); j++ {
+		r := runes[j]
+		switch {
+		case r >= '0' && r <= '9' && j+1 < len(runes) && runes[j+1] >= '0' && runes[j+1] <= '9':
+			data = append(data, byte(130+(r-'0')*10+(runes[j+1]-'0')))
+			j++
+		case r < 128:
+			data = append(data, byte(r+1))
+		case r < 256:
+			// Upper shift
+			data = append(data, 235, byte(r-127))
+		default:
+			return nil, fmt.Errorf("barcode: DataMatrix cannot encode character %q", r)
+		}
+	}
+	return
+}
+
+// dataMatrixCodewords distributes data over the interleaved blocks of the
+// symbol, appends the error correction codewords of each block and returns
+// the complete codeword sequence.
+func dataMatrixCodewords(data []byte, sz dataMatrixSize) []byte {
+	eccLen := sz.ecc / sz.blocks
+	out := make([]byte, len(data)+sz.ecc)
+	copy(out, data)
+	for b := 0; b < sz.blocks; b++ {
+		var block []byte
+		for j := b; j < len(data); j += sz.blocks {
+			block = append(block, data[j])
+		}
+		for j, e := range dataMatrixField.ecc(block, eccLen, 1) {
+			out[len(data)+b+j*sz.blocks] = e
+		}
+	}
+	return out
+}
+
+// dataMatrixLayout places the codewords in the utah shaped arrangement of the
+// ECC 200 specification and draws the finder and alignment patterns.
+func dataMatrixLayout(code *Code, sz dataMatrixSize, codewords []byte) {
+	n := sz.mapping()
+	region := n / sz.regions
+	// Each entry of grid is 0 for an unassigned module, or 8*codeword+bit+1
+	grid := make([]int, n*n)
+	module := func(row, col, chr, bit int) {
+		if row < 0 {
+			row += n
+			col += 4 - (n+4)%8
+		}
+		if col < 0 {
+			col += n
+			row += 4 - (n+4)%8
+		}
+		grid[row*n+col] = 8*chr + bit + 1
+	}
+	utah := func(row, col, chr int) {
+		module(row-2, col-2, chr, 0)
+		module(row-2, col-1, chr, 1)
+		module(row-1, col-2, chr, 2)
+		module(row-1, col-1, chr, 3)
+		module(row-1, col, chr, 4)
+		module(row, col-2, chr, 5)
+		module(row, col-1, chr, 6)
+		module(row, col, chr, 7)
+	}
+	corner := func(chr int, pos [8][2]int) {
+		for bit, p := range pos {
+			module(p[0], p[1], chr, bit)
+		}
+	}
+	chr, row, col := 0, 4, 0
+	for row < n || col < n {
+		if row == n && col == 0 {
+			corner(chr, [8][2]int{{n - 1, 0}, {n - 1, 1}, {n - 1, 2}, {0, n - 2}, {0, n - 1}, {1, n - 1}, {2, n - 1}, {3, n - 1}})
+			chr++
+		}
+		if row == n-2 && col == 0 && n%4 != 0 {
+			corner(chr, [8][2]int{{n - 3, 0}, {n - 2, 0}, {n - 1, 0}, {0, n - 4}, {0, n - 3}, {0, n - 2}, {0, n - 1}, {1, n - 1}})
+			chr++
+		}
+		if row == n-2 && col == 0 && n%8 == 4 {
+			corner(chr, [8][2]int{{n - 3, 0}, {n - 2, 0}, {n - 1, 0}, {0, n - 2}, {0, n - 1}, {1, n - 1}, {2, n - 1}, {3, n - 1}})
+			chr++
+		}
+		if row == n+4 && col == 2 && n%8 == 0 {
+			corner(chr, [8][2]int{{n - 1, 0}, {n - 1, n - 1}, {0, n - 3}, {0, n - 2}, {0, n - 1}, {1, n - 3}, {1, n - 2}, {1, n - 1}})
+			chr++
+		}
+		// Sweep up and to the right
+		for {
+			if row < n && col >= 0 && grid[row*n+col] == 0 {
+				utah(row, col, chr)
+				chr++
+			}
+			row -= 2
+			col += 2
+			if row < 0 || col >= n {
+				break
+			}
+		}
+		row++
+		col += 3
+		// Sweep down and to the left
+		for {
+			if row >= 0 && col < n && grid[row*n+col] == 0 {
+				utah(row, col, chr)
+				chr++
+			}
+			row += 2
+			col -= 2
+			if row >= n || col < 0 {
+				break
+			}
+		}
+		row += 3
+		col++
+	}
+	for r := 0; r < n; r++ {
+		for c := 0; c < n; c++ {
+			var dark bool
+			if v := grid[r*n+c]; v > 0 {
+				v--
+				dark = (codewords[v/8]>>uint(7-v%8))&1 == 1
+			} else {
+				// Only the lower right 2x2 corner can be left unfilled
+				dark = r == c
+			}
+			x := c/region*(region+2) + 1 + c%region
+			y := r/region*(region+2) + 1 + r%region
+			code.set(x, y, dark)
+		}
+	}
+	// Each data region is surrounded by a solid L shaped finder on the left
+	// and bottom edges and alternating modules on the top and right edges
+	for j := 0; j < sz.size; j++ {
+		for k := 0; k < sz.size; k += region + 2 {
+			code.set(k, j, true)
+			code.set(k+region+1, j, j%2 == 1)
+			code.set(j, k, j%2 == 0)
+			code.set(j, k+region+1, true)
+		}
+	}
+}
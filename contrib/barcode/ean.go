@@ -0,0 +1,128 @@
+package barcode
+
+import (
+	"fmt"
+)
+
+// eanL holds the left-hand, odd parity digit patterns. The even parity (G)
+// patterns are their mirror images and the right-hand (R) patterns their
+// complements.
+var eanL = [10]string{
+	"0001101", "0011001", "0010011", "0111101", "0100011",
+	"0110001", "0101111", "0111011", "0110111", "0001011",
+}
+
+// eanParity selects, for each leading EAN-13 digit, which of the six
+// left-hand digits use the even parity set.
+var eanParity = [10]string{
+	"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG",
+	"LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL",
+}
+
+// NewEAN13 encodes a 12 or 13 digit number as an EAN-13 symbol. With 12
+// digits the check digit is calculated and appended, with 13 digits it is
+// verified.
+func NewEAN13(content string) (code *Code, err error) {
+	var digits string
+	digits, err = eanDigits("EAN-13", content, 13)
+	if err != nil {
+		return
+	}
+	code = newCode("EAN-13", digits, 95, 1)
+	parity := eanParity[digits[0]-'0']
+	pos := code.appendBits(0, "101")
+	for j := 1; j <= 6; j++ {
+		pos = code.appendBits(pos, eanPattern(digits[j], parity[j-1]))
+	}
+	pos = code.appendBits(pos, "01010")
+	for j := 7; j <= 12; j++ {
+		pos = code.appendBits(pos, eanPattern(digits[j], 'R'))
+	}
+	code.appendBits(pos, "101")
+	return
+}
+
+// NewUPCA encodes an 11 or 12 digit number as a UPC-A symbol. With 11 digits
+// the check digit is calculated and appended, with 12 digits it is verified.
+func NewUPCA(content string) (code *Code, err error) {
+	var digits string
+	digits, err = eanDigits("UPC-A", content, 12)
+	if err != nil {
+		return
+	}
+	// UPC-A is the subset of EAN-13 with a leading zero
+	code, err = NewEAN13("0" + digits)
+	if err == nil {
+		code.Kind = "UPC-A"
+		code.Text = digits
+	}
+	return
+}
+
+// NewEAN8 encodes a 7 or 8 digit number as an EAN-8 symbol. With 7 digits the
+// check digit is calculated and appended, with 8 digits it is verified.
+func NewEAN8(content string) (code *Code, err error) {
+	var digits string
+	digits, err = eanDigits("EAN-8", content, 8)
+	if err != nil {
+		return
+	}
+	code = newCode("EAN-8", digits, 67, 1)
+	pos := code.appendBits(0, "101")
+	for j := 0; j < 4; j++ {
+		pos = code.appendBits(pos, eanPattern(digits[j], 'L'))
+	}
+	pos = code.appendBits(pos, "01010")
+	for j := 4; j < 8; j++ {
+		pos = code.appendBits(pos, eanPattern(digits[j], 'R'))
+	}
+	code.appendBits(pos, "101")
+	return
+}
+
+// eanDigits validates content as a number of size digits, or one less, in
+// which case the check digit is appended.
+func eanDigits(kind, content string, size int) (digits string, err error) {
+	if digitRun(content, 0) != len(content) || (len(content) != size && len(content) != size-1) {
+		err = fmt.Errorf("barcode: %s requires %d or %d digits, got %q", kind, size-1, size, content)
+		return
+	}
+	check := checkDigit(content[:size-1])
+	if len(content) == size {
+		if content[size-1] != check {
+			err = fmt.Errorf("barcode: invalid %s check digit in %q, expected %c", kind, content, check)
+		}
+		digits = content
+		return
+	}
+	digits = content + string(check)
+	return
+}
+
+// checkDigit returns the modulo 10 check digit, with weights alternating 3
+// and 1 from the rightmost digit, used by EAN, UPC and Interleaved 2 of 5.
+func checkDigit(digits string) byte {
+	sum := 0
+	weight := 3
+	for j := len(digits) - 1; j >= 0; j-- {
+		sum += int(digits[j]-'0') * weight
+		weight = 4 - weight
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func eanPattern(digit, set byte) string {
+	l := eanL[digit-'0']
+	buf := make([]byte, 7)
+	for j := 0; j < 7; j++ {
+		switch set {
+		case 'L':
+			buf[j] = l[j]
+		case 'G':
+			buf[j] = '0' + '1' - l[6-j]
+		default:
+			buf[j] = '0' + '1' - l[j]
+		}
+	}
+	return string(buf)
+}
@@ -0,0 +1,366 @@
+package barcode
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+const (
+	pdf417Text      = 900
+	pdf417Byte      = 901
+	pdf417Numeric   = 902
+	pdf417ByteShift = 913
+	pdf417Byte6     = 924
+	pdf417Start     = 0x1fea8
+	pdf417Stop      = 0x3fa29
+	// pdf417RowHeight is the height of a row in module widths
+	pdf417RowHeight = 3
+)
+
+// Text compaction sub-modes
+const (
+	pdf417Alpha = iota
+	pdf417Lower
+	pdf417Mixed
+	pdf417Punct
+)
+
+const (
+	pdf417MixedChars = "0123456789&\r\t,:#-.$/+%*=^"
+	pdf417PunctChars = ";<>@[\\]_`~!\r\t,:\n-.$/\"|*()?{}'"
+)
+
+// NewPDF417 encodes content as a PDF417 symbol. The securityLevel, 0 to 8,
+// selects the number of error correction codewords, 2 to 512; a negative
+// value selects the level recommended for the amount of data. The number of
+// data columns, 1 to 30, is given by columns; if columns is 0 it is chosen to
+// give the symbol a width of about twice its height.
+func NewPDF417(content string, securityLevel, columns int) (code *Code, err error) {
+	if securityLevel > 8 {
+		return nil, fmt.Errorf("barcode: invalid PDF417 security level %d", securityLevel)
+	}
+	if columns < 0 || columns > 30 {
+		return nil, fmt.Errorf("barcode: invalid number of PDF417 columns %d", columns)
+	}
+	data := pdf417Encode([]byte(content))
+	if securityLevel < 0 {
+		switch n := len(data); {
+		case n <= 40:
+			securityLevel = 2
+		case n <= 160:
+			securityLevel = 3
+		case n <= 320:
+			securityLevel = 4
+		default:
+			securityLevel = 5
+		}
+	}
+	eccLen := 2 << uint(securityLevel)
+	n := 1 + len(data) + eccLen
+	cols, rows := columns, 0
+	if cols > 0 {
+		rows = max(3, (n+cols-1)/cols)
+	} else {
+		best := math.Inf(1)
+		for c := 1; c <= 30; c++ {
+			r := max(3, (n+c-1)/c)
+			if r > 90 {
+				continue
+			}
+			ratio := float64(17*(c+4)+1) / float64(pdf417RowHeight*r)
+			if d := math.Abs(ratio - 2); d < best {
+				best, cols, rows = d, c, r
+			}
+		}
+	}
+	if cols == 0 || rows > 90 || rows*cols > 928 {
+		return nil, fmt.Errorf("barcode: content of %d codewords is too long for a PDF417", len(data))
+	}
+	// The symbol length descriptor counts itself, the data and the padding
+	for len(data)+1 < rows*cols-eccLen {
+		data = append(data, pdf417Text)
+	}
+	data = append([]int{len(data) + 1}, data...)
+	data = append(data, pdf417ECC(data, eccLen)...)
+	width := 17*(cols+4) + 1
+	code = newCode("PDF417", content, width, pdf417RowHeight*rows)
+	for r := 0; r < rows; r++ {
+		cluster := r % 3
+		base := 30 * (r / 3)
+		var left, right int
+		switch cluster {
+		case 0:
+			left = base + (rows-1)/3
+			right = base + cols - 1
+		case 1:
+			left = base + 3*securityLevel + (rows-1)%3
+			right = base + (rows-1)/3
+		default:
+			left = base + cols - 1
+			right = base + 3*securityLevel + (rows-1)%3
+		}
+		words := make([]int, 0, cols+4)
+		words = append(words, pdf417Start, pdf417Patterns[cluster][left])
+		for _, cw := range data[r*cols : (r+1)*cols] {
+			words = append(words, pdf417Patterns[cluster][cw])
+		}
+		words = append(words, pdf417Patterns[cluster][right], pdf417Stop)
+		pos := 0
+		for j, w := range words {
+			bits := 17
+			if j == len(words)-1 {
+				bits = 18
+			}
+			for k := bits - 1; k >= 0; k-- {
+				dark := (w>>uint(k))&1 == 1
+				for h := 0; h < pdf417RowHeight; h++ {
+					code.set(pos, pdf417RowHeight*r+h, dark)
+				}
+				pos++
+			}
+		}
+	}
+	return
+}
+
+// pdf417Encode returns the data codewords of s. Long runs of digits use
+// numeric compaction, printable text uses text compaction and anything else
+// byte compaction.
+func pdf417Encode(s []byte) (cw []int) {
+	mode := pdf417Text
+	sub := pdf417Alpha
+	for j := 0; j < len(s); {
+		if n := pdf417DigitRun(s, j); n >= 13 {
+			cw = append(cw, pdf417Numeric)
+			cw = append(cw, pdf417NumericCompact(s[j:j+n])...)
+			mode = pdf417Numeric
+			j += n
+			continue
+		}
+		if n := pdf417TextRun(s, j); n >= 5 || (n > 0 && j+n == len(s)) {
+			if mode != pdf417Text {
+				cw = append(cw, pdf417Text)
+				mode = pdf417Text
+				sub = pdf417Alpha
+			}
+			var vals []int
+			vals, sub = pdf417TextCompact(s[j:j+n], sub)
+			cw = append(cw, vals...)
+			j += n
+			continue
+		}
+		n := 1
+		for j+n < len(s) && pdf417DigitRun(s, j+n) < 13 && pdf417TextRun(s, j+n) < 5 {
+			n++
+		}
+		if n == 1 && mode == pdf417Text {
+			cw = append(cw, pdf417ByteShift, int(s[j]))
+			j++
+			continue
+		}
+		if n%6 == 0 {
+			cw = append(cw, pdf417Byte6)
+		} else {
+			cw = append(cw, pdf417Byte)
+		}
+		cw = append(cw, pdf417ByteCompact(s[j:j+n])...)
+		mode = pdf417Byte
+		j += n
+	}
+	return
+}
+
+func pdf417DigitRun(s []byte, j int) (n int) {
+	for j+n < len(s) && s[j+n] >= '0' && s[j+n] <= '9' {
+		n++
+	}
+	return
+}
+
+// pdf417TextRun returns the number of characters, starting at position j,
+// that can be text compacted before a run of digits long enough for numeric
+// compaction.
+func pdf417TextRun(s []byte, j int) (n int) {
+	for j+n < len(s) {
+		ch := s[j+n]
+		if (ch < 32 || ch > 126) && ch != '\t' && ch != '\n' && ch != '\r' {
+			break
+		}
+		if pdf417DigitRun(s, j+n) >= 13 {
+			break
+		}
+		n++
+	}
+	return
+}
+
+// pdf417TextCompact encodes s, starting in text sub-mode sub, and returns the
+// codewords and the final sub-mode.
+func pdf417TextCompact(s []byte, sub int) (cw []int, last int) {
+	var vals []int
+	isUpper := func(ch byte) bool { return (ch >= 'A' && ch <= 'Z') || ch == ' ' }
+	isLower := func(ch byte) bool { return (ch >= 'a' && ch <= 'z') || ch == ' ' }
+	mixed := func(ch byte) int {
+		if ch == ' ' {
+			return 26
+		}
+		return indexByte(pdf417MixedChars, ch)
+	}
+	letter := func(ch byte) int {
+		if ch == ' ' {
+			return 26
+		}
+		if ch >= 'a' {
+			return int(ch - 'a')
+		}
+		return int(ch - 'A')
+	}
+	for j := 0; j < len(s); {
+		ch := s[j]
+		switch sub {
+		case pdf417Alpha:
+			switch {
+			case isUpper(ch):
+				vals = append(vals, letter(ch))
+				j++
+			case isLower(ch):
+				vals = append(vals, 27)
+				sub = pdf417Lower
+			case mixed(ch) >= 0:
+				vals = append(vals, 28)
+				sub = pdf417Mixed
+			default:
+				vals = append(vals, 29, indexByte(pdf417PunctChars, ch))
+				j++
+			}
+		case pdf417Lower:
+			switch {
+			case isLower(ch):
+				vals = append(vals, letter(ch))
+				j++
+			case isUpper(ch):
+				vals = append(vals, 27, letter(ch))
+				j++
+			case mixed(ch) >= 0:
+				vals = append(vals, 28)
+				sub = pdf417Mixed
+			default:
+				vals = append(vals, 29, indexByte(pdf417PunctChars, ch))
+				j++
+			}
+		case pdf417Mixed:
+			switch {
+			case mixed(ch) >= 0:
+				vals = append(vals, mixed(ch))
+				j++
+			case isUpper(ch):
+				vals = append(vals, 28)
+				sub = pdf417Alpha
+			case isLower(ch):
+				vals = append(vals, 27)
+				sub = pdf417Lower
+			case j+1 < len(s) && mixed(s[j+1]) < 0 && indexByte(pdf417PunctChars, s[j+1]) >= 0:
+				vals = append(vals, 25)
+				sub = pdf417Punct
+			default:
+				vals = append(vals, 29, indexByte(pdf417PunctChars, ch))
+				j++
+			}
+		default:
+			if v := indexByte(pdf417PunctChars, ch); v >= 0 {
+				vals = append(vals, v)
+				j++
+			} else {
+				vals = append(vals, 29)
+				sub = pdf417Alpha
+			}
+		}
+	}
+	if len(vals)%2 == 1 {
+		vals = append(vals, 29)
+	}
+	for j := 0; j < len(vals); j += 2 {
+		cw = append(cw, 30*vals[j]+vals[j+1])
+	}
+	return cw, sub
+}
+
+// pdf417ByteCompact encodes groups of six bytes as five base 900 codewords
+// and any remaining bytes as one codeword each.
+func pdf417ByteCompact(s []byte) (cw []int) {
+	j := 0
+	for ; j+6 <= len(s); j += 6 {
+		var val uint64
+		for _, b := range s[j : j+6] {
+			val = val<<8 | uint64(b)
+		}
+		var group [5]int
+		for k := 4; k >= 0; k-- {
+			group[k] = int(val % 900)
+			val /= 900
+		}
+		cw = append(cw, group[:]...)
+	}
+	for ; j < len(s); j++ {
+		cw = append(cw, int(s[j]))
+	}
+	return
+}
+
+// pdf417NumericCompact encodes groups of up to 44 digits, prefixed with a
+// 1, as base 900 numbers.
+func pdf417NumericCompact(s []byte) (cw []int) {
+	radix := big.NewInt(900)
+	for j := 0; j < len(s); j += 44 {
+		val, _ := new(big.Int).SetString("1"+string(s[j:min(j+44, len(s))]), 10)
+		var group []int
+		mod := new(big.Int)
+		for val.Sign() > 0 {
+			val.DivMod(val, radix, mod)
+			group = append([]int{int(mod.Int64())}, group...)
+		}
+		cw = append(cw, group...)
+	}
+	return
+}
+
+// pdf417ECC returns the n Reed-Solomon error correction codewords, computed
+// over GF(929), of data.
+func pdf417ECC(data []int, n int) []int {
+	// Coefficients of the generator polynomial (x - 3)(x - 3^2)...(x - 3^n),
+	// lowest degree first
+	gen := []int{1}
+	root := 1
+	for j := 0; j < n; j++ {
+		root = root * 3 % 929
+		next := make([]int, len(gen)+1)
+		for k, c := range gen {
+			next[k+1] = (next[k+1] + c) % 929
+			next[k] = (next[k] + (929-root)*c) % 929
+		}
+		gen = next
+	}
+	e := make([]int, n)
+	for _, d := range data {
+		t := (d + e[n-1]) % 929
+		for k := n - 1; k >= 1; k-- {
+			e[k] = (e[k-1] + 929 - t*gen[k]%929) % 929
+		}
+		e[0] = (929 - t*gen[0]%929) % 929
+	}
+	out := make([]int, n)
+	for k := range e {
+		out[n-1-k] = (929 - e[k]) % 929
+	}
+	return out
+}
+
+func indexByte(s string, ch byte) int {
+	for j := 0; j < len(s); j++ {
+		if s[j] == ch {
+			return j
+		}
+	}
+	return -1
+}
@@ -0,0 +1,462 @@
+package barcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QRLevel is the error correction level of a QR Code. Higher levels recover
+// from more damage at the cost of a larger symbol.
+type QRLevel int
+
+const (
+	// QRLevelL recovers about 7% of the codewords
+	QRLevelL QRLevel = iota
+	// QRLevelM recovers about 15% of the codewords
+	QRLevelM
+	// QRLevelQ recovers about 25% of the codewords
+	QRLevelQ
+	// QRLevelH recovers about 30% of the codewords
+	QRLevelH
+)
+
+// qrEccPerBlock holds the number of error correction codewords in each block,
+// indexed by level and version.
+var qrEccPerBlock = [4][41]int{
+	{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+// qrBlocks holds the number of error correction blocks, indexed by level and
+// version.
+var qrBlocks = [4][41]int{
+	{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// qrLevelBits holds the level indicators used in the format information.
+var qrLevelBits = [4]int{1, 0, 3, 2}
+
+const qrAlphanumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+var qrField = newGaloisField(0x11d)
+
+// qrBits accumulates the bit stream of a QR Code.
+type qrBits []bool
+
+func (b *qrBits) put(val, n int) {
+	for j := n - 1; j >= 0; j-- {
+		*b = append(*b, (val>>uint(j))&1 == 1)
+	}
+}
+
+// NewQR encodes content as a QR Code with the given error correction level.
+// The smallest version (symbol size) that holds the content is selected
+// automatically. Content consisting only of digits, or of upper case letters,
+// digits and the characters " $%*+-./:", is encoded compactly; any other
+// content is encoded as UTF-8 bytes.
+func NewQR(content string, level QRLevel) (code *Code, err error) {
+	if level < QRLevelL || level > QRLevelH {
+		return nil, fmt.Errorf("barcode: invalid QR Code error correction level %d", level)
+	}
+	mode := 4
+	if digitRun(content, 0) == len(content) {
+		mode = 1
+	} else if strings.Trim(content, qrAlphanumeric) == "" {
+		mode = 2
+	}
+	version := 0
+	for v := 1; v <= 40; v++ {
+		if qrDataBits(mode, content, v) <= 8*qrDataCodewords(v, level) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("barcode: content of %d bytes is too long for a QR Code", len(content))
+	}
+	data := qrEncode(mode, content, version, level)
+	code = newCode("QR Code", content, 4*version+17, 4*version+17)
+	qrLayout(code, version, level, qrCodewords(data, version, level))
+	return
+}
+
+// qrCountBits returns the size of the character count indicator.
+func qrCountBits(mode, version int) int {
+	idx := 0
+	if version >= 27 {
+		idx = 2
+	} else if version >= 10 {
+		idx = 1
+	}
+	switch mode {
+	case 1:
+		return [3]int{10, 12, 14}[idx]
+	case 2:
+		return [3]int{9, 11, 13}[idx]
+	}
+	return [3]int{8, 16, 16}[idx]
+}
+
+// qrDataBits returns the number of bits required to encode s in the given
+// mode and version, without terminator and padding.
+func qrDataBits(mode int, s string, version int) (n int) {
+	n = 4 + qrCountBits(mode, version)
+	switch mode {
+	case 1:
+		n += len(s) / 3 * 10
+		n += [3]int{0, 4, 7}[len(s)%3]
+	case 2:
+		n += len(s)/2*11 + len(s)%2*6
+	default:
+		n += 8 * len(s)
+	}
+	return
+}
+
+// qrRawModules returns the number of modules available for data and error
+// correction codewords, including remainder bits.
+func qrRawModules(version int) (n int) {
+	n = (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return
+}
+
+func qrDataCodewords(version int, level QRLevel) int {
+	return qrRawModules(version)/8 - qrEccPerBlock[level][version]*qrBlocks[level][version]
+}
+
+// qrEncode returns the padded data codewords of s.
+func qrEncode(mode int, s string, version int, level QRLevel) []byte {
+	var bits qrBits
+	bits.put(mode, 4)
+	bits.put(len(s), qrCountBits(mode, version))
+	switch mode {
+	case 1:
+		for j := 0; j < len(s); j += 3 {
+			n := min(3, len(s)-j)
+			val := 0
+			for k := 0; k < n; k++ {
+				val = val*10 + int(s[j+k]-'0')
+			}
+			bits.put(val, 3*n+1)
+		}
+	case 2:
+		for j := 0; j < len(s); j += 2 {
+			val := strings.IndexByte(qrAlphanumeric, s[j])
+			if j+1 < len(s) {
+				bits.put(val*45+strings.IndexByte(qrAlphanumeric, s[j+1]), 11)
+			} else {
+				bits.put(val, 6)
+			}
+		}
+	default:
+		for j := 0; j < len(s); j++ {
+			bits.put(int(s[j]), 8)
+		}
+	}
+	capacity := 8 * qrDataCodewords(version, level)
+	bits.put(0, min(4, capacity-len(bits)))
+	bits.put(0, (8-len(bits)%8)%8)
+	data := make([]byte, 0, capacity/8)
+	for j := 0; j < len(bits); j += 8 {
+		var b byte
+		for k := 0; k < 8; k++ {
+			if bits[j+k] {
+				b |= 0x80 >> uint(k)
+			}
+		}
+		data = append(data, b)
+	}
+	for pad := byte(0xec); len(data) < capacity/8; pad ^= 0xec ^ 0x11 {
+		data = append(data, pad)
+	}
+	return data
+}
+
+// qrCodewords splits data into blocks, appends the error correction
+// codewords of each block and returns the interleaved result.
+func qrCodewords(data []byte, version int, level QRLevel) (out []byte) {
+	numBlocks := qrBlocks[level][version]
+	eccLen := qrEccPerBlock[level][version]
+	raw := qrRawModules(version) / 8
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	eccs := make([][]byte, numBlocks)
+	pos := 0
+	for j := range blocks {
+		n := shortLen
+		if j >= numShort {
+			n++
+		}
+		blocks[j] = data[pos : pos+n]
+		eccs[j] = qrField.ecc(blocks[j], eccLen, 0)
+		pos += n
+	}
+	for k := 0; k <= shortLen; k++ {
+		for j := range blocks {
+			if k < len(blocks[j]) {
+				out = append(out, blocks[j][k])
+			}
+		}
+	}
+	for k := 0; k < eccLen; k++ {
+		for j := range eccs {
+			out = append(out, eccs[j][k])
+		}
+	}
+	return
+}
+
+// qrMatrix is the work area used to lay out a QR Code symbol.
+type qrMatrix struct {
+	size    int
+	dark    []bool
+	isFixed []bool
+}
+
+func (m *qrMatrix) setFixed(x, y int, dark bool) {
+	m.dark[y*m.size+x] = dark
+	m.isFixed[y*m.size+x] = true
+}
+
+// qrLayout draws the function patterns and the codewords into code, choosing
+// the data mask with the lowest penalty.
+func qrLayout(code *Code, version int, level QRLevel, codewords []byte) {
+	size := code.cols
+	m := &qrMatrix{size: size, dark: code.modules, isFixed: make([]bool, size*size)}
+	for j := 0; j < size; j++ {
+		m.setFixed(6, j, j%2 == 0)
+		m.setFixed(j, 6, j%2 == 0)
+	}
+	m.finder(3, 3)
+	m.finder(size-4, 3)
+	m.finder(3, size-4)
+	align := qrAlignment(version)
+	last := len(align) - 1
+	for j, ay := range align {
+		for k, ax := range align {
+			if (j == 0 && k == 0) || (j == 0 && k == last) || (j == last && k == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					m.setFixed(ax+dx, ay+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	m.format(level, 0)
+	if version >= 7 {
+		rem := version
+		for j := 0; j < 12; j++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+		}
+		bits := version<<12 | rem
+		for j := 0; j < 18; j++ {
+			dark := (bits>>uint(j))&1 == 1
+			a, b := size-11+j%3, j/3
+			m.setFixed(a, b, dark)
+			m.setFixed(b, a, dark)
+		}
+	}
+	m.place(codewords)
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.format(level, mask)
+		if p := m.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		m.applyMask(mask)
+	}
+	m.applyMask(best)
+	m.format(level, best)
+}
+
+func (m *qrMatrix) finder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x >= 0 && y >= 0 && x < m.size && y < m.size {
+				d := max(abs(dx), abs(dy))
+				m.setFixed(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+}
+
+// format draws both copies of the format information and the dark module.
+func (m *qrMatrix) format(level QRLevel, mask int) {
+	data := qrLevelBits[level]<<3 | mask
+	rem := data
+	for j := 0; j < 10; j++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(j int) bool { return (bits>>uint(j))&1 == 1 }
+	for j := 0; j <= 5; j++ {
+		m.setFixed(8, j, bit(j))
+	}
+	m.setFixed(8, 7, bit(6))
+	m.setFixed(8, 8, bit(7))
+	m.setFixed(7, 8, bit(8))
+	for j := 9; j < 15; j++ {
+		m.setFixed(14-j, 8, bit(j))
+	}
+	for j := 0; j < 8; j++ {
+		m.setFixed(m.size-1-j, 8, bit(j))
+	}
+	for j := 8; j < 15; j++ {
+		m.setFixed(8, m.size-15+j, bit(j))
+	}
+	m.setFixed(8, m.size-8, true)
+}
+
+// place fills the non-function modules with the codeword bits in the zigzag
+// order of two-module wide columns, starting at the bottom right corner.
+func (m *qrMatrix) place(codewords []byte) {
+	j := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < m.size; vert++ {
+			y := vert
+			if upward {
+				y = m.size - 1 - vert
+			}
+			for k := 0; k < 2; k++ {
+				x := right - k
+				if !m.isFixed[y*m.size+x] && j < 8*len(codewords) {
+					m.dark[y*m.size+x] = (codewords[j>>3]>>uint(7-j&7))&1 == 1
+					j++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the non-function modules selected by the mask pattern.
+// Applying the same mask twice restores the original modules.
+func (m *qrMatrix) applyMask(mask int) {
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			default:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !m.isFixed[y*m.size+x] {
+				m.dark[y*m.size+x] = !m.dark[y*m.size+x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol according to the four rules of the QR Code
+// specification: runs of same colored modules, 2x2 blocks, finder-like
+// patterns and the balance of dark and light modules.
+func (m *qrMatrix) penalty() (p int) {
+	size := m.size
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			x, y = y, x
+		}
+		return m.dark[y*size+x]
+	}
+	finderLike := [2]string{"10111010000", "00001011101"}
+	for _, transpose := range []bool{false, true} {
+		for y := 0; y < size; y++ {
+			run := 1
+			for x := 1; x <= size; x++ {
+				if x < size && at(x, y, transpose) == at(x-1, y, transpose) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += run - 2
+				}
+				run = 1
+			}
+			for x := 0; x+11 <= size; x++ {
+				for _, pattern := range finderLike {
+					match := true
+					for k := 0; k < 11 && match; k++ {
+						match = at(x+k, y, transpose) == (pattern[k] == '1')
+					}
+					if match {
+						p += 40
+					}
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := m.dark[y*size+x]
+			if c {
+				dark++
+			}
+			if x+1 < size && y+1 < size && c == m.dark[y*size+x+1] &&
+				c == m.dark[(y+1)*size+x] && c == m.dark[(y+1)*size+x+1] {
+				p += 3
+			}
+		}
+	}
+	total := size * size
+	p += abs(dark*2-total) * 10 / total * 10
+	return
+}
+
+// qrAlignment returns the row and column centers of the alignment patterns.
+func qrAlignment(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := 26
+	if version != 32 {
+		step = (version*4 + n*2 + 1) / (n*2 - 2) * 2
+	}
+	pos := make([]int, n)
+	pos[0] = 6
+	for j, p := n-1, 4*version+10; j >= 1; j, p = j-1, p-step {
+		pos[j] = p
+	}
+	return pos
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,63 @@
+package barcode
+
+// galoisField implements arithmetic in GF(256) as used by the Reed-Solomon
+// error correction of QR Code and DataMatrix, which differ in the primitive
+// polynomial.
+type galoisField struct {
+	exp [512]int
+	log [256]int
+}
+
+func newGaloisField(poly int) (gf *galoisField) {
+	gf = new(galoisField)
+	x := 1
+	for j := 0; j < 255; j++ {
+		gf.exp[j] = x
+		gf.log[x] = j
+		x <<= 1
+		if x >= 256 {
+			x ^= poly
+		}
+	}
+	for j := 255; j < 512; j++ {
+		gf.exp[j] = gf.exp[j-255]
+	}
+	return
+}
+
+func (gf *galoisField) mul(a, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gf.exp[gf.log[a]+gf.log[b]]
+}
+
+// ecc returns the n error correction codewords of data. The roots of the
+// generator polynomial are the n consecutive powers of the primitive element
+// starting with exponent base.
+func (gf *galoisField) ecc(data []byte, n, base int) []byte {
+	gen := []int{1}
+	for j := 0; j < n; j++ {
+		next := make([]int, len(gen)+1)
+		root := gf.exp[(base+j)%255]
+		for k, c := range gen {
+			next[k] ^= c
+			next[k+1] ^= gf.mul(c, root)
+		}
+		gen = next
+	}
+	rem := make([]int, n)
+	for _, d := range data {
+		factor := int(d) ^ rem[0]
+		copy(rem, rem[1:])
+		rem[n-1] = 0
+		for k := 0; k < n; k++ {
+			rem[k] ^= gf.mul(gen[k+1], factor)
+		}
+	}
+	out := make([]byte, n)
+	for k, r := range rem {
+		out[k] = byte(r)
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package barcode
+
+import (
+	"fmt"
+)
+
+// i2of5Wide flags the two wide elements among the five of each digit.
+var i2of5Wide = [10]string{
+	"00110", "10001", "01001", "11000", "00101",
+	"10100", "01100", "00011", "10010", "01010",
+}
+
+// NewInterleaved2of5 encodes a string of digits as an Interleaved 2 of 5
+// symbol. If checksum is true, a modulo 10 check digit is appended. Since
+// digits are encoded in pairs, a leading zero is inserted when the number of
+// digits is odd.
+func NewInterleaved2of5(content string, checksum bool) (code *Code, err error) {
+	if content == "" || digitRun(content, 0) != len(content) {
+		return nil, fmt.Errorf("barcode: Interleaved 2 of 5 requires digits, got %q", content)
+	}
+	digits := content
+	if checksum {
+		digits += string(checkDigit(digits))
+	}
+	if len(digits)%2 == 1 {
+		digits = "0" + digits
+	}
+	// Each pair of digits takes 18 modules with wide elements three modules
+	// wide; the start pattern takes 4 and the stop pattern 5
+	code = newCode("Interleaved 2 of 5", digits, 9*len(digits)+9, 1)
+	pos := code.appendWidths(0, "1111")
+	widths := make([]byte, 10)
+	for j := 0; j < len(digits); j += 2 {
+		bars := i2of5Wide[digits[j]-'0']
+		spaces := i2of5Wide[digits[j+1]-'0']
+		for k := 0; k < 5; k++ {
+			widths[2*k] = '1' + 2*(bars[k]-'0')
+			widths[2*k+1] = '1' + 2*(spaces[k]-'0')
+		}
+		pos = code.appendWidths(pos, string(widths))
+	}
+	code.appendWidths(pos, "311")
+	return
+}
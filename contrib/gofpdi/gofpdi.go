@@ -10,6 +10,7 @@ package gofpdi
 
 import (
 	"io"
+	"io/fs"
 
 	realgofpdi "github.com/cdvelop/docpdf/gofpdi"
 )
@@ -24,6 +25,12 @@ type gofpdiPdf interface {
 	SetError(err error)
 }
 
+// resourceFSPdf is implemented by PDF generators that read their resources
+// from an fs.FS. Source files passed to ImportPage are read from it as well.
+type resourceFSPdf interface {
+	GetResourceFS() fs.FS
+}
+
 // Importer wraps an Importer from the gofpdi library.
 type Importer struct {
 	fpdi *realgofpdi.Importer
@@ -40,6 +47,10 @@ func NewImporter() *Importer {
 // /TrimBox, /ArtBox, /CropBox, or /BleedBox). Returns a template id that can
 // be used with UseImportedTemplate to draw the template onto the page.
 func (i *Importer) ImportPage(f gofpdiPdf, sourceFile string, pageno int, box string) int {
+	// Read the source file from the generator's file system, if any
+	if r, ok := f.(resourceFSPdf); ok {
+		i.fpdi.SetFS(r.GetResourceFS())
+	}
 	// Set source file for fpdi
 	i.fpdi.SetSourceFile(sourceFile)
 	// return template id
@@ -8,10 +8,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"math"
-	"path"
 	"strconv"
 	"time"
+
+	"github.com/cdvelop/docpdf/env"
 )
 
 // Version of FPDF from which this package is derived
@@ -25,10 +27,31 @@ type blendModeType struct {
 }
 
 type gradientType struct {
-	tp                int // 2: linear, 3: radial
+	tp                int // 2: linear, 3: radial, 6: Coons patch mesh
 	clr1Str, clr2Str  string
 	x1, y1, x2, y2, r float64
 	objNum            int
+	stops             []gradientStopType // colors between clr1Str and clr2Str, if any
+	spotStr           string             // spot color of a Separation color space
+	comps             int                // number of color components, 3 if zero
+	extend            [2]bool            // extension before the start and beyond the end
+	mesh              []byte             // patch data of a mesh
+	decodeStr         string             // ranges of the coordinates and colors of a mesh
+}
+
+// patternType is a shading or tiling pattern used as fill color
+type patternType struct {
+	gradient int      // index of the shading in gradientList, if any
+	tpl      Template // cell of a tiling pattern, if any
+	matrix   TransformMatrix
+	objNum   int
+}
+
+// gradientStopType is a color of a gradient at the offset, from 0 to 1, along
+// the gradient vector
+type gradientStopType struct {
+	offset float64
+	clrStr string
 }
 
 type RootDirectoryType string // RootDirectoryType is the root directory of the executable default is "." but test can set it to a different directory
@@ -38,9 +61,12 @@ type RootDirectoryType string // RootDirectoryType is the root directory of the
 //
 //	r.MakePath("file.txt") returns "/home/user/docpdf/file.txt"
 //	r.MakePath("dir", "file.txt") returns "/home/user/docpdf/dir/file.txt"
+//
+// A root given as URL (eg: "https://cdn.example.com/docpdf") keeps its scheme,
+// which allows fonts and images to be fetched in wasm environments.
 func (r RootDirectoryType) MakePath(pathElements ...string) string {
 	elements := append([]string{string(r)}, pathElements...)
-	return path.Join(elements...)
+	return env.JoinPath(elements...)
 }
 
 type FontsDirName string // FontsDirName is the name of the font directory default is "fonts"
@@ -138,6 +164,9 @@ type colorMode int
 const (
 	colorModeRGB colorMode = iota
 	colorModeSpot
+	colorModePattern
+	colorModeCMYK
+	colorModeGray
 )
 
 type colorType struct {
@@ -145,6 +174,7 @@ type colorType struct {
 	ir, ig, ib int
 	mode       colorMode
 	spotStr    string // name of current spot color
+	cmyk       cmykColorType
 	gray       bool
 	str        string
 }
@@ -388,6 +418,7 @@ type intLinkType struct {
 // outlineType is used for a sidebar outline of bookmarks
 type outlineType struct {
 	text                                   string
+	txt                                    string // text as given to Bookmark()
 	level, parent, first, last, next, prev int
 	y                                      float64
 	p                                      int
@@ -404,6 +435,7 @@ type InitType struct {
 	Size           PageSize
 	RootDirectory  RootDirectoryType // Root directory of the executable default is "." but test can set it to a different directory
 	FontDirName    string            // name to the font directory default is "fonts"
+	ResourceFS     fs.FS             // file system from which fonts, images and other resources are read (eg: embed.FS)
 }
 
 // FontLoader is used to read fonts (JSON font specification and zlib compressed font binaries)
@@ -438,15 +470,20 @@ type Pdf interface {
 	AddFont(familyStr, styleStr, fileStr string)
 	AddFontFromBytes(familyStr, styleStr string, jsonFileBytes, zFileBytes []byte)
 	AddFontFromReader(familyStr, styleStr string, r io.Reader)
+	AddHatchPattern(styleStr string, spacing, lineWd float64) (id int)
+	AddHyphenationPatterns(lang, fileStr string)
+	AddHyphenationPatternsFromBytes(lang string, data []byte)
 	AddLayer(name string, visible bool) (layerID int)
 	AddLink() int
 	AddPage()
 	AddPageFormat(orientationStr orientationType, size PageSize)
 	AddSpotColor(nameStr string, c, m, y, k byte)
+	AddTilingPattern(w, h float64, fn func(*Tpl)) (id int)
 	AliasNbPages(aliasStr string)
 	ArcTo(x, y, rx, ry, degRotate, degStart, degEnd float64)
 	Arc(x, y, rx, ry, degRotate, degStart, degEnd float64, styleStr string)
 	BeginLayer(id int)
+	BeginTag(role, altText string)
 	Beziergon(points []PointType, styleStr string)
 	Bookmark(txtStr string, level int, y float64)
 	CellFormat(w, h float64, txtStr, borderStr string, ln int, alignStr string, fill bool, link int, linkStr string)
@@ -463,6 +500,7 @@ type Pdf interface {
 	ClipText(x, y float64, txtStr string, outline bool)
 	Close()
 	ClosePath()
+	CoonsPatchGradient(patches []CoonsPatchType)
 	CreateTemplateCustom(corner PointType, size SizeType, fn func(*Tpl)) Template
 	CreateTemplate(fn func(*Tpl)) Template
 	CurveBezierCubicTo(cx0, cy0, cx1, cy1, x, y float64)
@@ -472,7 +510,9 @@ type Pdf interface {
 	Curve(x0, y0, cx, cy, x1, y1 float64, styleStr string)
 	DrawPath(styleStr string)
 	Ellipse(x, y, rx, ry, degRotate float64, styleStr string)
+	EndFrames()
 	EndLayer()
+	EndTag()
 	Err() bool
 	Error() error
 	GetAlpha() (alpha float64, blendModeStr string)
@@ -486,8 +526,10 @@ type Pdf interface {
 	GetCreator() string
 	GetDisplayMode() (zoomStr, layoutStr string)
 	GetDrawColor() (int, int, int)
+	GetDrawColorCMYK() (c, m, y, k byte)
 	GetDrawSpotColor() (name string, c, m, y, k byte)
 	GetFillColor() (int, int, int)
+	GetFillColorCMYK() (c, m, y, k byte)
 	GetFillSpotColor() (name string, c, m, y, k byte)
 	GetFontDesc(familyStr, styleStr string) FontDescType
 	GetFontFamily() string
@@ -506,10 +548,12 @@ type Pdf interface {
 	GetModificationDate() time.Time
 	GetPageSize() (width, height float64)
 	GetPageSizeStr(sizeStr string) (size PageSize)
+	GetPDFAConformance() string
 	GetProducer() string
 	GetStringWidth(s string) float64
 	GetSubject() string
 	GetTextColor() (int, int, int)
+	GetTextColorCMYK() (c, m, y, k byte)
 	GetTextSpotColor() (name string, c, m, y, k byte)
 	GetTitle() string
 	GetUnderlineThickness() float64
@@ -519,10 +563,12 @@ type Pdf interface {
 	GetXY() (float64, float64)
 	GetY() float64
 	HTMLBasicNew() (html HTMLBasicType)
+	HTMLNew() (html HTMLType)
 	Image(imageNameStr string, x, y, w, h float64, flow bool, tp string, link int, linkStr string)
 	ImageOptions(imageNameStr string, x, y, w, h float64, flow bool, options ImageOptions, link int, linkStr string)
 	ImageTypeFromMime(mimeStr string) (tp string)
 	LinearGradient(x, y, w, h float64, r1, g1, b1, r2, g2, b2 int, x1, y1, x2, y2 float64)
+	LinearGradientStops(x, y, w, h float64, grad GradientType, x1, y1, x2, y2 float64)
 	LineTo(x, y float64)
 	Line(x1, y1, x2, y2 float64)
 	LinkString(x, y, w, h float64, linkStr string)
@@ -542,6 +588,7 @@ type Pdf interface {
 	PointToUnitConvert(pt float64) (u float64)
 	Polygon(points []PointType, styleStr string)
 	RadialGradient(x, y, w, h float64, r1, g1, b1, r2, g2, b2 int, x1, y1, x2, y2, r float64)
+	RadialGradientStops(x, y, w, h float64, grad GradientType, x1, y1, x2, y2, r float64)
 	RawWriteBuf(r io.Reader)
 	RawWriteStr(str string)
 	Rect(x, y, w, h float64, styleStr string)
@@ -550,11 +597,14 @@ type Pdf interface {
 	RegisterImageOptions(fileStr string, options ImageOptions) (info *ImageInfoType)
 	RegisterImageOptionsReader(imgName string, options ImageOptions, r io.Reader) (info *ImageInfoType)
 	RegisterImageReader(imgName, tp string, r io.Reader) (info *ImageInfoType)
+	ReserveTOC(pages int, titleStr string, maxLevel int)
 	SetAcceptPageBreakFunc(fnc func() bool)
 	SetAlpha(alpha float64, blendModeStr string)
 	SetAuthor(authorStr string, isUTF8 bool)
 	SetAutoPageBreak(auto bool, margin float64)
 	SetCatalogSort(flag bool)
+	SetCMYKConversion(enabled bool)
+	SetColumns(n int, gutter float64, balanced bool)
 	SetCellMargin(margin float64)
 	SetCompression(compress bool)
 	SetCreationDate(tm time.Time)
@@ -563,23 +613,34 @@ type Pdf interface {
 	SetDisplayMode(zoomStr, layoutStr string)
 	SetLang(lang string)
 	SetDrawColor(r, g, b int)
+	SetDrawColorCMYK(c, m, y, k byte)
+	SetDrawColorGray(level int)
 	SetDrawSpotColor(nameStr string, tint byte)
 	SetError(err error)
 	SetErrorf(fmtStr string, args ...interface{})
 	SetFillColor(r, g, b int)
+	SetFillColorCMYK(c, m, y, k byte)
+	SetFillColorGray(level int)
+	SetFillLinearGradient(x, y, w, h float64, grad GradientType, x1, y1, x2, y2 float64)
+	SetFillPattern(id int)
+	SetFillRadialGradient(x, y, w, h float64, grad GradientType, x1, y1, x2, y2, r float64)
 	SetFillSpotColor(nameStr string, tint byte)
 	SetFont(familyStr, styleStr string, size float64)
+	SetFontFallback(familyStr string, fallbacks []string)
 	SetFontLoader(loader FontLoader)
 	SetFontLocation(fontDirStr string)
 	SetFontSize(size float64)
 	SetFontStyle(styleStr string)
 	SetFontUnitSize(size float64)
 	SetFooterFunc(fnc func())
+	SetFrames(frames []TextFrame, balanced bool)
 	SetFooterFuncLpi(fnc func(lastPage bool))
 	SetHeaderFunc(fnc func())
 	SetHeaderFuncMode(fnc func(), homeMode bool)
 	SetHomeXY()
+	SetICCBasedColors(enabled bool)
 	SetJavascript(script string)
+	SetKerning(enabled bool)
 	SetKeywords(keywordsStr string, isUTF8 bool)
 	SetLeftMargin(margin float64)
 	SetLineCapStyle(styleStr string)
@@ -588,12 +649,21 @@ type Pdf interface {
 	SetLink(link int, y float64, page int)
 	SetMargins(left, top, right float64)
 	SetPageBoxRec(t string, pb PageBox)
+	SetParagraphLayout(layout *ParagraphLayout)
+	SetPDFAConformance(levelStr string)
 	SetPageBox(t string, x, y, wd, ht float64)
 	SetPage(pageNum int)
 	SetProtection(actionFlag byte, userPassStr, ownerPassStr string)
+	SetProtectionAES128(actionFlag int, userPassStr, ownerPassStr string)
+	SetProtectionAES256(actionFlag int, userPassStr, ownerPassStr string)
 	SetRightMargin(margin float64)
 	SetSubject(subjectStr string, isUTF8 bool)
+	SetTagged(tagged bool)
 	SetTextColor(r, g, b int)
+	SetTextColorCMYK(c, m, y, k byte)
+	SetTextColorGray(level int)
+	SetTextPattern(id int)
+	SetTextShaping(enabled bool)
 	SetTextSpotColor(nameStr string, tint byte)
 	SetTitle(titleStr string, isUTF8 bool)
 	SetTopMargin(margin float64)
@@ -605,6 +675,7 @@ type Pdf interface {
 	SplitLines(txt []byte, w float64) [][]byte
 	String() string
 	SVGBasicWrite(sb *SVGBasicType, scale float64)
+	SVGWrite(svg *SVGType, x, y, w, h float64)
 	Text(x, y float64, txtStr string)
 	TransformBegin()
 	TransformEnd()
@@ -645,6 +716,13 @@ type PageBox struct {
 type DocPDF struct {
 	isCurrentUTF8    bool                       // is current font used in utf-8 mode
 	isRTL            bool                       // is is right to left mode enabled
+	shaping          bool                       // shape text written with UTF-8 fonts
+	kerning          bool                       // apply pair kerning
+	fontFallbacks    map[string][]string        // fallback font families of each family
+	inFallback       bool                       // set while a run of fallback text is processed
+	hyphenators      map[string]*hyphenator     // hyphenation patterns of each language
+	paragraphLayout  *ParagraphLayout           // layout of MultiCell() and SplitText(), nil for the default one
+	flow             *textFlowType              // frames in which text flows, nil if none
 	page             int                        // current page number
 	n                int                        // current object number
 	offsets          []int                      // array of object offsets
@@ -682,6 +760,7 @@ type DocPDF struct {
 	fontsDirName     FontsDirName               // fonts directory name default is "fonts"
 	fontsPath        string                     // full path containing fonts directory included rootDirectory eg. "/home/user/docpdf/fonts"
 	fontLoader       FontLoader                 // used to load font files from arbitrary locations
+	resourceFS       fs.FS                      // used to read fonts, images, SVG and other resources instead of env
 	coreFonts        map[string]bool            // array of core font names
 	fonts            map[string]fontDefType     // array of used fonts
 	fontFiles        map[string]fontFileType    // array of font files
@@ -700,8 +779,19 @@ type DocPDF struct {
 	links            []intLinkType              // array of internal links
 	attachments      []Attachment               // slice of content to embed globally
 	pageAttachments  [][]annotationAttach       // 1-based array of annotation for file attachments (per page)
+	formFields       []*formFieldType           // interactive form fields
+	pageWidgets      [][]*formWidgetType        // 1-based array of form field widgets (per page)
+	nFormZaDb        int                        // ZapfDingbats font object number for form buttons
+	signature        *signatureStateType        // document signature, nil if not signed
+	tagged           bool                       // emit a logical structure tree
+	structRoot       *structElemType            // root Document structure element
+	tagStack         []*structElemType          // open structure elements, innermost last
+	tagArtifact      int                        // number of open artifact sequences
+	structParents    map[int][]*structElemType  // structure element of each marked content, per page
+	nStructTreeRoot  int                        // structure tree root object number
 	outlines         []outlineType              // array of outlines
 	outlineRoot      int                        // root of outlines
+	toc              *tocType                   // table of contents reserved with ReserveTOC()
 	autoPageBreak    bool                       // automatic page breaking
 	acceptPageBreak  func() bool                // returns true to accept page break
 	pageBreakTrigger float64                    // threshold used to trigger page breaks
@@ -735,6 +825,7 @@ type DocPDF struct {
 	blendMode        string                     // current blend mode
 	alpha            float64                    // current transpacency
 	gradientList     []gradientType             // slice[idx] of gradient records
+	patternList      []patternType              // slice[idx] of shading pattern records
 	clipNest         int                        // Number of active clipping contexts
 	transformNest    int                        // Number of active transformation contexts
 	err              error                      // Set if error occurs during life cycle of instance
@@ -751,6 +842,10 @@ type DocPDF struct {
 	spotColorMap           map[string]spotColorType // Map of named ink-based colors
 	outputIntents          []OutputIntentType       // OutputIntents
 	outputIntentStartN     int                      // Start object number for
+	cmykConversion         bool                     // convert RGB colors and images to CMYK
+	iccColors              bool                     // specify colors in the ICCBased color space of the output intent
+	pdfaPart               int                      // part of the PDF/A conformance mode, 0 if disabled
+	outputTime             time.Time                // time the document is output, used for unset dates
 	userUnderlineThickness float64                  // A custom user underline thickness multiplier.
 
 	fmt struct {
@@ -763,6 +858,9 @@ const (
 	pdfVers1_3 = pdfVersion(uint16(1)<<8 | uint16(3))
 	pdfVers1_4 = pdfVersion(uint16(1)<<8 | uint16(4))
 	pdfVers1_5 = pdfVersion(uint16(1)<<8 | uint16(5))
+	pdfVers1_6 = pdfVersion(uint16(1)<<8 | uint16(6))
+	pdfVers1_7 = pdfVersion(uint16(1)<<8 | uint16(7))
+	pdfVers2_0 = pdfVersion(uint16(2)<<8 | uint16(0))
 )
 
 type pdfVersion uint16
@@ -873,22 +971,23 @@ type FontDescType struct {
 }
 
 type fontDefType struct {
-	Tp           string        // "Core", "TrueType", ...
-	Name         string        // "Courier-Bold", ...
-	Desc         FontDescType  // Font descriptor
-	Up           int           // Underline position
-	Ut           int           // Underline thickness
-	Cw           []int         // Character width by ordinal
-	Enc          string        // "cp1252", ...
-	Diff         string        // Differences from reference encoding
-	File         string        // "Redressed.z"
-	Size1, Size2 int           // Type1 values
-	OriginalSize int           // Size of uncompressed font file
-	N            int           // Set by font loader
-	DiffN        int           // Position of diff in app array, set by font loader
-	i            string        // 1-based position in font list, set by font loader, not this program
-	utf8File     *utf8FontFile // UTF-8 font
-	usedRunes    map[int]int   // Array of used runes
+	Tp           string              // "Core", "TrueType", ...
+	Name         string              // "Courier-Bold", ...
+	Desc         FontDescType        // Font descriptor
+	Up           int                 // Underline position
+	Ut           int                 // Underline thickness
+	Cw           []int               // Character width by ordinal
+	Kp           map[int]map[int]int `json:",omitempty"` // Kerning pairs by ordinal, in thousandths of em
+	Enc          string              // "cp1252", ...
+	Diff         string              // Differences from reference encoding
+	File         string              // "Redressed.z"
+	Size1, Size2 int                 // Type1 values
+	OriginalSize int                 // Size of uncompressed font file
+	N            int                 // Set by font loader
+	DiffN        int                 // Position of diff in app array, set by font loader
+	i            string              // 1-based position in font list, set by font loader, not this program
+	utf8File     *utf8FontFile       // UTF-8 font
+	usedRunes    map[int]int         // Array of used runes
 }
 
 // generateFontID generates a font Id from the font definition
@@ -909,6 +1008,7 @@ type fontInfoType struct {
 	UnderlineThickness int
 	UnderlinePosition  int
 	Widths             []int
+	Kerning            map[int]map[int]int
 	Size1, Size2       uint32
 	Desc               FontDescType
 }
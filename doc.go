@@ -16,11 +16,11 @@ support for text, drawing and images.
 
 -   Automatic page breaks, line breaks, and text justification
 
--   Inclusion of JPEG, PNG, GIF, TIFF and basic path-only SVG images
+-   Inclusion of JPEG, PNG, GIF, TIFF and SVG images
 
 -   Colors, gradients and alpha channel transparency
 
--   Outline bookmarks
+-   Outline bookmarks and automatic table of contents
 
 -   Internal and external links
 
@@ -40,12 +40,20 @@ support for text, drawing and images.
 
 -   Templates
 
+-   Tables with automatic column widths, cell spans and repeated headers
+
+-   Rendering of a subset of HTML and CSS
+
+-   Rendering of Markdown, with GitHub tables and task lists
+
 -   Barcodes
 
 -   Charting facility
 
 -   Import PDFs as templates
 
+-   PDF/A-1b, PDF/A-2b and PDF/A-3b conformance
+
 go-pdf/docpdf has no dependencies other than the Go standard library. All tests
 pass on Linux, Mac and Windows platforms.
 
@@ -149,8 +157,11 @@ helvetica, times, zapfdingbats) in your documents other than calling
 SetFont().
 
 You should use AddUTF8Font() or AddUTF8FontFromBytes() to add a TrueType
-UTF-8 encoded font. Use RTL() and LTR() methods switch between
-“right-to-left” and “left-to-right” mode.
+or OpenType (.otf) UTF-8 encoded font, and AddUTF8FontFace() or
+AddUTF8FontFaceFromBytes() to add a font of a collection (.ttc). An
+instance of a variable font is added with AddUTF8FontVariation() or
+AddUTF8FontVariationFromBytes(), given its axis values. Use RTL() and LTR()
+methods switch between “right-to-left” and “left-to-right” mode.
 
 In order to use a different non-UTF-8 TrueType or Type1 font, you will
 need to generate a font definition file and, if the font will be
@@ -9,8 +9,8 @@ import (
 	"image/jpeg"
 	"image/png"
 	"io"
+	"io/fs"
 	"math"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -59,7 +59,8 @@ func New(options ...any) (f *DocPDF) {
 			f.rootDirectory = v
 		case FontsDirName:
 			f.fontsDirName = v
-
+		case fs.FS:
+			f.resourceFS = v
 		}
 	}
 	if initType != nil {
@@ -74,6 +75,9 @@ func New(options ...any) (f *DocPDF) {
 		// Note: page size conversion happens later after scale factor is set
 		f.rootDirectory = initType.RootDirectory
 		f.fontsPath = initType.RootDirectory.MakePath(initType.FontDirName)
+		if initType.ResourceFS != nil {
+			f.resourceFS = initType.ResourceFS
+		}
 	}
 
 	f.page = 0
@@ -100,6 +104,8 @@ func New(options ...any) (f *DocPDF) {
 	f.links = append(f.links, intLinkType{}) // links[0] is unused (1-based)
 	f.pageAttachments = make([][]annotationAttach, 0, 8)
 	f.pageAttachments = append(f.pageAttachments, []annotationAttach{}) //
+	f.pageWidgets = make([][]*formWidgetType, 0, 8)
+	f.pageWidgets = append(f.pageWidgets, nil) // pageWidgets[0] is unused (1-based)
 	f.aliasMap = make(map[string]string)
 	f.inHeader = false
 	f.inFooter = false
@@ -116,6 +122,10 @@ func New(options ...any) (f *DocPDF) {
 	f.ws = 0
 	// Set fontsPath instance
 	f.fontsPath = f.rootDirectory.MakePath(string(f.fontsDirName))
+	if f.resourceFS != nil {
+		// paths within the resource file system are relative to its root
+		f.fontsPath = string(f.fontsDirName)
+	}
 
 	// Core fonts
 	f.coreFonts = map[string]bool{
@@ -213,6 +223,7 @@ func New(options ...any) (f *DocPDF) {
 	f.alpha = 1
 	f.gradientList = make([]gradientType, 0, 8)
 	f.gradientList = append(f.gradientList, gradientType{}) // gradientList[0] is unused
+	f.patternList = make([]patternType, 1)                  // patternList[0] is unused
 	// Set default PDF version number
 	f.pdfVersion = pdfVers1_3
 	f.SetProducer("FPDF "+cnFpdfVersion, true)
@@ -476,17 +487,28 @@ func (f *DocPDF) Close() {
 			return
 		}
 	}
+	if f.toc != nil {
+		f.putTOC()
+		if f.err != nil {
+			return
+		}
+	}
 	// Page footer
 	f.inFooter = true
+	f.artifactBegin()
 	if f.footerFnc != nil {
 		f.footerFnc()
 	} else if f.footerFncLpi != nil {
 		f.footerFncLpi(true)
 	}
+	f.artifactEnd()
 	f.inFooter = false
 
 	// Close page
 	f.endpage()
+	if f.toc != nil {
+		f.moveTOC()
+	}
 	// Close document
 	f.enddoc()
 }
@@ -506,6 +528,10 @@ func (f *DocPDF) rgbColorValue(r, g, b int, grayStr, fullStr string) (clr colorT
 	clr.ib, clr.b = colorComp(b)
 	clr.mode = colorModeRGB
 	clr.gray = clr.ir == clr.ig && clr.r == clr.b
+	if str, ok := f.rgbColorStr(clr, len(grayStr) > 0, fullStr == "RG"); ok {
+		clr.str = str
+		return
+	}
 	const prec = 3
 	if len(grayStr) > 0 {
 		if clr.gray {
@@ -604,10 +630,11 @@ func (f *DocPDF) Bookmark(txtStr string, level int, y float64) {
 	if y == -1 {
 		y = f.y
 	}
+	txt := txtStr
 	if f.isCurrentUTF8 {
 		txtStr = utf8toutf16(txtStr)
 	}
-	f.outlines = append(f.outlines, outlineType{text: txtStr, level: level, y: y, p: f.PageNo(), prev: -1, last: -1, next: -1, first: -1})
+	f.outlines = append(f.outlines, outlineType{text: txtStr, txt: txt, level: level, y: y, p: f.page, prev: -1, last: -1, next: -1, first: -1})
 }
 
 // GetWordSpacing returns the spacing between words of following text.
@@ -689,6 +716,9 @@ func (f *DocPDF) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 		f.err = fmt.Errorf("font has not been set; unable to render text")
 		return
 	}
+	if f.tagAuto("P", "") {
+		defer f.tagPop()
+	}
 
 	borderStr = strings.ToUpper(borderStr)
 	k := f.k
@@ -697,7 +727,7 @@ func (f *DocPDF) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 		x := f.x
 		ws := f.ws
 		// dbg("auto page break, x %.2f, ws %.2f", x, ws)
-		if ws > 0 {
+		if ws != 0 {
 			f.ws = 0
 			f.out("0 Tw")
 		}
@@ -706,7 +736,7 @@ func (f *DocPDF) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 			return
 		}
 		f.x = x
-		if ws > 0 {
+		if ws != 0 {
 			f.ws = ws
 			// f.outf("%.3f Tw", ws*k)
 			f.putF64(ws*k, 3)
@@ -790,7 +820,22 @@ func (f *DocPDF) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 			s.printf("q %s ", f.color.text.str)
 		}
 		//If multibyte, Tw has no effect - do word spacing using an adjustment before each space
-		if (f.ws != 0 || alignStr == "J") && f.isCurrentUTF8 { // && f.ws != 0
+		if runs := f.fallbackRuns(txtStr); runs != nil || f.shapingActive() {
+			shift := 0.0
+			if f.ws != 0 || alignStr == "J" {
+				wmax := int(math.Ceil((w - 2*f.cMargin) * 1000 / f.fontSize))
+				if n := strings.Count(txtStr, " "); n > 0 {
+					shift = float64(wmax-f.GetStringSymbolWidth(txtStr)) / float64(n)
+				}
+			}
+			bt := (f.x + dx) * k
+			td := (f.h - (f.y + dy + .5*h + .3*f.fontSize)) * k
+			if runs != nil {
+				s.printf("BT 0 Tw %.2f %.2f Td %s ET", bt, td, f.fallbackText(runs, shift))
+			} else {
+				s.printf("BT 0 Tw %.2f %.2f Td %s ET", bt, td, f.shapedText(txtStr, shift))
+			}
+		} else if (f.ws != 0 || alignStr == "J") && f.isCurrentUTF8 { // && f.ws != 0
 			if f.isRTL {
 				txtStr = reverseText(txtStr)
 			}
@@ -804,12 +849,16 @@ func (f *DocPDF) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 			t := strings.Split(txtStr, " ")
 			shift := float64((wmax - strSize)) / float64(len(t)-1)
 			numt := len(t)
-			for i := 0; i < numt; i++ {
-				tx := t[i]
-				tx = "(" + f.escape(utf8toutf16(tx, false)) + ")"
-				s.printf("%s ", tx)
-				if (i + 1) < numt {
-					s.printf("%.3f(%s) ", -shift, space)
+			if f.kerning {
+				s.printf("%s", f.kernedText(txtStr, shift))
+			} else {
+				for i := 0; i < numt; i++ {
+					tx := t[i]
+					tx = "(" + f.escape(utf8toutf16(tx, false)) + ")"
+					s.printf("%s ", tx)
+					if (i + 1) < numt {
+						s.printf("%.3f(%s) ", -shift, space)
+					}
 				}
 			}
 			s.printf("] TJ ET")
@@ -831,7 +880,11 @@ func (f *DocPDF) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 			}
 			bt := (f.x + dx) * k
 			td := (f.h - (f.y + dy + .5*h + .3*f.fontSize)) * k
-			s.printf("BT %.2f %.2f Td (%s)Tj ET", bt, td, txt2)
+			if f.kerning {
+				s.printf("BT %.2f %.2f Td [%s] TJ ET", bt, td, f.kernedText(txtStr, 0))
+			} else {
+				s.printf("BT %.2f %.2f Td (%s)Tj ET", bt, td, txt2)
+			}
 			//BT %.2F %.2F Td (%s) Tj ET',(f.x+dx)*k,(f.h-(f.y+.5*h+.3*f.FontSize))*k,txt2);
 		}
 
@@ -850,7 +903,14 @@ func (f *DocPDF) CellFormat(w, h float64, txtStr, borderStr string, ln int,
 	}
 	str := s.String()
 	if len(str) > 0 {
+		marked := f.markBegin(len(txtStr) > 0)
 		f.out(str)
+		if marked {
+			f.markEnd()
+		}
+	}
+	if f.flow != nil && !f.inHeader && !f.inFooter {
+		f.frameRow(h)
 	}
 	f.lasth = h
 	if ln > 0 {
@@ -916,6 +976,9 @@ func (f *DocPDF) SplitLines(txt []byte, w float64) [][]byte {
 	for i < nb {
 		c := s[i]
 		l += cw[c]
+		if i > j {
+			l += f.kern(rune(s[i-1]), rune(c))
+		}
 		if c == ' ' || c == '\t' || c == '\n' {
 			sep = i
 		}
@@ -970,10 +1033,14 @@ func (f *DocPDF) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fil
 		return
 	}
 	// dbg("MultiCell")
+	if f.tagAuto("P", "") {
+		defer f.tagPop()
+	}
 	if alignStr == "" {
 		alignStr = "J"
 	}
 	cw := f.currentFont.Cw
+	fitFrame := w == 0 && f.flow != nil
 	if w == 0 {
 		w = f.w - f.rMargin - f.x
 	}
@@ -1028,6 +1095,16 @@ func (f *DocPDF) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fil
 			}
 		}
 	}
+	if f.paragraphLayout != nil {
+		if !f.isCurrentUTF8 {
+			srune = make([]rune, len(s))
+			for i := range s {
+				srune[i] = rune(s[i])
+			}
+		}
+		f.multiCellLayout(w, h, srune, borderStr, b, b2, alignStr, fill)
+		return
+	}
 	sep := -1
 	i := 0
 	j := 0
@@ -1072,6 +1149,11 @@ func (f *DocPDF) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fil
 			if len(borderStr) > 0 && nl == 2 {
 				b = b2
 			}
+			if fitFrame {
+				// the paragraph may have flowed into a frame of another width
+				w = f.w - f.rMargin - f.x
+				wmax = int(math.Ceil((w - 2*f.cMargin) * 1000 / f.fontSize))
+			}
 			continue
 		}
 		if c == ' ' || isChinese(c) {
@@ -1083,11 +1165,20 @@ func (f *DocPDF) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fil
 			f.err = fmt.Errorf("character outside the supported range: %s", string(c))
 			return
 		}
-		if cw[int(c)] == 0 { //Marker width 0 used for missing symbols
+		if fw, ok := f.fallbackWidth(c); ok {
+			l += fw
+		} else if cw[int(c)] == 0 { //Marker width 0 used for missing symbols
 			l += f.currentFont.Desc.MissingWidth
 		} else if cw[int(c)] != 65535 { //Marker width 65535 used for zero width symbols
 			l += cw[int(c)]
 		}
+		if i > j {
+			if f.isCurrentUTF8 {
+				l += f.kern(srune[i-1], c)
+			} else {
+				l += f.kern(rune(s[i-1]), c)
+			}
+		}
 		if l > wmax {
 			// Automatic line break
 			if sep == -1 {
@@ -1129,6 +1220,11 @@ func (f *DocPDF) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fil
 			if len(borderStr) > 0 && nl == 2 {
 				b = b2
 			}
+			if fitFrame {
+				// the paragraph may have flowed into a frame of another width
+				w = f.w - f.rMargin - f.x
+				wmax = int(math.Ceil((w - 2*f.cMargin) * 1000 / f.fontSize))
+			}
 		} else {
 			i++
 		}
@@ -1159,6 +1255,9 @@ func (f *DocPDF) MultiCell(w, h float64, txtStr, borderStr, alignStr string, fil
 // write outputs text in flowing mode
 func (f *DocPDF) write(h float64, txtStr string, link int, linkStr string) {
 	// dbg("Write")
+	if f.tagAuto("P", "") {
+		defer f.tagPop()
+	}
 	cw := f.currentFont.Cw
 	w := f.w - f.rMargin - f.x
 	wmax := (w - 2*f.cMargin) * 1000 / f.fontSize
@@ -1197,7 +1296,7 @@ func (f *DocPDF) write(h float64, txtStr string, link int, linkStr string) {
 			sep = -1
 			j = i
 			l = 0.0
-			if nl == 1 {
+			if nl == 1 || f.flow != nil {
 				f.x = f.lMargin
 				w = f.w - f.rMargin - f.x
 				wmax = (w - 2*f.cMargin) * 1000 / f.fontSize
@@ -1208,7 +1307,18 @@ func (f *DocPDF) write(h float64, txtStr string, link int, linkStr string) {
 		if c == ' ' {
 			sep = i
 		}
-		l += float64(cw[int(c)])
+		if fw, ok := f.fallbackWidth(c); ok {
+			l += float64(fw)
+		} else {
+			l += float64(cw[int(c)])
+		}
+		if i > j {
+			if f.isCurrentUTF8 {
+				l += float64(f.kern([]rune(s)[i-1], c))
+			} else {
+				l += float64(f.kern(rune(s[i-1]), c))
+			}
+		}
 		if l > wmax {
 			// Automatic line break
 			if sep == -1 {
@@ -1241,7 +1351,7 @@ func (f *DocPDF) write(h float64, txtStr string, link int, linkStr string) {
 			sep = -1
 			j = i
 			l = 0.0
-			if nl == 1 {
+			if nl == 1 || f.flow != nil {
 				f.x = f.lMargin
 				w = f.w - f.rMargin - f.x
 				wmax = (w - 2*f.cMargin) * 1000 / f.fontSize
@@ -1429,6 +1539,7 @@ func (f *DocPDF) imageOut(info *ImageInfoType, x, y, w, h float64, allowNegative
 	// q 85.04 0 0 NaN 28.35 NaN cm /I2 Do Q
 	// f.outf("q %.5f 0 0 %.5f %.5f %.5f cm /I%s Do Q", w*f.k, h*f.k, x*f.k, (f.h-(y+h))*f.k, info.i)
 	const prec = 5
+	marked := f.markBegin(true)
 	f.put("q ")
 	f.putF64(w*f.k, prec)
 	f.put(" 0 0 ")
@@ -1438,6 +1549,9 @@ func (f *DocPDF) imageOut(info *ImageInfoType, x, y, w, h float64, allowNegative
 	f.put(" ")
 	f.putF64((f.h-(y+h))*f.k, prec)
 	f.put(" cm /I" + info.i + " Do Q\n")
+	if marked {
+		f.markEnd()
+	}
 	if link > 0 || len(linkStr) > 0 {
 		f.newLink(x, y, w, h, link, linkStr)
 	}
@@ -1499,6 +1613,9 @@ func (f *DocPDF) ImageOptions(imageNameStr string, x, y, w, h float64, flow bool
 	if f.err != nil {
 		return
 	}
+	if f.tagAuto("Figure", options.AltText) {
+		defer f.tagPop()
+	}
 	f.imageOut(info, x, y, w, h, options.AllowNegativePosition, flow, link, linkStr)
 }
 
@@ -1529,10 +1646,14 @@ func (f *DocPDF) RegisterImageReader(imgName, tp string, r io.Reader) (info *Ima
 //
 // AllowNegativePosition can be set to true in order to prevent the default
 // coercion of negative x values to the current x position.
+//
+// AltText is the alternate description of the image read by assistive
+// technology when the document is tagged. See SetTagged().
 type ImageOptions struct {
 	ImageType             string
 	ReadDpi               bool
 	AllowNegativePosition bool
+	AltText               string
 }
 
 // RegisterImageOptionsReader registers an image, reading it from Reader r, adding it
@@ -1608,12 +1729,11 @@ func (f *DocPDF) RegisterImageOptions(fileStr string, options ImageOptions) (inf
 		return
 	}
 
-	file, err := os.Open(fileStr)
+	data, err := f.readFile(fileStr)
 	if err != nil {
 		f.err = err
 		return
 	}
-	defer file.Close()
 
 	// First use of this image, get info
 	if options.ImageType == "" {
@@ -1625,7 +1745,7 @@ func (f *DocPDF) RegisterImageOptions(fileStr string, options ImageOptions) (inf
 		options.ImageType = fileStr[pos+1:]
 	}
 
-	return f.RegisterImageOptionsReader(fileStr, options, file)
+	return f.RegisterImageOptionsReader(fileStr, options, bytes.NewReader(data))
 }
 
 // GetImageInfo returns information about the registered image specified by
@@ -1742,9 +1862,7 @@ func (f *DocPDF) escape(s string) string {
 // textstring formats a text string
 func (f *DocPDF) textstring(s string) string {
 	if f.protect.encrypted {
-		b := []byte(s)
-		f.protect.rc4(uint32(f.n), &b)
-		s = string(b)
+		s = string(f.protect.encrypt(uint32(f.n), []byte(s)))
 	}
 	return "(" + f.escape(s) + ")"
 }
@@ -1876,7 +1994,7 @@ func (f *DocPDF) newobj() {
 func (f *DocPDF) putstream(b []byte) {
 	// dbg("putstream")
 	if f.protect.encrypted {
-		f.protect.rc4(uint32(f.n), &b)
+		b = f.protect.encrypt(uint32(f.n), b)
 	}
 	f.out("stream")
 	f.out(string(b))
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -911,6 +912,151 @@ func Test_LinearGradient(t *testing.T) {
 	// Successfully generated pdf/Test_LinearGradient_gradient.pdf
 }
 
+// Test_LinearGradientStops demonstrates gradients of several colors in RGB,
+// CMYK and spot colors, gradient fills of arbitrary shapes and a Coons patch
+// mesh.
+func Test_LinearGradientStops(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddSpotColor("PANTONE 145 CVC", 0, 42, 100, 25)
+	pdf.AddPage()
+	rainbow := docpdf.GradientType{Stops: []docpdf.GradientStopType{
+		{Offset: 0, Color: docpdf.GradientRGB(228, 3, 3)},
+		{Offset: 0.2, Color: docpdf.GradientRGB(255, 140, 0)},
+		{Offset: 0.4, Color: docpdf.GradientRGB(255, 237, 0)},
+		{Offset: 0.6, Color: docpdf.GradientRGB(0, 128, 38)},
+		{Offset: 0.8, Color: docpdf.GradientRGB(36, 64, 142)},
+		{Offset: 1, Color: docpdf.GradientRGB(115, 41, 130)},
+	}, ExtendStart: true, ExtendEnd: true}
+	pdf.LinearGradientStops(10, 10, 190, 30, rainbow, 0, 0, 1, 0)
+	// Without extension, the area beyond the vector is left unpainted
+	ink := docpdf.GradientType{Stops: []docpdf.GradientStopType{
+		{Offset: 0, Color: docpdf.GradientCMYK(100, 0, 0, 0)},
+		{Offset: 0.5, Color: docpdf.GradientCMYK(0, 100, 0, 0)},
+		{Offset: 1, Color: docpdf.GradientCMYK(0, 0, 100, 0)},
+	}}
+	pdf.LinearGradientStops(10, 45, 90, 40, ink, 0.2, 0, 0.8, 0)
+	pdf.Rect(10, 45, 90, 40, "D")
+	spot := docpdf.GradientType{Stops: []docpdf.GradientStopType{
+		{Offset: 0, Color: docpdf.GradientSpot("PANTONE 145 CVC", 10)},
+		{Offset: 1, Color: docpdf.GradientSpot("PANTONE 145 CVC", 100)},
+	}, ExtendStart: true, ExtendEnd: true}
+	pdf.RadialGradientStops(110, 45, 90, 40, spot, 0.5, 0.5, 0.5, 0.5, 0.5)
+	pdf.Rect(110, 45, 90, 40, "D")
+
+	// Gradient fills of shapes and paths
+	pdf.SetFillLinearGradient(10, 95, 90, 60, rainbow, 0, 1, 1, 0)
+	pdf.Polygon([]docpdf.PointType{{X: 55, Y: 95}, {X: 100, Y: 155}, {X: 10, Y: 155}}, "FD")
+	pdf.SetFillRadialGradient(110, 95, 90, 60, ink, 0.5, 0.5, 0.5, 0.5, 0.5)
+	pdf.MoveTo(110, 125)
+	pdf.CurveTo(155, 65, 200, 125)
+	pdf.CurveTo(155, 185, 110, 125)
+	pdf.ClosePath()
+	pdf.DrawPath("DF")
+	pdf.SetFillColor(255, 255, 255)
+	pdf.Rect(145, 120, 20, 10, "F")
+
+	// Mesh of two patches sharing a side
+	patch := func(x float64, clrs [4]docpdf.GradientColorType) docpdf.CoonsPatchType {
+		return docpdf.CoonsPatchType{Points: [12]docpdf.PointType{
+			{X: x, Y: 260}, {X: x - 10, Y: 230}, {X: x + 10, Y: 200}, {X: x, Y: 170},
+			{X: x + 30, Y: 160}, {X: x + 60, Y: 180}, {X: x + 90, Y: 170},
+			{X: x + 80, Y: 200}, {X: x + 100, Y: 230}, {X: x + 90, Y: 260},
+			{X: x + 60, Y: 250}, {X: x + 30, Y: 270}}, Colors: clrs}
+	}
+	red, yellow := docpdf.GradientRGB(220, 20, 60), docpdf.GradientRGB(255, 215, 0)
+	blue, green := docpdf.GradientRGB(30, 144, 255), docpdf.GradientRGB(46, 139, 87)
+	pdf.CoonsPatchGradient([]docpdf.CoonsPatchType{
+		patch(10, [4]docpdf.GradientColorType{red, yellow, blue, green}),
+		patch(100, [4]docpdf.GradientColorType{green, blue, red, yellow}),
+	})
+	pdf.SetCompression(false)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, str := range []string{"/FunctionType 3", "/Bounds [0.20000 0.40000 0.60000 0.80000]",
+		"/ColorSpace /DeviceCMYK", "/C0 [1.000 0.000 0.000 0.000]", "/Extend [false false]",
+		"/Extend [true true]", "/C0 [0.100] /C1 [1.000]", "/PatternType 2", "/Pattern cs /P2 scn",
+		"/ShadingType 6"} {
+		if !strings.Contains(out, str) {
+			t.Errorf("%q not found in output", str)
+		}
+	}
+	fileStr := Filename("Test_LinearGradientStops")
+	err := os.WriteFile(fileStr, buf.Bytes(), 0644)
+	SummaryCompare(err, fileStr)
+
+	pdf = NewDocPdfTest()
+	pdf.AddPage()
+	pdf.LinearGradientStops(10, 10, 100, 20, docpdf.GradientType{Stops: []docpdf.GradientStopType{
+		{Color: docpdf.GradientRGB(0, 0, 0)}, {Offset: 1, Color: docpdf.GradientCMYK(0, 0, 0, 100)},
+	}}, 0, 0, 1, 0)
+	if pdf.Error() == nil {
+		t.Errorf("gradient of mixed color spaces accepted")
+	}
+}
+
+// Test_SetFillPattern demonstrates hatch fills and a tiling pattern drawn
+// with a template.
+func Test_SetFillPattern(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Helvetica", "B", 48)
+	pdf.AddPage()
+	pdf.SetDrawColor(0, 0, 0)
+	styles := []string{"/", "\\", "-", "|", "+", "x", "."}
+	ids := make([]int, len(styles))
+	for j, styleStr := range styles {
+		ids[j] = pdf.AddHatchPattern(styleStr, 3, 0.3)
+	}
+	// Bars of a chart series printed in greyscale
+	for j, id := range ids {
+		x := 15 + float64(j)*26
+		ht := 20 + float64(j)*8
+		pdf.SetFillPattern(id)
+		pdf.Rect(x, 90-ht, 20, ht, "FD")
+	}
+	checker := pdf.AddTilingPattern(10, 10, func(tpl *docpdf.Tpl) {
+		tpl.SetFillColor(200, 220, 255)
+		tpl.Rect(0, 0, 5, 5, "F")
+		tpl.Rect(5, 5, 5, 5, "F")
+		tpl.SetFillColor(30, 60, 160)
+		tpl.Circle(7.5, 2.5, 1.5, "F")
+	})
+	pdf.SetFillPattern(checker)
+	pdf.Circle(50, 130, 30, "FD")
+	pdf.Polygon([]docpdf.PointType{{X: 110, Y: 100}, {X: 190, Y: 100}, {X: 150, Y: 160}}, "FD")
+	pdf.SetTextPattern(ids[5])
+	pdf.SetXY(15, 175)
+	pdf.CellFormat(180, 25, "Hatched text", "", 1, "C", false, 0, "")
+	pdf.SetFillColor(255, 255, 255)
+	pdf.SetFillPattern(99)
+	if pdf.Error() == nil {
+		t.Fatalf("undefined pattern accepted")
+	}
+	pdf.ClearError()
+	pdf.AddHatchPattern("#", 3, 0.3)
+	if pdf.Error() == nil {
+		t.Fatalf("unknown hatch style accepted")
+	}
+	pdf.ClearError()
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, str := range []string{"/PatternType 1 /PaintType 1 /TilingType 1", "/XStep 28.34646 /YStep 28.34646",
+		"/Pattern cs /P8 scn", "/Pattern cs /P6 scn", "1 J"} {
+		if !strings.Contains(out, str) {
+			t.Errorf("%q not found in output", str)
+		}
+	}
+	fileStr := Filename("Test_SetFillPattern")
+	err := os.WriteFile(fileStr, buf.Bytes(), 0644)
+	SummaryCompare(err, fileStr)
+}
+
 // Test_ClipText demonstrates clipping.
 func Test_ClipText(t *testing.T) {
 	pdf := NewDocPdfTest()
@@ -1368,6 +1514,82 @@ func Test_SVGBasicDraw(t *testing.T) {
 	// Successfully generated pdf/Test_SVGBasicDraw.pdf
 }
 
+// Test_SVGWrite demonstrates how to render an SVG image with groups,
+// transformations, styles, text and gradients.
+func Test_SVGWrite(t *testing.T) {
+	const svgStr = `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"
+	width="400" height="300" viewBox="0 0 200 150">
+	<style>
+		.outline { fill: none; stroke: navy; stroke-width: 2 }
+		#dashed { stroke-dasharray: 4 2 }
+	</style>
+	<defs>
+		<linearGradient id="sunset" x1="0" y1="0" x2="1" y2="0">
+			<stop offset="0" stop-color="gold"/>
+			<stop offset="0.5" stop-color="orangered"/>
+			<stop offset="1" stop-color="#4b0082"/>
+		</linearGradient>
+		<radialGradient id="glow" cx="0.5" cy="0.5" r="0.5" fx="0.3" fy="0.3">
+			<stop offset="0" stop-color="white"/>
+			<stop offset="1" stop-color="steelblue"/>
+		</radialGradient>
+		<symbol id="star" viewBox="0 0 10 10">
+			<polygon points="5,0 6.2,3.8 10,3.8 7,6.2 8,10 5,7.6 2,10 3,6.2 0,3.8 3.8,3.8"/>
+		</symbol>
+	</defs>
+	<rect x="5" y="5" width="190" height="40" rx="6" fill="url(#sunset)"/>
+	<g transform="translate(40 80) rotate(-15)" opacity="0.8">
+		<circle r="25" fill="url(#glow)" class="outline" stroke-opacity="0.5"/>
+		<ellipse cx="60" rx="25" ry="12" fill="seagreen" fill-opacity="0.6"/>
+	</g>
+	<line id="dashed" class="outline" x1="10" y1="120" x2="190" y2="120" stroke-linecap="round"/>
+	<polyline class="outline" points="110,60 130,90 150,60 170,90" stroke-linejoin="round"/>
+	<path d="M150 140 a20 15 0 1 1 30 -10 Q 190 145 170 145 z" fill="tomato" fill-rule="evenodd"/>
+	<use xlink:href="#star" x="100" y="95" width="20" height="20" fill="purple"/>
+	<text x="100" y="30" font-family="serif" font-size="14" text-anchor="middle" fill="white">SVG <tspan font-weight="bold">rendering</tspan></text>
+</svg>`
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	svg, err := docpdf.SVGParse([]byte(svgStr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svg.Wd != 300 || svg.Ht != 225 {
+		t.Fatalf("unexpected size %.2f x %.2f", svg.Wd, svg.Ht)
+	}
+	pdf.SVGWrite(&svg, 10, 10, 160, 0)
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(0.2)
+	pdf.Rect(10, 10, 160, 120, "D")
+	logo, logoErr := pdf.SVGFileParse(ImageFile("mit.svg"))
+	if logoErr == nil {
+		pdf.SVGWrite(&logo, 10, 140, 60, 0)
+	} else {
+		pdf.SetError(logoErr)
+	}
+	pdf.SetCompression(false)
+	var buf bytes.Buffer
+	if err = pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, str := range []string{" cm\n", "/Sh1 sh", "/Sh2 sh", "/FunctionType 3", "[56.69 28.35] 0.00 d",
+		"(SVG ) Tj", "(rendering) Tj", "/ca 0.480", "/CA 0.400"} {
+		if !strings.Contains(out, str) {
+			t.Errorf("%q not found in output", str)
+		}
+	}
+	fileStr := Filename("Test_SVGWrite")
+	err = os.WriteFile(fileStr, buf.Bytes(), 0644)
+	SummaryCompare(err, fileStr)
+
+	if _, err = docpdf.SVGParse([]byte("<html><body></body></html>")); err == nil {
+		t.Errorf("document without svg element accepted")
+	}
+}
+
 // Test_CellFormat_align demonstrates Stefan Schroeder's code to control vertical
 // alignment.
 func Test_CellFormat_align(t *testing.T) {
@@ -1511,6 +1733,35 @@ func Test_SetProtection(t *testing.T) {
 	// Successfully generated pdf/Test_SetProtection.pdf
 }
 
+// Test_SetProtectionAES demonstrates AES-128 and AES-256 encryption of a
+// document with bookmarks, metadata, an attachment and a form field.
+func Test_SetProtectionAES(t *testing.T) {
+	for _, name := range []string{"Test_SetProtectionAES128", "Test_SetProtectionAES256"} {
+		pdf := NewDocPdfTest()
+		flags := docpdf.CnProtectPrint | docpdf.CnProtectPrintHighRes |
+			docpdf.CnProtectFillForms | docpdf.CnProtectExtract
+		if name == "Test_SetProtectionAES128" {
+			pdf.SetProtectionAES128(flags, "123", "abc")
+		} else {
+			pdf.SetProtectionAES256(flags, "123", "abc")
+		}
+		pdf.SetTitle("Encrypted document", true)
+		pdf.SetXmpMetadata([]byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"></x:xmpmeta>`))
+		pdf.SetAttachments([]docpdf.Attachment{{Content: []byte("attached"), Filename: "note.txt"}})
+		pdf.AddPage()
+		pdf.SetFont("Arial", "", 12)
+		pdf.Bookmark("Introduction", 0, 0)
+		pdf.Write(10, "Password-protected (AES).")
+		pdf.AddTextField("name", 10, 30, 80, 8, docpdf.FormFieldOptions{Value: "Jane", Border: true})
+		fileStr := Filename(name)
+		err := pdf.OutputFileAndClose(fileStr)
+		SummaryCompare(err, fileStr)
+	}
+	// Output:
+	// Successfully generated pdf/Test_SetProtectionAES128.pdf
+	// Successfully generated pdf/Test_SetProtectionAES256.pdf
+}
+
 // Test_Polygon displays equilateral polygons in a demonstration of the Polygon
 // function.
 func Test_Polygon(t *testing.T) {
@@ -2205,6 +2456,141 @@ func Test_Grid(t *testing.T) {
 	// Successfully generated pdf/Test_Grid.pdf
 }
 
+// Test_NewChart demonstrates the chart builders based on GridType.
+func Test_NewChart(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Arial", "", 12)
+	pdf.AddPage()
+
+	months := []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun"}
+	kpi := docpdf.ChartDataType{
+		Title:      "Monthly revenue",
+		XTitle:     "Month",
+		YTitle:     "Revenue (k$)",
+		Categories: months,
+		Series: []docpdf.ChartSeriesType{
+			{Name: "North", Values: []float64{12, 15, 14, 18, 21, 19}},
+			{Name: "South", Values: []float64{8, 9, 11, 10, 12, 15}},
+			{Name: "West", Values: []float64{5, 7, 6, 9, 8, 11}},
+		},
+	}
+	chart := docpdf.NewChart(10, 10, 90, 80)
+	chart.ValueLabels = true
+	chart.Bar(pdf, kpi)
+	chart = docpdf.NewChart(110, 10, 90, 80)
+	chart.StackedBar(pdf, kpi)
+	chart = docpdf.NewChart(10, 100, 90, 80)
+	chart.Markers = true
+	chart.Line(pdf, kpi)
+	chart = docpdf.NewChart(110, 100, 90, 80)
+	chart.Area(pdf, kpi)
+	chart = docpdf.NewChart(10, 190, 90, 90)
+	chart.ValueLabels = true
+	chart.Pie(pdf, docpdf.ChartDataType{
+		Title:      "Market share",
+		Categories: []string{"Alpha", "Beta", "Gamma", "Delta"},
+		Series:     []docpdf.ChartSeriesType{{Values: []float64{45, 25, 20, 10}}},
+	})
+	chart = docpdf.NewChart(110, 190, 90, 90)
+	chart.ValueLabels = true
+	chart.Donut(pdf, docpdf.ChartDataType{
+		Title:      "Budget",
+		Categories: []string{"Staff", "Rent", "Travel", "Other"},
+		Series:     []docpdf.ChartSeriesType{{Values: []float64{60, 20, 12, 8}}},
+	})
+	pdf.AddPage()
+
+	var xs, ys, obs []float64
+	for j := 0; j < 40; j++ {
+		x := float64(j) / 4
+		xs = append(xs, x)
+		ys = append(ys, math.Sin(x)*10+x)
+		obs = append(obs, 50+20*math.Sin(float64(j*j)))
+	}
+	chart = docpdf.NewChart(10, 10, 190, 120)
+	chart.Scatter(pdf, docpdf.ChartDataType{
+		Title:  "Scatter",
+		XTitle: "x",
+		YTitle: "y",
+		Series: []docpdf.ChartSeriesType{{Name: "Measured", X: xs, Values: ys}},
+	})
+	chart = docpdf.NewChart(10, 140, 190, 120)
+	chart.ValueLabels = true
+	chart.GridFnc = func(grid *docpdf.GridType) {
+		grid.YDiv = 2
+	}
+	chart.Histogram(pdf, docpdf.ChartDataType{
+		Title:  "Response times",
+		XTitle: "Milliseconds",
+		YTitle: "Requests",
+		Series: []docpdf.ChartSeriesType{{Name: "Requests", Values: obs}},
+	})
+
+	fileStr := Filename("Test_NewChart")
+	err := pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Test_NewChart.pdf
+}
+
+// Test_NewChart_nonFinite verifies that NaN and infinite values set the
+// error state of the document instead of panicking.
+func Test_NewChart_nonFinite(t *testing.T) {
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		data := docpdf.ChartDataType{
+			Categories: []string{"A", "B"},
+			Series:     []docpdf.ChartSeriesType{{Name: "Bad", Values: []float64{1, v}}},
+		}
+		for _, draw := range []func(c docpdf.ChartType, pdf *docpdf.DocPDF, data docpdf.ChartDataType){
+			docpdf.ChartType.Bar, docpdf.ChartType.StackedBar, docpdf.ChartType.Line,
+			docpdf.ChartType.Scatter, docpdf.ChartType.Area, docpdf.ChartType.Histogram,
+			docpdf.ChartType.Pie, docpdf.ChartType.Donut,
+		} {
+			pdf := NewDocPdfTest()
+			pdf.SetFont("Arial", "", 12)
+			pdf.AddPage()
+			draw(docpdf.NewChart(10, 10, 90, 80), pdf, data)
+			if err := pdf.Error(); err == nil || !strings.Contains(err.Error(), "not a finite number") {
+				t.Errorf("value %v: expected error, got %v", v, err)
+			}
+		}
+	}
+
+	// Finite values whose range, or stacked sum, overflows, and values too
+	// close to each other to be divided into tickmarks
+	for _, c := range []struct {
+		name   string
+		series []docpdf.ChartSeriesType
+		draws  []func(c docpdf.ChartType, pdf *docpdf.DocPDF, data docpdf.ChartDataType)
+	}{
+		{"range", []docpdf.ChartSeriesType{{Values: []float64{1e308, -1e308}}},
+			[]func(c docpdf.ChartType, pdf *docpdf.DocPDF, data docpdf.ChartDataType){
+				docpdf.ChartType.Bar, docpdf.ChartType.StackedBar, docpdf.ChartType.Histogram,
+				docpdf.ChartType.Line, docpdf.ChartType.Scatter, docpdf.ChartType.Area}},
+		{"maximum", []docpdf.ChartSeriesType{{Values: []float64{math.MaxFloat64, -math.MaxFloat64}}},
+			[]func(c docpdf.ChartType, pdf *docpdf.DocPDF, data docpdf.ChartDataType){
+				docpdf.ChartType.Bar, docpdf.ChartType.StackedBar, docpdf.ChartType.Histogram}},
+		{"sum", []docpdf.ChartSeriesType{{Values: []float64{1e308, 1e308}}, {Values: []float64{1e308}}},
+			[]func(c docpdf.ChartType, pdf *docpdf.DocPDF, data docpdf.ChartDataType){
+				docpdf.ChartType.StackedBar, docpdf.ChartType.Histogram, docpdf.ChartType.Area}},
+		{"precision", []docpdf.ChartSeriesType{{Values: []float64{1, math.Nextafter(1, 2)}}},
+			[]func(c docpdf.ChartType, pdf *docpdf.DocPDF, data docpdf.ChartDataType){
+				docpdf.ChartType.Line, docpdf.ChartType.Scatter}},
+	} {
+		for _, draw := range c.draws {
+			pdf := NewDocPdfTest()
+			pdf.SetFont("Arial", "", 12)
+			pdf.AddPage()
+			chart := docpdf.NewChart(10, 10, 90, 80)
+			chart.ValueLabels = true
+			draw(chart, pdf, docpdf.ChartDataType{Series: c.series})
+			if err := pdf.Error(); err == nil || !strings.Contains(err.Error(), "cannot be") {
+				t.Errorf("%s: expected error, got %v", c.name, err)
+			}
+		}
+	}
+}
+
 // Test_SetPageBox demonstrates the use of a page box
 func Test_SetPageBox(t *testing.T) {
 	// pdfinfo (from http://www.xpdfreader.com) reports the following for this example:
@@ -2745,6 +3131,183 @@ func Test_AddAttachmentAnnotation(t *testing.T) {
 	// Successfully generated pdf/Test_FileAnnotations.pdf
 }
 
+// Test_AddFormFields demonstrates a fillable form with text fields, check
+// boxes, a radio group, combo and list boxes and a submit button.
+func Test_AddFormFields(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.AddPage()
+	pdf.SetDrawColor(120, 120, 120)
+	pdf.SetFillColor(240, 244, 250)
+	pdf.SetLineWidth(0.3)
+	box := docpdf.FormFieldOptions{Border: true, Fill: true}
+	label := func(y float64, txt string) {
+		pdf.SetXY(20, y)
+		pdf.CellFormat(40, 8, txt, "", 0, "LM", false, 0, "")
+	}
+	label(20, "Name")
+	opts := box
+	opts.Required = true
+	opts.ToolTip = "Full legal name"
+	opts.TabOrder = 1
+	pdf.AddTextField("name", 60, 20, 100, 8, opts)
+	label(32, "Employee ID")
+	opts = box
+	opts.Value = "E-0042"
+	opts.ReadOnly = true
+	opts.TabOrder = 3
+	pdf.AddTextField("id", 60, 32, 40, 8, opts)
+	label(44, "Email")
+	opts = box
+	opts.MaxLen = 40
+	opts.TabOrder = 2
+	pdf.AddTextField("email", 60, 44, 100, 8, opts)
+	label(56, "Notes")
+	opts = box
+	opts.Multiline = true
+	opts.Value = "First line\nSecond line"
+	opts.TabOrder = 4
+	pdf.AddTextField("notes", 60, 56, 100, 20, opts)
+	label(82, "Department")
+	opts = box
+	opts.Value = "Engineering"
+	opts.Default = "Engineering"
+	pdf.AddComboBox("department", 60, 82, 60, 8, []string{"Engineering", "Finance", "Sales"}, opts)
+	label(94, "Office")
+	opts = box
+	opts.Value = "Lisbon"
+	pdf.AddListBox("office", 60, 94, 60, 20, []string{"Berlin", "Lisbon", "Toronto"}, opts)
+	label(120, "Contract")
+	for j, val := range []string{"Full time", "Part time", "Contractor"} {
+		opts = box
+		opts.Default = "Full time"
+		pdf.AddRadioButton("contract", val, 60+35*float64(j), 121, 6, j == 0, opts)
+		pdf.SetXY(68+35*float64(j), 120)
+		pdf.CellFormat(25, 8, val, "", 0, "LM", false, 0, "")
+	}
+	label(132, "Remote")
+	pdf.AddCheckBox("remote", 60, 133, 6, true, box)
+	opts = box
+	opts.SubmitURL = "https://example.com/onboarding"
+	pdf.SetFillColor(200, 215, 235)
+	pdf.AddPushButton("submit", "Submit", 60, 148, 40, 10, opts)
+	pdf.AddTextField("name", 60, 170, 40, 8, box)
+	if err := pdf.Error(); err == nil {
+		t.Errorf("duplicate field name was accepted")
+	}
+	pdf.ClearError()
+	fileStr := Filename("Test_AddFormFields")
+	err := pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Test_AddFormFields.pdf
+}
+
+// Test_SetTagged builds a tagged document with headings, paragraphs, a list,
+// a table, a figure and artifacts spread over several pages.
+func Test_SetTagged(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.SetCompression(false)
+	pdf.SetTagged(true)
+	pdf.SetLang("en-US")
+	pdf.SetTitle("Tagged report", true)
+	pdf.SetHeaderFunc(func() {
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 6, "Quarterly report", "B", 1, "R", false, 0, "")
+		pdf.Ln(4)
+	})
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.BeginTag("H1", "")
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.Cell(0, 10, "Quarterly report")
+	pdf.Ln(12)
+	pdf.EndTag()
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.MultiCell(0, 5, lorem(), "", "", false)
+	pdf.Ln(4)
+	pdf.Write(5, "This paragraph is written in flowing mode. ")
+	pdf.Ln(8)
+	pdf.ImageOptions(ImageFile("logo.png"), 10, pdf.GetY(), 30, 0, true,
+		docpdf.ImageOptions{AltText: "Company logo"}, 0, "")
+	pdf.BeginTag("Artifact", "")
+	pdf.Line(10, pdf.GetY()+2, 200, pdf.GetY()+2)
+	pdf.EndTag()
+	pdf.Ln(6)
+	pdf.BeginTag("L", "")
+	for _, item := range []string{"Revenue grew", "Costs fell"} {
+		pdf.BeginTag("LI", "")
+		pdf.BeginTag("Lbl", "")
+		pdf.Cell(6, 6, "-")
+		pdf.EndTag()
+		pdf.BeginTag("LBody", "")
+		pdf.Cell(0, 6, item)
+		pdf.EndTag()
+		pdf.EndTag()
+		pdf.Ln(6)
+	}
+	pdf.EndTag()
+	pdf.Ln(4)
+	pdf.BeginTag("Table", "")
+	for i := 0; i < 40; i++ {
+		pdf.BeginTag("TR", "")
+		for j := 0; j < 3; j++ {
+			role := "TD"
+			if i == 0 {
+				role = "TH"
+			}
+			pdf.BeginTag(role, "")
+			pdf.CellFormat(40, 7, fmt.Sprintf("R%dC%d", i, j), "1", 0, "C", false, 0, "")
+			pdf.EndTag()
+		}
+		pdf.EndTag()
+		pdf.Ln(-1)
+	}
+	pdf.EndTag()
+	pdf.BeginTag("P", "")
+	pdf.Cell(0, 6, "This paragraph continues")
+	pdf.AddPage()
+	pdf.Cell(0, 6, "on the next page.")
+	pdf.EndTag()
+	pdf.EndTag()
+	if err := pdf.Error(); err == nil {
+		t.Errorf("unmatched EndTag was accepted")
+	}
+	pdf.ClearError()
+	pdf.BeginTag("Paragraph", "")
+	if err := pdf.Error(); err == nil {
+		t.Errorf("unknown structure type was accepted")
+	}
+	pdf.ClearError()
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err == nil {
+		doc := buf.String()
+		open := strings.Count(doc, " BDC") + strings.Count(doc, " BMC")
+		if closed := strings.Count(doc, "EMC"); open != closed {
+			t.Errorf("%d marked-content sequences opened, %d closed", open, closed)
+		}
+		for _, s := range []string{"/StructTreeRoot", "/MarkInfo <</Marked true>>",
+			"/S /Figure", "/S /TH", "/Type /MCR", "/StructParents 1"} {
+			if !strings.Contains(doc, s) {
+				t.Errorf("%s not found in tagged document", s)
+			}
+		}
+		fileStr := Filename("Test_SetTagged")
+		err = os.WriteFile(fileStr, buf.Bytes(), 0644)
+		SummaryCompare(err, fileStr)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Output:
+	// Successfully generated pdf/Test_SetTagged.pdf
+}
+
 func Test_SetModificationDate(t *testing.T) {
 	// pdfinfo (from http://www.xpdfreader.com) reports the following for this example :
 	// ~ pdfinfo -box pdf/Test_PageBox.pdf
@@ -2859,3 +3422,1004 @@ func Test_AddOutputIntent(t *testing.T) {
 	// Output:
 	// Successfully generated pdf/Test_AddOutputIntent.pdf
 }
+
+// Test_SetDrawColorCMYK demonstrates colors specified with CMYK ink
+// components, gray levels and an ICC profile, and the conversion of RGB
+// colors and images to CMYK.
+func Test_SetDrawColorCMYK(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.AddPage()
+	pdf.SetLineWidth(1)
+	pdf.SetDrawColorCMYK(0, 100, 100, 0)
+	pdf.SetFillColorCMYK(100, 0, 0, 20)
+	pdf.Rect(10, 10, 40, 20, "FD")
+	pdf.SetTextColorCMYK(0, 0, 0, 100)
+	pdf.Text(10, 40, "Process colors")
+	if c, m, y, k := pdf.GetFillColorCMYK(); c != 100 || m != 0 || y != 0 || k != 20 {
+		t.Errorf("unexpected CMYK fill color %d %d %d %d", c, m, y, k)
+	}
+	pdf.SetDrawColorGray(64)
+	pdf.SetFillColorGray(220)
+	pdf.Rect(60, 10, 40, 20, "FD")
+	if r, g, b := pdf.GetFillColor(); r != 220 || g != 220 || b != 220 {
+		t.Errorf("unexpected gray fill color %d %d %d", r, g, b)
+	}
+	pdf.SetCMYKConversion(true)
+	pdf.SetFillColor(255, 0, 0)
+	pdf.Rect(110, 10, 40, 20, "F")
+	pdf.Image(ImageFile("logo.png"), 10, 50, 30, 0, false, "", 0, "")
+	pdf.Image(ImageFile("logo.jpg"), 50, 50, 30, 0, false, "", 0, "")
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, str := range []string{"0.000 1.000 1.000 0.000 K", "1.000 0.000 0.000 0.200 k", "0.251 G", "0.863 g",
+		"0.000 1.000 1.000 0.000 k", "/ColorSpace /DeviceCMYK"} {
+		if !strings.Contains(out, str) {
+			t.Errorf("%q not found in output", str)
+		}
+	}
+	if strings.Contains(out, "/ColorSpace /DeviceRGB") {
+		t.Errorf("RGB image not converted to CMYK")
+	}
+
+	iccBytes, err := os.ReadFile(ICCFile("sRGB2014.icc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf = NewDocPdfTest()
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.AddOutputIntent(docpdf.OutputIntentType{
+		SubtypeIdent:              docpdf.OutputIntent_GTS_PDFA1,
+		OutputConditionIdentifier: "sRGB IEC61966-2.1",
+		ICCProfile:                iccBytes,
+	})
+	pdf.SetICCBasedColors(true)
+	pdf.AddPage()
+	pdf.SetDrawColor(0, 0, 128)
+	pdf.SetFillColor(255, 200, 0)
+	pdf.Rect(10, 10, 40, 20, "FD")
+	pdf.SetTextColor(0, 128, 0)
+	pdf.Text(10, 40, "Colors of the sRGB profile")
+	pdf.Image(ImageFile("logo.png"), 10, 50, 30, 0, false, "", 0, "")
+	fileStr := Filename("Test_SetDrawColorCMYK")
+	err = pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	data, err := os.ReadFile(fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = string(data)
+	for _, str := range []string{"/ICC CS 0.000 0.000 0.502 SCN", "/ICC cs 1.000 0.784 0.000 scn",
+		"/N 3 /Alternate /DeviceRGB", "/ICC [/ICCBased"} {
+		if !strings.Contains(out, str) {
+			t.Errorf("%q not found in output", str)
+		}
+	}
+	// Output:
+	// Successfully generated pdf/Test_SetDrawColorCMYK.pdf
+}
+
+// Test_SetPDFAConformance demonstrates the output of PDF/A documents and the
+// rejection of the features they forbid.
+func Test_SetPDFAConformance(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.SetPDFAConformance("PDF/A-1b")
+	pdf.SetTitle("Archivo de prueba ñ & <PDF/A>", true)
+	pdf.SetAuthor("docpdf", false)
+	pdf.AddUTF8Font("dejavu", "", FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "", 14)
+	pdf.Cell(0, 10, "Documento archivable según PDF/A-1b")
+	pdf.Link(10, 10, 50, 10, pdf.AddLink())
+	fileStr := Filename("Test_SetPDFAConformance")
+	err := pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	data, err := os.ReadFile(fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, str := range []string{"<pdfaid:part>1</pdfaid:part>", "<pdfaid:conformance>B</pdfaid:conformance>",
+		"Archivo de prueba ñ &amp; &lt;PDF/A&gt;", "<xmp:CreateDate>2000-01-01T00:00:00Z</xmp:CreateDate>",
+		"/CreationDate (D:20000101000000+00'00')", "/S /GTS_PDFA1 /OutputConditionIdentifier (sRGB IEC61966-2.1)",
+		"/N 3 /Alternate /DeviceRGB", "/Metadata ", "/ID [<", "/F 4 "} {
+		if !strings.Contains(out, str) {
+			t.Errorf("%q not found in output", str)
+		}
+	}
+	if strings.Contains(out, "/S /Transparency") {
+		t.Errorf("transparency group in PDF/A-1b output")
+	}
+
+	for _, c := range []struct {
+		level string
+		fn    func(pdf *docpdf.DocPDF)
+		errs  string
+	}{
+		{"PDF/A-2b", func(pdf *docpdf.DocPDF) { pdf.SetFont("Helvetica", "", 12) }, "font Helvetica is not embedded"},
+		{"PDF/A-2b", func(pdf *docpdf.DocPDF) { pdf.SetProtection(0, "", "owner") }, "forbids encryption"},
+		{"PDF/A-3b", func(pdf *docpdf.DocPDF) { pdf.SetJavascript("print(true);") }, "forbids JavaScript"},
+		{"PDF/A-2b", func(pdf *docpdf.DocPDF) {
+			pdf.AddPushButton("alert", "Alert", 10, 30, 30, 10, docpdf.FormFieldOptions{JavaScript: "app.alert(1)"})
+		}, "form field alert runs a script"},
+		{"PDF/A-1b", func(pdf *docpdf.DocPDF) { pdf.AddLayer("Notes", true) }, "layer Notes is defined"},
+		{"PDF/A-1b", func(pdf *docpdf.DocPDF) { pdf.SetAlpha(0.5, "Normal") }, "forbids transparency"},
+		{"PDF/A-1b", func(pdf *docpdf.DocPDF) {
+			pdf.SetAttachments([]docpdf.Attachment{{Content: []byte("data"), Filename: "data.txt"}})
+		}, "forbids attachments"},
+		{"PDF/A-2b", func(pdf *docpdf.DocPDF) {
+			pdf.SetAttachments([]docpdf.Attachment{{Content: []byte("data"), Filename: "data.txt"}})
+		}, "data.txt is not a PDF file"},
+	} {
+		pdf = NewDocPdfTest()
+		pdf.SetPDFAConformance(c.level)
+		pdf.AddUTF8Font("dejavu", "", FontFile("DejaVuSansCondensed.ttf"))
+		pdf.SetFont("dejavu", "", 14)
+		pdf.AddPage()
+		c.fn(pdf)
+		pdf.Cell(0, 10, "PDF/A")
+		var buf bytes.Buffer
+		err = pdf.Output(&buf)
+		if err == nil || !strings.Contains(err.Error(), c.errs) {
+			t.Errorf("%s: expected error %q, got %v", c.level, c.errs, err)
+		}
+	}
+	pdf = NewDocPdfTest()
+	pdf.SetPDFAConformance("PDF/A-4")
+	if pdf.Error() == nil {
+		t.Errorf("unsupported conformance level accepted")
+	}
+
+	// Factur-X style invoice with its data attached
+	pdf = NewDocPdfTest()
+	pdf.SetPDFAConformance("PDF/A-3b")
+	pdf.AddUTF8Font("dejavu", "", FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "", 14)
+	pdf.SetAlpha(0.5, "Multiply")
+	pdf.Cell(0, 10, "Invoice 42")
+	pdf.SetAttachments([]docpdf.Attachment{{Content: []byte("<invoice id=\"42\"/>"), Filename: "invoice.xml",
+		MimeType: "text/xml", Relationship: "Data"}})
+	var buf bytes.Buffer
+	if err = pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out = buf.String()
+	for _, str := range []string{"<pdfaid:part>3</pdfaid:part>", "/AFRelationship /Data", "/Subtype /text#2Fxml",
+		"/ModDate (D:20000101000000+00'00')", "/AF [", "%PDF-1.7"} {
+		if !strings.Contains(out, str) {
+			t.Errorf("%q not found in output", str)
+		}
+	}
+	// Output:
+	// Successfully generated pdf/Test_SetPDFAConformance.pdf
+}
+
+// Test_ResourceFS demonstrates reading fonts, images, SVG files, code page
+// maps and attachments from an fs.FS such as an embed.FS.
+func Test_ResourceFS(t *testing.T) {
+	pdf := NewDocPdfTest(os.DirFS(string(rootTestDir)))
+	pdf.AddUTF8Font("dejavu", "", "DejaVuSansCondensed.ttf")
+	pdf.AddFont("Calligrapher", "", "calligra.json")
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "", 14)
+	pdf.Cell(0, 10, "Resources read from an fs.FS")
+	pdf.Ln(10)
+	pdf.SetFont("Calligrapher", "", 20)
+	tr := pdf.UnicodeTranslatorFromDescriptor("cp1252")
+	pdf.Cell(0, 10, tr("Calligrapher «font»"))
+	pdf.Ln(10)
+	pdf.ImageOptions("image/logo.png", 10, 40, 30, 0, false, docpdf.ImageOptions{ReadDpi: true}, 0, "")
+	sig, err := pdf.SVGBasicFileParse("image/signature.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf.SetXY(50, 40)
+	pdf.SVGBasicWrite(&sig, 0.3)
+	pdf.SetAttachments([]docpdf.Attachment{
+		pdf.AttachmentFromFile("text/countries.txt", "Countries"),
+	})
+	fileStr := Filename("Test_ResourceFS")
+	err = pdf.OutputFileAndClose(fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SummaryCompare(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Test_ResourceFS.pdf
+}
+
+// Test_TableNew demonstrates the table layout engine: fixed, percent and
+// automatic column widths, multi-line cells, colspan and rowspan, zebra
+// striping and header rows repeated after automatic page breaks.
+func Test_TableNew(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.SetHeaderFunc(func() {
+		pdf.SetFont("Arial", "I", 8)
+		pdf.CellFormat(0, 6, "Country report", "", 1, "C", false, 0, "")
+		pdf.SetFont("Arial", "", 10)
+	})
+	pdf.SetFont("Arial", "", 10)
+	pdf.AddPage()
+	tbl := pdf.TableNew(
+		docpdf.TableColumnType{Width: 12, AlignStr: "C"},
+		docpdf.TableColumnType{},
+		docpdf.TableColumnType{Percent: 20, AlignStr: "RM"},
+		docpdf.TableColumnType{},
+	)
+	tbl.Zebra = true
+	tbl.AddHeader(
+		docpdf.TableCellType{Text: "#", RowSpan: 2, AlignStr: "CM"},
+		docpdf.TableCellType{Text: "Country", RowSpan: 2, AlignStr: "M"},
+		docpdf.TableCellType{Text: "Figures", ColSpan: 2, AlignStr: "C"},
+	)
+	tbl.AddHeaderText("Population", "Notes")
+	lorem := loremList()
+	for j := 0; j < 40; j++ {
+		if j%10 == 9 {
+			tbl.AddRow(
+				docpdf.TableCellType{Text: strconv.Itoa(j + 1)},
+				docpdf.TableCellType{Text: "Summary of the preceding rows", ColSpan: 3, AlignStr: "C"},
+			)
+			continue
+		}
+		tbl.AddRowText(strconv.Itoa(j+1), fmt.Sprintf("Country %d", j+1),
+			strconv.Itoa((j+1)*12345), lorem[j%len(lorem)][:20+j*3%60])
+	}
+	tbl.Output()
+	pdf.Ln(4)
+	pdf.Cell(0, 6, "Text following the table")
+	if pdf.PageCount() < 2 {
+		t.Errorf("table did not break across pages: got %d pages", pdf.PageCount())
+	}
+	fileStr := Filename("Test_TableNew")
+	err := pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Test_TableNew.pdf
+}
+
+// Test_TableNew_splitRows demonstrates splitting a row across pages.
+func Test_TableNew_splitRows(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Arial", "", 10)
+	pdf.AddPage()
+	_, ht := pdf.GetPageSize()
+	pdf.SetY(ht - 60)
+	tbl := pdf.TableNew()
+	tbl.SplitRows = true
+	tbl.AddHeaderText("Paragraph", "Text")
+	tbl.AddRowText("1", strings.Repeat(strings.Join(loremList(), " ")+"\n", 3))
+	tbl.Output()
+	if got, want := pdf.PageCount(), 2; got != want {
+		t.Errorf("invalid page count: got=%d, want=%d", got, want)
+	}
+	fileStr := Filename("Test_TableNew_splitRows")
+	err := pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Test_TableNew_splitRows.pdf
+}
+
+// Test_TableNew_splitRowsOverflow verifies that a split row fails instead of
+// adding pages forever when the repeated header leaves no room for a line.
+func Test_TableNew_splitRowsOverflow(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Arial", "", 10)
+	pdf.AddPage()
+	tbl := pdf.TableNew()
+	tbl.SplitRows = true
+	tbl.AddHeaderText("Paragraph", strings.Repeat("Header line\n", 70))
+	tbl.AddRowText("1", strings.Join(loremList(), " "))
+	tbl.Output()
+	if err := pdf.Error(); err == nil || !strings.Contains(err.Error(), "no line fits") {
+		t.Errorf("expected overflow error, got %v", err)
+	}
+
+	// Without SplitRows, a row taller than a page is split rather than
+	// drawn past the bottom of a new page
+	pdf = NewDocPdfTest()
+	pdf.SetFont("Arial", "", 10)
+	pdf.AddPage()
+	pdf.SetY(100)
+	tbl = pdf.TableNew()
+	tbl.AddRowText("1", strings.Repeat("Line\n", 70))
+	tbl.Output()
+	if err := pdf.Error(); err != nil {
+		t.Fatal(err)
+	}
+	_, pageHt := pdf.GetPageSize()
+	if pages, y := pdf.PageCount(), pdf.GetY(); pages != 2 || y > pageHt {
+		t.Errorf("row taller than a page: got %d pages, y=%.2f", pages, y)
+	}
+
+	// A group of rows joined by a rowspan cannot be split
+	pdf = NewDocPdfTest()
+	pdf.SetFont("Arial", "", 10)
+	pdf.AddPage()
+	tbl = pdf.TableNew()
+	tbl.AddRow(docpdf.TableCellType{Text: "1", RowSpan: 2}, docpdf.TableCellType{Text: strings.Repeat("Line\n", 40)})
+	tbl.AddRow(docpdf.TableCellType{Text: strings.Repeat("Line\n", 40)})
+	tbl.Output()
+	if err := pdf.Error(); err == nil || !strings.Contains(err.Error(), "do not fit on a page") {
+		t.Errorf("expected overflow error, got %v", err)
+	}
+	if got := pdf.PageCount(); got != 1 {
+		t.Errorf("page added for rows that cannot fit: got %d pages", got)
+	}
+}
+
+// Test_HTMLNew demonstrates rendering a subset of HTML and CSS with headings,
+// nested lists, tables, images, preformatted text and inline styles.
+func Test_HTMLNew(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.AddPage()
+	_, lineHt := pdf.GetFontSize()
+	lineHt *= 1.4
+	htmlStr := `<h1>Quarterly report</h1>
+<p>This report mixes <b>bold</b>, <i>italic</i>, <u>underlined</u> and
+<span style="color: #c00000; background-color: rgb(255, 240, 200)">highlighted</span>
+text with a <a href="https://en.wikipedia.org/wiki/PDF">link</a> &amp; entities.</p>
+<h2 style="color: navy">Highlights</h2>
+<ul>
+  <li>Revenue grew in every region
+    <ol type="a">
+      <li>North: <span style="font-size: 14pt; font-weight: bold">+12%</span></li>
+      <li>South: +8%</li>
+    </ol>
+  </li>
+  <li>Costs were stable</li>
+</ul>
+<div style="text-align: center; margin: 4mm 20mm; background-color: #eeeeee">
+A centered block with margins and a background color.</div>
+<p style="text-align: right">Right aligned paragraph.</p>
+<p style="text-align: justify">` + lorem() + `</p>
+<blockquote>Quoted text is indented and marked with a bar on the left side.</blockquote>
+<pre>func main() {
+	fmt.Println("preformatted")
+}</pre>
+<p>Inline <code>code</code> uses the monospace family.</p>
+<hr>
+<table border="1">
+  <thead><tr><th>Region</th><th colspan="2">Figures</th></tr></thead>
+  <tr><td rowspan="2">North</td><td align="right">1,200</td><td>units</td></tr>
+  <tr><td align="right">300</td><td>returns</td></tr>
+  <tr><td>South</td><td align="right">900</td><td>units</td></tr>
+</table>
+<p style="text-align: center"><img src="` + ImageFile("logo.png") + `" width="80"></p>`
+	for j := 0; j < 3; j++ {
+		htmlStr += `<h3>Section ` + strconv.Itoa(j+1) + `</h3><p>` + lorem() + `</p>`
+	}
+	html := pdf.HTMLNew()
+	html.Write(lineHt, htmlStr)
+	if pdf.PageCount() < 2 {
+		t.Errorf("html content did not flow across pages: got %d pages", pdf.PageCount())
+	}
+	fileStr := Filename("Test_HTMLNew")
+	err := pdf.OutputFileAndClose(fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SummaryCompare(err, fileStr)
+	// Output:
+	// Successfully generated pdf/Test_HTMLNew.pdf
+}
+
+// Test_HTMLNew_emptyTable verifies that tables without cells are skipped
+// instead of failing the document.
+func Test_HTMLNew_emptyTable(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.AddPage()
+	html := pdf.HTMLNew()
+	html.Write(6, `<p>Before</p><table></table><table border="1"><tr></tr><tr> </tr></table><p>After</p>`)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_SetTextShaping(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.AddUTF8Font("dejavu", "", FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("dejavu", "", 16)
+	plain := pdf.GetStringWidth("لا")
+	pdf.SetTextShaping(true)
+	if shaped := pdf.GetStringWidth("لا"); shaped >= plain {
+		t.Errorf("lam-alef ligature is %.2f wide, expected less than %.2f", shaped, plain)
+	}
+	pdf.AddPage()
+	for _, line := range []string{
+		"office affine ﬁnal: fi ff ffl",
+		"Combining marks: á è ö ñ",
+		"مرحبا بالعالم",
+		"العدد 123 والنسبة 45%",
+		"שלום עולם (123)",
+		"Mixed: English עברית English",
+	} {
+		pdf.CellFormat(0, 10, line, "1", 1, "L", false, 0, "")
+	}
+	pdf.CellFormat(0, 10, "justified text with shaping", "1", 1, "J", false, 0, "")
+	pdf.Text(20, 120, "Text: كتاب")
+	pdf.RTL()
+	pdf.CellFormat(0, 10, "مرحبا 2024", "1", 1, "R", false, 0, "")
+	pdf.LTR()
+	pdf.Ln(20)
+	pdf.Write(8, "Flowing text with ligatures: official find ")
+	pdf.Write(8, "and Arabic السلام عليكم in the middle.")
+	fileStr := Filename("Test_SetTextShaping")
+	err := pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Output:
+	// Successfully generated pdf/Test_SetTextShaping.pdf
+}
+
+func Test_SetKerning(t *testing.T) {
+	dir := t.TempDir()
+	err := docpdf.MakeFont(FontFile("DejaVuSansCondensed.ttf"), FontFile("cp1252.map"), dir, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = docpdf.MakeFont(FontFile("cmmi10.pfb"), FontFile("cp1252.map"), dir, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def, _ := os.ReadFile(filepath.Join(dir, "cmmi10.json")); !bytes.Contains(def, []byte(`"Kp"`)) {
+		t.Errorf("no kerning pairs read from the AFM file")
+	}
+	pdf := NewDocPdfTest()
+	pdf.SetFontLocation(dir)
+	pdf.AddFont("dejavu8", "", "DejaVuSansCondensed.json")
+	pdf.AddUTF8Font("dejavu", "", FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddPage()
+	const txt = "AVAVAVAV WAVE Type"
+	for _, family := range []string{"dejavu8", "dejavu"} {
+		pdf.SetFont(family, "", 14)
+		pdf.SetKerning(false)
+		plain := pdf.GetStringWidth(txt)
+		pdf.SetKerning(true)
+		kerned := pdf.GetStringWidth(txt)
+		if kerned >= plain {
+			t.Errorf("%s: kerned width %.2f is not less than %.2f", family, kerned, plain)
+		}
+		w := kerned + 2*pdf.GetCellMargin() + 0.1
+		if n := len(pdf.SplitText(txt, w)); n != 1 {
+			t.Errorf("%s: kerned text split into %d lines", family, n)
+		}
+		if family == "dejavu8" {
+			if n := len(pdf.SplitLines([]byte(txt), w)); n != 1 {
+				t.Errorf("%s: kerned text split into %d lines", family, n)
+			}
+		}
+		for _, kern := range []bool{false, true} {
+			pdf.SetKerning(kern)
+			pdf.CellFormat(w, 10, txt, "1", 1, "L", false, 0, "")
+		}
+		pdf.MultiCell(60, 7, "AWAY To Tokyo: VALUE, TAVERN, WAVY Yacht. "+lorem()[:120], "1", "J", false)
+		pdf.Write(7, "Flowing kerned text: AVATAR, Type, WAVE.")
+		pdf.Ln(10)
+	}
+	pdf.SetTextShaping(true)
+	pdf.Cell(0, 10, "Shaped and kerned: AVATAR office Type")
+	pdf.Text(20, 280, "AVATAR Text")
+	fileStr := Filename("Test_SetKerning")
+	err = pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Output:
+	// Successfully generated pdf/Test_SetKerning.pdf
+}
+
+func Test_SetFontFallback(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.AddUTF8Font("calligra", "", FontFile("calligra.ttf"))
+	pdf.AddUTF8Font("calligra", "B", FontFile("calligra.ttf"))
+	pdf.AddUTF8Font("dejavu", "", FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddUTF8Font("dejavu", "B", FontFile("DejaVuSansCondensed-Bold.ttf"))
+	pdf.AddPage()
+	pdf.SetFont("calligra", "", 14)
+	const txt = "Names: Αλέξανδρος, Дмитрий, Ελένη and Ольга"
+	plain := pdf.GetStringWidth(txt)
+	pdf.SetFontFallback("Calligra", []string{"dejavu"})
+	mixed := pdf.GetStringWidth(txt)
+	if mixed == plain {
+		t.Errorf("fallback fonts do not change the width of the text")
+	}
+	w := mixed + 2*pdf.GetCellMargin() + 0.1
+	if n := len(pdf.SplitText(txt, w)); n != 1 {
+		t.Errorf("mixed text split into %d lines", n)
+	}
+	if n := len(pdf.SplitText(txt, w-1)); n != 2 {
+		t.Errorf("mixed text split into %d lines", n)
+	}
+	pdf.CellFormat(w, 10, txt, "1", 1, "L", false, 0, "")
+	pdf.CellFormat(w+30, 10, txt, "1", 1, "J", false, 0, "")
+	pdf.MultiCell(80, 7, "Greek: Καλημέρα κόσμε. Russian: Здравствуй, мир. "+lorem()[:80], "1", "J", false)
+	pdf.Write(7, "Flowing text with Ελληνικά and русский words.")
+	pdf.Ln(10)
+	pdf.SetKerning(true)
+	pdf.Cell(0, 10, "Kerned: AVATAR Ψυχή WAVE")
+	pdf.Ln(10)
+	pdf.SetKerning(false)
+	pdf.SetFont("calligra", "B", 14)
+	pdf.Text(20, 280, "Text with Ωμέγα")
+	fileStr := Filename("Test_SetFontFallback")
+	err := pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Output:
+	// Successfully generated pdf/Test_SetFontFallback.pdf
+}
+
+func Test_SetParagraphLayout(t *testing.T) {
+	const patterns = `% patterns of the TeXbook example
+\patterns{
+.hy3ph he2n hena4 hen5at 1na n2at 1tio 2io o2n
+}
+\hyphenation{ ta-ble }`
+	pdf := NewDocPdfTest()
+	pdf.AddUTF8Font("dejavu", "", FontFile("DejaVuSansCondensed.ttf"))
+	pdf.AddHyphenationPatternsFromBytes("en", []byte(patterns))
+	pdf.SetParagraphLayout(&docpdf.ParagraphLayout{Language: "fr"})
+	if !pdf.Err() {
+		t.Fatalf("missing hyphenation patterns accepted")
+	}
+	pdf.ClearError()
+	pdf.SetParagraphLayout(&docpdf.ParagraphLayout{Language: "en", Orphans: 3, Widows: 2})
+	pdf.AddPage()
+	pdf.SetFont("dejavu", "", 12)
+
+	margin := 2 * pdf.GetCellMargin()
+	for _, test := range []struct {
+		txt, fit string
+		lines    []string
+	}{
+		{"hyphenation", "hyphenat", []string{"hyphen-", "ation"}},
+		{"a table", "a tab", []string{"a ta-", "ble"}},
+		{"Donau\u00addampf\u00adschiff", "Donaudampf-", []string{"Donaudampf-", "schiff"}},
+		{"well-known", "well-kno", []string{"well-", "known"}},
+		{"日本語の文。", "日本語の文", []string{"日本語の", "文。"}},
+	} {
+		lines := pdf.SplitText(test.txt, pdf.GetStringWidth(test.fit)+margin)
+		if strings.Join(lines, "|") != strings.Join(test.lines, "|") {
+			t.Errorf("%q split into %q, want %q", test.txt, lines, test.lines)
+		}
+	}
+
+	// the breaks of the whole paragraph make the spacing even
+	txt := lorem()
+	w := 60.0
+	lines := pdf.SplitText(txt, w)
+	joined := strings.Join(lines, " ")
+	if strings.ReplaceAll(joined, "- ", "") != txt {
+		t.Errorf("split text %q", joined)
+	}
+	shortfall := func(lines []string) (worst float64) {
+		for _, line := range lines[:len(lines)-1] {
+			worst = math.Max(worst, w-margin-pdf.GetStringWidth(line))
+		}
+		return
+	}
+	pdf.SetParagraphLayout(nil)
+	greedy := pdf.SplitText(txt, w)
+	pdf.SetParagraphLayout(&docpdf.ParagraphLayout{Language: "en", Orphans: 3, Widows: 2})
+	if shortfall(lines) > shortfall(greedy) {
+		t.Errorf("lines %q less even than %q", lines, greedy)
+	}
+	pdf.MultiCell(w, 5, txt, "1", "J", false)
+	pdf.Ln(5)
+	pdf.MultiCell(w, 5, txt, "", "L", false)
+
+	// a column narrower than a glyph holds one glyph per line whatever the
+	// alignment
+	for _, alignStr := range []string{"L", "C", "R", "J"} {
+		pdf.AddPage()
+		y := pdf.GetY()
+		pdf.MultiCell(1, 5, "ab cd", "", alignStr, false)
+		if got := pdf.GetY() - y; math.Abs(got-20) > 1e-6 {
+			t.Errorf("alignment %s: narrow column %.2f high, want 20", alignStr, got)
+		}
+	}
+
+	// orphan and widow control
+	_, pageHeight := pdf.GetPageSize()
+	_, top, _, bottom := pdf.GetMargins()
+	par := strings.Repeat("Lines of a paragraph. ", 12)
+	n := len(pdf.SplitText(par, w))
+	for _, test := range []struct {
+		fit, lines int
+	}{{2, 0}, {n - 1, n - 2}, {n - 2, n - 2}, {3, 3}} {
+		pdf.AddPage()
+		pdf.SetY(pageHeight - bottom - float64(test.fit)*5 - 0.5)
+		page := pdf.PageNo()
+		pdf.MultiCell(w, 5, par, "", "J", false)
+		if got := pdf.GetY() - top; pdf.PageNo() != page+1 || math.Abs(got-float64(n-test.lines)*5) > 1e-6 {
+			t.Errorf("%d lines on page %d, %d lines on page %d, want %d lines on the first page",
+				n-int(math.Round(got/5)), page, int(math.Round(got/5)), pdf.PageNo(), test.lines)
+		}
+	}
+
+	fileStr := Filename("Test_SetParagraphLayout")
+	err := pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Output:
+	// Successfully generated pdf/Test_SetParagraphLayout.pdf
+}
+
+func Test_SetColumns(t *testing.T) {
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Times", "", 12)
+	pdf.AddPage()
+	pdf.CellFormat(0, 10, "Newsletter", "", 1, "C", false, 0, "")
+	pageW, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	colW := (pageW - left - right - 10) / 2
+	txt := strings.Repeat(lorem()+"\n", 2)
+	n := len(pdf.SplitLines([]byte(txt), colW))
+
+	// balanced columns share the lines
+	top := pdf.GetY()
+	pdf.SetColumns(2, 10, true)
+	pdf.MultiCell(0, 5, txt, "", "J", false)
+	if x := pdf.GetX(); x != left {
+		t.Errorf("x %.2f after the paragraph, want %.2f", x, left)
+	}
+	pdf.EndFrames()
+	if y, want := pdf.GetY(), top+float64((n+1)/2)*5; math.Abs(y-want) > 1e-6 {
+		t.Errorf("balanced columns end at %.2f, want %.2f", y, want)
+	}
+
+	// unbalanced columns fill the first column
+	top = pdf.GetY() + 5
+	pdf.SetY(top)
+	pdf.SetColumns(2, 10, false)
+	pdf.MultiCell(0, 5, txt, "", "L", false)
+	pdf.EndFrames()
+	if y, want := pdf.GetY(), top+float64(n)*5; math.Abs(y-want) > 1e-6 {
+		t.Errorf("unbalanced columns end at %.2f, want %.2f", y, want)
+	}
+
+	// long text flows to the next page
+	pdf.SetColumns(3, 5, true)
+	for j := 0; j < 8; j++ {
+		pdf.Write(5, lorem()+" ")
+		pdf.SetFont("Times", "B", 12)
+		pdf.WriteLinkString(5, "Read more.", "https://github.com/cdvelop/docpdf")
+		pdf.SetFont("Times", "", 12)
+		pdf.Ln(7)
+	}
+	html := pdf.HTMLBasicNew()
+	html.Write(5, "Text with <b>bold</b> and <i>italic</i> words. "+lorem())
+	pdf.EndFrames()
+	if pdf.PageNo() != 2 {
+		t.Errorf("text in columns ends on page %d", pdf.PageNo())
+	}
+
+	// linked frames of different widths
+	pdf.AddPage()
+	pdf.SetFrames([]docpdf.TextFrame{{X: 10, Y: 20, W: 80, H: 40}, {X: 110, Y: 20, W: 50, H: 100}, {X: 10, Y: 70, W: 80, H: 60}}, false)
+	pdf.MultiCell(0, 5, txt, "", "L", false)
+	if y := pdf.GetY(); y < 70 {
+		t.Errorf("text ends at %.2f, want the third frame", y)
+	}
+	pdf.EndFrames()
+
+	pdf.SetColumns(0, 5, false)
+	if !pdf.Err() {
+		t.Errorf("no error for zero columns")
+	}
+	pdf.ClearError()
+	pdf.SetError(fmt.Errorf("first error"))
+	pdf.SetColumns(0, 5, false)
+	pdf.SetFrames(nil, false)
+	if err := pdf.Error(); err == nil || err.Error() != "first error" {
+		t.Errorf("first error replaced by %v", err)
+	}
+	pdf.ClearError()
+
+	// headings at the top of a column get no leading space, as at the top
+	// of a page
+	pdf.AddPage()
+	_, top, _, _ = pdf.GetMargins()
+	md := pdf.MarkdownNew()
+	md.Write(5, "# Heading")
+	pageHt := pdf.GetY() - top
+	pdf.SetY(100)
+	pdf.SetColumns(2, 10, false)
+	md.Write(5, "# Heading")
+	if colHt := pdf.GetY() - 100; math.Abs(colHt-pageHt) > 1e-6 {
+		t.Errorf("heading at the top of a column is %.2f high, want %.2f", colHt, pageHt)
+	}
+	pdf.EndFrames()
+
+	fileStr := Filename("Test_SetColumns")
+	err := pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Output:
+	// Successfully generated pdf/Test_SetColumns.pdf
+}
+
+// Test_ParagraphNew demonstrates paragraphs of spans with mixed fonts, sizes,
+// colors and decorations in each alignment.
+func Test_ParagraphNew(t *testing.T) {
+	// pieces returns the position in points of the text pieces of pdf
+	pieces := func(pdf *docpdf.DocPDF) map[string][2]float64 {
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		list := make(map[string][2]float64)
+		re := regexp.MustCompile(`BT ([-\d.]+) ([-\d.]+) Td \(([^)]*)\)Tj ET`)
+		for _, m := range re.FindAllStringSubmatch(buf.String(), -1) {
+			x, _ := strconv.ParseFloat(m[1], 64)
+			y, _ := strconv.ParseFloat(m[2], 64)
+			list[m[3]] = [2]float64{x, y}
+		}
+		return list
+	}
+
+	// mixed sizes share the baseline, and offsets move it
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.AddPage()
+	para := pdf.ParagraphNew()
+	para.AddSpan(docpdf.TextSpanType{Text: "Small", FontSize: 8},
+		docpdf.TextSpanType{Text: " Big", FontSize: 20, FontStyle: "B"},
+		docpdf.TextSpanType{Text: " raised", FontSize: 8, Offset: 4})
+	para.Output()
+	if size, _ := pdf.GetFontSize(); size != 12 {
+		t.Errorf("font size %.2f after the paragraph, want 12", size)
+	}
+	list := pieces(pdf)
+	if small, big := list["Small"][1], list[" Big"][1]; small != big {
+		t.Errorf("baselines %.2f and %.2f differ", small, big)
+	}
+	if small, raised := list["Small"][1], list[" raised"][1]; math.Abs(raised-small-4) > 0.01 {
+		t.Errorf("raised baseline %.2f, want %.2f", raised, small+4)
+	}
+
+	// justified and right aligned lines end at the right edge
+	for _, alignStr := range []string{"J", "R"} {
+		pdf = NewDocPdfTest()
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.AddPage()
+		left, _, _, _ := pdf.GetMargins()
+		wd := pdf.GetStringWidth("one two three") + 2
+		para = pdf.ParagraphNew()
+		para.Width = wd
+		para.AlignStr = alignStr
+		para.AddSpan(docpdf.TextSpanType{Text: "one two "}, docpdf.TextSpanType{Text: "three", FontStyle: "I"},
+			docpdf.TextSpanType{Text: " four"})
+		pdf.SetFont("Helvetica", "I", 12)
+		end := wd - pdf.GetStringWidth("three")
+		pdf.SetFont("Helvetica", "", 12)
+		para.Output()
+		k := pdf.GetConversionRatio()
+		if x := pieces(pdf)["three"][0]/k - left; math.Abs(x-end) > 0.01 {
+			t.Errorf("%s: last word of the line at %.2f, want %.2f", alignStr, x, end)
+		}
+	}
+
+	pdf = NewDocPdfTest()
+	pdf.AddUTF8Font("dejavu", "", FontFile("DejaVuSansCondensed.ttf"))
+	pdf.SetFont("Times", "", 12)
+	pdf.AddPage()
+	link := pdf.AddLink()
+	for _, alignStr := range []string{"L", "C", "R", "J"} {
+		para = pdf.ParagraphNew()
+		para.AlignStr = alignStr
+		para.AddSpan(docpdf.TextSpanType{Text: "Paragraph aligned " + alignStr + ". ", FontSize: 16, FontStyle: "B"},
+			docpdf.TextSpanType{Text: lorem()[:120] + " "},
+			docpdf.TextSpanType{Text: "Water is H"}, docpdf.TextSpanType{Text: "2", FontSize: 8, Offset: -2},
+			docpdf.TextSpanType{Text: "O and energy is mc"}, docpdf.TextSpanType{Text: "2", FontSize: 8, Offset: 5},
+			docpdf.TextSpanType{Text: ". "},
+			docpdf.TextSpanType{Text: "Highlighted text in red", TextColor: &docpdf.RGBType{R: 200}, Highlight: &docpdf.RGBType{R: 255, G: 240, B: 160}},
+			docpdf.TextSpanType{Text: " next to "},
+			docpdf.TextSpanType{Text: "underlined", FontStyle: "U"}, docpdf.TextSpanType{Text: " and "},
+			docpdf.TextSpanType{Text: "struck out", FontStyle: "S"}, docpdf.TextSpanType{Text: " words, "},
+			docpdf.TextSpanType{Text: "Unicode text: Ελληνικά, Русский, Português ", FontFamily: "dejavu", FontSize: 11},
+			docpdf.TextSpanType{Text: "and a link", LinkStr: "https://github.com/cdvelop/docpdf", TextColor: &docpdf.RGBType{B: 200}, FontStyle: "U"},
+			docpdf.TextSpanType{Text: " to the last paragraph.\n" + lorem()[120:200]})
+		if alignStr == "J" {
+			pdf.SetLink(link, -1, -1)
+		} else {
+			para.AddSpan(docpdf.TextSpanType{Text: " Go to the last paragraph.", Link: link})
+		}
+		para.Output()
+		pdf.Ln(4)
+	}
+	para = pdf.ParagraphNew()
+	para.AddSpan(docpdf.TextSpanType{Text: strings.Repeat("Unbreakable", 12), FontSize: 14})
+	para.Output()
+	if pdf.Err() {
+		t.Fatal(pdf.Error())
+	}
+
+	fileStr := Filename("Test_ParagraphNew")
+	err := pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Output:
+	// Successfully generated pdf/Test_ParagraphNew.pdf
+}
+
+// Test_MarkdownNew demonstrates rendering a Markdown document with headings,
+// emphasis, links, lists, task lists, code, quotes, tables and images.
+func Test_MarkdownNew(t *testing.T) {
+	mdStr := "Release notes\n=============\n\n" +
+		"Version **2.0** brings *faster* rendering, ~~fewer~~ __no__ known bugs, `inline code` and " +
+		"a [link to the repository][repo]. See also <https://pkg.go.dev> and www.example.com, then\\\n" +
+		"Escaped \\*stars\\* &amp; entities stay literal.\n\n" +
+		"[repo]: https://github.com/cdvelop/docpdf \"Repository\"\n\n" +
+		"## Installation\n\n" +
+		"```go\nimport \"github.com/cdvelop/docpdf\"\n\nfunc main() {\n\tpdf := docpdf.New()\n}\n```\n\n" +
+		"### Changes\n\n" +
+		"1. First change\n2. Second change with a nested list:\n   - alpha\n   - beta\n     * gamma\n3. Third change\n\n" +
+		"- [x] Done task\n- [ ] Pending task\n\n" +
+		"> Quoted text with **bold** words.\n> Lazy continuation\nof the quote.\n>\n> > Nested quote.\n\n" +
+		"| Feature | Status | Count |\n|:--------|:------:|------:|\n| Tables  | done   | 3 |\n| Pipes \\| escaped | *yes* | 12 |\n\n" +
+		"***\n\n" +
+		"    indented code block\n\n" +
+		"![Logo](" + ImageFile("logo.png") + ")\n\n" +
+		"#### Deep heading\n\nLast paragraph.\n"
+
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.AddPage()
+	md := pdf.MarkdownNew()
+	md.Write(5.5, mdStr)
+	if pdf.Err() {
+		t.Fatal(pdf.Error())
+	}
+	if style := pdf.GetFontStyle(); style != "" {
+		t.Errorf("font style %q after the document", style)
+	}
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, str := range []string{
+		"/Title (Release notes)", "/Title (Installation)", "/Title (Changes)", "/Title (Deep heading)",
+		"/URI (https://github.com/cdvelop/docpdf)", "/URI (https://pkg.go.dev)", "/URI (http://www.example.com)",
+		"(2.0)", "(faster)", "(inline code)", "(Escaped *stars* & entities stay literal.)",
+		"(func main\\(\\) {)", "(    pdf := docpdf.New\\(\\))", "(indented code block)",
+		"(Pipes | escaped)", "(Nested quote.)", "(1.)", "(3.)", "(alpha)",
+	} {
+		if !strings.Contains(out, str) {
+			t.Errorf("output does not contain %s", str)
+		}
+	}
+	for _, str := range []string{"(**", "[repo]", "(- [x]", "(> "} {
+		if strings.Contains(out, str) {
+			t.Errorf("markup %s printed", str)
+		}
+	}
+
+	pdf = NewDocPdfTest()
+	pdf.SetFont("Times", "", 12)
+	pdf.AddPage()
+	md = pdf.MarkdownNew()
+	md.Write(6, mdStr+"\n"+strings.Repeat("Paragraph of *filler* text. ", 40)+"\n\n"+
+		"> "+strings.Repeat("A long quote spanning pages. ", 200))
+	fileStr := Filename("Test_MarkdownNew")
+	err = pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Output:
+	// Successfully generated pdf/Test_MarkdownNew.pdf
+}
+
+// Test_ReserveTOC demonstrates an automatic table of contents that is printed
+// when the document is closed and moved in place of the reserved pages.
+func Test_ReserveTOC(t *testing.T) {
+	// the listed page numbers are final whatever the number of reserved pages
+	for _, reserved := range []int{0, 1, 3} {
+		pdf := NewDocPdfTest()
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.AddPage()
+		pdf.Cell(40, 10, "Cover")
+		pdf.ReserveTOC(reserved, "Contents", 1)
+		for j := 1; j <= 3; j++ {
+			pdf.AddPage()
+			pdf.Bookmark(fmt.Sprintf("Chapter %d", j), 0, -1)
+			pdf.Cell(40, 10, fmt.Sprintf("Body %d", j))
+			pdf.Bookmark(fmt.Sprintf("Section %d.1", j), 1, -1)
+			pdf.Bookmark("Detail", 2, -1)
+		}
+		var buf bytes.Buffer
+		if err := pdf.Output(&buf); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		if n := strings.Count(out, "/Type /Page\n"); n != 5 {
+			t.Errorf("%d reserved: %d pages, want 5", reserved, n)
+		}
+		cover, contents, body := strings.Index(out, "(Cover)"), strings.Index(out, "(Contents)"), strings.Index(out, "(Body 1)")
+		if !(cover < contents && contents < body) {
+			t.Errorf("%d reserved: table of contents not between the cover and the first chapter", reserved)
+		}
+		for _, str := range []string{"(Chapter 1)Tj", "(Section 3.1)Tj", "(3)Tj", "(4)Tj", "(5)Tj", "(.....", "/Dest ["} {
+			if !strings.Contains(out, str) {
+				t.Errorf("%d reserved: output does not contain %s", reserved, str)
+			}
+		}
+		if strings.Contains(out, "(Detail)Tj") {
+			t.Errorf("%d reserved: entry above the maximum level listed", reserved)
+		}
+	}
+
+	// Bookmarks of a core font are listed in the code page of the font
+	pdf := NewDocPdfTest()
+	pdf.SetFont("Helvetica", "", 12)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.ReserveTOC(0, "Contents", 0)
+	pdf.AddPage()
+	pdf.Bookmark(tr("Café résumé"), 0, -1)
+	pdf.Cell(40, 10, tr("Café résumé"))
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if n := strings.Count(out, "(Caf\xe9 r\xe9sum\xe9)Tj"); n != 2 {
+		t.Errorf("cp1252 heading printed %d times, want 2", n)
+	}
+	if strings.Contains(out, "\uFFFD") {
+		t.Errorf("cp1252 bookmark decoded as UTF-8")
+	}
+
+	pdf = NewDocPdfTest()
+	pdf.SetFont("Times", "", 12)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+	pdf.SetFont("Times", "B", 24)
+	pdf.CellFormat(0, 40, "Document with a table of contents", "", 1, "C", false, 0, "")
+	pdf.SetFont("Times", "", 12)
+	pdf.ReserveTOC(2, "Table of Contents", 1)
+	for j := 1; j <= 12; j++ {
+		pdf.AddPage()
+		pdf.SetFont("Times", "B", 16)
+		pdf.Bookmark(fmt.Sprintf("Chapter %d", j), 0, -1)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Chapter %d", j), "", 1, "L", false, 0, "")
+		pdf.SetFont("Times", "", 12)
+		for s := 1; s <= 3; s++ {
+			pdf.Bookmark(fmt.Sprintf("Section %d.%d, with a title long enough to wrap on the next line of the table of contents", j, s), 1, -1)
+			pdf.MultiCell(0, 5, lorem(), "", "J", false)
+			pdf.Ln(4)
+		}
+	}
+	fileStr := Filename("Test_ReserveTOC")
+	err := pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Output:
+	// Successfully generated pdf/Test_ReserveTOC.pdf
+}
@@ -2,11 +2,14 @@ package docpdf
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 	"io"
-	"os"
 	"sort"
 	"strings"
+	"time"
+
+	"github.com/cdvelop/docpdf/env"
 )
 
 // PageSize returns the width and height of the specified page in the units
@@ -43,6 +46,10 @@ func (f *DocPDF) AddPageFormat(orientationStr orientationType, size PageSize) {
 	if f.state == 0 {
 		f.open()
 	}
+	if f.flow != nil {
+		// the header and footer use the page margins
+		f.lMargin, f.rMargin, f.pageBreakTrigger = f.flow.lMargin, f.flow.rMargin, f.flow.trigger
+	}
 	familyStr := f.fontFamily
 	style := f.fontStyle
 	if f.underline {
@@ -60,6 +67,7 @@ func (f *DocPDF) AddPageFormat(orientationStr orientationType, size PageSize) {
 
 	if f.page > 0 {
 		f.inFooter = true
+		f.artifactBegin()
 		// Page footer avoid double call on footer.
 		if f.footerFnc != nil {
 			f.footerFnc()
@@ -67,6 +75,7 @@ func (f *DocPDF) AddPageFormat(orientationStr orientationType, size PageSize) {
 		} else if f.footerFncLpi != nil {
 			f.footerFncLpi(false) // not last page.
 		}
+		f.artifactEnd()
 		f.inFooter = false
 		// Close page
 		f.endpage()
@@ -106,7 +115,9 @@ func (f *DocPDF) AddPageFormat(orientationStr orientationType, size PageSize) {
 	// 	Page header
 	if f.headerFnc != nil {
 		f.inHeader = true
+		f.artifactBegin()
 		f.headerFnc()
+		f.artifactEnd()
 		f.inHeader = false
 		if f.headerHomeMode {
 			f.SetHomeXY()
@@ -135,6 +146,9 @@ func (f *DocPDF) AddPageFormat(orientationStr orientationType, size PageSize) {
 	}
 	f.color.text = tc
 	f.colorFlag = cf
+	if f.flow != nil {
+		f.startFrames()
+	}
 }
 
 // AddPage adds a new page to the document. If a page is already present, the
@@ -159,10 +173,15 @@ func (f *DocPDF) AddPage() {
 	f.AddPageFormat(f.defOrientation, f.defPageSize)
 }
 
-// PageNo returns the current page number.
+// PageNo returns the current page number. While the table of contents
+// reserved with ReserveTOC() is printed, it returns the number the page will
+// have once the table is moved in place.
 //
 // See the example for AddPage() for a demonstration of this method.
 func (f *DocPDF) PageNo() int {
+	if f.toc != nil && f.toc.first > 0 && f.page >= f.toc.first {
+		return f.toc.page + f.page - f.toc.first + 1
+	}
 	return f.page
 }
 
@@ -386,6 +405,9 @@ func (f *DocPDF) SetAutoPageBreak(auto bool, margin float64) {
 // full access to the document regardless of the actionFlag value. An empty
 // string for this argument will be replaced with a random value, effectively
 // prohibiting full access to the document.
+//
+// The document is encrypted with 40-bit RC4, which is no longer considered
+// secure; see SetProtectionAES128() and SetProtectionAES256().
 func (f *DocPDF) SetProtection(actionFlag byte, userPassStr, ownerPassStr string) {
 	if f.err != nil {
 		return
@@ -393,6 +415,34 @@ func (f *DocPDF) SetProtection(actionFlag byte, userPassStr, ownerPassStr string
 	f.protect.setProtection(actionFlag, userPassStr, ownerPassStr)
 }
 
+// SetProtectionAES128 is like SetProtection but encrypts the document with
+// 128-bit AES (security handler revision 4), which raises the PDF version to
+// 1.6. In addition to the flags of SetProtection, actionFlag may include
+// CnProtectFillForms, CnProtectExtract, CnProtectAssemble and
+// CnProtectPrintHighRes.
+func (f *DocPDF) SetProtectionAES128(actionFlag int, userPassStr, ownerPassStr string) {
+	if f.err != nil {
+		return
+	}
+	if f.pdfVersion < pdfVers1_6 {
+		f.pdfVersion = pdfVers1_6
+	}
+	f.protect.setProtectionAES128(actionFlag, userPassStr, ownerPassStr)
+}
+
+// SetProtectionAES256 is like SetProtectionAES128 but uses 256-bit AES
+// (security handler revision 6), which raises the PDF version to 2.0.
+// Passwords are UTF-8 strings of at most 127 bytes.
+func (f *DocPDF) SetProtectionAES256(actionFlag int, userPassStr, ownerPassStr string) {
+	if f.err != nil {
+		return
+	}
+	if f.pdfVersion < pdfVers2_0 {
+		f.pdfVersion = pdfVers2_0
+	}
+	f.protect.setProtectionAES256(actionFlag, userPassStr, ownerPassStr)
+}
+
 // OutputAndClose sends the PDF document to the writer specified by w. This
 // method will close both f and w, even if an error is detected and no document
 // is produced.
@@ -406,8 +456,11 @@ func (f *DocPDF) OutputAndClose(w io.WriteCloser) error {
 }
 
 // OutputFileAndClose creates or truncates the file specified by fileStr and
-// writes the PDF document to it. This method will close f and the newly
-// written file, even if an error is detected and no document is produced.
+// writes the PDF document to it. This method will close f, even if an error
+// is detected and no document is produced.
+//
+// The document is handed off to env.FileWriter, so in wasm environments the
+// browser-side writer is used instead of the file system.
 //
 // Most examples demonstrate the use of this method.
 func (f *DocPDF) OutputFileAndClose(fileStr string) error {
@@ -415,17 +468,14 @@ func (f *DocPDF) OutputFileAndClose(fileStr string) error {
 		return f.err
 	}
 
-	pdfFile, err := os.Create(fileStr)
-	if err != nil {
-		f.err = err
+	var buf bytes.Buffer
+	if f.Output(&buf) != nil {
 		return f.err
 	}
 
-	_ = f.Output(pdfFile)
-
-	err = pdfFile.Close()
+	err := env.FileWriter(fileStr, buf.Bytes())
 	if err != nil {
-		return fmt.Errorf("could not close output file: %w", err)
+		f.err = fmt.Errorf("could not write output file: %w", err)
 	}
 
 	return f.err
@@ -442,6 +492,9 @@ func (f *DocPDF) Output(w io.Writer) error {
 	// dbg("Output")
 	if f.state < 3 {
 		f.Close()
+		if f.err != nil {
+			return f.err
+		}
 	}
 	_, err := f.buffer.WriteTo(w)
 	if err != nil {
@@ -487,7 +540,9 @@ func (f *DocPDF) beginpage(newPageOrientation orientationType, size PageSize) {
 	f.pages = append(f.pages, bytes.NewBufferString(""))
 	f.pageLinks = append(f.pageLinks, make([]linkType, 0))
 	f.pageAttachments = append(f.pageAttachments, []annotationAttach{})
+	f.pageWidgets = append(f.pageWidgets, nil)
 	f.state = 2
+	f.tagBeginPage()
 	f.x = f.lMargin
 	f.y = f.tMargin
 	f.fontFamily = ""
@@ -520,6 +575,7 @@ func (f *DocPDF) beginpage(newPageOrientation orientationType, size PageSize) {
 }
 
 func (f *DocPDF) endpage() {
+	f.tagEndPage()
 	f.EndLayer()
 	f.state = 1
 }
@@ -564,6 +620,8 @@ func (f *DocPDF) putpages() {
 		hPt = f.defPageSize.Wd
 	}
 	pagesObjectNumbers := make([]int, nb+1) // 1-based
+	// Form fields are written after the pages
+	f.formAssignObjects(f.n)
 	for n := 1; n <= nb; n++ {
 		// Page
 		f.newobj()
@@ -579,12 +637,12 @@ func (f *DocPDF) putpages() {
 		}
 		f.out("/Resources 2 0 R")
 		// Links
-		if len(f.pageLinks[n])+len(f.pageAttachments[n]) > 0 {
+		if len(f.pageLinks[n])+len(f.pageAttachments[n])+len(f.pageWidgets[n]) > 0 {
 			var annots fmtBuffer
 			annots.printf("/Annots [")
 			for _, pl := range f.pageLinks[n] {
-				annots.printf("<</Type /Annot /Subtype /Link /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0] ",
-					pl.x, pl.y, pl.x+pl.wd, pl.y-pl.ht)
+				annots.printf("<</Type /Annot /Subtype /Link /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0] %s",
+					pl.x, pl.y, pl.x+pl.wd, pl.y-pl.ht, f.annotFlags())
 				if pl.link == 0 {
 					annots.printf("/A <</S /URI /URI %s>>>>", f.textstring(pl.linkStr))
 				} else {
@@ -602,12 +660,20 @@ func (f *DocPDF) putpages() {
 				}
 			}
 			f.putAttachmentAnnotationLinks(&annots, n)
+			f.putFormAnnotationRefs(&annots, n)
 			annots.printf("]")
 			f.out(annots.String())
 		}
-		if f.pdfVersion > pdfVers1_3 {
+		// PDF/A-1 forbids transparency groups
+		if f.pdfVersion > pdfVers1_3 && f.pdfaPart != 1 {
 			f.out("/Group <</Type /Group /S /Transparency /CS /DeviceRGB>>")
 		}
+		if f.tagged {
+			if len(f.structParents[n]) > 0 {
+				f.outf("/StructParents %d", n-1)
+			}
+			f.out("/Tabs /S")
+		}
 		f.outf("/Contents %d 0 R>>", f.n+1)
 		f.out("endobj")
 		// Page content
@@ -615,15 +681,17 @@ func (f *DocPDF) putpages() {
 		if f.compress {
 			mem := xmem.compress(f.pages[n].Bytes())
 			data := mem.bytes()
-			f.outf("<</Filter /FlateDecode /Length %d>>", len(data))
+			f.outf("<</Filter /FlateDecode /Length %d>>", f.protect.encryptedLen(len(data)))
 			f.putstream(data)
 			mem.release()
 		} else {
-			f.outf("<</Length %d>>", f.pages[n].Len())
+			f.outf("<</Length %d>>", f.protect.encryptedLen(f.pages[n].Len()))
 			f.putstream(f.pages[n].Bytes())
 		}
 		f.out("endobj")
 	}
+	f.putformfields()
+	f.putStructTree(pagesObjectNumbers)
 	// Pages root
 	f.offsets[1] = f.buffer.Len()
 	f.out("1 0 obj")
@@ -678,15 +746,29 @@ func (f *DocPDF) putimages() {
 func (f *DocPDF) putimage(info *ImageInfoType) {
 	f.newobj()
 	info.n = f.n
+	// Color space and number of components of palettes
+	base, comps := "DeviceRGB", 3
+	if f.cmykConversion && (info.cs == "DeviceRGB" || info.cs == "Indexed") {
+		img, err := f.imageCMYK(info)
+		if err != nil {
+			f.err = err
+			return
+		}
+		n := info.n
+		info = &img
+		info.n = n
+		base, comps = "DeviceCMYK", 4
+	}
 	f.out("<</Type /XObject")
 	f.out("/Subtype /Image")
 	f.outf("/Width %d", int(info.w))
 	f.outf("/Height %d", int(info.h))
 	if info.cs == "Indexed" {
-		f.outf("/ColorSpace [/Indexed /DeviceRGB %d %d 0 R]", len(info.pal)/3-1, f.n+1)
+		f.outf("/ColorSpace [/Indexed %s %d %d 0 R]", f.imageColorSpace(base), len(info.pal)/comps-1, f.n+1)
 	} else {
-		f.outf("/ColorSpace /%s", info.cs)
-		if info.cs == "DeviceCMYK" {
+		f.outf("/ColorSpace %s", f.imageColorSpace(info.cs))
+		// Adobe CMYK JPEG images are inverted
+		if info.cs == "DeviceCMYK" && info.f == "DCTDecode" {
 			f.out("/Decode [1 0 1 0 1 0 1 0]")
 		}
 	}
@@ -707,7 +789,7 @@ func (f *DocPDF) putimage(info *ImageInfoType) {
 	if info.smask != nil {
 		f.outf("/SMask %d 0 R", f.n+1)
 	}
-	f.outf("/Length %d>>", len(info.data))
+	f.outf("/Length %d>>", f.protect.encryptedLen(len(info.data)))
 	f.putstream(info.data)
 	f.out("endobj")
 	// 	Soft mask
@@ -730,11 +812,11 @@ func (f *DocPDF) putimage(info *ImageInfoType) {
 		if f.compress {
 			mem := xmem.compress(info.pal)
 			pal := mem.bytes()
-			f.outf("<</Filter /FlateDecode /Length %d>>", len(pal))
+			f.outf("<</Filter /FlateDecode /Length %d>>", f.protect.encryptedLen(len(pal)))
 			f.putstream(pal)
 			mem.release()
 		} else {
-			f.outf("<</Length %d>>", len(info.pal))
+			f.outf("<</Length %d>>", f.protect.encryptedLen(len(info.pal)))
 			f.putstream(info.pal)
 		}
 		f.out("endobj")
@@ -817,6 +899,14 @@ func (f *DocPDF) putresourcedict() {
 		}
 		f.out(">>")
 	}
+	count = len(f.patternList)
+	if count > 1 {
+		f.out("/Pattern <<")
+		for j := 1; j < count; j++ {
+			f.outf("/P%d %d 0 R", j, f.patternList[j].objNum)
+		}
+		f.out(">>")
+	}
 	// Layers
 	f.layerPutResourceDict()
 	f.spotColorPutResourceDict()
@@ -839,20 +929,49 @@ func (f *DocPDF) putGradients() {
 	for j := 1; j < count; j++ {
 		var f1 int
 		gr := f.gradientList[j]
-		if gr.tp == 2 || gr.tp == 3 {
+		if len(gr.stops) > 2 {
+			// Stitch the blendings between successive stops
+			var funcs, bounds, encode fmtBuffer
+			for k := 1; k < len(gr.stops); k++ {
+				funcs.printf("<</FunctionType 2 /Domain [0.0 1.0] /C0 [%s] /C1 [%s] /N 1>> ",
+					gr.stops[k-1].clrStr, gr.stops[k].clrStr)
+				if k < len(gr.stops)-1 {
+					bounds.printf("%.5f ", gr.stops[k].offset)
+				}
+				encode.printf("0 1 ")
+			}
+			f.newobj()
+			f.outf("<</FunctionType 3 /Domain [0.0 1.0] /Functions [%s] /Bounds [%s] /Encode [%s]>>",
+				strings.TrimSpace(funcs.String()), strings.TrimSpace(bounds.String()), strings.TrimSpace(encode.String()))
+			f.out("endobj")
+			f1 = f.n
+		} else if gr.tp == 2 || gr.tp == 3 {
 			f.newobj()
 			f.outf("<</FunctionType 2 /Domain [0.0 1.0] /C0 [%s] /C1 [%s] /N 1>>", gr.clr1Str, gr.clr2Str)
 			f.out("endobj")
 			f1 = f.n
 		}
 		f.newobj()
-		f.outf("<</ShadingType %d /ColorSpace /DeviceRGB", gr.tp)
+		f.outf("<</ShadingType %d /ColorSpace %s", gr.tp, f.gradientColorSpace(gr))
+		extendStr := sprintf("/Extend [%t %t]>>", gr.extend[0], gr.extend[1])
 		if gr.tp == 2 {
-			f.outf("/Coords [%.5f %.5f %.5f %.5f] /Function %d 0 R /Extend [true true]>>",
-				gr.x1, gr.y1, gr.x2, gr.y2, f1)
+			f.outf("/Coords [%.5f %.5f %.5f %.5f] /Function %d 0 R %s",
+				gr.x1, gr.y1, gr.x2, gr.y2, f1, extendStr)
 		} else if gr.tp == 3 {
-			f.outf("/Coords [%.5f %.5f 0 %.5f %.5f %.5f] /Function %d 0 R /Extend [true true]>>",
-				gr.x1, gr.y1, gr.x2, gr.y2, gr.r, f1)
+			f.outf("/Coords [%.5f %.5f 0 %.5f %.5f %.5f] /Function %d 0 R %s",
+				gr.x1, gr.y1, gr.x2, gr.y2, gr.r, f1, extendStr)
+		} else if gr.tp == 6 {
+			f.outf("/BitsPerCoordinate 32 /BitsPerComponent 16 /BitsPerFlag 8 /Decode [%s]", gr.decodeStr)
+			if f.compress {
+				mem := xmem.compress(gr.mesh)
+				data := mem.bytes()
+				f.outf("/Filter /FlateDecode /Length %d>>", f.protect.encryptedLen(len(data)))
+				f.putstream(data)
+				mem.release()
+			} else {
+				f.outf("/Length %d>>", f.protect.encryptedLen(len(gr.mesh)))
+				f.putstream(gr.mesh)
+			}
 		}
 		f.out("endobj")
 		f.gradientList[j].objNum = f.n
@@ -865,8 +984,11 @@ func (f *DocPDF) putresources() {
 	}
 	f.layerPutLayers()
 	f.putBlendModes()
-	f.putGradients()
+	// Output intent color profile streams, used by ICCBased color spaces
+	f.putOutputIntentStreams()
+	// Gradients may be drawn in spot colors
 	f.putSpotColors()
+	f.putGradients()
 	f.putfonts()
 	if f.err != nil {
 		return
@@ -874,6 +996,7 @@ func (f *DocPDF) putresources() {
 	f.putimages()
 	f.putTemplates()
 	f.putImportedTemplates() // gofpdi
+	f.putPatterns()
 	// 	Resource dictionary
 	f.offsets[2] = f.buffer.Len()
 	f.out("2 0 obj")
@@ -887,10 +1010,26 @@ func (f *DocPDF) putresources() {
 		f.protect.objNum = f.n
 		f.out("<<")
 		f.out("/Filter /Standard")
-		f.out("/V 1")
-		f.out("/R 2")
-		f.outf("/O (%s)", f.escape(string(f.protect.oValue)))
-		f.outf("/U (%s)", f.escape(string(f.protect.uValue)))
+		switch f.protect.revision {
+		case 4:
+			f.out("/V 4 /R 4 /Length 128")
+			f.out("/CF <</StdCF <</CFM /AESV2 /AuthEvent /DocOpen /Length 16>>>> /StmF /StdCF /StrF /StdCF")
+			f.outf("/O <%s>", hex.EncodeToString(f.protect.oValue))
+			f.outf("/U <%s>", hex.EncodeToString(f.protect.uValue))
+		case 6:
+			f.out("/V 5 /R 6 /Length 256")
+			f.out("/CF <</StdCF <</CFM /AESV3 /AuthEvent /DocOpen /Length 32>>>> /StmF /StdCF /StrF /StdCF")
+			f.outf("/O <%s>", hex.EncodeToString(f.protect.oValue))
+			f.outf("/U <%s>", hex.EncodeToString(f.protect.uValue))
+			f.outf("/OE <%s>", hex.EncodeToString(f.protect.oeValue))
+			f.outf("/UE <%s>", hex.EncodeToString(f.protect.ueValue))
+			f.outf("/Perms <%s>", hex.EncodeToString(f.protect.perms))
+		default:
+			f.out("/V 1")
+			f.out("/R 2")
+			f.outf("/O (%s)", f.escape(string(f.protect.oValue)))
+			f.outf("/U (%s)", f.escape(string(f.protect.uValue)))
+		}
 		f.outf("/P %d", f.protect.pValue)
 		f.out(">>")
 		f.out("endobj")
@@ -916,10 +1055,9 @@ func (f *DocPDF) putinfo() {
 	if len(f.creator) > 0 {
 		f.outf("/Creator %s", f.textstring(f.creator))
 	}
-	creation := timeOrNow(f.creationDate)
-	f.outf("/CreationDate %s", f.textstring("D:"+creation.Format("20060102150405")))
-	mod := timeOrNow(f.modDate)
-	f.outf("/ModDate %s", f.textstring("D:"+mod.Format("20060102150405")))
+	creation, mod := f.documentDates()
+	f.outf("/CreationDate %s", f.textstring(f.infoDate(creation)))
+	f.outf("/ModDate %s", f.textstring(f.infoDate(mod)))
 }
 
 func (f *DocPDF) putcatalog() {
@@ -927,7 +1065,7 @@ func (f *DocPDF) putcatalog() {
 	f.out("/Pages 1 0 R")
 	f.putOutputIntents()
 	if f.lang != "" {
-		f.outf("/Lang (%s)", f.lang)
+		f.outf("/Lang %s", f.textstring(f.lang))
 	}
 	switch f.zoomMode {
 	case "fullpage":
@@ -959,6 +1097,10 @@ func (f *DocPDF) putcatalog() {
 		f.outf("/Outlines %d 0 R", f.outlineRoot)
 		f.out("/PageMode /UseOutlines")
 	}
+	// Logical structure
+	f.putStructTreeCatalog()
+	// Interactive form
+	f.putAcroForm()
 	// Layers
 	f.layerPutCatalog()
 	// XMP metadata
@@ -976,6 +1118,8 @@ func (f *DocPDF) putcatalog() {
 	// Embedded files
 	f.outf("/EmbeddedFiles %s", f.getEmbeddedFiles())
 	f.out(">>")
+	// Associated files of PDF/A-3
+	f.putAssociatedFiles()
 }
 
 func (f *DocPDF) putheader() {
@@ -989,7 +1133,16 @@ func (f *DocPDF) puttrailer() {
 	f.outf("/Info %d 0 R", f.n-1)
 	if f.protect.encrypted {
 		f.outf("/Encrypt %d 0 R", f.protect.objNum)
-		f.out("/ID [()()]")
+		if f.protect.fileID != nil {
+			id := hex.EncodeToString(f.protect.fileID)
+			f.outf("/ID [<%s><%s>]", id, id)
+		} else {
+			f.out("/ID [()()]")
+		}
+	} else if f.pdfaPart > 0 {
+		// PDF/A requires a file identifier
+		id := checksum(f.buffer.Bytes())
+		f.outf("/ID [<%s><%s>]", id, id)
 	}
 }
 
@@ -999,7 +1152,7 @@ func (f *DocPDF) putxmp() {
 	}
 	f.newobj()
 	f.nXMP = f.n
-	f.outf("<< /Type /Metadata /Subtype /XML /Length %d >>", len(f.xmp))
+	f.outf("<< /Type /Metadata /Subtype /XML /Length %d >>", f.protect.encryptedLen(len(f.xmp)))
 	f.putstream(f.xmp)
 	f.out("endobj")
 }
@@ -1066,11 +1219,11 @@ func (f *DocPDF) putOutputIntents() {
 	for index, oi := range f.outputIntents {
 		infoSegment := ""
 		if oi.Info != "" {
-			infoSegment = fmt.Sprintf("/Info (%s) ", oi.Info)
+			infoSegment = fmt.Sprintf("/Info %s ", f.textstring(oi.Info))
 		}
 		f.outf(
-			`<< /Type /OutputIntent /S /%s /OutputConditionIdentifier (%s) %s/DestOutputProfile %d 0 R >>`,
-			oi.SubtypeIdent, oi.OutputConditionIdentifier, infoSegment, f.outputIntentStartN+index,
+			`<< /Type /OutputIntent /S /%s /OutputConditionIdentifier %s %s/DestOutputProfile %d 0 R >>`,
+			oi.SubtypeIdent, f.textstring(oi.OutputConditionIdentifier), infoSegment, f.outputIntentStartN+index,
 		)
 	}
 	f.out("]")
@@ -1086,7 +1239,9 @@ func (f *DocPDF) putOutputIntentStreams() {
 		f.newobj()
 		mem := xmem.compress(oi.ICCProfile)
 		compressedICC := mem.bytes()
-		f.outf("<< /N 3 /Alternate /DeviceRGB /Length %d /Filter /FlateDecode >>", len(compressedICC))
+		n := iccProfileComps(oi.ICCProfile)
+		alternateStr := map[int]string{1: "DeviceGray", 3: "DeviceRGB", 4: "DeviceCMYK"}[n]
+		f.outf("<< /N %d /Alternate /%s /Length %d /Filter /FlateDecode >>", n, alternateStr, f.protect.encryptedLen(len(compressedICC)))
 		f.putstream(compressedICC)
 		f.out("endobj")
 
@@ -1099,6 +1254,13 @@ func (f *DocPDF) enddoc() {
 		return
 	}
 	f.layerEndDoc()
+	f.outputTime = time.Now()
+	if f.pdfaPart > 0 {
+		f.pdfaPrepare()
+		if f.err != nil {
+			return
+		}
+	}
 	f.putheader()
 	// Embedded files
 	f.putAttachments()
@@ -1118,8 +1280,6 @@ func (f *DocPDF) enddoc() {
 	f.putinfo()
 	f.out(">>")
 	f.out("endobj")
-	// Output intent color profile streams
-	f.putOutputIntentStreams()
 	// 	Catalog
 	f.newobj()
 	f.out("<<")
@@ -1142,6 +1302,8 @@ func (f *DocPDF) enddoc() {
 	f.out("startxref")
 	f.outf("%d", o)
 	f.out("%%EOF")
+	// Signature over the completed file
+	f.putsignature()
 	f.state = 3
 }
 
@@ -143,6 +143,12 @@ func (f *DocPDF) GetStringSymbolWidth(s string) int {
 	if f.err != nil {
 		return 0
 	}
+	if runs := f.fallbackRuns(s); runs != nil {
+		return f.fallbackWidthRuns(runs)
+	}
+	if f.shapingActive() {
+		return f.shapedWidth(s)
+	}
 	w := 0
 	if f.isCurrentUTF8 {
 		for _, char := range s {
@@ -165,7 +171,7 @@ func (f *DocPDF) GetStringSymbolWidth(s string) int {
 			w += f.currentFont.Cw[ch]
 		}
 	}
-	return w
+	return w + f.kernWidth(s)
 }
 
 // SetLineWidth defines the line width. By default, the value equals 0.2 mm.
@@ -460,19 +466,28 @@ func (f *DocPDF) arc(x, y, rx, ry, degRotate, degStart, degEnd float64,
 // or Write() which are the standard methods to print text.
 func (f *DocPDF) Text(x, y float64, txtStr string) {
 	var txt2 string
-	if f.isCurrentUTF8 {
+	if runs := f.fallbackRuns(txtStr); runs != nil {
 		if f.isRTL {
-			txtStr = reverseText(txtStr)
 			x -= f.GetStringWidth(txtStr)
 		}
-		txt2 = f.escape(utf8toutf16(txtStr, false))
-		for _, uni := range txtStr {
-			f.currentFont.usedRunes[int(uni)] = int(uni)
+		txt2 = f.fallbackText(runs, 0)
+	} else if f.shapingActive() {
+		if f.isRTL {
+			x -= f.GetStringWidth(txtStr)
+		}
+		txt2 = f.shapedText(txtStr, 0)
+	} else if f.isCurrentUTF8 {
+		if f.isRTL {
+			txtStr = reverseText(txtStr)
+			x -= f.GetStringWidth(txtStr)
 		}
+		txt2 = f.runText(txtStr, 0)
+	} else if f.kerning {
+		txt2 = "[" + f.kernedText(txtStr, 0) + "] TJ"
 	} else {
-		txt2 = f.escape(txtStr)
+		txt2 = "(" + f.escape(txtStr) + ") Tj"
 	}
-	s := sprintf("BT %.2f %.2f Td (%s) Tj ET", x*f.k, (f.h-y)*f.k, txt2)
+	s := sprintf("BT %.2f %.2f Td %s ET", x*f.k, (f.h-y)*f.k, txt2)
 	if f.underline && txtStr != "" {
 		s += " " + f.dounderline(x, y, txtStr)
 	}
@@ -482,7 +497,14 @@ func (f *DocPDF) Text(x, y float64, txtStr string) {
 	if f.colorFlag {
 		s = sprintf("q %s %s Q", f.color.text.str, s)
 	}
+	if f.tagAuto("P", "") {
+		defer f.tagPop()
+	}
+	marked := f.markBegin(true)
 	f.out(s)
+	if marked {
+		f.markEnd()
+	}
 }
 
 // Line draws a line between points (x1, y1) and (x2, y2) using the current
@@ -888,8 +910,18 @@ func (f *DocPDF) gradient(tp, r1, g1, b1, r2, g2, b2 int, x1, y1, x2, y2, r floa
 	pos := len(f.gradientList)
 	clr1 := f.rgbColorValue(r1, g1, b1, "", "")
 	clr2 := f.rgbColorValue(r2, g2, b2, "", "")
-	f.gradientList = append(f.gradientList, gradientType{tp, clr1.str, clr2.str,
-		x1, y1, x2, y2, r, 0})
+	f.gradientList = append(f.gradientList, gradientType{tp: tp, clr1Str: clr1.str, clr2Str: clr2.str,
+		x1: x1, y1: y1, x2: x2, y2: y2, r: r, comps: f.rgbComps(), extend: [2]bool{true, true}})
+	f.outf("/Sh%d sh", pos)
+}
+
+// gradientStops paints a blending of the colors of stops, the first of which
+// is at offset 0 and the last at offset 1
+func (f *DocPDF) gradientStops(tp int, stops []gradientStopType, x1, y1, x2, y2, r float64) {
+	pos := len(f.gradientList)
+	f.gradientList = append(f.gradientList, gradientType{tp: tp, clr1Str: stops[0].clrStr,
+		clr2Str: stops[len(stops)-1].clrStr, x1: x1, y1: y1, x2: x2, y2: y2, r: r, stops: stops,
+		comps: f.rgbComps(), extend: [2]bool{true, true}})
 	f.outf("/Sh%d sh", pos)
 }
 
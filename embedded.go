@@ -11,6 +11,11 @@ import (
 //go:embed font_embed/*.json font_embed/*.map
 var embFS embed.FS
 
+// sRGB color profile of the output intent of PDF/A documents
+//
+//go:embed icc/sRGB2014.icc
+var srgbProfile []byte
+
 func (f *DocPDF) coreFontReader(familyStr, styleStr string) (r io.ReadCloser) {
 	key := familyStr + styleStr
 	key = strings.ToLower(key)
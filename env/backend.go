@@ -22,65 +22,78 @@ func SetupDefaultFileWriter() func(filename string, data []byte) error {
 	}
 }
 
-// FileExists checks if a file exists and returns its contents.
+// SetupDefaultFileExists configures the default file reader for backend environments.
+// The returned function checks if a file exists and returns its contents.
 // Accepts string (path) or []byte (content).
 // For paths, verifies existence and reads the file.
 // For []byte, returns the provided content directly.
-func FileExists(pathOrContent any) ([]byte, error) {
-	switch v := pathOrContent.(type) {
-	case string:
-		// Handle path string
-		// Get absolute path
-		absolutePath, err := filepath.Abs(v)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get absolute path: %w", err)
-		}
+func SetupDefaultFileExists() func(pathOrContent any) ([]byte, error) {
+	return func(pathOrContent any) ([]byte, error) {
+		switch v := pathOrContent.(type) {
+		case string:
+			// Handle path string
+			// Get absolute path
+			absolutePath, err := filepath.Abs(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get absolute path: %w", err)
+			}
 
-		// Check if file exists and is not a directory
-		info, err := os.Stat(absolutePath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return nil, fmt.Errorf("file does not exist: %s", absolutePath)
+			// Check if file exists and is not a directory
+			info, err := os.Stat(absolutePath)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil, fmt.Errorf("file does not exist: %s", absolutePath)
+				}
+				return nil, fmt.Errorf("failed to stat file: %w", err)
 			}
-			return nil, fmt.Errorf("failed to stat file: %w", err)
-		}
 
-		if info.IsDir() {
-			return nil, fmt.Errorf("path is a directory, not a file: %s", absolutePath)
-		}
+			if info.IsDir() {
+				return nil, fmt.Errorf("path is a directory, not a file: %s", absolutePath)
+			}
 
-		// Read file content
-		content, err := os.ReadFile(absolutePath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read file: %w", err)
-		}
+			// Read file content
+			content, err := os.ReadFile(absolutePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read file: %w", err)
+			}
 
-		return content, nil
+			return content, nil
 
-	case []byte:
-		// If content is provided directly, return it as is
-		return v, nil
+		case []byte:
+			// If content is provided directly, return it as is
+			return v, nil
 
-	default:
-		return nil, fmt.Errorf("unsupported type: %T, expected string or []byte", pathOrContent)
+		default:
+			return nil, fmt.Errorf("unsupported type: %T, expected string or []byte", pathOrContent)
+		}
 	}
 }
 
-// GetSize returns the size of a file or byte slice.
-// For backend: uses os.Stat for files, len() for byte slices.
-func GetSize(pathOrContent any) (int64, error) {
-	switch v := pathOrContent.(type) {
-	case string:
-		// Assume it's a file path
-		stat, err := os.Stat(v)
-		if err != nil {
-			return -1, err
+// SetupDefaultGetSize configures the default size function for backend environments.
+// The returned function returns the size of a file or byte slice:
+// uses os.Stat for files, len() for byte slices.
+func SetupDefaultGetSize() func(pathOrContent any) (int64, error) {
+	return func(pathOrContent any) (int64, error) {
+		switch v := pathOrContent.(type) {
+		case string:
+			// Assume it's a file path
+			stat, err := os.Stat(v)
+			if err != nil {
+				return -1, err
+			}
+			if stat.IsDir() {
+				return -1, fmt.Errorf("path is a directory, not a file: %s", v)
+			}
+			return stat.Size(), nil
+		case []byte:
+			// It's already content
+			return int64(len(v)), nil
+		default:
+			return -1, fmt.Errorf("unsupported type for GetSize: %T", pathOrContent)
 		}
-		return stat.Size(), nil
-	case []byte:
-		// It's already content
-		return int64(len(v)), nil
-	default:
-		return -1, fmt.Errorf("unsupported type for GetSize: %T", pathOrContent)
 	}
 }
+
+func isAbsOS(p string) bool {
+	return filepath.IsAbs(p)
+}
@@ -1,5 +1,7 @@
 package env
 
+import "path"
+
 var (
 	// logger is a function that will be used for logging
 	// depending on the environment (WASM or backend)
@@ -7,4 +9,38 @@ var (
 	// FileWriter is a function that will be used for writing PDF data to a file
 	// eg: FileWriter("output.pdf", data)
 	FileWriter = SetupDefaultFileWriter()
+	// FileExists is a function that will be used for reading files (fonts, images, etc.)
+	// it returns the content of the file or an error if the file does not exist
+	// eg: FileExists("fonts/calligra.ttf")
+	FileExists = SetupDefaultFileExists()
+	// GetSize is a function that will be used to obtain the size of a file
+	// eg: GetSize("fonts/calligra.ttf")
+	GetSize = SetupDefaultGetSize()
 )
+
+// IsURL checks if a string is a valid URL
+func IsURL(str string) bool {
+	// Simple check for URL format (starts with http:// or https://)
+	return len(str) > 8 && (str[:7] == "http://" || str[:8] == "https://")
+}
+
+// JoinPath joins any number of path elements into a single path. Unlike
+// path.Join, the scheme of a base URL (eg: "https://cdn.example.com/fonts")
+// is preserved so that the result can be fetched in wasm environments.
+func JoinPath(elem ...string) string {
+	if len(elem) > 0 && IsURL(elem[0]) {
+		scheme := "http://"
+		if elem[0][:8] == "https://" {
+			scheme = "https://"
+		}
+		parts := append([]string{elem[0][len(scheme):]}, elem[1:]...)
+		return scheme + path.Join(parts...)
+	}
+	return path.Join(elem...)
+}
+
+// IsAbs reports whether the path is absolute or a URL, in which case
+// it must not be joined with a base directory.
+func IsAbs(p string) bool {
+	return IsURL(p) || path.IsAbs(p) || isAbsOS(p)
+}
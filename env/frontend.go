@@ -23,9 +23,32 @@ func SetupDefaultLogger() func(a ...any) {
 	}
 }
 
+// SetupDefaultFileWriter configures the default file writer for frontend
+// environments. The data is handed off to the browser as a Blob and a
+// download of filename is triggered.
 func SetupDefaultFileWriter() func(filename string, data []byte) error {
 	return func(filename string, data []byte) error {
-		return errs.New("file writing not implemented in frontend")
+		document := js.Global().Get("document")
+		if document.IsUndefined() || document.IsNull() {
+			return errs.New("file writing requires a browser document")
+		}
+
+		// Copy Go []byte to a Uint8Array
+		uint8Array := js.Global().Get("Uint8Array").New(len(data))
+		js.CopyBytesToJS(uint8Array, data)
+
+		blob := js.Global().Get("Blob").New([]any{uint8Array}, map[string]any{"type": "application/pdf"})
+		url := js.Global().Get("URL").Call("createObjectURL", blob)
+		defer js.Global().Get("URL").Call("revokeObjectURL", url)
+
+		anchor := document.Call("createElement", "a")
+		anchor.Set("href", url)
+		anchor.Set("download", filename)
+		anchor.Get("style").Set("display", "none")
+		document.Get("body").Call("appendChild", anchor)
+		anchor.Call("click")
+		document.Get("body").Call("removeChild", anchor)
+		return nil
 	}
 }
 
@@ -83,25 +106,22 @@ func FetchURL(url string) ([]byte, error) {
 	}
 }
 
-// isURL checks if a string is a valid URL
-func isURL(str string) bool {
-	// Simple check for URL format (starts with http:// or https://)
-	return len(str) > 8 && (str[:7] == "http://" || str[:8] == "https://")
-}
-
-// FileExists checks if a file exists and returns its contents.
-// Accepts string (path/URL) or []byte (content).
-// In frontend environment:
+// SetupDefaultFileExists configures the default file reader for frontend
+// environments. The returned function accepts string (path/URL) or []byte (content):
 // - If given a URL, it will fetch the content using JavaScript fetch API
 // - If given local file path, it warns that direct access is not supported
 // - If given []byte, it returns the content directly
-func FileExists(pathOrContent any) ([]byte, error) {
+func SetupDefaultFileExists() func(pathOrContent any) ([]byte, error) {
+	return fileExists
+}
+
+func fileExists(pathOrContent any) ([]byte, error) {
 	console := js.Global().Get("console")
 
 	switch v := pathOrContent.(type) {
 	case string:
 		// Check if it's a URL
-		if isURL(v) {
+		if IsURL(v) {
 			// Use the reusable FetchURL function
 			return FetchURL(v)
 		} else {
@@ -121,16 +141,20 @@ func FileExists(pathOrContent any) ([]byte, error) {
 	}
 }
 
-// GetSize returns the size of content from a URL or a byte slice.
-// For frontend: fetches URL content for size, uses len() for byte slices.
+// SetupDefaultGetSize configures the default size function for frontend
+// environments: fetches URL content for size, uses len() for byte slices.
 // Does not support local file paths.
-func GetSize(pathOrContent any) (int64, error) {
+func SetupDefaultGetSize() func(pathOrContent any) (int64, error) {
+	return getSize
+}
+
+func getSize(pathOrContent any) (int64, error) {
 	console := js.Global().Get("console")
 
 	switch v := pathOrContent.(type) {
 	case string:
 		// Check if it's a URL
-		if isURL(v) {
+		if IsURL(v) {
 			// Fetch the content to get its size
 			content, err := FetchURL(v)
 			if err != nil {
@@ -152,3 +176,7 @@ func GetSize(pathOrContent any) (int64, error) {
 		return -1, js.Error{Value: js.ValueOf(errMsg)}
 	}
 }
+
+func isAbsOS(p string) bool {
+	return false
+}
@@ -0,0 +1,174 @@
+package docpdf
+
+import (
+	"strings"
+	"unicode"
+)
+
+// fallbackRunType is a part of a text shown with a single font. key is the
+// key of the fallback font, or empty for the current font.
+type fallbackRunType struct {
+	key  string
+	text string
+}
+
+// SetFontFallback sets the fallback fonts of the UTF-8 font family familyStr.
+// Characters that are missing from the fonts of the family are shown with
+// the first font of the fallbacks families that has a glyph for them, in the
+// same style if the fallback family has it and in regular style otherwise.
+// All the fonts must have been added with AddUTF8Font() or a similar method.
+// Use a nil fallbacks to remove the fallback fonts of a family.
+//
+// Fallback fonts apply to the text output by Cell(), CellFormat(),
+// MultiCell(), Write() and Text(), and are taken into account by
+// GetStringWidth() and SplitText(). This lets text in any script, such as
+// names of people, print correctly when the main font of the document does
+// not cover it.
+func (f *DocPDF) SetFontFallback(familyStr string, fallbacks []string) {
+	familyStr = strings.ToLower(fontFamilyEscape(familyStr))
+	if len(fallbacks) == 0 {
+		delete(f.fontFallbacks, familyStr)
+		return
+	}
+	if f.fontFallbacks == nil {
+		f.fontFallbacks = make(map[string][]string)
+	}
+	chain := make([]string, len(fallbacks))
+	for j, fb := range fallbacks {
+		chain[j] = strings.ToLower(fontFamilyEscape(fb))
+	}
+	f.fontFallbacks[familyStr] = chain
+}
+
+// fontCovers reports whether the UTF-8 font has a glyph for r.
+func fontCovers(font *fontDefType, r rune) bool {
+	return font.Tp == "UTF8" && int(r) < len(font.Cw) && font.Cw[r] != 0
+}
+
+// fallbackFont returns the key of the font used to show r, missing from the
+// current font, or an empty string if no fallback font has a glyph for it.
+func (f *DocPDF) fallbackFont(r rune) string {
+	if !f.isCurrentUTF8 || f.inFallback || fontCovers(&f.currentFont, r) {
+		return ""
+	}
+	for _, fb := range f.fontFallbacks[f.fontFamily] {
+		for _, style := range []string{f.fontStyle, ""} {
+			key := getFontKey(fb, style)
+			if font, ok := f.fonts[key]; ok && fontCovers(&font, r) {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+// fallbackWidth returns the width of r, in thousandths of the font size, if
+// it is shown with a fallback font.
+func (f *DocPDF) fallbackWidth(r rune) (int, bool) {
+	key := f.fallbackFont(r)
+	if key == "" {
+		return 0, false
+	}
+	if w := f.fonts[key].Cw[r]; w != 65535 {
+		return w, true
+	}
+	return 0, true
+}
+
+// fallbackRuns splits txt into runs of characters shown with the same font.
+// It returns nil if the whole text is shown with the current font.
+func (f *DocPDF) fallbackRuns(txt string) []fallbackRunType {
+	if !f.isCurrentUTF8 || f.inFallback || len(f.fontFallbacks[f.fontFamily]) == 0 {
+		return nil
+	}
+	var runs []fallbackRunType
+	key, start := "", 0
+	for pos, r := range txt {
+		k := key
+		// spaces and marks stay with the preceding characters
+		if !unicode.IsSpace(r) && !unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+			k = f.fallbackFont(r)
+		}
+		if pos > 0 && k != key {
+			runs = append(runs, fallbackRunType{key: key, text: txt[start:pos]})
+			start = pos
+		}
+		key = k
+	}
+	runs = append(runs, fallbackRunType{key: key, text: txt[start:]})
+	if len(runs) == 1 && key == "" {
+		return nil
+	}
+	return runs
+}
+
+// withFont calls fn with the font of the given key, or the current font if
+// key is empty, as current font.
+func (f *DocPDF) withFont(key string, fn func()) {
+	font := f.currentFont
+	if key != "" {
+		f.currentFont = f.fonts[key]
+	}
+	f.inFallback = true
+	fn()
+	f.inFallback = false
+	f.currentFont = font
+}
+
+// fallbackWidthRuns returns the width of the runs, in thousandths of the
+// font size.
+func (f *DocPDF) fallbackWidthRuns(runs []fallbackRunType) (w int) {
+	for _, run := range runs {
+		f.withFont(run.key, func() {
+			w += f.GetStringSymbolWidth(run.text)
+		})
+	}
+	return
+}
+
+// fallbackText returns the text showing operators of the runs, switching
+// fonts between them. spaceAdj is added to the width of each space, in
+// thousandths of the font size.
+func (f *DocPDF) fallbackText(runs []fallbackRunType, spaceAdj float64) string {
+	if f.isRTL && !f.shapingActive() {
+		reversed := make([]fallbackRunType, len(runs))
+		for j, run := range runs {
+			reversed[len(runs)-1-j] = fallbackRunType{key: run.key, text: reverseText(run.text)}
+		}
+		runs = reversed
+	}
+	var s fmtBuffer
+	active := f.currentFont.i
+	for j, run := range runs {
+		f.withFont(run.key, func() {
+			if f.currentFont.i != active {
+				active = f.currentFont.i
+				s.printf("/F%s %.2f Tf ", active, f.fontSizePt)
+			}
+			s.printf("%s ", f.runText(run.text, spaceAdj))
+		})
+		// the adjustment of a space that ends a run
+		if spaceAdj != 0 && j < len(runs)-1 && strings.HasSuffix(run.text, " ") {
+			s.printf("[%.3f] TJ ", -spaceAdj)
+		}
+	}
+	if active != f.currentFont.i {
+		s.printf("/F%s %.2f Tf", f.currentFont.i, f.fontSizePt)
+	}
+	return strings.TrimSpace(s.String())
+}
+
+// runText returns the text showing operators of txt with the current UTF-8
+// font.
+func (f *DocPDF) runText(txt string, spaceAdj float64) string {
+	switch {
+	case f.shapingActive():
+		return f.shapedText(txt, spaceAdj)
+	case f.kerning || spaceAdj != 0:
+		return "[" + f.kernedText(txt, spaceAdj) + "] TJ"
+	}
+	for _, uni := range txt {
+		f.currentFont.usedRunes[int(uni)] = int(uni)
+	}
+	return "(" + f.escape(utf8toutf16(txt, false)) + ") Tj"
+}
@@ -9,14 +9,16 @@ package docpdf
 
 import (
 	"bufio"
+	"bytes"
 	"compress/zlib"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/cdvelop/docpdf/env"
 )
 
 func baseNoExt(fileStr string) string {
@@ -30,16 +32,15 @@ func baseNoExt(fileStr string) string {
 
 func loadMap(encodingFileStr string) (encList encListType, err error) {
 	// printf("Encoding file string [%s]\n", encodingFileStr)
-	var f *os.File
-	// f, err = os.Open(encodingFilepath(encodingFileStr))
-	f, err = os.Open(encodingFileStr)
+	var data []byte
+	// data, err = env.FileExists(encodingFilepath(encodingFileStr))
+	data, err = env.FileExists(encodingFileStr)
 	if err == nil {
-		defer f.Close()
 		for j := range encList {
 			encList[j].uv = -1
 			encList[j].name = ".notdef"
 		}
-		scanner := bufio.NewScanner(f)
+		scanner := bufio.NewScanner(bytes.NewReader(data))
 		var enc encType
 		var pos int
 		for scanner.Scan() {
@@ -76,7 +77,7 @@ func getInfoFromTrueType(fileStr string, msgWriter io.Writer, embed bool, encLis
 			err = fmt.Errorf("font license does not allow embedding")
 			return
 		}
-		info.Data, err = os.ReadFile(fileStr)
+		info.Data, err = env.FileExists(fileStr)
 		if err != nil {
 			return
 		}
@@ -115,6 +116,27 @@ func getInfoFromTrueType(fileStr string, msgWriter io.Writer, embed bool, encLis
 		}
 		info.Widths[j] = wd
 	}
+	for j := 32; j < len(encList); j++ {
+		left, ok := ttf.Chars[uint16(encList[j].uv)]
+		if !ok || encList[j].name == ".notdef" {
+			continue
+		}
+		for n := 32; n < len(encList); n++ {
+			right, ok := ttf.Chars[uint16(encList[n].uv)]
+			if !ok || encList[n].name == ".notdef" {
+				continue
+			}
+			if kp := round(k * float64(ttf.KernPair(left, right))); kp != 0 {
+				if info.Kerning == nil {
+					info.Kerning = make(map[int]map[int]int)
+				}
+				if info.Kerning[j] == nil {
+					info.Kerning[j] = make(map[int]int)
+				}
+				info.Kerning[j][n] = kp
+			}
+		}
+	}
 	// printf("getInfoFromTrueType/FontBBox\n")
 	// dump(info.Desc.FontBBox)
 	return
@@ -153,12 +175,12 @@ func segmentRead(r io.Reader) (s segmentType, err error) {
 func getInfoFromType1(fileStr string, msgWriter io.Writer, embed bool, encList encListType) (info fontInfoType, err error) {
 	info.Widths = make([]int, 256)
 	if embed {
-		var f *os.File
-		f, err = os.Open(fileStr)
+		var data []byte
+		data, err = env.FileExists(fileStr)
 		if err != nil {
 			return
 		}
-		defer f.Close()
+		f := bytes.NewReader(data)
 		// Read first segment
 		var s1, s2 segmentType
 		s1, err = segmentRead(f)
@@ -184,13 +206,12 @@ func getInfoFromType1(fileStr string, msgWriter io.Writer, embed bool, encList e
 		return
 	}
 
-	f, err := os.Open(afmFileStr)
+	afm, err := env.FileExists(afmFileStr)
 	if err != nil {
 		return
 	}
-	defer f.Close()
 
-	p := newAFMParser(f)
+	p := newAFMParser(bytes.NewReader(afm))
 	err = p.parse(&info)
 	if err != nil {
 		return info, err
@@ -222,6 +243,25 @@ func getInfoFromType1(fileStr string, msgWriter io.Writer, embed bool, encList e
 			}
 		}
 	}
+	codes := make(map[string][]int)
+	for j := 32; j < len(encList); j++ {
+		if name := encList[j].name; name != ".notdef" {
+			codes[name] = append(codes[name], j)
+		}
+	}
+	for pair, kp := range p.kpx {
+		for _, j := range codes[pair[0]] {
+			for _, n := range codes[pair[1]] {
+				if info.Kerning == nil {
+					info.Kerning = make(map[int]map[int]int)
+				}
+				if info.Kerning[j] == nil {
+					info.Kerning[j] = make(map[int]int)
+				}
+				info.Kerning[j][n] = kp
+			}
+		}
+	}
 	// printf("getInfoFromType1/FontBBox\n")
 	// dump(info.Desc.FontBBox)
 	return
@@ -282,6 +322,7 @@ func makeDefinitionFile(fileStr, tpStr, encodingFileStr string, embed bool, encL
 	def.Up = info.UnderlinePosition
 	def.Ut = info.UnderlineThickness
 	def.Cw = info.Widths
+	def.Kp = info.Kerning
 	def.Enc = baseNoExt(encodingFileStr)
 	// fmt.Printf("encodingFileStr [%s], def.Enc [%s]\n", encodingFileStr, def.Enc)
 	// fmt.Printf("reference [%s]\n", filepath.Join(filepath.Dir(encodingFileStr), "cp1252.map"))
@@ -299,23 +340,7 @@ func makeDefinitionFile(fileStr, tpStr, encodingFileStr string, embed bool, encL
 	if err != nil {
 		return err
 	}
-	var f *os.File
-	f, err = os.Create(fileStr)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	_, err = f.Write(buf)
-	if err != nil {
-		return err
-	}
-
-	err = f.Close()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return env.FileWriter(fileStr, buf)
 }
 
 // MakeFont generates a font definition file in JSON format. A definition file
@@ -383,20 +408,19 @@ func MakeFont(fontFileStr, encodingFileStr, dstDirStr string, msgWriter io.Write
 	baseStr := baseNoExt(fontFileStr)
 	// fmt.Printf("Base [%s]\n", baseStr)
 	if embed {
-		var f *os.File
+		var buf bytes.Buffer
 		info.File = baseStr + ".z"
 		zFileStr := filepath.Join(dstDirStr, info.File)
-		f, err = os.Create(zFileStr)
+		cmp := zlib.NewWriter(&buf)
+		_, err = cmp.Write(info.Data)
 		if err != nil {
 			return err
 		}
-		defer f.Close()
-		cmp := zlib.NewWriter(f)
-		_, err = cmp.Write(info.Data)
+		err = cmp.Close()
 		if err != nil {
 			return err
 		}
-		err = cmp.Close()
+		err = env.FileWriter(zFileStr, buf.Bytes())
 		if err != nil {
 			return err
 		}
@@ -16,12 +16,14 @@ type afmParser struct {
 	toks []string
 
 	wdmap map[string]int
+	kpx   map[[2]string]int // kerning pairs by glyph names
 }
 
 func newAFMParser(r io.Reader) *afmParser {
 	return &afmParser{
 		s:     bufio.NewScanner(r),
 		wdmap: make(map[string]int),
+		kpx:   make(map[[2]string]int),
 	}
 }
 
@@ -60,6 +62,11 @@ loop:
 			if err != nil {
 				return fmt.Errorf("could not scan AFM CharMetrics section: %w", err)
 			}
+		case "StartKernPairs", "StartKernPairs0":
+			err := p.parseKernPairs()
+			if err != nil {
+				return fmt.Errorf("could not scan AFM KernPairs section: %w", err)
+			}
 		case "EndFontMetrics":
 			break loop
 		default:
@@ -121,6 +128,23 @@ func (p *afmParser) parseCharMetrics(fnt *fontInfoType, n int) error {
 	return p.err
 }
 
+func (p *afmParser) parseKernPairs() error {
+	for p.scan() {
+		switch p.toks[0] {
+		case "EndKernPairs":
+			return nil
+		case "KPX":
+			if len(p.toks) < 4 {
+				return fmt.Errorf("invalid KPX entry (line=%d)", p.line)
+			}
+			p.kpx[[2]string{p.toks[1], p.toks[2]}] = p.readFixed(3)
+		default:
+			// ignore KP, KPY, KPH and comments.
+		}
+	}
+	return p.err
+}
+
 func (p *afmParser) parseCharMetric(fnt *fontInfoType) error {
 	type metric struct {
 		Name string
@@ -5,12 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"os"
-	"path"
-	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/cdvelop/docpdf/env"
 )
 
 // AddFontFromBytes imports a TrueType, OpenType or Type1 font from static
@@ -29,7 +28,7 @@ import (
 //
 // zFileBytes contain all bytes of Z file.
 func (f *DocPDF) AddFontFromBytes(familyStr, styleStr string, jsonFileBytes, zFileBytes []byte) {
-	f.addFontFromBytes(fontFamilyEscape(familyStr), styleStr, jsonFileBytes, zFileBytes, nil)
+	f.addFontFromBytes(fontFamilyEscape(familyStr), styleStr, jsonFileBytes, zFileBytes, nil, 0, nil)
 }
 
 // AddUTF8FontFromBytes  imports a TrueType font with utf-8 symbols from static
@@ -48,10 +47,27 @@ func (f *DocPDF) AddFontFromBytes(familyStr, styleStr string, jsonFileBytes, zFi
 //
 // zFileBytes contain all bytes of Z file.
 func (f *DocPDF) AddUTF8FontFromBytes(familyStr, styleStr string, utf8Bytes []byte) {
-	f.addFontFromBytes(fontFamilyEscape(familyStr), styleStr, nil, nil, utf8Bytes)
+	f.addFontFromBytes(fontFamilyEscape(familyStr), styleStr, nil, nil, utf8Bytes, 0, nil)
+}
+
+// AddUTF8FontVariationFromBytes is like AddUTF8FontVariation() but reads the
+// variable font from static bytes.
+func (f *DocPDF) AddUTF8FontVariationFromBytes(familyStr, styleStr string, utf8Bytes []byte, variation map[string]float64) {
+	if variation == nil {
+		variation = map[string]float64{}
+	}
+	f.addFontFromBytes(fontFamilyEscape(familyStr), styleStr, nil, nil, utf8Bytes, 0, variation)
+}
+
+// AddUTF8FontFaceFromBytes is like AddUTF8FontFromBytes() but imports the
+// face of index faceIndex, starting at 0, of a TrueType or OpenType font
+// collection (.ttc). AddUTF8FontFromBytes() imports the first face of a
+// collection.
+func (f *DocPDF) AddUTF8FontFaceFromBytes(familyStr, styleStr string, utf8Bytes []byte, faceIndex int) {
+	f.addFontFromBytes(fontFamilyEscape(familyStr), styleStr, nil, nil, utf8Bytes, faceIndex, nil)
 }
 
-func (f *DocPDF) addFontFromBytes(familyStr, styleStr string, jsonFileBytes, zFileBytes, utf8Bytes []byte) {
+func (f *DocPDF) addFontFromBytes(familyStr, styleStr string, jsonFileBytes, zFileBytes, utf8Bytes []byte, face int, variation map[string]float64) {
 	if f.err != nil {
 		return
 	}
@@ -72,13 +88,22 @@ func (f *DocPDF) addFontFromBytes(familyStr, styleStr string, jsonFileBytes, zFi
 		// }
 
 		Type := "UTF8"
+		if variation != nil {
+			var err error
+			if utf8Bytes, err = instantiateVariation(utf8Bytes, face, variation); err != nil {
+				f.SetError(err)
+				return
+			}
+			face = 0
+		}
 		reader := fileReader{readerPosition: 0, array: utf8Bytes}
 
 		utf8File := newUTF8Font(&reader)
+		utf8File.faceIndex = face
 
 		err := utf8File.parseFile()
 		if err != nil {
-			fmt.Printf("get metrics Error: %e\n", err)
+			f.SetError(err)
 			return
 		}
 		desc := FontDescType{
@@ -430,7 +455,7 @@ func (f *DocPDF) SetFontLoader(loader FontLoader) {
 // definition file to be added. The file will be loaded from the font directory
 // specified in the call to New() or SetFontLocation().
 func (f *DocPDF) AddFont(familyStr, styleStr, fileStr string) {
-	f.addFont(fontFamilyEscape(familyStr), styleStr, fileStr, false)
+	f.addFont(fontFamilyEscape(familyStr), styleStr, fileStr, false, 0, nil)
 }
 
 // AddUTF8Font imports a TrueType font with utf-8 symbols and makes it available.
@@ -453,11 +478,38 @@ func (f *DocPDF) AddFont(familyStr, styleStr, fileStr string) {
 // fileStr specifies the base name with ".json" extension of the font
 // definition file to be added. The file will be loaded from the font directory
 // specified in the call to New() or SetFontLocation().
+//
+// Fonts with TrueType outlines (.ttf) and OpenType fonts with PostScript
+// outlines (.otf) are supported; the latter are embedded as CFF font
+// programs. Only the glyphs used in the document are embedded.
 func (f *DocPDF) AddUTF8Font(familyStr, styleStr, fileStr string) {
-	f.addFont(fontFamilyEscape(familyStr), styleStr, fileStr, true)
+	f.addFont(fontFamilyEscape(familyStr), styleStr, fileStr, true, 0, nil)
+}
+
+// AddUTF8FontVariation is like AddUTF8Font() but imports an instance of a
+// variable TrueType font, at the design coordinates given by axis tag in
+// variation, for example map[string]float64{"wght": 650, "wdth": 90}. The
+// axes that are not given take their default value, and values out of the
+// range of an axis are clamped to it. The outlines and advance widths of the
+// instance are computed from the gvar and HVAR tables when the font is
+// added, and the instance is then handled as a static font.
+func (f *DocPDF) AddUTF8FontVariation(familyStr, styleStr, fileStr string, variation map[string]float64) {
+	if variation == nil {
+		variation = map[string]float64{}
+	}
+	f.addFont(fontFamilyEscape(familyStr), styleStr, fileStr, true, 0, variation)
+}
+
+// AddUTF8FontFace is like AddUTF8Font() but imports the face of index
+// faceIndex, starting at 0, of a TrueType or OpenType font collection
+// (.ttc), such as the collections that hold the fonts of the several
+// Chinese, Japanese and Korean variants of a family. AddUTF8Font() imports
+// the first face of a collection.
+func (f *DocPDF) AddUTF8FontFace(familyStr, styleStr, fileStr string, faceIndex int) {
+	f.addFont(fontFamilyEscape(familyStr), styleStr, fileStr, true, faceIndex, nil)
 }
 
-func (f *DocPDF) addFont(familyStr, styleStr, fileStr string, isUTF8 bool) {
+func (f *DocPDF) addFont(familyStr, styleStr, fileStr string, isUTF8 bool, face int, variation map[string]float64) {
 	if fileStr == "" {
 		if isUTF8 {
 			fileStr = strings.Replace(familyStr, " ", "", -1) + strings.ToLower(styleStr) + ".ttf"
@@ -471,28 +523,28 @@ func (f *DocPDF) addFont(familyStr, styleStr, fileStr string, isUTF8 bool) {
 		if ok {
 			return
 		}
-		var ttfStat os.FileInfo
-		var err error
 		// If fileStr is already an absolute path, use it directly
 		// Otherwise, join it with the fonts path
-		if !filepath.IsAbs(fileStr) {
-			fileStr = path.Join(f.fontsPath, fileStr)
+		if !isAbsolutePath(fileStr) {
+			fileStr = env.JoinPath(f.fontsPath, fileStr)
 		}
-		ttfStat, err = os.Stat(fileStr)
+		utf8Bytes, err := f.readFile(fileStr)
 		if err != nil {
 			f.SetError(err)
 			return
 		}
-		originalSize := ttfStat.Size()
-		Type := "UTF8"
-		var utf8Bytes []byte
-		utf8Bytes, err = os.ReadFile(fileStr)
-		if err != nil {
-			f.SetError(err)
-			return
+		if variation != nil {
+			if utf8Bytes, err = instantiateVariation(utf8Bytes, face, variation); err != nil {
+				f.SetError(err)
+				return
+			}
+			face = 0
 		}
+		originalSize := int64(len(utf8Bytes))
+		Type := "UTF8"
 		reader := fileReader{readerPosition: 0, array: utf8Bytes}
 		utf8File := newUTF8Font(&reader)
+		utf8File.faceIndex = face
 		err = utf8File.parseFile()
 		if err != nil {
 			f.SetError(err)
@@ -550,17 +602,16 @@ func (f *DocPDF) addFont(familyStr, styleStr, fileStr string, isUTF8 bool) {
 
 		// If fileStr is already an absolute path, use it directly
 		// Otherwise, join it with the fonts path
-		if !filepath.IsAbs(fileStr) {
-			fileStr = path.Join(f.fontsPath, fileStr)
+		if !isAbsolutePath(fileStr) {
+			fileStr = env.JoinPath(f.fontsPath, fileStr)
 		}
-		file, err := os.Open(fileStr)
+		data, err := f.readFile(fileStr)
 		if err != nil {
 			f.err = err
 			return
 		}
-		defer file.Close()
 
-		f.AddFontFromReader(familyStr, styleStr, file)
+		f.AddFontFromReader(familyStr, styleStr, bytes.NewReader(data))
 	}
 }
 
@@ -587,11 +638,12 @@ func (f *DocPDF) loadFontFile(name string) ([]byte, error) {
 			return data, err
 		}
 	}
-	return os.ReadFile(path.Join(f.fontsPath, name))
+	return f.readFile(env.JoinPath(f.fontsPath, name))
 }
 
+// isAbsolutePath reports whether p is an absolute path or an URL
 func isAbsolutePath(p string) bool {
-	return filepath.IsAbs(p)
+	return env.IsAbs(p)
 }
 
 func (f *DocPDF) putfonts() {
@@ -640,7 +692,7 @@ func (f *DocPDF) putfonts() {
 					buf = append(buf, font[6+info.length1+6:info.length2]...)
 					font = buf
 				}
-				f.outf("<</Length %d", len(font))
+				f.outf("<</Length %d", f.protect.encryptedLen(len(font)))
 				if compressed {
 					f.out("/Filter /FlateDecode")
 				}
@@ -743,24 +795,43 @@ func (f *DocPDF) putfonts() {
 				f.newobj()
 				f.out(fmt.Sprintf("<</Type /Font\n/Subtype /Type0\n/BaseFont /%s\n/Encoding /Identity-H\n/DescendantFonts [%d 0 R]\n/ToUnicode %d 0 R>>\n"+"endobj", fontName, f.n+1, f.n+2))
 
+				// fonts with CFF outlines are embedded as CID-keyed CFF font
+				// programs, which map the identifiers to glyphs themselves
+				isCFF := font.utf8File.cff != nil
+				subtype := "CIDFontType2"
+				if isCFF {
+					subtype = "CIDFontType0"
+				}
 				f.newobj()
-				f.out("<</Type /Font\n/Subtype /CIDFontType2\n/BaseFont /" + fontName + "\n" +
+				f.out("<</Type /Font\n/Subtype /" + subtype + "\n/BaseFont /" + fontName + "\n" +
 					"/CIDSystemInfo " + strconv.Itoa(f.n+2) + " 0 R\n/FontDescriptor " + strconv.Itoa(f.n+3) + " 0 R")
 				if font.Desc.MissingWidth != 0 {
 					f.out("/DW " + strconv.Itoa(font.Desc.MissingWidth))
 				}
 				f.generateCIDFontMap(&font, font.utf8File.LastRune)
-				f.out("/CIDToGIDMap " + strconv.Itoa(f.n+4) + " 0 R>>")
+				if isCFF {
+					f.out(">>")
+				} else {
+					f.out("/CIDToGIDMap " + strconv.Itoa(f.n+4) + " 0 R>>")
+				}
 				f.out("endobj")
 
+				cmap := toUnicode
+				if sh := font.utf8File.shaperState; sh != nil && len(sh.cidText) > 0 {
+					cmap = toUnicodeCMap(sh.cidText)
+				}
 				f.newobj()
-				f.out("<</Length " + strconv.Itoa(len(toUnicode)) + ">>")
-				f.putstream([]byte(toUnicode))
+				f.out("<</Length " + strconv.Itoa(f.protect.encryptedLen(len(cmap))) + ">>")
+				f.putstream([]byte(cmap))
 				f.out("endobj")
 
 				// CIDInfo
 				f.newobj()
-				f.out("<</Registry (Adobe)\n/Ordering (UCS)\n/Supplement 0>>")
+				if isCFF {
+					f.out("<</Registry (Adobe)\n/Ordering (Identity)\n/Supplement 0>>")
+				} else {
+					f.out("<</Registry (Adobe)\n/Ordering (UCS)\n/Supplement 0>>")
+				}
 				f.out("endobj")
 
 				// Font descriptor
@@ -778,34 +849,44 @@ func (f *DocPDF) putfonts() {
 				s.printf(" /ItalicAngle %d", font.Desc.ItalicAngle)
 				s.printf(" /StemV %d", font.Desc.StemV)
 				s.printf(" /MissingWidth %d", font.Desc.MissingWidth)
-				s.printf("/FontFile2 %d 0 R", f.n+2)
+				if isCFF {
+					s.printf("/FontFile3 %d 0 R", f.n+1)
+				} else {
+					s.printf("/FontFile2 %d 0 R", f.n+2)
+				}
 				s.printf(">>")
 				f.out(s.String())
 				f.out("endobj")
 
-				// Embed CIDToGIDMap
-				cidToGidMap := make([]byte, 256*256*2)
+				if !isCFF {
+					// Embed CIDToGIDMap
+					cidToGidMap := make([]byte, 256*256*2)
 
-				for cc, glyph := range CodeSignDictionary {
-					cidToGidMap[cc*2] = byte(glyph >> 8)
-					cidToGidMap[cc*2+1] = byte(glyph & 0xFF)
-				}
+					for cc, glyph := range CodeSignDictionary {
+						cidToGidMap[cc*2] = byte(glyph >> 8)
+						cidToGidMap[cc*2+1] = byte(glyph & 0xFF)
+					}
 
-				mem := xmem.compress(cidToGidMap)
-				cidToGidMap = mem.bytes()
-				f.newobj()
-				f.out("<</Length " + strconv.Itoa(len(cidToGidMap)) + "/Filter /FlateDecode>>")
-				f.putstream(cidToGidMap)
-				f.out("endobj")
-				mem.release()
+					mem := xmem.compress(cidToGidMap)
+					cidToGidMap = mem.bytes()
+					f.newobj()
+					f.out("<</Length " + strconv.Itoa(f.protect.encryptedLen(len(cidToGidMap))) + "/Filter /FlateDecode>>")
+					f.putstream(cidToGidMap)
+					f.out("endobj")
+					mem.release()
+				}
 
 				//Font file
-				mem = xmem.compress(utf8FontStream)
+				mem := xmem.compress(utf8FontStream)
 				compressedFontStream := mem.bytes()
 				f.newobj()
-				f.out("<</Length " + strconv.Itoa(len(compressedFontStream)))
+				f.out("<</Length " + strconv.Itoa(f.protect.encryptedLen(len(compressedFontStream))))
 				f.out("/Filter /FlateDecode")
-				f.out("/Length1 " + strconv.Itoa(utf8FontSize))
+				if isCFF {
+					f.out("/Subtype /CIDFontType0C")
+				} else {
+					f.out("/Length1 " + strconv.Itoa(utf8FontSize))
+				}
 				f.out(">>")
 				f.putstream(compressedFontStream)
 				f.out("endobj")
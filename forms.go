@@ -0,0 +1,719 @@
+package docpdf
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// FormFieldOptions specifies the optional properties of an interactive form
+// field added with AddTextField(), AddCheckBox(), AddRadioButton(),
+// AddComboBox(), AddListBox() or AddPushButton(). Fields are drawn with the
+// current font, font size and text color; the border uses the current draw
+// color and line width and the background the current fill color.
+type FormFieldOptions struct {
+	// Value is the initial value of a text field or the selected item of a
+	// combo or list box.
+	Value string
+	// Default is the value restored when the form is reset. For a check box
+	// "Yes" means checked; for a radio group it is the value of one of its
+	// buttons.
+	Default string
+	// ToolTip is the text shown by the viewer when the pointer rests on the
+	// field.
+	ToolTip string
+	// ReadOnly prevents the user from changing the value of the field.
+	ReadOnly bool
+	// Required marks the field as needing a value before the form is
+	// submitted.
+	Required bool
+	// MaxLen is the maximum number of characters of a text field; 0 means
+	// no limit.
+	MaxLen int
+	// Multiline allows a text field to hold several lines, separated by "\n".
+	Multiline bool
+	// Password shows the characters of a text field as asterisks.
+	Password bool
+	// Editable allows the user to type a value not found in the items of a
+	// combo box.
+	Editable bool
+	// AlignStr is "L" (default), "C" or "R" for the alignment of the text.
+	AlignStr string
+	// Border draws a frame around the field.
+	Border bool
+	// Fill paints the background of the field.
+	Fill bool
+	// TabOrder sets the position of the field in the tab order of its page.
+	// Fields with a positive TabOrder are visited first, in increasing order,
+	// followed by the others in the order they were added.
+	TabOrder int
+	// JavaScript is run when a push button is pressed.
+	JavaScript string
+	// SubmitURL is the address the form is submitted to, in HTML form
+	// format, when a push button is pressed.
+	SubmitURL string
+}
+
+const (
+	formText = iota
+	formCheckBox
+	formRadio
+	formCombo
+	formList
+	formPushButton
+	formSignature
+)
+
+// Field flags, see tables 221, 226, 228 and 230 of the PDF specification
+const (
+	formFlagReadOnly    = 1 << 0
+	formFlagRequired    = 1 << 1
+	formFlagMultiline   = 1 << 12
+	formFlagPassword    = 1 << 13
+	formFlagNoToggleOff = 1 << 14
+	formFlagRadio       = 1 << 15
+	formFlagPushButton  = 1 << 16
+	formFlagCombo       = 1 << 17
+	formFlagEdit        = 1 << 18
+)
+
+type formFieldType struct {
+	kind    int
+	name    string
+	opts    FormFieldOptions
+	value   string   // text value, or state name of a button
+	items   []string // combo and list box items
+	utf8    bool     // values are encoded for a UTF-8 font
+	da      string   // default appearance
+	objNum  int
+	widgets []*formWidgetType
+}
+
+type formAppearanceType struct {
+	state   string // empty for a field with a single appearance
+	content []byte
+	objNum  int
+}
+
+type formWidgetType struct {
+	field      *formFieldType
+	page       int
+	pageObj    int
+	x, y, w, h float64 // points, lower left corner
+	tabOrder   int
+	onState    string // state name of a check box or radio button when on
+	bc, bg     string // border and background colors, empty if not drawn
+	bw         float64
+	caption    string
+	ap         []formAppearanceType
+	objNum     int
+}
+
+// AddTextField adds a text field named name to the current page, on the
+// rectangle defined by x, y, w and h. The initial text, the maximum length
+// and the multiline and password flags are taken from opts. Field names must
+// be unique in the document.
+func (f *DocPDF) AddTextField(name string, x, y, w, h float64, opts FormFieldOptions) {
+	fld := f.newFormField(formText, name, opts)
+	if fld == nil {
+		return
+	}
+	fld.value = opts.Value
+	wd := f.addFormWidget(fld, x, y, w, h, opts.TabOrder)
+	f.formMK(wd, opts, "")
+	wd.ap = []formAppearanceType{{content: f.formTextAppearance(wd, opts, opts.Value)}}
+}
+
+// AddCheckBox adds a square check box named name to the current page, with
+// its upper left corner at x, y. The box is initially checked if checked is
+// true.
+func (f *DocPDF) AddCheckBox(name string, x, y, size float64, checked bool, opts FormFieldOptions) {
+	fld := f.newFormField(formCheckBox, name, opts)
+	if fld == nil {
+		return
+	}
+	fld.value = "Off"
+	if checked {
+		fld.value = "Yes"
+	}
+	wd := f.addFormWidget(fld, x, y, size, size, opts.TabOrder)
+	wd.onState = "Yes"
+	f.formMK(wd, opts, "4")
+	wd.ap = []formAppearanceType{
+		{state: "Yes", content: f.formButtonAppearance(wd, opts, false, true)},
+		{state: "Off", content: f.formButtonAppearance(wd, opts, false, false)},
+	}
+}
+
+// AddRadioButton adds a round button, with its upper left corner at x, y,
+// to the radio group named group, creating the group on first use. The
+// buttons of a group can be spread over several pages; selecting one of
+// them deselects the others. value is the value the group takes when this
+// button is selected. The flags, tool tip and default value of the group are
+// taken from the opts of its first button.
+func (f *DocPDF) AddRadioButton(group, value string, x, y, size float64, selected bool, opts FormFieldOptions) {
+	if f.err != nil {
+		return
+	}
+	var fld *formFieldType
+	for _, fl := range f.formFields {
+		if fl.name == group {
+			if fl.kind != formRadio {
+				f.err = fmt.Errorf("form field %q is not a radio group", group)
+				return
+			}
+			fld = fl
+		}
+	}
+	if fld == nil {
+		fld = f.newFormField(formRadio, group, opts)
+		if fld == nil {
+			return
+		}
+		fld.value = "Off"
+	} else if f.page < 1 {
+		f.err = fmt.Errorf("form field %q: no page has been added", group)
+		return
+	}
+	state := formStateName(value)
+	for _, wd := range fld.widgets {
+		if wd.onState == state {
+			f.err = fmt.Errorf("radio group %q already has a button with value %q", group, value)
+			return
+		}
+	}
+	if selected {
+		fld.value = state
+	}
+	wd := f.addFormWidget(fld, x, y, size, size, opts.TabOrder)
+	wd.onState = state
+	f.formMK(wd, opts, "l")
+	wd.ap = []formAppearanceType{
+		{state: state, content: f.formButtonAppearance(wd, opts, true, true)},
+		{state: "Off", content: f.formButtonAppearance(wd, opts, true, false)},
+	}
+}
+
+// AddComboBox adds a drop-down list named name, offering items, to the
+// current page on the rectangle defined by x, y, w and h. The selected item
+// is given by opts.Value. If opts.Editable is set the user may also type a
+// value of their own.
+func (f *DocPDF) AddComboBox(name string, x, y, w, h float64, items []string, opts FormFieldOptions) {
+	fld := f.newFormField(formCombo, name, opts)
+	if fld == nil {
+		return
+	}
+	fld.value = opts.Value
+	fld.items = items
+	wd := f.addFormWidget(fld, x, y, w, h, opts.TabOrder)
+	f.formMK(wd, opts, "")
+	opts.Multiline = false
+	opts.Password = false
+	wd.ap = []formAppearanceType{{content: f.formTextAppearance(wd, opts, opts.Value)}}
+}
+
+// AddListBox adds a scrollable list named name, offering items, to the
+// current page on the rectangle defined by x, y, w and h. The selected item
+// is given by opts.Value.
+func (f *DocPDF) AddListBox(name string, x, y, w, h float64, items []string, opts FormFieldOptions) {
+	fld := f.newFormField(formList, name, opts)
+	if fld == nil {
+		return
+	}
+	fld.value = opts.Value
+	fld.items = items
+	wd := f.addFormWidget(fld, x, y, w, h, opts.TabOrder)
+	f.formMK(wd, opts, "")
+	wd.ap = []formAppearanceType{{content: f.formListAppearance(wd, opts, items, opts.Value)}}
+}
+
+// AddPushButton adds a button named name, labelled with caption, to the
+// current page on the rectangle defined by x, y, w and h. Pressing the
+// button runs opts.JavaScript or, if it is empty, submits the form to
+// opts.SubmitURL.
+func (f *DocPDF) AddPushButton(name, caption string, x, y, w, h float64, opts FormFieldOptions) {
+	fld := f.newFormField(formPushButton, name, opts)
+	if fld == nil {
+		return
+	}
+	fld.value = caption
+	wd := f.addFormWidget(fld, x, y, w, h, opts.TabOrder)
+	f.formMK(wd, opts, f.formString(fld, caption))
+	opts.AlignStr = "C"
+	opts.Multiline = false
+	opts.Password = false
+	wd.ap = []formAppearanceType{{content: f.formTextAppearance(wd, opts, caption)}}
+}
+
+// newFormField registers a field of the given kind and returns it, or nil
+// if the field cannot be added.
+func (f *DocPDF) newFormField(kind int, name string, opts FormFieldOptions) *formFieldType {
+	if f.err != nil {
+		return nil
+	}
+	if f.page < 1 {
+		f.err = fmt.Errorf("form field %q: no page has been added", name)
+		return nil
+	}
+	if f.currentFont.Name == "" {
+		f.err = fmt.Errorf("font has not been set; unable to render form field %q", name)
+		return nil
+	}
+	if name == "" || strings.Contains(name, ".") {
+		f.err = fmt.Errorf("invalid form field name %q", name)
+		return nil
+	}
+	for _, fl := range f.formFields {
+		if fl.name == name {
+			f.err = fmt.Errorf("form field %q is already defined", name)
+			return nil
+		}
+	}
+	fld := &formFieldType{kind: kind, name: name, opts: opts, utf8: f.isCurrentUTF8}
+	switch kind {
+	case formCheckBox, formRadio:
+		fld.da = sprintf("/ZaDb 0 Tf %s", f.color.text.str)
+	default:
+		fld.da = sprintf("/F%s %.2f Tf %s", f.currentFont.i, f.fontSizePt, f.color.text.str)
+	}
+	f.formFields = append(f.formFields, fld)
+	return fld
+}
+
+func (f *DocPDF) addFormWidget(fld *formFieldType, x, y, w, h float64, tabOrder int) *formWidgetType {
+	wd := &formWidgetType{
+		field:    fld,
+		page:     f.page,
+		tabOrder: tabOrder,
+		x:        x * f.k, y: f.hPt - (y+h)*f.k, w: w * f.k, h: h * f.k,
+	}
+	fld.widgets = append(fld.widgets, wd)
+	f.pageWidgets[f.page] = append(f.pageWidgets[f.page], wd)
+	return wd
+}
+
+// formStateName returns s as the name of an appearance state.
+func formStateName(s string) string {
+	var b strings.Builder
+	for j := 0; j < len(s); j++ {
+		c := s[j]
+		if c <= ' ' || c > '~' || strings.IndexByte("#()<>[]{}/%", c) >= 0 {
+			fmt.Fprintf(&b, "#%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	if b.Len() == 0 {
+		return "On"
+	}
+	return b.String()
+}
+
+// formString returns s as an unescaped PDF text string for the field.
+func (f *DocPDF) formString(fld *formFieldType, s string) string {
+	if fld.utf8 {
+		return utf8toutf16(s)
+	}
+	return s
+}
+
+// formEncode returns s escaped for a text showing operator in the current
+// font.
+func (f *DocPDF) formEncode(s string) string {
+	if f.isCurrentUTF8 {
+		for _, uni := range s {
+			f.currentFont.usedRunes[int(uni)] = int(uni)
+		}
+		return f.escape(utf8toutf16(s, false))
+	}
+	return f.escape(s)
+}
+
+// formMK records the appearance characteristics of a widget, used by
+// viewers that rebuild its appearance.
+func (f *DocPDF) formMK(wd *formWidgetType, opts FormFieldOptions, caption string) {
+	if opts.Border {
+		c := f.color.draw
+		wd.bc = sprintf("%.3f %.3f %.3f", c.r, c.g, c.b)
+		wd.bw = f.lineWidth * f.k
+	}
+	if opts.Fill {
+		c := f.color.fill
+		wd.bg = sprintf("%.3f %.3f %.3f", c.r, c.g, c.b)
+	}
+	wd.caption = caption
+}
+
+// formFrame paints the background and border of a widget and returns the
+// width of the border.
+func (f *DocPDF) formFrame(s *fmtBuffer, wd *formWidgetType, opts FormFieldOptions, round bool) (lw float64) {
+	if opts.Border {
+		lw = f.lineWidth * f.k
+	}
+	shape := func(inset float64) {
+		if round {
+			r := math.Min(wd.w, wd.h)/2 - inset
+			formCircle(s, wd.w/2, wd.h/2, r)
+		} else {
+			s.printf("%.2f %.2f %.2f %.2f re ", inset, inset, wd.w-2*inset, wd.h-2*inset)
+		}
+	}
+	if opts.Fill {
+		s.printf("%s ", f.color.fill.str)
+		shape(0)
+		s.printf("f\n")
+	}
+	if opts.Border {
+		s.printf("%s %.2f w ", f.color.draw.str, lw)
+		shape(lw / 2)
+		s.printf("S\n")
+	}
+	return
+}
+
+// formCircle appends a circle, approximated by four Bézier curves, to the
+// path in s.
+func formCircle(s *fmtBuffer, cx, cy, r float64) {
+	k := 0.5523 * r
+	s.printf("%.2f %.2f m ", cx+r, cy)
+	s.printf("%.2f %.2f %.2f %.2f %.2f %.2f c ", cx+r, cy+k, cx+k, cy+r, cx, cy+r)
+	s.printf("%.2f %.2f %.2f %.2f %.2f %.2f c ", cx-k, cy+r, cx-r, cy+k, cx-r, cy)
+	s.printf("%.2f %.2f %.2f %.2f %.2f %.2f c ", cx-r, cy-k, cx-k, cy-r, cx, cy-r)
+	s.printf("%.2f %.2f %.2f %.2f %.2f %.2f c ", cx+k, cy-r, cx+r, cy-k, cx+r, cy)
+}
+
+// formTextAppearance returns the appearance stream of a text field, combo
+// box or push button showing txt.
+func (f *DocPDF) formTextAppearance(wd *formWidgetType, opts FormFieldOptions, txt string) []byte {
+	var s fmtBuffer
+	pad := f.formFrame(&s, wd, opts, false) + 2
+	lines := []string{txt}
+	if opts.Password {
+		lines[0] = strings.Repeat("*", utf8.RuneCountInString(txt))
+	} else if opts.Multiline {
+		lines = strings.Split(txt, "\n")
+	}
+	s.printf("/Tx BMC q %.2f %.2f %.2f %.2f re W n\n", pad/2, pad/2, wd.w-pad, wd.h-pad)
+	sz := f.fontSizePt
+	for j, line := range lines {
+		x := pad
+		switch tw := f.GetStringWidth(line) * f.k; opts.AlignStr {
+		case "C":
+			x = (wd.w - tw) / 2
+		case "R":
+			x = wd.w - pad - tw
+		}
+		y := wd.h/2 - 0.3*sz
+		if opts.Multiline {
+			y = wd.h - pad - sz*(0.85+1.15*float64(j))
+		}
+		s.printf("BT /F%s %.2f Tf %s %.2f %.2f Td (%s) Tj ET\n",
+			f.currentFont.i, sz, f.color.text.str, x, y, f.formEncode(line))
+	}
+	s.printf("Q EMC")
+	return s.Bytes()
+}
+
+// formListAppearance returns the appearance stream of a list box with the
+// item value highlighted.
+func (f *DocPDF) formListAppearance(wd *formWidgetType, opts FormFieldOptions, items []string, value string) []byte {
+	var s fmtBuffer
+	pad := f.formFrame(&s, wd, opts, false) + 2
+	s.printf("/Tx BMC q %.2f %.2f %.2f %.2f re W n\n", pad/2, pad/2, wd.w-pad, wd.h-pad)
+	sz := f.fontSizePt
+	lh := 1.15 * sz
+	for j, item := range items {
+		top := wd.h - pad/2 - lh*float64(j)
+		if top < 0 {
+			break
+		}
+		if item == value {
+			s.printf("0.600 0.757 0.855 rg %.2f %.2f %.2f %.2f re f\n", pad/2, top-lh, wd.w-pad, lh)
+		}
+		s.printf("BT /F%s %.2f Tf %s %.2f %.2f Td (%s) Tj ET\n",
+			f.currentFont.i, sz, f.color.text.str, pad, top-0.85*sz, f.formEncode(item))
+	}
+	s.printf("Q EMC")
+	return s.Bytes()
+}
+
+// formButtonAppearance returns the on or off appearance stream of a check
+// box, or of a radio button if round is true.
+func (f *DocPDF) formButtonAppearance(wd *formWidgetType, opts FormFieldOptions, round, on bool) []byte {
+	var s fmtBuffer
+	f.formFrame(&s, wd, opts, round)
+	if on {
+		c := f.color.text
+		if round {
+			s.printf("%s ", c.str)
+			formCircle(&s, wd.w/2, wd.h/2, math.Min(wd.w, wd.h)/4)
+			s.printf("f")
+		} else {
+			s.printf("%s %.2f w 1 J 1 j %.2f %.2f m %.2f %.2f l %.2f %.2f l S",
+				strokeColorStr(c.str), 0.12*math.Min(wd.w, wd.h),
+				0.22*wd.w, 0.52*wd.h, 0.42*wd.w, 0.28*wd.h, 0.78*wd.w, 0.76*wd.h)
+		}
+	}
+	return s.Bytes()
+}
+
+// formAssignObjects numbers the objects written by putformfields. Pages
+// start after object pageBase and the form objects follow the pages.
+func (f *DocPDF) formAssignObjects(pageBase int) {
+	n := pageBase + 2*f.page
+	f.nFormZaDb = 0
+	for _, fld := range f.formFields {
+		if fld.kind == formRadio {
+			n++
+			fld.objNum = n
+		}
+		for _, wd := range fld.widgets {
+			n++
+			wd.objNum = n
+			wd.pageObj = pageBase + 2*wd.page - 1
+			if fld.kind != formRadio {
+				fld.objNum = n
+			}
+			for j := range wd.ap {
+				n++
+				wd.ap[j].objNum = n
+			}
+		}
+		if fld.kind == formSignature {
+			n++
+			f.signature.objNum = n
+		}
+		if (fld.kind == formCheckBox || fld.kind == formRadio) && f.nFormZaDb == 0 {
+			f.nFormZaDb = -1
+		}
+	}
+	if f.nFormZaDb < 0 {
+		f.nFormZaDb = n + 1
+	}
+}
+
+// putFormAnnotationRefs appends the widgets of a page, in tab order, to its
+// /Annots array.
+func (f *DocPDF) putFormAnnotationRefs(out *fmtBuffer, page int) {
+	list := make([]*formWidgetType, len(f.pageWidgets[page]))
+	copy(list, f.pageWidgets[page])
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i].tabOrder, list[j].tabOrder
+		if a <= 0 || b <= 0 {
+			return a > 0 && b <= 0
+		}
+		return a < b
+	})
+	for _, wd := range list {
+		out.printf("%d 0 R ", wd.objNum)
+	}
+}
+
+// formFlags returns the field flags of fld.
+func formFlags(fld *formFieldType) (ff int) {
+	if fld.opts.ReadOnly {
+		ff |= formFlagReadOnly
+	}
+	if fld.opts.Required {
+		ff |= formFlagRequired
+	}
+	switch fld.kind {
+	case formText:
+		if fld.opts.Multiline {
+			ff |= formFlagMultiline
+		}
+		if fld.opts.Password {
+			ff |= formFlagPassword
+		}
+	case formRadio:
+		ff |= formFlagRadio | formFlagNoToggleOff
+	case formPushButton:
+		ff |= formFlagPushButton
+	case formCombo:
+		ff |= formFlagCombo
+		if fld.opts.Editable {
+			ff |= formFlagEdit
+		}
+	}
+	return
+}
+
+// putFormFieldDict writes the field entries of fld to s.
+func (f *DocPDF) putFormFieldDict(s *fmtBuffer, fld *formFieldType) {
+	switch fld.kind {
+	case formText:
+		s.printf(" /FT /Tx")
+	case formCombo, formList:
+		s.printf(" /FT /Ch")
+	case formSignature:
+		s.printf(" /FT /Sig")
+	default:
+		s.printf(" /FT /Btn")
+	}
+	s.printf(" /T %s", f.textstring(utf8toutf16(fld.name)))
+	if fld.opts.ToolTip != "" {
+		s.printf(" /TU %s", f.textstring(utf8toutf16(fld.opts.ToolTip)))
+	}
+	if ff := formFlags(fld); ff != 0 {
+		s.printf(" /Ff %d", ff)
+	}
+	switch fld.kind {
+	case formCheckBox, formRadio:
+		s.printf(" /V /%s", fld.value)
+		if fld.opts.Default != "" {
+			def := formStateName(fld.opts.Default)
+			if fld.kind == formCheckBox && def != "Yes" {
+				def = "Off"
+			}
+			s.printf(" /DV /%s", def)
+		}
+	case formPushButton:
+	case formSignature:
+		s.printf(" /V %d 0 R", f.signature.objNum)
+	default:
+		s.printf(" /V %s", f.textstring(f.formString(fld, fld.value)))
+		if fld.opts.Default != "" {
+			s.printf(" /DV %s", f.textstring(f.formString(fld, fld.opts.Default)))
+		}
+	}
+	if fld.kind == formText && fld.opts.MaxLen > 0 {
+		s.printf(" /MaxLen %d", fld.opts.MaxLen)
+	}
+	if len(fld.items) > 0 {
+		s.printf(" /Opt [")
+		for _, item := range fld.items {
+			s.printf("%s ", f.textstring(f.formString(fld, item)))
+		}
+		s.printf("]")
+	}
+}
+
+// putformfields writes the form fields, their widget annotations and
+// appearance streams in the order numbered by formAssignObjects.
+func (f *DocPDF) putformfields() {
+	for _, fld := range f.formFields {
+		if fld.kind == formRadio {
+			f.newobj()
+			var s fmtBuffer
+			s.printf("<<")
+			f.putFormFieldDict(&s, fld)
+			s.printf(" /Kids [")
+			for _, wd := range fld.widgets {
+				s.printf("%d 0 R ", wd.objNum)
+			}
+			s.printf("]>>")
+			f.out(s.String())
+			f.out("endobj")
+		}
+		for _, wd := range fld.widgets {
+			f.newobj()
+			var s fmtBuffer
+			s.printf("<</Type /Annot /Subtype /Widget /Rect [%.2f %.2f %.2f %.2f] /F 4 /P %d 0 R",
+				wd.x, wd.y, wd.x+wd.w, wd.y+wd.h, wd.pageObj)
+			if fld.kind == formRadio {
+				s.printf(" /Parent %d 0 R", fld.objNum)
+			} else {
+				f.putFormFieldDict(&s, fld)
+			}
+			if q := strings.Index("LCR", fld.opts.AlignStr); q > 0 && fld.kind != formPushButton {
+				s.printf(" /Q %d", q)
+			}
+			s.printf(" /DA %s /MK <<", f.textstring(fld.da))
+			if wd.bc != "" {
+				s.printf("/BC [%s] ", wd.bc)
+			}
+			if wd.bg != "" {
+				s.printf("/BG [%s] ", wd.bg)
+			}
+			if wd.caption != "" {
+				s.printf("/CA %s", f.textstring(wd.caption))
+			}
+			s.printf(">>")
+			if wd.bc != "" {
+				s.printf(" /BS <</W %.2f /S /S>>", wd.bw)
+			}
+			if wd.onState != "" {
+				state := "Off"
+				if fld.value == wd.onState {
+					state = wd.onState
+				}
+				s.printf(" /AS /%s /AP <</N <<", state)
+				for _, ap := range wd.ap {
+					s.printf("/%s %d 0 R ", ap.state, ap.objNum)
+				}
+				s.printf(">>>>")
+			} else {
+				s.printf(" /AP <</N %d 0 R>>", wd.ap[0].objNum)
+			}
+			if fld.kind == formPushButton {
+				if fld.opts.JavaScript != "" {
+					s.printf(" /A <</S /JavaScript /JS %s>>", f.textstring(fld.opts.JavaScript))
+				} else if fld.opts.SubmitURL != "" {
+					s.printf(" /A <</S /SubmitForm /F <</FS /URL /F %s>> /Flags 4>>", f.textstring(fld.opts.SubmitURL))
+				}
+			}
+			s.printf(">>")
+			f.out(s.String())
+			f.out("endobj")
+			for _, ap := range wd.ap {
+				f.newobj()
+				if f.compress {
+					mem := xmem.compress(ap.content)
+					data := mem.bytes()
+					f.outf("<</Type /XObject /Subtype /Form /BBox [0 0 %.2f %.2f] /Resources 2 0 R /Filter /FlateDecode /Length %d>>",
+						wd.w, wd.h, f.protect.encryptedLen(len(data)))
+					f.putstream(data)
+					mem.release()
+				} else {
+					f.outf("<</Type /XObject /Subtype /Form /BBox [0 0 %.2f %.2f] /Resources 2 0 R /Length %d>>",
+						wd.w, wd.h, f.protect.encryptedLen(len(ap.content)))
+					f.putstream(ap.content)
+				}
+				f.out("endobj")
+			}
+		}
+		if fld.kind == formSignature {
+			f.newobj()
+			f.putSignatureDict()
+			f.out("endobj")
+		}
+	}
+	if f.nFormZaDb > 0 {
+		f.newobj()
+		f.out("<</Type /Font /Subtype /Type1 /BaseFont /ZapfDingbats>>")
+		f.out("endobj")
+	}
+}
+
+// putAcroForm writes the interactive form dictionary of the catalog.
+func (f *DocPDF) putAcroForm() {
+	if len(f.formFields) == 0 {
+		return
+	}
+	var s fmtBuffer
+	s.printf("/AcroForm <</Fields [")
+	for _, fld := range f.formFields {
+		s.printf("%d 0 R ", fld.objNum)
+	}
+	s.printf("] /DR <</Font <<")
+	fonts := make([]fontDefType, 0, len(f.fonts))
+	for _, font := range f.fonts {
+		fonts = append(fonts, font)
+	}
+	sort.Slice(fonts, func(i, j int) bool { return fonts[i].i < fonts[j].i })
+	for _, font := range fonts {
+		s.printf("/F%s %d 0 R ", font.i, font.N)
+	}
+	if f.nFormZaDb > 0 {
+		s.printf("/ZaDb %d 0 R", f.nFormZaDb)
+	}
+	s.printf(">>>>")
+	if f.signature != nil {
+		// SignaturesExist and AppendOnly
+		s.printf(" /SigFlags 3")
+	}
+	s.printf(">>")
+	f.out(s.String())
+}
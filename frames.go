@@ -0,0 +1,268 @@
+package docpdf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// TextFrame is a rectangle of the page in which text flows, in the unit of
+// measure specified in New().
+type TextFrame struct {
+	X, Y, W, H float64
+}
+
+// frameRowType is a row of cells output in a frame. The row holds the page
+// content from the end of the previous row to the end of its last cell, and
+// the links from the end of the previous row.
+type frameRowType struct {
+	frame      int
+	y, h       float64
+	start, end int
+	links      [2]int
+}
+
+// textFlowType holds the state of the text flowing in frames.
+type textFlowType struct {
+	frames     []TextFrame
+	index      int     // current frame
+	columns    int     // number of columns, if the frames are columns
+	gutter     float64 // space between the columns
+	balanced   bool
+	rows       []frameRowType // rows of the current page
+	start      int            // page content offset of the first row
+	linkStart  int            // page link index of the first row
+	lMargin    float64        // page margins and page break function
+	rMargin    float64
+	trigger    float64
+	acceptFunc func() bool
+}
+
+// SetColumns makes the text flow in n columns separated by gutter, between
+// the left and right margins. The columns start at the current vertical
+// position and end at the page break margin, and they start below the
+// header on the next pages. Cells output by Cell(), CellFormat(),
+// MultiCell(), Write() and the HTML writers, and images output in flowing
+// mode, move to the next column when they reach the bottom of a column, and
+// to the next page after the last column. If balanced is true, EndFrames()
+// evens the heights of the columns of the last page.
+//
+// The page break function set with SetAcceptPageBreakFunc() is called when
+// a page break is needed after the last column.
+func (f *DocPDF) SetColumns(n int, gutter float64, balanced bool) {
+	if f.err != nil {
+		return
+	}
+	if n < 1 {
+		f.err = fmt.Errorf("invalid number of columns: %d", n)
+		return
+	}
+	f.beginFrames(&textFlowType{columns: n, gutter: gutter, balanced: balanced})
+}
+
+// SetFrames makes the text flow in the linked frames of each page, in order.
+// It works like SetColumns(), with arbitrary rectangles in place of
+// columns. A paragraph output by MultiCell() with a width of zero takes the
+// width of each frame it flows into.
+func (f *DocPDF) SetFrames(frames []TextFrame, balanced bool) {
+	if f.err != nil {
+		return
+	}
+	if len(frames) == 0 {
+		f.err = fmt.Errorf("no text frame given")
+		return
+	}
+	f.beginFrames(&textFlowType{frames: append([]TextFrame(nil), frames...), balanced: balanced})
+}
+
+// EndFrames ends the text flow started by SetColumns() or SetFrames(). The
+// rows of the frames of the current page are first moved between the frames
+// to even their heights if the frames are balanced. The margins and the
+// page break function are then restored, and the current position is set
+// to the left margin, below the lowest row of the frames.
+func (f *DocPDF) EndFrames() {
+	fl := f.flow
+	if fl == nil {
+		return
+	}
+	if fl.balanced {
+		f.balanceFrames()
+	}
+	y := f.y
+	if len(fl.rows) > 0 {
+		y = fl.frames[fl.rows[0].frame].Y
+		for _, row := range fl.rows {
+			y = math.Max(y, row.y+row.h)
+		}
+	}
+	f.lMargin, f.rMargin, f.pageBreakTrigger = fl.lMargin, fl.rMargin, fl.trigger
+	f.acceptPageBreak = fl.acceptFunc
+	f.flow = nil
+	f.x, f.y = f.lMargin, y
+}
+
+func (f *DocPDF) beginFrames(fl *textFlowType) {
+	if f.err != nil {
+		return
+	}
+	if f.page == 0 {
+		f.err = fmt.Errorf("text frames need a page")
+		return
+	}
+	f.EndFrames()
+	fl.lMargin, fl.rMargin, fl.trigger = f.lMargin, f.rMargin, f.pageBreakTrigger
+	fl.acceptFunc = f.acceptPageBreak
+	f.flow = fl
+	f.acceptPageBreak = f.nextFrame
+	f.startFrames()
+}
+
+// startFrames places the current position at the top of the first frame of
+// the current page.
+func (f *DocPDF) startFrames() {
+	fl := f.flow
+	if fl.columns > 0 {
+		w := (f.w - fl.lMargin - fl.rMargin - float64(fl.columns-1)*fl.gutter) / float64(fl.columns)
+		fl.frames = make([]TextFrame, fl.columns)
+		for j := range fl.frames {
+			fl.frames[j] = TextFrame{X: fl.lMargin + float64(j)*(w+fl.gutter), Y: f.y, W: w, H: fl.trigger - f.y}
+		}
+	}
+	fl.rows = nil
+	fl.start = f.pages[f.page].Len()
+	fl.linkStart = len(f.pageLinks[f.page])
+	f.setFrame(0)
+	f.x = f.lMargin
+}
+
+// setFrame makes the frame j the current one, and moves the current position
+// to its top.
+func (f *DocPDF) setFrame(j int) {
+	fr := f.flow.frames[j]
+	f.flow.index = j
+	f.lMargin, f.rMargin = fr.X, f.w-fr.X-fr.W
+	f.pageBreakTrigger = fr.Y + fr.H
+	f.y = fr.Y
+}
+
+// frameTop returns the top of the current frame, or the top margin if text
+// does not flow in frames.
+func (f *DocPDF) frameTop() float64 {
+	if f.flow == nil {
+		return f.tMargin
+	}
+	return f.flow.frames[f.flow.index].Y
+}
+
+// nextFrame is the page break function used while text flows in frames. It
+// moves the current position to the next frame, keeping its horizontal
+// offset, or lets a page break happen after the last frame.
+func (f *DocPDF) nextFrame() bool {
+	fl := f.flow
+	dx := f.x - f.lMargin
+	if fl.index+1 < len(fl.frames) {
+		f.setFrame(fl.index + 1)
+		f.x = f.lMargin + dx
+		return false
+	}
+	if !fl.acceptFunc() {
+		return false
+	}
+	// the position is restored after the page break
+	f.x = fl.frames[0].X + dx
+	return true
+}
+
+// frameRow records the cell of height h just output in the current frame.
+func (f *DocPDF) frameRow(h float64) {
+	fl := f.flow
+	end, links := f.pages[f.page].Len(), len(f.pageLinks[f.page])
+	if n := len(fl.rows); n > 0 && fl.rows[n-1].frame == fl.index && fl.rows[n-1].y == f.y {
+		row := &fl.rows[n-1]
+		row.h = math.Max(row.h, h)
+		row.end, row.links[1] = end, links
+		return
+	}
+	start, linkStart := fl.start, fl.linkStart
+	if n := len(fl.rows); n > 0 {
+		start, linkStart = fl.rows[n-1].end, fl.rows[n-1].links[1]
+	}
+	fl.rows = append(fl.rows, frameRowType{frame: fl.index, y: f.y, h: h, start: start, end: end, links: [2]int{linkStart, links}})
+}
+
+// balanceFrames moves the rows of the frames of the current page so that
+// the frames are filled as evenly as possible.
+func (f *DocPDF) balanceFrames() {
+	fl := f.flow
+	rows := fl.rows
+	if len(rows) == 0 {
+		return
+	}
+	first := rows[0].frame
+	advance := make([]float64, len(rows))
+	for j, row := range rows {
+		if j+1 < len(rows) && rows[j+1].frame == row.frame {
+			advance[j] = rows[j+1].y - row.y
+		} else {
+			advance[j] = row.h
+		}
+	}
+	// place returns the frame and the vertical position of each row when
+	// the frames are filled up to limit
+	place := func(limit float64) (frames []int, ys []float64, ok bool) {
+		frame, pos := first, rows[0].y-fl.frames[first].Y
+		for j, row := range rows {
+			if j > 0 && pos+row.h > min(limit, fl.frames[frame].H)+1e-6 {
+				frame++
+				pos = 0
+				if frame == len(fl.frames) {
+					return nil, nil, false
+				}
+			}
+			frames = append(frames, frame)
+			ys = append(ys, fl.frames[frame].Y+pos)
+			pos += advance[j]
+		}
+		return frames, ys, true
+	}
+	lo, hi := 0.0, 0.0
+	for _, fr := range fl.frames {
+		hi = math.Max(hi, fr.H)
+	}
+	if _, _, ok := place(hi); !ok {
+		return
+	}
+	for j := 0; j < 40; j++ {
+		if _, _, ok := place((lo + hi) / 2); ok {
+			hi = (lo + hi) / 2
+		} else {
+			lo = (lo + hi) / 2
+		}
+	}
+	frames, ys, _ := place(hi)
+
+	page := f.pages[f.page]
+	content := append([]byte(nil), page.Bytes()...)
+	var buf bytes.Buffer
+	buf.Write(content[:rows[0].start])
+	k := f.k
+	for j, row := range rows {
+		dx, dy := fl.frames[frames[j]].X-fl.frames[row.frame].X, ys[j]-row.y
+		if dx == 0 && dy == 0 {
+			buf.Write(content[row.start:row.end])
+			continue
+		}
+		dxPt, dyPt := f.fmtF64(dx*k, 2), f.fmtF64(-dy*k, 2)
+		fmt.Fprintf(&buf, "1 0 0 1 %s %s cm\n", dxPt, dyPt)
+		buf.Write(content[row.start:row.end])
+		fmt.Fprintf(&buf, "1 0 0 1 %s %s cm\n", f.fmtF64(-dx*k, 2), f.fmtF64(dy*k, 2))
+		for l := row.links[0]; l < row.links[1]; l++ {
+			f.pageLinks[f.page][l].x += dx * k
+			f.pageLinks[f.page][l].y -= dy * k
+		}
+		rows[j].frame, rows[j].y = frames[j], ys[j]
+	}
+	buf.Write(content[rows[len(rows)-1].end:])
+	page.Reset()
+	page.Write(buf.Bytes())
+}
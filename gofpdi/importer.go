@@ -3,11 +3,13 @@ package gofpdi
 import (
 	"fmt"
 	"io"
+	"io/fs"
 )
 
 // The Importer class to be used by a pdf generation library
 type Importer struct {
 	sourceFile    string
+	fsys          fs.FS
 	readers       map[string]*PdfReader
 	writers       map[string]*PdfWriter
 	tplMap        map[int]*TplInfo
@@ -61,12 +63,24 @@ func (this *Importer) init() {
 	this.importedPages = make(map[string]int, 0)
 }
 
+// SetFS sets the file system from which SetSourceFile reads source pdf
+// files. A nil fsys restores reading from the operating system.
+func (this *Importer) SetFS(fsys fs.FS) {
+	this.fsys = fsys
+}
+
 func (this *Importer) SetSourceFile(f string) {
 	this.sourceFile = f
 
 	// If reader hasn't been instantiated, do that now
 	if _, ok := this.readers[this.sourceFile]; !ok {
-		reader, err := NewPdfReader(this.sourceFile)
+		var reader *PdfReader
+		var err error
+		if this.fsys != nil {
+			reader, err = NewPdfReaderFromFS(this.fsys, this.sourceFile)
+		} else {
+			reader, err = NewPdfReader(this.sourceFile)
+		}
 		if err != nil {
 			panic(err)
 		}
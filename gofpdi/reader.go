@@ -7,6 +7,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"math"
 	"os"
@@ -47,6 +48,15 @@ func NewPdfReaderFromStream(sourceFile string, rs io.ReadSeeker) (*PdfReader, er
 	return parser, nil
 }
 
+// NewPdfReaderFromFS reads the pdf named filename from the file system fsys.
+func NewPdfReaderFromFS(fsys fs.FS, filename string) (*PdfReader, error) {
+	data, err := fs.ReadFile(fsys, filename)
+	if err != nil {
+		return nil, errs.New(err, "Failed to open file")
+	}
+	return NewPdfReaderFromStream(filename, bytes.NewReader(data))
+}
+
 func NewPdfReader(filename string) (*PdfReader, error) {
 	var err error
 	f, err := os.Open(filename)
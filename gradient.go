@@ -0,0 +1,282 @@
+package docpdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// GradientColorType is a color of a gradient or of a mesh shading, created
+// with GradientRGB(), GradientCMYK() or GradientSpot(). The colors of a
+// gradient or a mesh are all of the same kind and, for spot colors, tints of
+// the same spot color.
+type GradientColorType struct {
+	spotStr string    // name of the spot color, if any
+	comps   []float64 // components ranging from 0 to 1
+}
+
+// GradientRGB returns a gradient color specified with red, green and blue
+// components ranging from 0 to 255.
+func GradientRGB(r, g, b int) GradientColorType {
+	_, rf := colorComp(r)
+	_, gf := colorComp(g)
+	_, bf := colorComp(b)
+	return GradientColorType{comps: []float64{rf, gf, bf}}
+}
+
+// GradientCMYK returns a gradient color specified with cyan, magenta, yellow
+// and black ink components. The components specify percentages ranging from 0
+// to 100. Values above this are quietly capped to 100.
+func GradientCMYK(c, m, y, k byte) GradientColorType {
+	return GradientColorType{comps: []float64{float64(byteBound(c)) / 100, float64(byteBound(m)) / 100,
+		float64(byteBound(y)) / 100, float64(byteBound(k)) / 100}}
+}
+
+// GradientSpot returns a tint of the spot color associated with nameStr by
+// AddSpotColor(). The value for tint ranges from 0 (no intensity) to 100 (full
+// intensity). It is quietly bounded to this range.
+func GradientSpot(nameStr string, tint byte) GradientColorType {
+	return GradientColorType{spotStr: nameStr, comps: []float64{float64(byteBound(tint)) / 100}}
+}
+
+func (clr GradientColorType) str() string {
+	var s strings.Builder
+	for j, v := range clr.comps {
+		if j > 0 {
+			s.WriteByte(' ')
+		}
+		s.WriteString(sprintf("%.3f", v))
+	}
+	return s.String()
+}
+
+// GradientStopType is the color of a gradient at a position along the
+// gradient vector
+type GradientStopType struct {
+	Offset float64 // from 0 at the origin of the vector to 1 at its end
+	Color  GradientColorType
+}
+
+// GradientType describes the blending of the colors of a gradient
+type GradientType struct {
+	// Colors of the gradient at increasing offsets. The first color is used
+	// before the first offset and the last color after the last offset. An
+	// offset smaller than the previous one is raised to it.
+	Stops []GradientStopType
+	// Extension of the gradient with its first color before the origin of the
+	// vector, and with its last color beyond its end. The area outside the
+	// vector is left unpainted otherwise.
+	ExtendStart, ExtendEnd bool
+}
+
+// CoonsPatchType is a patch of a Coons patch mesh, bounded by four cubic
+// Bézier curves
+type CoonsPatchType struct {
+	// Points of the boundary in user units: a corner, then for each side the
+	// two control points of the curve followed by the corner that ends it. The
+	// last side ends at the first corner, which is not repeated.
+	Points [12]PointType
+	// Colors of the corners Points[0], Points[3], Points[6] and Points[9]
+	Colors [4]GradientColorType
+}
+
+// gradientSpace checks that the colors are of the same kind, and returns the
+// name of their spot color and their number of components
+func (f *DocPDF) gradientSpace(clrs []GradientColorType) (spotStr string, n int) {
+	for j, clr := range clrs {
+		if len(clr.comps) == 0 {
+			f.err = fmt.Errorf("gradient color %d is not set", j)
+			return
+		}
+		if j == 0 {
+			spotStr, n = clr.spotStr, len(clr.comps)
+			continue
+		}
+		if clr.spotStr != spotStr || len(clr.comps) != n {
+			f.err = fmt.Errorf("gradient colors must be of the same color space")
+			return
+		}
+	}
+	if spotStr != "" {
+		f.getSpotColor(spotStr)
+	}
+	return
+}
+
+// gradientNew records the gradient grad of type tp (2: linear, 3: radial) and
+// returns its index, or zero if an error occurs
+func (f *DocPDF) gradientNew(tp int, grad GradientType, x1, y1, x2, y2, r float64) (pos int) {
+	if f.err != nil {
+		return
+	}
+	if len(grad.Stops) == 0 {
+		f.err = fmt.Errorf("gradient has no color stops")
+		return
+	}
+	clrs := make([]GradientColorType, len(grad.Stops))
+	for j, s := range grad.Stops {
+		clrs[j] = f.gradientColor(s.Color)
+	}
+	spotStr, n := f.gradientSpace(clrs)
+	if f.err != nil {
+		return
+	}
+	stops := make([]gradientStopType, 0, len(grad.Stops)+2)
+	offset := 0.0
+	for j, s := range grad.Stops {
+		offset = math.Min(math.Max(s.Offset, offset), 1)
+		stops = append(stops, gradientStopType{offset: offset, clrStr: clrs[j].str()})
+	}
+	// The blending function covers the whole vector
+	if stops[0].offset > 0 {
+		stops = append([]gradientStopType{{clrStr: stops[0].clrStr}}, stops...)
+	}
+	if last := stops[len(stops)-1]; last.offset < 1 {
+		stops = append(stops, gradientStopType{offset: 1, clrStr: last.clrStr})
+	}
+	pos = len(f.gradientList)
+	f.gradientList = append(f.gradientList, gradientType{tp: tp, clr1Str: stops[0].clrStr,
+		clr2Str: stops[len(stops)-1].clrStr, x1: x1, y1: y1, x2: x2, y2: y2, r: r, stops: stops,
+		spotStr: spotStr, comps: n, extend: [2]bool{grad.ExtendStart, grad.ExtendEnd}})
+	return
+}
+
+// LinearGradientStops draws a rectangular area with a blending of the colors
+// of grad. The rectangle is of width w and height h. Its upper left corner is
+// positioned at point (x, y). The gradient vector from (x1, y1) to (x2, y2) is
+// specified as with LinearGradient(), and the offsets of the colors of grad
+// are positions along it.
+func (f *DocPDF) LinearGradientStops(x, y, w, h float64, grad GradientType, x1, y1, x2, y2 float64) {
+	pos := f.gradientNew(2, grad, x1, y1, x2, y2, 0)
+	if pos > 0 {
+		f.gradientClipStart(x, y, w, h)
+		f.outf("/Sh%d sh", pos)
+		f.gradientClipEnd()
+	}
+}
+
+// RadialGradientStops draws a rectangular area with a blending of the colors
+// of grad. The rectangle is of width w and height h. Its upper left corner is
+// positioned at point (x, y). The origin (x1, y1) and the circle of center
+// (x2, y2) and radius r are specified as with RadialGradient(). The colors of
+// grad at offset 0 begins at the origin and the color at offset 1 at the
+// circle.
+//
+// The LinearGradientStops() example demonstrates this method.
+func (f *DocPDF) RadialGradientStops(x, y, w, h float64, grad GradientType, x1, y1, x2, y2, r float64) {
+	pos := f.gradientNew(3, grad, x1, y1, x2, y2, r)
+	if pos > 0 {
+		f.gradientClipStart(x, y, w, h)
+		f.outf("/Sh%d sh", pos)
+		f.gradientClipEnd()
+	}
+}
+
+// SetFillLinearGradient sets the fill color to a linear gradient of the colors
+// of grad. The gradient is positioned relative to the rectangle of width w and
+// height h whose upper left corner is at point (x, y), with the gradient
+// vector from (x1, y1) to (x2, y2) specified as with LinearGradient(). The
+// gradient extends over the whole page.
+//
+// The gradient fills the shapes drawn until the fill color is set again,
+// such as those of Rect(), Circle(), Polygon() and DrawPath() and the
+// background of cells. It is located on the current page in the default
+// coordinate system: it does not follow the transformations set with
+// TransformBegin(). GetFillColor() returns black while a gradient is the fill
+// color.
+func (f *DocPDF) SetFillLinearGradient(x, y, w, h float64, grad GradientType, x1, y1, x2, y2 float64) {
+	f.setFillPattern(f.gradientNew(2, grad, x1, y1, x2, y2, 0), x, y, w, h)
+}
+
+// SetFillRadialGradient sets the fill color to a radial gradient of the
+// colors of grad. The gradient is positioned relative to the rectangle of
+// width w and height h whose upper left corner is at point (x, y), with the
+// origin (x1, y1) and the circle of center (x2, y2) and radius r specified as
+// with RadialGradient(). See SetFillLinearGradient() for the use of the fill
+// gradient.
+//
+// The SetFillLinearGradient() example demonstrates this method.
+func (f *DocPDF) SetFillRadialGradient(x, y, w, h float64, grad GradientType, x1, y1, x2, y2, r float64) {
+	f.setFillPattern(f.gradientNew(3, grad, x1, y1, x2, y2, r), x, y, w, h)
+}
+
+// setFillPattern sets the fill color to a shading pattern of the gradient at
+// index pos, mapped to the rectangle (x, y, w, h)
+func (f *DocPDF) setFillPattern(pos int, x, y, w, h float64) {
+	if pos == 0 {
+		return
+	}
+	f.patternList = append(f.patternList, patternType{gradient: pos,
+		matrix: TransformMatrix{A: w * f.k, D: h * f.k, E: x * f.k, F: (f.h - (y + h)) * f.k}})
+	f.SetFillPattern(len(f.patternList) - 1)
+}
+
+// CoonsPatchGradient paints a mesh of Coons patches. The colors of each patch
+// are blended from its corners across the area bounded by its sides, and the
+// patches are painted in order, overlapping the previous ones. The shading is
+// confined by the current clipping area, see ClipRect() for instance, and
+// follows the current transformations.
+func (f *DocPDF) CoonsPatchGradient(patches []CoonsPatchType) {
+	if f.err != nil || len(patches) == 0 {
+		return
+	}
+	clrs := make([]GradientColorType, 0, 4*len(patches))
+	for _, p := range patches {
+		for _, clr := range p.Colors {
+			clrs = append(clrs, f.gradientColor(clr))
+		}
+	}
+	spotStr, n := f.gradientSpace(clrs)
+	if f.err != nil {
+		return
+	}
+	// Coordinates are encoded relative to their extent
+	xMin, yMin := math.Inf(1), math.Inf(1)
+	xMax, yMax := math.Inf(-1), math.Inf(-1)
+	for _, p := range patches {
+		for _, pt := range p.Points {
+			x, y := pt.X*f.k, (f.h-pt.Y)*f.k
+			xMin, xMax = math.Min(xMin, x), math.Max(xMax, x)
+			yMin, yMax = math.Min(yMin, y), math.Max(yMax, y)
+		}
+	}
+	xMax, yMax = math.Max(xMax, xMin+1), math.Max(yMax, yMin+1)
+	coord := func(v, lo, hi float64) uint32 {
+		return uint32(math.Round((v - lo) / (hi - lo) * math.MaxUint32))
+	}
+	var mesh []byte
+	for j, p := range patches {
+		mesh = append(mesh, 0)
+		for _, pt := range p.Points {
+			mesh = binary.BigEndian.AppendUint32(mesh, coord(pt.X*f.k, xMin, xMax))
+			mesh = binary.BigEndian.AppendUint32(mesh, coord((f.h-pt.Y)*f.k, yMin, yMax))
+		}
+		for _, clr := range clrs[4*j : 4*j+4] {
+			for _, v := range clr.comps {
+				mesh = binary.BigEndian.AppendUint16(mesh, uint16(math.Round(v*math.MaxUint16)))
+			}
+		}
+	}
+	var decode bytes.Buffer
+	decode.WriteString(sprintf("%.5f %.5f %.5f %.5f", xMin, xMax, yMin, yMax))
+	decode.WriteString(strings.Repeat(" 0 1", n))
+	pos := len(f.gradientList)
+	f.gradientList = append(f.gradientList, gradientType{tp: 6, spotStr: spotStr, comps: n,
+		mesh: mesh, decodeStr: decode.String()})
+	f.outf("/Sh%d sh", pos)
+}
+
+// gradientColorSpace returns the color space of the gradient gr
+func (f *DocPDF) gradientColorSpace(gr gradientType) string {
+	switch {
+	case gr.spotStr != "":
+		return sprintf("%d 0 R", f.spotColorMap[gr.spotStr].objID)
+	case f.iccComps() == max(gr.comps, 3):
+		return f.iccColorSpace()
+	case gr.comps == 4:
+		return "/DeviceCMYK"
+	}
+	return "/DeviceRGB"
+}
@@ -275,6 +275,14 @@ func (g *GridType) TickmarksExtentY(min, div float64, count int) {
 	g.ym, g.yb = linearTickmark(g.yTicks, g.y+g.h, g.y)
 }
 
+// place moves the grid to the rectangle of width w and height h with the upper
+// left corner positioned at point (x, y), keeping the current tickmarks.
+func (g *GridType) place(x, y, w, h float64) {
+	g.x, g.y, g.w, g.h = x, y, w, h
+	g.xm, g.xb = linearTickmark(g.xTicks, x, x+w)
+	g.ym, g.yb = linearTickmark(g.yTicks, y+h, y)
+}
+
 // func (g *GridType) SetXExtent(dataLf, paperLf, dataRt, paperRt float64) {
 // 	g.xm, g.xb = linear(dataLf, paperLf, dataRt, paperRt)
 // }
@@ -0,0 +1,1235 @@
+package docpdf
+
+import (
+	"fmt"
+	htmlesc "html"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// htmlTokenize splits htmlStr into text segments, open tags and close tags.
+// Attribute values may be quoted with single or double quotes and may contain
+// spaces. Comments, doctype declarations, processing instructions and the
+// content of script and style elements are dropped. Self-closing tags such as
+// <p/> yield an open and a close segment, except for the void elements br, hr
+// and img which only yield an open segment. Text is returned unchanged.
+func htmlTokenize(htmlStr string) (list []HTMLBasicSegmentType) {
+	list = make([]HTMLBasicSegmentType, 0, 16)
+	pos := 0
+	textStart := 0
+	flushText := func(end int) {
+		if end > textStart {
+			list = append(list, HTMLBasicSegmentType{Cat: 'T', Str: htmlStr[textStart:end]})
+		}
+	}
+	isNameByte := func(c byte) bool {
+		return c == '-' || c == ':' || c == '_' || c >= '0' && c <= '9' ||
+			c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+	}
+	for pos < len(htmlStr) {
+		if htmlStr[pos] != '<' {
+			pos++
+			continue
+		}
+		rest := htmlStr[pos:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			flushText(pos)
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				pos = len(htmlStr)
+			} else {
+				pos += 4 + end + 3
+			}
+			textStart = pos
+			continue
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			flushText(pos)
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				pos = len(htmlStr)
+			} else {
+				pos += end + 1
+			}
+			textStart = pos
+			continue
+		}
+		closing := len(rest) > 1 && rest[1] == '/'
+		j := 1
+		if closing {
+			j = 2
+		}
+		if j >= len(rest) || !(rest[j] >= 'a' && rest[j] <= 'z' || rest[j] >= 'A' && rest[j] <= 'Z') {
+			// A lone '<' is literal text
+			pos++
+			continue
+		}
+		flushText(pos)
+		k := j
+		for k < len(rest) && isNameByte(rest[k]) {
+			k++
+		}
+		seg := HTMLBasicSegmentType{Cat: 'O', Str: strings.ToLower(rest[j:k])}
+		if closing {
+			seg.Cat = 'C'
+		} else {
+			seg.Attr = make(map[string]string)
+		}
+		selfClosing := false
+		// Attributes
+		for k < len(rest) && rest[k] != '>' {
+			c := rest[k]
+			switch {
+			case c == '/':
+				selfClosing = true
+				k++
+			case unicode.IsSpace(rune(c)):
+				k++
+			default:
+				selfClosing = false
+				n := k
+				for k < len(rest) && !unicode.IsSpace(rune(rest[k])) && rest[k] != '=' &&
+					rest[k] != '>' && rest[k] != '/' {
+					k++
+				}
+				name := strings.ToLower(rest[n:k])
+				for k < len(rest) && unicode.IsSpace(rune(rest[k])) {
+					k++
+				}
+				val := ""
+				if k < len(rest) && rest[k] == '=' {
+					k++
+					for k < len(rest) && unicode.IsSpace(rune(rest[k])) {
+						k++
+					}
+					if k < len(rest) && (rest[k] == '"' || rest[k] == '\'') {
+						q := rest[k]
+						end := strings.IndexByte(rest[k+1:], q)
+						if end < 0 {
+							end = len(rest) - k - 1
+						}
+						val = rest[k+1 : k+1+end]
+						k += end + 2
+					} else {
+						n = k
+						for k < len(rest) && !unicode.IsSpace(rune(rest[k])) && rest[k] != '>' {
+							k++
+						}
+						val = rest[n:k]
+					}
+				}
+				if seg.Attr != nil && name != "" {
+					seg.Attr[name] = val
+				}
+			}
+		}
+		pos += min(k+1, len(rest))
+		textStart = pos
+		list = append(list, seg)
+		if seg.Cat == 'O' {
+			switch seg.Str {
+			case "br", "hr", "img":
+			case "script", "style":
+				// Skip the content of the element
+				end := strings.Index(strings.ToLower(htmlStr[pos:]), "</"+seg.Str)
+				if end < 0 {
+					pos = len(htmlStr)
+				} else {
+					pos += end
+				}
+				textStart = pos
+			default:
+				if selfClosing {
+					list = append(list, HTMLBasicSegmentType{Cat: 'C', Str: seg.Str})
+				}
+			}
+		}
+	}
+	flushText(len(htmlStr))
+	return
+}
+
+// HTMLType is used for rendering a subset of HTML and CSS as flowed text with
+// automatic page breaks. See HTMLNew() for the supported elements. In the Link
+// structure, the ClrR, ClrG and ClrB fields (0 through 255) define the color
+// of hyperlinks. The Bold, Italic and Underscore values define the hyperlink
+// style. MonoFamily is the font family used for pre and code elements.
+type HTMLType struct {
+	pdf  *DocPDF
+	Link struct {
+		ClrR, ClrG, ClrB         int
+		Bold, Italic, Underscore bool
+	}
+	MonoFamily string
+
+	tr       func(string) string // code page translator for non UTF-8 fonts
+	padding  float64             // cell margin in effect when Write was called
+	lineHt   float64             // line height of the base font size
+	baseSize float64             // base font size in points
+	styles   []htmlStyle         // style stack, the last element is current
+	blocks   []htmlBlock         // block stack, the last element is current
+	lists    []htmlList          // list stack
+	runs     []htmlRun           // inline content not yet laid out
+	marker   *htmlRun            // pending list item marker
+	table    *htmlTable          // table being collected
+	blockSpacing
+}
+
+// htmlStyle holds the inherited text attributes of an element
+type htmlStyle struct {
+	tag                             string
+	family                          string
+	bold, italic, underline, strike bool
+	sizePt                          float64
+	clr                             RGBType
+	bgOn                            bool
+	bg                              RGBType
+	align                           string
+	href                            string
+	pre                             bool
+}
+
+// htmlBlock holds the horizontal extent of a block element
+type htmlBlock struct {
+	tag         string
+	left, right float64 // indents from the left and right margins
+	bgOn        bool
+	bg          RGBType
+	bar         bool    // blockquote bar drawn left of the content
+	bottom      float64 // vertical space after the block
+	depth       int     // index of the style of the element in the style stack
+}
+
+// htmlList holds the state of an ordered or unordered list
+type htmlList struct {
+	ordered bool
+	typeStr string
+	count   int
+}
+
+// htmlRun is a span of text sharing the same style
+type htmlRun struct {
+	text string
+	st   htmlStyle
+	br   bool // forced line break
+}
+
+// htmlTable collects the rows of a table element
+type htmlTable struct {
+	tbl     TableType
+	cells   []TableCellType
+	thead   bool // rows are in a thead element
+	allTh   bool // current row is made of th cells only
+	cell    *TableCellType
+	text    strings.Builder
+	hasBody bool
+}
+
+// htmlPiece is a measured word or space of a line
+type htmlPiece struct {
+	text  string
+	st    htmlStyle
+	w     float64
+	space bool
+}
+
+// HTMLNew returns an instance that renders a subset of HTML in the specified
+// PDF file. The following elements are supported:
+//
+//   - p, div, h1 to h6, blockquote, pre, center, left and right blocks
+//   - ul, ol (type and start attributes) and li, with nesting
+//   - table, thead, tbody, tr, th and td (colspan, rowspan and align
+//     attributes), laid out with TableNew()
+//   - img (src, width and height attributes); src is an image registered with
+//     RegisterImage() or RegisterImageOptionsReader(), or a file name
+//   - hr and br
+//   - a, span, b, strong, i, em, u, s, strike, del and code inline elements
+//
+// The style attribute of any element may set color, background-color,
+// font-size, font-weight, font-style, text-decoration, text-align and
+// margin (including margin-top, margin-right, margin-bottom and
+// margin-left). Lengths may be given in pt, px, mm, cm, in, em or %.
+//
+// Character references such as &amp; are decoded. When the current font is
+// not a UTF-8 font, text is translated to code page 1252.
+func (f *DocPDF) HTMLNew() (html HTMLType) {
+	html.pdf = f
+	html.Link.ClrR, html.Link.ClrG, html.Link.ClrB = 0, 0, 128
+	html.Link.Bold, html.Link.Italic, html.Link.Underscore = false, false, true
+	html.MonoFamily = "Courier"
+	return
+}
+
+// Write renders htmlStr starting at the left margin of the current vertical
+// position, using the current font as base font and the current text color.
+// Content flows from page to page according to SetAutoPageBreak(). Upon
+// method exit, the current position is left at the left margin below the
+// rendered content.
+//
+// lineHt indicates the line height of the base font size in the unit of
+// measure specified in New(). Lines of larger or smaller text are scaled
+// accordingly.
+func (html *HTMLType) Write(lineHt float64, htmlStr string) {
+	f := html.pdf
+	if f.err != nil {
+		return
+	}
+	if f.currentFont.Name == "" {
+		f.err = fmt.Errorf("font has not been set; unable to render html")
+		return
+	}
+	// Save the state modified while rendering
+	cMargin := f.cMargin
+	family, sizePt := f.fontFamily, f.fontSizePt
+	styleStr := f.fontStyle
+	if f.underline {
+		styleStr += "U"
+	}
+	if f.strikeout {
+		styleStr += "S"
+	}
+	textR, textG, textB := f.GetTextColor()
+	fillR, fillG, fillB := f.GetFillColor()
+	drawR, drawG, drawB := f.GetDrawColor()
+	lineWd := f.GetLineWidth()
+	defer func() {
+		f.cMargin = cMargin
+		f.SetFont(family, styleStr, sizePt)
+		f.SetTextColor(textR, textG, textB)
+		f.SetFillColor(fillR, fillG, fillB)
+		f.SetDrawColor(drawR, drawG, drawB)
+		f.SetLineWidth(lineWd)
+	}()
+	f.cMargin = 0
+
+	html.padding = cMargin
+	html.lineHt = lineHt
+	html.baseSize = sizePt
+	html.styles = []htmlStyle{{
+		family:    family,
+		bold:      strings.Contains(styleStr, "B"),
+		italic:    strings.Contains(styleStr, "I"),
+		underline: f.underline,
+		strike:    f.strikeout,
+		sizePt:    sizePt,
+		clr:       RGBType{textR, textG, textB},
+		align:     "L",
+	}}
+	html.blocks = []htmlBlock{{}}
+	html.lists = nil
+	html.runs = nil
+	html.space = 0
+	html.marker = nil
+	html.table = nil
+	f.x = f.lMargin
+
+	for _, seg := range htmlTokenize(htmlStr) {
+		if f.err != nil {
+			return
+		}
+		switch seg.Cat {
+		case 'T':
+			html.text(seg.Str)
+		case 'O':
+			html.open(seg.Str, seg.Attr)
+		case 'C':
+			html.close(seg.Str)
+		}
+	}
+	// Close the elements left open
+	if html.table != nil {
+		html.close("table")
+	}
+	for len(html.styles) > 1 {
+		html.close(html.styles[len(html.styles)-1].tag)
+	}
+	html.flush()
+	f.x = f.lMargin
+}
+
+// cur returns the current style
+func (html *HTMLType) cur() *htmlStyle {
+	return &html.styles[len(html.styles)-1]
+}
+
+// block returns the current block
+func (html *HTMLType) block() *htmlBlock {
+	return &html.blocks[len(html.blocks)-1]
+}
+
+// em returns the current font size in user units
+func (html *HTMLType) em() float64 {
+	return html.cur().sizePt / html.pdf.k
+}
+
+// text appends literal text to the current inline content
+func (html *HTMLType) text(str string) {
+	str = htmlesc.UnescapeString(str)
+	if html.table != nil {
+		if html.table.cell != nil {
+			html.table.text.WriteString(str)
+		}
+		return
+	}
+	html.runs = append(html.runs, htmlRun{text: str, st: *html.cur()})
+}
+
+// open processes an open tag
+func (html *HTMLType) open(tag string, attr map[string]string) {
+	f := html.pdf
+	if html.table != nil {
+		html.tableOpen(tag, attr)
+		return
+	}
+	st := *html.cur()
+	st.tag = tag
+	blk := htmlBlock{tag: tag, left: html.block().left, right: html.block().right}
+	isBlock := true
+	var before, after float64
+	switch tag {
+	case "br":
+		html.runs = append(html.runs, htmlRun{br: true, st: st})
+		return
+	case "hr":
+		html.flush()
+		html.addSpace(html.lineHt / 2)
+		html.breakIfNeeded(f, html.lineHt/2)
+		html.useSpace(f)
+		y := f.y + html.lineHt/4
+		left, right := html.extent()
+		f.SetDrawColor(160, 160, 160)
+		f.SetLineWidth(f.PointConvert(0.5))
+		f.Line(left, y, right, y)
+		f.SetY(y)
+		html.addSpace(html.lineHt / 2)
+		return
+	case "img":
+		html.image(attr)
+		return
+	case "p", "div":
+		before, after = html.lineHt/2, html.lineHt/2
+		if tag == "div" {
+			before, after = 0, 0
+		}
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		scale := map[string]float64{"h1": 2, "h2": 1.5, "h3": 1.17, "h4": 1, "h5": 0.83, "h6": 0.67}[tag]
+		st.sizePt = html.baseSize * scale
+		st.bold = true
+		before, after = html.lineHt*scale*0.6, html.lineHt*scale*0.4
+	case "blockquote":
+		blk.left += 2 * html.lineHt
+		blk.right += 2 * html.lineHt
+		blk.bar = true
+		before, after = html.lineHt/2, html.lineHt/2
+	case "pre":
+		st.family = html.MonoFamily
+		st.pre = true
+		blk.bgOn, blk.bg = true, RGBType{245, 245, 245}
+		before, after = html.lineHt/2, html.lineHt/2
+	case "center":
+		st.align = "C"
+	case "right":
+		st.align = "R"
+	case "left":
+		st.align = "L"
+	case "ul", "ol":
+		list := htmlList{ordered: tag == "ol", typeStr: attr["type"]}
+		if start, err := strconv.Atoi(attr["start"]); err == nil {
+			list.count = start - 1
+		}
+		html.lists = append(html.lists, list)
+		blk.left += 1.5 * html.lineHt
+		if len(html.lists) == 1 {
+			before, after = html.lineHt/2, html.lineHt/2
+		}
+	case "li":
+		html.flush()
+		if len(html.lists) > 0 {
+			list := &html.lists[len(html.lists)-1]
+			list.count++
+			html.marker = &htmlRun{text: htmlListMarker(list, len(html.lists)), st: st}
+		}
+	case "table":
+		before, after = html.lineHt/2, html.lineHt/2
+	default:
+		isBlock = false
+		switch tag {
+		case "b", "strong":
+			st.bold = true
+		case "i", "em":
+			st.italic = true
+		case "u":
+			st.underline = true
+		case "s", "strike", "del":
+			st.strike = true
+		case "code", "tt", "kbd", "samp":
+			st.family = html.MonoFamily
+		case "a":
+			st.href = attr["href"]
+			if st.href != "" {
+				st.clr = RGBType{html.Link.ClrR, html.Link.ClrG, html.Link.ClrB}
+				st.bold = st.bold || html.Link.Bold
+				st.italic = st.italic || html.Link.Italic
+				st.underline = st.underline || html.Link.Underscore
+			}
+		}
+	}
+	if align, ok := attr["align"]; ok {
+		st.align = htmlAlign(align, st.align)
+	}
+	html.styles = append(html.styles, st)
+	if isBlock {
+		html.flush()
+		html.applyStyle(attr["style"], &blk, &before, &after)
+		if bg := html.cur(); bg.bgOn {
+			// Backgrounds of block elements span the full block width
+			blk.bgOn, blk.bg = true, bg.bg
+			bg.bgOn = false
+		}
+		html.addSpace(before)
+		blk.bottom = after
+		blk.depth = len(html.styles) - 1
+		html.blocks = append(html.blocks, blk)
+		if tag == "table" {
+			html.table = &htmlTable{tbl: f.TableNew()}
+			html.table.tbl.Padding = html.padding
+			if attr["border"] == "0" {
+				html.table.tbl.BorderStr = ""
+			}
+		}
+	} else {
+		html.applyStyle(attr["style"], nil, nil, nil)
+	}
+}
+
+// close processes a close tag
+func (html *HTMLType) close(tag string) {
+	if html.table != nil && tag != "table" {
+		html.tableClose(tag)
+		return
+	}
+	// Find the matching open element, ignoring unbalanced close tags
+	idx := -1
+	for j := len(html.styles) - 1; j > 0; j-- {
+		if html.styles[j].tag == tag {
+			idx = j
+			break
+		}
+	}
+	if idx < 0 {
+		return
+	}
+	switch tag {
+	case "table":
+		html.tableOutput()
+	case "li":
+		if html.marker != nil {
+			// Print the marker of an empty item
+			html.runs = append(html.runs, htmlRun{br: true, st: *html.cur()})
+		}
+	}
+	// Close the blocks opened by the element or by its descendants
+	for len(html.blocks) > 1 && html.block().depth >= idx {
+		html.flush()
+		blk := html.blocks[len(html.blocks)-1]
+		html.blocks = html.blocks[:len(html.blocks)-1]
+		if (blk.tag == "ul" || blk.tag == "ol") && len(html.lists) > 0 {
+			html.lists = html.lists[:len(html.lists)-1]
+		}
+		html.addSpace(blk.bottom)
+	}
+	html.styles = html.styles[:idx]
+}
+
+// applyStyle applies the declarations of an inline style attribute to the
+// current style and, for block elements, to blk and the vertical margins.
+func (html *HTMLType) applyStyle(styleStr string, blk *htmlBlock, before, after *float64) {
+	st := html.cur()
+	for _, decl := range strings.Split(styleStr, ";") {
+		name, val, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		val = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(val), "!important"))
+		lval := strings.ToLower(val)
+		switch name {
+		case "color":
+			if clr, ok := htmlColor(lval); ok {
+				st.clr = clr
+			}
+		case "background-color", "background":
+			if clr, ok := htmlColor(lval); ok {
+				st.bgOn, st.bg = true, clr
+			}
+		case "font-size":
+			if size, ok := html.fontSize(lval); ok {
+				st.sizePt = size
+			}
+		case "font-weight":
+			if n, err := strconv.Atoi(lval); err == nil {
+				st.bold = n >= 600
+			} else {
+				st.bold = lval == "bold" || lval == "bolder"
+			}
+		case "font-style":
+			st.italic = lval == "italic" || lval == "oblique"
+		case "text-decoration", "text-decoration-line":
+			st.underline = strings.Contains(lval, "underline")
+			st.strike = strings.Contains(lval, "line-through")
+		case "text-align":
+			st.align = htmlAlign(lval, st.align)
+		case "margin", "margin-top", "margin-right", "margin-bottom", "margin-left":
+			if blk == nil {
+				continue
+			}
+			var vals []float64
+			for _, field := range strings.Fields(lval) {
+				v, _ := html.length(field, html.pdf.w-html.pdf.lMargin-html.pdf.rMargin)
+				vals = append(vals, v)
+			}
+			if len(vals) == 0 {
+				continue
+			}
+			// top, right, bottom, left
+			var box [4]float64
+			switch len(vals) {
+			case 1:
+				box = [4]float64{vals[0], vals[0], vals[0], vals[0]}
+			case 2:
+				box = [4]float64{vals[0], vals[1], vals[0], vals[1]}
+			case 3:
+				box = [4]float64{vals[0], vals[1], vals[2], vals[1]}
+			default:
+				box = [4]float64{vals[0], vals[1], vals[2], vals[3]}
+			}
+			parent := html.block()
+			set := func(side int) {
+				switch side {
+				case 0:
+					*before = box[0]
+				case 1:
+					blk.right = parent.right + box[1]
+				case 2:
+					*after = box[2]
+				case 3:
+					blk.left = parent.left + box[3]
+				}
+			}
+			switch name {
+			case "margin":
+				for side := range box {
+					set(side)
+				}
+			case "margin-top":
+				box[0] = vals[0]
+				set(0)
+			case "margin-right":
+				box[1] = vals[0]
+				set(1)
+			case "margin-bottom":
+				box[2] = vals[0]
+				set(2)
+			case "margin-left":
+				box[3] = vals[0]
+				set(3)
+			}
+		}
+	}
+}
+
+// length converts a CSS length to user units. Percentages are relative to
+// ref.
+func (html *HTMLType) length(val string, ref float64) (u float64, ok bool) {
+	f := html.pdf
+	units := []struct {
+		suffix string
+		pt     float64
+	}{{"pt", 1}, {"px", 0.75}, {"mm", 72 / 25.4}, {"cm", 72 / 2.54}, {"in", 72}, {"pc", 12}}
+	for _, unit := range units {
+		if num, found := strings.CutSuffix(val, unit.suffix); found {
+			v, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+			return f.PointConvert(v * unit.pt), err == nil
+		}
+	}
+	if num, found := strings.CutSuffix(val, "em"); found {
+		v, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+		return v * html.em(), err == nil
+	}
+	if num, found := strings.CutSuffix(val, "%"); found {
+		v, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+		return v * ref / 100, err == nil
+	}
+	v, err := strconv.ParseFloat(val, 64)
+	// Unitless values, as in HTML width and height attributes, are pixels
+	return f.PointConvert(v * 0.75), err == nil
+}
+
+// fontSize converts a CSS font-size value to points
+func (html *HTMLType) fontSize(val string) (pt float64, ok bool) {
+	keywords := map[string]float64{"xx-small": 0.6, "x-small": 0.75, "small": 0.89, "medium": 1,
+		"large": 1.2, "x-large": 1.5, "xx-large": 2}
+	if scale, found := keywords[val]; found {
+		return html.baseSize * scale, true
+	}
+	switch val {
+	case "smaller":
+		return html.cur().sizePt / 1.2, true
+	case "larger":
+		return html.cur().sizePt * 1.2, true
+	}
+	u, ok := html.length(val, html.em())
+	return html.pdf.UnitToPointConvert(u), ok && u > 0
+}
+
+// htmlAlign converts an align attribute or text-align value to a CellFormat
+// alignment string, returning def if val is not recognized
+func htmlAlign(val, def string) string {
+	switch strings.ToLower(strings.TrimSpace(val)) {
+	case "left", "start":
+		return "L"
+	case "center":
+		return "C"
+	case "right", "end":
+		return "R"
+	case "justify":
+		return "J"
+	}
+	return def
+}
+
+// htmlColors holds the named colors of CSS
+var htmlColors = map[string]RGBType{
+	"aliceblue": {240, 248, 255}, "antiquewhite": {250, 235, 215}, "aqua": {0, 255, 255},
+	"aquamarine": {127, 255, 212}, "azure": {240, 255, 255}, "beige": {245, 245, 220},
+	"bisque": {255, 228, 196}, "black": {0, 0, 0}, "blanchedalmond": {255, 235, 205},
+	"blue": {0, 0, 255}, "blueviolet": {138, 43, 226}, "brown": {165, 42, 42},
+	"burlywood": {222, 184, 135}, "cadetblue": {95, 158, 160}, "chartreuse": {127, 255, 0},
+	"chocolate": {210, 105, 30}, "coral": {255, 127, 80}, "cornflowerblue": {100, 149, 237},
+	"cornsilk": {255, 248, 220}, "crimson": {220, 20, 60}, "cyan": {0, 255, 255},
+	"darkblue": {0, 0, 139}, "darkcyan": {0, 139, 139}, "darkgoldenrod": {184, 134, 11},
+	"darkgray": {169, 169, 169}, "darkgreen": {0, 100, 0}, "darkgrey": {169, 169, 169},
+	"darkkhaki": {189, 183, 107}, "darkmagenta": {139, 0, 139}, "darkolivegreen": {85, 107, 47},
+	"darkorange": {255, 140, 0}, "darkorchid": {153, 50, 204}, "darkred": {139, 0, 0},
+	"darksalmon": {233, 150, 122}, "darkseagreen": {143, 188, 143}, "darkslateblue": {72, 61, 139},
+	"darkslategray": {47, 79, 79}, "darkslategrey": {47, 79, 79}, "darkturquoise": {0, 206, 209},
+	"darkviolet": {148, 0, 211}, "deeppink": {255, 20, 147}, "deepskyblue": {0, 191, 255},
+	"dimgray": {105, 105, 105}, "dimgrey": {105, 105, 105}, "dodgerblue": {30, 144, 255},
+	"firebrick": {178, 34, 34}, "floralwhite": {255, 250, 240}, "forestgreen": {34, 139, 34},
+	"fuchsia": {255, 0, 255}, "gainsboro": {220, 220, 220}, "ghostwhite": {248, 248, 255},
+	"gold": {255, 215, 0}, "goldenrod": {218, 165, 32}, "gray": {128, 128, 128},
+	"grey": {128, 128, 128}, "green": {0, 128, 0}, "greenyellow": {173, 255, 47},
+	"honeydew": {240, 255, 240}, "hotpink": {255, 105, 180}, "indianred": {205, 92, 92},
+	"indigo": {75, 0, 130}, "ivory": {255, 255, 240}, "khaki": {240, 230, 140},
+	"lavender": {230, 230, 250}, "lavenderblush": {255, 240, 245}, "lawngreen": {124, 252, 0},
+	"lemonchiffon": {255, 250, 205}, "lightblue": {173, 216, 230}, "lightcoral": {240, 128, 128},
+	"lightcyan": {224, 255, 255}, "lightgoldenrodyellow": {250, 250, 210},
+	"lightgray": {211, 211, 211}, "lightgreen": {144, 238, 144}, "lightgrey": {211, 211, 211},
+	"lightpink": {255, 182, 193}, "lightsalmon": {255, 160, 122}, "lightseagreen": {32, 178, 170},
+	"lightskyblue": {135, 206, 250}, "lightslategray": {119, 136, 153},
+	"lightslategrey": {119, 136, 153}, "lightsteelblue": {176, 196, 222},
+	"lightyellow": {255, 255, 224}, "lime": {0, 255, 0}, "limegreen": {50, 205, 50},
+	"linen": {250, 240, 230}, "magenta": {255, 0, 255}, "maroon": {128, 0, 0},
+	"mediumaquamarine": {102, 205, 170}, "mediumblue": {0, 0, 205}, "mediumorchid": {186, 85, 211},
+	"mediumpurple": {147, 112, 219}, "mediumseagreen": {60, 179, 113},
+	"mediumslateblue": {123, 104, 238}, "mediumspringgreen": {0, 250, 154},
+	"mediumturquoise": {72, 209, 204}, "mediumvioletred": {199, 21, 133},
+	"midnightblue": {25, 25, 112}, "mintcream": {245, 255, 250}, "mistyrose": {255, 228, 225},
+	"moccasin": {255, 228, 181}, "navajowhite": {255, 222, 173}, "navy": {0, 0, 128},
+	"oldlace": {253, 245, 230}, "olive": {128, 128, 0}, "olivedrab": {107, 142, 35},
+	"orange": {255, 165, 0}, "orangered": {255, 69, 0}, "orchid": {218, 112, 214},
+	"palegoldenrod": {238, 232, 170}, "palegreen": {152, 251, 152}, "paleturquoise": {175, 238, 238},
+	"palevioletred": {219, 112, 147}, "papayawhip": {255, 239, 213}, "peachpuff": {255, 218, 185},
+	"peru": {205, 133, 63}, "pink": {255, 192, 203}, "plum": {221, 160, 221},
+	"powderblue": {176, 224, 230}, "purple": {128, 0, 128}, "rebeccapurple": {102, 51, 153},
+	"red": {255, 0, 0}, "rosybrown": {188, 143, 143}, "royalblue": {65, 105, 225},
+	"saddlebrown": {139, 69, 19}, "salmon": {250, 128, 114}, "sandybrown": {244, 164, 96},
+	"seagreen": {46, 139, 87}, "seashell": {255, 245, 238}, "sienna": {160, 82, 45},
+	"silver": {192, 192, 192}, "skyblue": {135, 206, 235}, "slateblue": {106, 90, 205},
+	"slategray": {112, 128, 144}, "slategrey": {112, 128, 144}, "snow": {255, 250, 250},
+	"springgreen": {0, 255, 127}, "steelblue": {70, 130, 180}, "tan": {210, 180, 140},
+	"teal": {0, 128, 128}, "thistle": {216, 191, 216}, "tomato": {255, 99, 71},
+	"turquoise": {64, 224, 208}, "violet": {238, 130, 238}, "wheat": {245, 222, 179},
+	"white": {255, 255, 255}, "whitesmoke": {245, 245, 245}, "yellow": {255, 255, 0},
+	"yellowgreen": {154, 205, 50},
+}
+
+// htmlColor parses a CSS color in #rgb, #rrggbb or rgb(r, g, b) notation or a
+// color name
+func htmlColor(val string) (clr RGBType, ok bool) {
+	if clr, ok = htmlColors[val]; ok {
+		return
+	}
+	if hex, found := strings.CutPrefix(val, "#"); found {
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return
+		}
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return
+		}
+		return RGBType{int(n >> 16), int(n >> 8 & 0xff), int(n & 0xff)}, true
+	}
+	if args, found := strings.CutPrefix(val, "rgb("); found {
+		parts := strings.Split(strings.TrimSuffix(args, ")"), ",")
+		if len(parts) != 3 {
+			return
+		}
+		var c [3]int
+		for j, part := range parts {
+			part = strings.TrimSpace(part)
+			if num, pct := strings.CutSuffix(part, "%"); pct {
+				v, err := strconv.ParseFloat(num, 64)
+				if err != nil {
+					return
+				}
+				c[j] = int(math.Round(v * 255 / 100))
+			} else {
+				v, err := strconv.Atoi(part)
+				if err != nil {
+					return
+				}
+				c[j] = v
+			}
+		}
+		return RGBType{c[0], c[1], c[2]}, true
+	}
+	return
+}
+
+// htmlListMarker returns the marker of the current item of list, nested at
+// the specified level
+func htmlListMarker(list *htmlList, level int) string {
+	if !list.ordered {
+		return []string{"•", "-", "·"}[min(level-1, 2)]
+	}
+	n := list.count
+	switch list.typeStr {
+	case "a", "A":
+		var s string
+		for ; n > 0; n = (n - 1) / 26 {
+			s = string(rune('a'+(n-1)%26)) + s
+		}
+		if list.typeStr == "A" {
+			s = strings.ToUpper(s)
+		}
+		return s + "."
+	case "i", "I":
+		s := htmlRoman(n)
+		if list.typeStr == "I" {
+			s = strings.ToUpper(s)
+		}
+		return s + "."
+	}
+	return strconv.Itoa(n) + "."
+}
+
+// htmlRoman returns n in lower case roman numerals
+func htmlRoman(n int) (s string) {
+	vals := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	syms := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+	for j, v := range vals {
+		for n >= v {
+			s += syms[j]
+			n -= v
+		}
+	}
+	return
+}
+
+// extent returns the left and right page positions of the current block
+func (html *HTMLType) extent() (left, right float64) {
+	f := html.pdf
+	blk := html.block()
+	return f.lMargin + blk.left, f.w - f.rMargin - blk.right
+}
+
+// setFont selects the font of st
+func (html *HTMLType) setFont(st htmlStyle) {
+	styleStr := ""
+	if st.bold {
+		styleStr += "B"
+	}
+	if st.italic {
+		styleStr += "I"
+	}
+	if st.underline {
+		styleStr += "U"
+	}
+	if st.strike {
+		styleStr += "S"
+	}
+	html.pdf.SetFont(st.family, styleStr, st.sizePt)
+}
+
+// encode translates str for the current font
+func (html *HTMLType) encode(str string) string {
+	f := html.pdf
+	if f.isCurrentUTF8 {
+		return str
+	}
+	if html.tr == nil {
+		html.tr = f.UnicodeTranslatorFromDescriptor("")
+	}
+	return html.tr(str)
+}
+
+// flush lays out the pending inline content in lines within the current block
+func (html *HTMLType) flush() {
+	f := html.pdf
+	runs := html.runs
+	html.runs = nil
+	if f.err != nil || len(runs) == 0 {
+		return
+	}
+	left, right := html.extent()
+	maxWd := right - left
+	var lines [][]htmlPiece
+	var line []htmlPiece
+	var lineWd float64
+	trailingSpace := true // collapse leading white space
+	endLine := func() {
+		// Trailing spaces are not printed
+		for len(line) > 0 && line[len(line)-1].space {
+			lineWd -= line[len(line)-1].w
+			line = line[:len(line)-1]
+		}
+		lines = append(lines, line)
+		line = nil
+		lineWd = 0
+		trailingSpace = true
+	}
+	addWord := func(word string, st htmlStyle) {
+		html.setFont(st)
+		word = html.encode(word)
+		wd := f.GetStringWidth(word)
+		if lineWd+wd > maxWd && len(line) > 0 {
+			endLine()
+		}
+		// Break words that are longer than a line
+		for wd > maxWd && len(word) > 1 {
+			n := fitWord(f, word, maxWd)
+			part := word[:n]
+			line = append(line, htmlPiece{text: part, st: st, w: f.GetStringWidth(part)})
+			endLine()
+			word = word[n:]
+			wd = f.GetStringWidth(word)
+		}
+		line = append(line, htmlPiece{text: word, st: st, w: wd})
+		lineWd += wd
+		trailingSpace = false
+	}
+	addSpace := func(st htmlStyle) {
+		html.setFont(st)
+		wd := f.GetStringWidth(" ")
+		line = append(line, htmlPiece{text: " ", st: st, w: wd, space: true})
+		lineWd += wd
+		trailingSpace = true
+	}
+	hasText := false
+	for _, run := range runs {
+		if run.br {
+			endLine()
+			hasText = true
+			continue
+		}
+		if run.st.pre {
+			for j, part := range strings.Split(strings.ReplaceAll(run.text, "\r", ""), "\n") {
+				if j > 0 {
+					endLine()
+				}
+				if part != "" {
+					part = strings.ReplaceAll(part, "\t", "    ")
+					html.setFont(run.st)
+					part = html.encode(part)
+					wd := f.GetStringWidth(part)
+					line = append(line, htmlPiece{text: part, st: run.st, w: wd})
+					lineWd += wd
+				}
+				hasText = true
+			}
+			continue
+		}
+		str := run.text
+		for len(str) > 0 {
+			n := strings.IndexFunc(str, unicode.IsSpace)
+			if n == 0 {
+				if !trailingSpace {
+					addSpace(run.st)
+				}
+				str = strings.TrimLeftFunc(str, unicode.IsSpace)
+				continue
+			}
+			if n < 0 {
+				n = len(str)
+			}
+			addWord(str[:n], run.st)
+			hasText = true
+			str = str[n:]
+		}
+	}
+	if len(line) > 0 {
+		endLine()
+	}
+	if !hasText {
+		return
+	}
+	// Drop the empty line produced by a leading forced break of a pre block
+	if len(runs) > 0 && runs[0].st.pre && len(lines) > 0 && len(lines[0]) == 0 {
+		lines = lines[1:]
+	}
+	for j, ln := range lines {
+		html.drawLine(ln, runs[0].st.align, j == len(lines)-1)
+	}
+}
+
+// drawLine prints the pieces of a line at the current position, moving to a
+// new page if needed, and advances the position below the line
+func (html *HTMLType) drawLine(line []htmlPiece, align string, last bool) {
+	f := html.pdf
+	k := html.lineHt / (html.baseSize / f.k)
+	maxSize := 0.0
+	lineWd := 0.0
+	spaces := 0
+	for _, p := range line {
+		maxSize = math.Max(maxSize, p.st.sizePt/f.k)
+		lineWd += p.w
+		if p.space {
+			spaces++
+		}
+	}
+	ht := html.lineHt
+	if maxSize > 0 {
+		ht = k * maxSize
+	}
+	html.breakIfNeeded(f, ht)
+	html.useSpace(f)
+	y := f.y
+	left, right := html.extent()
+
+	// Block decorations
+	for _, blk := range html.blocks {
+		bl, br := f.lMargin+blk.left, f.w-f.rMargin-blk.right
+		if blk.bgOn {
+			f.SetFillColor(blk.bg.R, blk.bg.G, blk.bg.B)
+			f.Rect(bl, y, br-bl, ht, "F")
+		}
+		if blk.bar {
+			x := bl - 1.5*html.lineHt
+			f.SetFillColor(200, 200, 200)
+			f.Rect(x, y, f.PointConvert(2), ht, "F")
+		}
+	}
+	if html.marker != nil {
+		m := html.marker
+		html.setFont(m.st)
+		f.SetTextColor(m.st.clr.R, m.st.clr.G, m.st.clr.B)
+		txt := html.encode(m.text)
+		wd := f.GetStringWidth(txt)
+		f.SetXY(left-wd-html.lineHt/2, y+(maxSize-m.st.sizePt/f.k)*(k/2+0.3))
+		f.CellFormat(wd, k*m.st.sizePt/f.k, txt, "", 0, "L", false, 0, "")
+		html.marker = nil
+	}
+
+	x := left
+	extra := 0.0
+	switch align {
+	case "C":
+		x += (right - left - lineWd) / 2
+	case "R":
+		x += right - left - lineWd
+	case "J":
+		if !last && spaces > 0 {
+			extra = (right - left - lineWd) / float64(spaces)
+		}
+	}
+	if extra == 0 {
+		// Print adjacent pieces of the same style at once
+		var merged []htmlPiece
+		for _, p := range line {
+			p.st.tag = ""
+			if n := len(merged); n > 0 && merged[n-1].st == p.st {
+				merged[n-1].text += p.text
+				merged[n-1].w += p.w
+				continue
+			}
+			merged = append(merged, p)
+		}
+		line = merged
+	}
+	for _, p := range line {
+		wd := p.w
+		if p.space {
+			wd += extra
+		}
+		size := p.st.sizePt / f.k
+		// Align the baselines of text of different sizes
+		f.SetXY(x, y+(maxSize-size)*(k/2+0.3))
+		html.setFont(p.st)
+		f.SetTextColor(p.st.clr.R, p.st.clr.G, p.st.clr.B)
+		if p.st.bgOn {
+			f.SetFillColor(p.st.bg.R, p.st.bg.G, p.st.bg.B)
+		}
+		f.CellFormat(wd, k*size, p.text, "", 0, "L", p.st.bgOn, 0, p.st.href)
+		x += wd
+	}
+	f.SetXY(left, y+ht)
+}
+
+// image places the image described by the attributes of an img element on
+// its own line
+func (html *HTMLType) image(attr map[string]string) {
+	f := html.pdf
+	src := attr["src"]
+	if src == "" {
+		return
+	}
+	html.flush()
+	info := f.RegisterImageOptions(src, ImageOptions{ReadDpi: true})
+	if f.err != nil {
+		return
+	}
+	left, right := html.extent()
+	wd, ht := info.Extent()
+	w, wOk := html.length(attr["width"], right-left)
+	h, hOk := html.length(attr["height"], right-left)
+	for _, decl := range strings.Split(attr["style"], ";") {
+		name, val, _ := strings.Cut(decl, ":")
+		switch strings.TrimSpace(strings.ToLower(name)) {
+		case "width":
+			w, wOk = html.length(strings.TrimSpace(val), right-left)
+		case "height":
+			h, hOk = html.length(strings.TrimSpace(val), right-left)
+		}
+	}
+	switch {
+	case wOk && hOk:
+		wd, ht = w, h
+	case wOk && wd > 0:
+		wd, ht = w, ht*w/wd
+	case hOk && ht > 0:
+		wd, ht = wd*h/ht, h
+	}
+	if wd > right-left {
+		ht = ht * (right - left) / wd
+		wd = right - left
+	}
+	html.breakIfNeeded(f, ht)
+	html.useSpace(f)
+	x := left
+	switch html.cur().align {
+	case "C":
+		x += (right - left - wd) / 2
+	case "R":
+		x += right - left - wd
+	}
+	f.ImageOptions(src, x, f.y, wd, ht, false, ImageOptions{ReadDpi: true}, 0, html.cur().href)
+	f.SetXY(left, f.y+ht)
+}
+
+// tableOpen processes an open tag within a table element
+func (html *HTMLType) tableOpen(tag string, attr map[string]string) {
+	t := html.table
+	switch tag {
+	case "thead":
+		t.thead = true
+	case "tbody", "tfoot":
+		t.thead = false
+	case "tr":
+		t.cells = nil
+		t.allTh = true
+	case "td", "th":
+		t.cell = &TableCellType{}
+		t.cell.ColSpan, _ = strconv.Atoi(attr["colspan"])
+		t.cell.RowSpan, _ = strconv.Atoi(attr["rowspan"])
+		align := htmlAlign(attr["align"], "")
+		for _, decl := range strings.Split(attr["style"], ";") {
+			name, val, _ := strings.Cut(decl, ":")
+			if strings.TrimSpace(strings.ToLower(name)) == "text-align" {
+				align = htmlAlign(val, align)
+			}
+		}
+		if align == "J" {
+			align = "L"
+		}
+		switch strings.ToLower(attr["valign"]) {
+		case "middle":
+			align += "M"
+		case "bottom":
+			align += "B"
+		}
+		t.cell.AlignStr = align
+		if tag == "td" {
+			t.allTh = false
+		}
+		t.text.Reset()
+	case "br":
+		if t.cell != nil {
+			t.text.WriteString("\n")
+		}
+	}
+}
+
+// tableClose processes a close tag within a table element
+func (html *HTMLType) tableClose(tag string) {
+	t := html.table
+	switch tag {
+	case "thead":
+		t.thead = false
+	case "td", "th":
+		if t.cell == nil {
+			return
+		}
+		var lines []string
+		for _, line := range strings.Split(t.text.String(), "\n") {
+			lines = append(lines, strings.Join(strings.Fields(line), " "))
+		}
+		html.setFont(*html.cur())
+		t.cell.Text = html.encode(strings.Join(lines, "\n"))
+		t.cells = append(t.cells, *t.cell)
+		t.cell = nil
+	case "tr":
+		if t.cell != nil {
+			html.tableClose("td")
+		}
+		if len(t.cells) == 0 {
+			return
+		}
+		if t.thead || (t.allTh && !t.hasBody) {
+			t.tbl.AddHeader(t.cells...)
+		} else {
+			t.tbl.AddRow(t.cells...)
+			t.hasBody = true
+		}
+		t.cells = nil
+	}
+}
+
+// tableOutput prints the collected table within the current block
+func (html *HTMLType) tableOutput() {
+	f := html.pdf
+	t := html.table
+	html.table = nil
+	if t.cell != nil || len(t.cells) > 0 {
+		html.tableClose("tr")
+	}
+	if len(t.tbl.header) == 0 && len(t.tbl.body) == 0 {
+		// A table without cells has no columns to lay out
+		return
+	}
+	left, right := html.extent()
+	html.setFont(*html.cur())
+	f.SetTextColor(html.cur().clr.R, html.cur().clr.G, html.cur().clr.B)
+	html.breakIfNeeded(f, html.lineHt)
+	html.useSpace(f)
+	f.SetX(left)
+	t.tbl.Width = right - left
+	t.tbl.LineHt = html.lineHt
+	t.tbl.Output()
+	f.SetX(left)
+}
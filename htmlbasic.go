@@ -1,7 +1,6 @@
 package docpdf
 
 import (
-	"regexp"
 	"strings"
 )
 
@@ -13,61 +12,14 @@ type HTMLBasicSegmentType struct {
 	Attr map[string]string // Attribute keys are lower case
 }
 
-// HTMLBasicTokenize returns a list of HTML tags and literal elements. This is
-// done with regular expressions, so the result is only marginally better than
-// useless.
+// HTMLBasicTokenize returns a list of HTML tags and literal elements. Line
+// breaks are replaced with spaces. Attribute values may be quoted and may
+// contain spaces; comments are dropped. Literal text is returned unchanged.
 func HTMLBasicTokenize(htmlStr string) (list []HTMLBasicSegmentType) {
-	// This routine is adapted from http://www.docpdf.org/
-	list = make([]HTMLBasicSegmentType, 0, 16)
 	htmlStr = strings.Replace(htmlStr, "\n", " ", -1)
 	htmlStr = strings.Replace(htmlStr, "\r", "", -1)
-	tagRe, _ := regexp.Compile(`(?U)<.*>`)
-	attrRe, _ := regexp.Compile(`([^=]+)=["']?([^"']+)`)
-	capList := tagRe.FindAllStringIndex(htmlStr, -1)
-	if capList != nil {
-		var seg HTMLBasicSegmentType
-		var parts []string
-		pos := 0
-		for _, cap := range capList {
-			if pos < cap[0] {
-				seg.Cat = 'T'
-				seg.Str = htmlStr[pos:cap[0]]
-				seg.Attr = nil
-				list = append(list, seg)
-			}
-			if htmlStr[cap[0]+1] == '/' {
-				seg.Cat = 'C'
-				seg.Str = strings.ToLower(htmlStr[cap[0]+2 : cap[1]-1])
-				seg.Attr = nil
-				list = append(list, seg)
-			} else {
-				// Extract attributes
-				parts = strings.Split(htmlStr[cap[0]+1:cap[1]-1], " ")
-				if len(parts) > 0 {
-					for j, part := range parts {
-						if j == 0 {
-							seg.Cat = 'O'
-							seg.Str = strings.ToLower(parts[0])
-							seg.Attr = make(map[string]string)
-						} else {
-							attrList := attrRe.FindAllStringSubmatch(part, -1)
-							for _, attr := range attrList {
-								seg.Attr[strings.ToLower(attr[1])] = attr[2]
-							}
-						}
-					}
-					list = append(list, seg)
-				}
-			}
-			pos = cap[1]
-		}
-		if len(htmlStr) > pos {
-			seg.Cat = 'T'
-			seg.Str = htmlStr[pos:]
-			seg.Attr = nil
-			list = append(list, seg)
-		}
-	} else {
+	list = htmlTokenize(htmlStr)
+	if len(list) == 0 {
 		list = append(list, HTMLBasicSegmentType{Cat: 'T', Str: htmlStr, Attr: nil})
 	}
 	return
@@ -78,6 +30,9 @@ func HTMLBasicTokenize(htmlStr string) (list []HTMLBasicSegmentType) {
 // structure, the ClrR, ClrG and ClrB fields (0 through 255) define the color
 // of hyperlinks. The Bold, Italic and Underscore values define the hyperlink
 // style.
+//
+// Deprecated: HTMLType, returned by HTMLNew(), renders a larger subset of
+// HTML and CSS, including the elements supported by HTMLBasicType.
 type HTMLBasicType struct {
 	pdf  *DocPDF
 	Link struct {
@@ -0,0 +1,143 @@
+package docpdf
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// hyphenator holds the hyphenation patterns and exceptions of a language.
+type hyphenator struct {
+	patterns   map[string][]int // letters of each pattern and its values
+	maxLen     int              // maximum number of letters of a pattern
+	exceptions map[string][]int // hyphen positions of the exception words
+}
+
+// AddHyphenationPatterns reads TeX hyphenation patterns from the file
+// fileStr and makes them available to the paragraph layout under the name
+// lang, such as "en-us". See AddHyphenationPatternsFromBytes() for the
+// format of the file.
+func (f *DocPDF) AddHyphenationPatterns(lang, fileStr string) {
+	if f.err != nil {
+		return
+	}
+	data, err := f.readFile(fileStr)
+	if err != nil {
+		f.err = err
+		return
+	}
+	f.AddHyphenationPatternsFromBytes(lang, data)
+}
+
+// AddHyphenationPatternsFromBytes makes the TeX hyphenation patterns data
+// available to the paragraph layout under the name lang. The patterns use
+// the Liang format of the hyph-utf8 project: either a plain list of
+// patterns separated by white space, as in the hyph-*.pat.txt files, or
+// the \patterns{...} and \hyphenation{...} groups of the hyph-*.tex files.
+// Exception words list their hyphens explicitly, as in "ta-ble". Comments
+// start with % and the patterns must be encoded in UTF-8.
+func (f *DocPDF) AddHyphenationPatternsFromBytes(lang string, data []byte) {
+	if f.err != nil {
+		return
+	}
+	h, err := parseHyphenation(string(data))
+	if err != nil {
+		f.err = err
+		return
+	}
+	if f.hyphenators == nil {
+		f.hyphenators = make(map[string]*hyphenator)
+	}
+	f.hyphenators[lang] = h
+}
+
+// parseHyphenation parses hyphenation patterns and exceptions.
+func parseHyphenation(data string) (*hyphenator, error) {
+	h := &hyphenator{patterns: make(map[string][]int), exceptions: make(map[string][]int)}
+	var lines []string
+	for _, line := range strings.Split(data, "\n") {
+		if j := strings.IndexByte(line, '%'); j >= 0 {
+			line = line[:j]
+		}
+		lines = append(lines, line)
+	}
+	data = strings.NewReplacer("{", " { ", "}", " } ").Replace(strings.Join(lines, "\n"))
+	group := "\\patterns"
+	command := ""
+	for _, tok := range strings.Fields(data) {
+		switch {
+		case tok == "{":
+			group, command = command, ""
+			continue
+		case tok == "}":
+			group = "\\patterns"
+			continue
+		case strings.HasPrefix(tok, "\\"):
+			command = tok
+			continue
+		}
+		switch group {
+		case "\\patterns":
+			letters, values := []rune{}, []int{0}
+			for _, r := range tok {
+				if r >= '0' && r <= '9' {
+					values[len(values)-1] = int(r - '0')
+				} else {
+					letters = append(letters, unicode.ToLower(r))
+					values = append(values, 0)
+				}
+			}
+			if len(letters) == 0 {
+				return nil, fmt.Errorf("invalid hyphenation pattern %q", tok)
+			}
+			h.patterns[string(letters)] = values
+			h.maxLen = max(h.maxLen, len(letters))
+		case "\\hyphenation":
+			var letters []rune
+			var positions []int
+			for _, r := range tok {
+				if r == '-' {
+					positions = append(positions, len(letters))
+				} else {
+					letters = append(letters, unicode.ToLower(r))
+				}
+			}
+			h.exceptions[string(letters)] = positions
+		}
+	}
+	return h, nil
+}
+
+// hyphenate returns the positions of word where a hyphen can be inserted,
+// leaving at least left characters before it and right characters after it.
+func (h *hyphenator) hyphenate(word []rune, left, right int) (positions []int) {
+	lower := make([]rune, len(word))
+	for j, r := range word {
+		lower[j] = unicode.ToLower(r)
+	}
+	points, ok := h.exceptions[string(lower)]
+	if !ok {
+		w := append(append([]rune{'.'}, lower...), '.')
+		values := make([]int, len(w)+1)
+		for j := range w {
+			for k := j + 1; k <= len(w) && k-j <= h.maxLen; k++ {
+				if pattern, ok := h.patterns[string(w[j:k])]; ok {
+					for l, v := range pattern {
+						values[j+l] = max(values[j+l], v)
+					}
+				}
+			}
+		}
+		for j := 1; j < len(word); j++ {
+			if values[j+1]%2 == 1 {
+				points = append(points, j)
+			}
+		}
+	}
+	for _, j := range points {
+		if j >= left && j <= len(word)-right {
+			positions = append(positions, j)
+		}
+	}
+	return
+}
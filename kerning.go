@@ -0,0 +1,162 @@
+package docpdf
+
+import (
+	"math"
+	"strconv"
+)
+
+// SetKerning enables or disables pair kerning. When kerning is enabled, the
+// space between two characters is adjusted with the kerning data of the
+// current font: the kern table and the GPOS kern feature of UTF-8 fonts, and
+// the kerning pairs of the font definition files generated by MakeFont, read
+// from the same tables of TrueType fonts and from the KPX entries of the AFM
+// file of Type1 fonts. Font definition files generated by older versions and
+// the core fonts carry no kerning pairs.
+//
+// Kerning applies to the text output by Cell(), CellFormat(), MultiCell(),
+// Write() and Text(), and is taken into account by GetStringWidth(),
+// SplitText() and SplitLines().
+func (f *DocPDF) SetKerning(enabled bool) {
+	f.kerning = enabled
+}
+
+// kern returns the kerning adjustment between the characters a and b with
+// the current font, in thousandths of the font size.
+func (f *DocPDF) kern(a, b rune) int {
+	if !f.kerning {
+		return 0
+	}
+	if f.isCurrentUTF8 {
+		if f.currentFont.utf8File == nil {
+			return 0
+		}
+		return f.currentFont.utf8File.shaper().kernPair(a, b)
+	}
+	return f.currentFont.Kp[int(a)][int(b)]
+}
+
+// kernWidth returns the sum of the kerning adjustments of the characters of
+// s with the current font, in thousandths of the font size.
+func (f *DocPDF) kernWidth(s string) int {
+	if !f.kerning {
+		return 0
+	}
+	w := 0
+	chars := f.kernChars(s)
+	for j := 1; j < len(chars); j++ {
+		w += f.kern(chars[j-1], chars[j])
+	}
+	return w
+}
+
+// kernChars returns the characters of s: runes for UTF-8 fonts, bytes
+// otherwise.
+func (f *DocPDF) kernChars(s string) []rune {
+	if f.isCurrentUTF8 {
+		return []rune(s)
+	}
+	chars := make([]rune, len(s))
+	for j := 0; j < len(s); j++ {
+		chars[j] = rune(s[j])
+	}
+	return chars
+}
+
+// kernedText returns the elements of a TJ array that shows s with the current
+// font, with kerning applied. spaceAdj is added to the width of each space,
+// in thousandths of the font size.
+func (f *DocPDF) kernedText(s string, spaceAdj float64) string {
+	chars := f.kernChars(s)
+	var buf fmtBuffer
+	j := 0
+	flush := func(k int) {
+		if k == j {
+			return
+		}
+		if f.isCurrentUTF8 {
+			for _, uni := range chars[j:k] {
+				f.currentFont.usedRunes[int(uni)] = int(uni)
+			}
+			buf.printf("(%s) ", f.escape(utf8toutf16(string(chars[j:k]), false)))
+		} else {
+			buf.printf("(%s) ", f.escape(s[j:k]))
+		}
+		j = k
+	}
+	for k := 0; k+1 < len(chars); k++ {
+		adj := -float64(f.kern(chars[k], chars[k+1]))
+		if chars[k] == ' ' {
+			adj -= spaceAdj
+		}
+		if adj != 0 {
+			flush(k + 1)
+			buf.printf("%s ", strconv.FormatFloat(math.Round(adj*1000)/1000, 'f', -1, 64))
+		}
+	}
+	flush(len(chars))
+	return buf.String()
+}
+
+// kernPair returns the kerning adjustment between the runes a and b, in
+// thousandths of the font size.
+func (sh *shaperType) kernPair(a, b rune) int {
+	key := [2]int{int(a), int(b)}
+	if v, ok := sh.kernCache[key]; ok {
+		return v
+	}
+	v := 0
+	if ga, gb := sh.cmap[int(a)], sh.cmap[int(b)]; ga != 0 && gb != 0 {
+		v = int(math.Round(float64(pairKern(sh.gpos, sh.gdef, sh.kern, ga, gb)) * 1000 / float64(sh.upem)))
+	}
+	sh.kernCache[key] = v
+	return v
+}
+
+// pairKern returns the kerning adjustment between the glyphs left and right
+// given by the kern feature of the GPOS table, or by the kern table if the
+// font has no kern feature, in font units.
+func pairKern(gpos *otLayout, gdef *otGDEF, kern map[[2]int]int, left, right int) int {
+	for _, script := range []string{"latn", "DFLT"} {
+		if len(gpos.featureLookups(script, "kern")) == 0 {
+			continue
+		}
+		buf := []otGlyph{{gid: left, attach: -1, mask: maskGlobal}, {gid: right, attach: -1, mask: maskGlobal}}
+		for j := range buf {
+			buf[j].class = otClassBase
+			if gdef != nil && gdef.glyphClass != 0 {
+				buf[j].class = gdef.data.classDef(gdef.glyphClass, buf[j].gid)
+			}
+		}
+		var ligID int
+		buf = gpos.applyStages(gdef, script, []otFeatureStage{{features: []string{"kern"}, masks: []uint32{maskGlobal}}}, buf, &ligID)
+		return buf[0].xAdv + buf[1].xOff
+	}
+	return kern[[2]int{left, right}]
+}
+
+// parseKernTable returns the horizontal kerning pairs of the format 0
+// subtables of a kern table.
+func parseKernTable(data []byte) map[[2]int]int {
+	d := otData(data)
+	if len(d) < 4 || d.u16(0) != 0 {
+		return nil
+	}
+	pairs := make(map[[2]int]int)
+	off := 4
+	for j := 0; j < d.u16(2) && off+6 <= len(d); j++ {
+		length, coverage := d.u16(off+2), d.u16(off+4)
+		// format 0, horizontal, kerning values
+		if coverage>>8 == 0 && coverage&0x7 == 0x1 {
+			n := d.u16(off + 6)
+			for k := 0; k < n && off+14+6*k+6 <= len(d); k++ {
+				rec := off + 14 + 6*k
+				pairs[[2]int{d.u16(rec), d.u16(rec + 2)}] = d.s16(rec + 4)
+			}
+		}
+		if length == 0 {
+			break
+		}
+		off += length
+	}
+	return pairs
+}
@@ -66,13 +66,20 @@ func TickmarkPrecision(div float64) int {
 
 // Tickmarks returns a slice of tickmarks appropriate for a chart axis and an
 // appropriate precision for formatting purposes. The values min and max will
-// be contained within the tickmark range.
+// be contained within the tickmark range. The slice is empty if max is not
+// greater than min, or if the range cannot be represented with tickmarks.
 func Tickmarks(min, max float64) (list []float64, precision int) {
 	if max > min {
 		spread := niceNum(max-min, false)
 		d := niceNum((spread / 4), true)
 		graphMin := math.Floor(min/d) * d
 		graphMax := math.Ceil(max/d) * d
+		// The range cannot be divided if it overflows or if the step is lost
+		// in the magnitude of the values
+		if math.IsNaN(d) || math.IsInf(graphMin, 0) || math.IsInf(graphMax, 0) ||
+			graphMin+d == graphMin || graphMax-d == graphMax {
+			return
+		}
 		precision = TickmarkPrecision(d)
 		for x := graphMin; x < graphMax+0.5*d; x += d {
 			list = append(list, x)
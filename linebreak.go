@@ -0,0 +1,229 @@
+package docpdf
+
+import "unicode"
+
+// Line breaking classes of the Unicode line breaking algorithm (UAX #14)
+const (
+	lbAL  = iota // alphabetic
+	lbBA         // break after
+	lbBB         // break before
+	lbB2         // break before and after
+	lbBK         // mandatory break
+	lbCB         // contingent break
+	lbCL         // closing punctuation
+	lbCM         // combining mark
+	lbCP         // closing parenthesis
+	lbCR         // carriage return
+	lbEX         // exclamation
+	lbGL         // non-breaking glue
+	lbHY         // hyphen
+	lbID         // ideographic
+	lbIN         // inseparable
+	lbIS         // infix numeric separator
+	lbLF         // line feed
+	lbNL         // next line
+	lbNS         // non-starter
+	lbNU         // numeric
+	lbOP         // opening punctuation
+	lbPO         // postfix numeric
+	lbPR         // prefix numeric
+	lbQU         // quotation
+	lbRI         // regional indicator
+	lbSP         // space
+	lbSY         // symbol allowing break after
+	lbWJ         // word joiner
+	lbZW         // zero width space
+	lbZWJ        // zero width joiner
+)
+
+// Break opportunities between two characters
+const (
+	lineBreakNone = iota
+	lineBreakAllowed
+	lineBreakMandatory
+)
+
+// lineBreakClass returns the line breaking class of r. As for bidiClass(),
+// the classes are derived from the Unicode blocks and general categories
+// where the Unicode character database lists too many characters. The
+// ambiguous, complex context and conditional Japanese starter classes are
+// resolved as AL, AL and NS, which the algorithm recommends when no
+// language specific tailoring is used.
+func lineBreakClass(r rune) int {
+	switch r {
+	case '\n':
+		return lbLF
+	case '\r':
+		return lbCR
+	case 0x0B, 0x0C, 0x2028, 0x2029:
+		return lbBK
+	case 0x85:
+		return lbNL
+	case ' ':
+		return lbSP
+	case '\t', 0xAD, 0x058A, 0x1680, 0x2010, 0x2012, 0x2013, 0x205F, 0x3000:
+		return lbBA
+	case '-':
+		return lbHY
+	case 0x2014:
+		return lbB2
+	case 0xB4, 0x02C8, 0x02CC, 0x02DF, 0x0F01, 0x1FFD:
+		return lbBB
+	case 0x200B:
+		return lbZW
+	case 0x200D:
+		return lbZWJ
+	case 0x2060, 0xFEFF:
+		return lbWJ
+	case 0xA0, 0x034F, 0x2007, 0x2011, 0x202F, 0x0F0C:
+		return lbGL
+	case 0xFFFC:
+		return lbCB
+	case ')', ']':
+		return lbCP
+	case '!', '?', 0x05C6, 0x061B, 0x061F, 0x06D4, 0xFE15, 0xFE16, 0xFE56, 0xFE57, 0xFF01, 0xFF1F:
+		return lbEX
+	case ',', '.', ':', ';', 0x037E, 0x0589, 0x060C, 0x060D, 0x07F8, 0x2044, 0xFE10, 0xFE13, 0xFE14:
+		return lbIS
+	case '/':
+		return lbSY
+	case '"', '\'', 0x275B, 0x275C, 0x275D, 0x275E, 0x2E00, 0x2E01, 0x2E06, 0x2E07, 0x2E08:
+		return lbQU
+	case '%', 0xA2, 0xB0, 0x2030, 0x2031, 0x2032, 0x2033, 0x2034, 0x2035, 0x2036, 0x2037,
+		0x2103, 0x2109, 0xFE6A, 0xFF05, 0xFFE0:
+		return lbPO
+	case '$', '+', '\\', 0xA3, 0xA4, 0xA5, 0xB1, 0x2116, 0x2212, 0x2213, 0xFE69, 0xFF04, 0xFFE1, 0xFFE5, 0xFFE6:
+		return lbPR
+	case 0x2024, 0x2025, 0x2026, 0x22EF, 0xFE19:
+		return lbIN
+	case 0x3001, 0x3002, 0xFE11, 0xFE12, 0xFF0C, 0xFF0E, 0xFF61, 0xFF64:
+		return lbCL
+	case 0x3005, 0x303B, 0x309B, 0x309C, 0x309D, 0x309E, 0x30A0, 0x30FB, 0x30FC, 0x30FD, 0x30FE,
+		0x203C, 0x203D, 0x2047, 0x2048, 0x2049, 0x301C, 0xFF65, 0xFF70, 0xFF9E, 0xFF9F:
+		return lbNS
+	case 0x3041, 0x3043, 0x3045, 0x3047, 0x3049, 0x3063, 0x3083, 0x3085, 0x3087, 0x308E, 0x3095, 0x3096,
+		0x30A1, 0x30A3, 0x30A5, 0x30A7, 0x30A9, 0x30C3, 0x30E3, 0x30E5, 0x30E7, 0x30EE, 0x30F5, 0x30F6:
+		// small kana, conditional Japanese starters
+		return lbNS
+	}
+	switch {
+	case r >= 0x1F1E6 && r <= 0x1F1FF:
+		return lbRI
+	case r >= 0x2000 && r <= 0x2006, r >= 0x2008 && r <= 0x200A:
+		return lbBA
+	case r >= 0x31F0 && r <= 0x31FF, r >= 0xFF67 && r <= 0xFF6F:
+		return lbNS
+	case r >= 0x20A0 && r <= 0x20CF:
+		return lbPR
+	case unicode.Is(unicode.Ps, r):
+		return lbOP
+	case unicode.Is(unicode.Pe, r):
+		return lbCL
+	case unicode.Is(unicode.Pi, r), unicode.Is(unicode.Pf, r):
+		return lbQU
+	case unicode.Is(unicode.Nd, r):
+		return lbNU
+	case unicode.In(r, unicode.Mn, unicode.Mc, unicode.Me, unicode.Cc):
+		return lbCM
+	case r >= 0x1100 && r <= 0x11FF, r >= 0x2E80 && r <= 0x2FFF, r >= 0x3000 && r <= 0x303F,
+		r >= 0x3040 && r <= 0x30FF, r >= 0x3130 && r <= 0x318F, r >= 0x3190 && r <= 0x31EF,
+		r >= 0x3200 && r <= 0x4DBF, r >= 0x4E00 && r <= 0x9FFF, r >= 0xA000 && r <= 0xA4CF,
+		r >= 0xAC00 && r <= 0xD7AF, r >= 0xF900 && r <= 0xFAFF, r >= 0xFE30 && r <= 0xFE4F,
+		r >= 0xFF00 && r <= 0xFF60, r >= 0xFFE0 && r <= 0xFFE6,
+		r >= 0x1F000 && r <= 0x1FAFF, r >= 0x20000 && r <= 0x3FFFD:
+		// CJK scripts and symbols, fullwidth forms and emoji
+		return lbID
+	}
+	return lbAL
+}
+
+// lineBreaks returns the break opportunity before each character of s,
+// following the pair rules of the Unicode line breaking algorithm. The
+// first element is always lineBreakNone, and the opportunity after the
+// last character is not reported.
+func lineBreaks(s []rune) []int {
+	n := len(s)
+	breaks := make([]int, n)
+	orig := make([]int, n)
+	cls := make([]int, n)
+	for j, r := range s {
+		orig[j] = lineBreakClass(r)
+		cls[j] = orig[j]
+	}
+	// LB9 and LB10: combining marks take the class of their base
+	attached := make([]bool, n)
+	for j := range cls {
+		if cls[j] != lbCM && cls[j] != lbZWJ {
+			continue
+		}
+		if j > 0 {
+			switch orig[j-1] {
+			case lbBK, lbCR, lbLF, lbNL, lbSP, lbZW:
+			default:
+				cls[j] = cls[j-1]
+				attached[j] = true
+				continue
+			}
+		}
+		cls[j] = lbAL
+	}
+	riCount := 0
+	for j := 1; j < n; j++ {
+		a, b := cls[j-1], cls[j]
+		if a == lbRI {
+			riCount++
+		} else if !attached[j-1] {
+			riCount = 0
+		}
+		// class before the spaces preceding j
+		k := j - 1
+		for k > 0 && cls[k] == lbSP {
+			k--
+		}
+		before := cls[k]
+		breaks[j] = lineBreakAllowed
+		switch {
+		case a == lbBK || a == lbLF || a == lbNL || (a == lbCR && b != lbLF):
+			breaks[j] = lineBreakMandatory
+		case b == lbBK || b == lbCR || b == lbLF || b == lbNL:
+			breaks[j] = lineBreakNone
+		case b == lbSP || b == lbZW:
+			breaks[j] = lineBreakNone
+		case before == lbZW:
+		case orig[j-1] == lbZWJ, attached[j]:
+			breaks[j] = lineBreakNone
+		case a == lbWJ || b == lbWJ, a == lbGL:
+			breaks[j] = lineBreakNone
+		case b == lbGL && a != lbSP && a != lbBA && a != lbHY:
+			breaks[j] = lineBreakNone
+		case b == lbCL || b == lbCP || b == lbEX || b == lbIS || b == lbSY:
+			breaks[j] = lineBreakNone
+		case before == lbOP, before == lbQU && b == lbOP,
+			(before == lbCL || before == lbCP) && b == lbNS, before == lbB2 && b == lbB2:
+			breaks[j] = lineBreakNone
+		case a == lbSP:
+		case a == lbQU || b == lbQU:
+			breaks[j] = lineBreakNone
+		case a == lbCB || b == lbCB:
+		case b == lbBA || b == lbHY || b == lbNS || a == lbBB, b == lbIN:
+			breaks[j] = lineBreakNone
+		case a == lbAL && b == lbNU, a == lbNU && b == lbAL:
+			breaks[j] = lineBreakNone
+		case a == lbPR && b == lbID, a == lbID && b == lbPO:
+			breaks[j] = lineBreakNone
+		case (a == lbPR || a == lbPO) && b == lbAL, a == lbAL && (b == lbPR || b == lbPO):
+			breaks[j] = lineBreakNone
+		case (a == lbCL || a == lbCP || a == lbNU) && (b == lbPO || b == lbPR),
+			(a == lbPO || a == lbPR) && (b == lbOP || b == lbNU),
+			(a == lbHY || a == lbIS || a == lbNU || a == lbSY) && b == lbNU, a == lbOP && b == lbNU:
+			breaks[j] = lineBreakNone
+		case a == lbAL && b == lbAL, a == lbIS && b == lbAL:
+			breaks[j] = lineBreakNone
+		case (a == lbAL || a == lbNU) && b == lbOP, a == lbCP && (b == lbAL || b == lbNU):
+			breaks[j] = lineBreakNone
+		case a == lbRI && b == lbRI && riCount%2 == 1:
+			breaks[j] = lineBreakNone
+		}
+	}
+	return breaks
+}
@@ -0,0 +1,1144 @@
+package docpdf
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MarkdownType renders Markdown documents. See MarkdownNew() to create an
+// instance that is associated with a PDF document instance. The exported
+// fields can be modified prior to calling Write().
+type MarkdownType struct {
+	pdf *DocPDF
+	// Core font family of code spans and code blocks
+	MonoFamily string
+	// Background of code blocks
+	ClrCode RGBType
+	// Color of link text, which is also underlined
+	ClrLink RGBType
+	// Color of the bar drawn left of block quotes and of their text
+	ClrQuoteBar  RGBType
+	ClrQuoteText RGBType
+	// Headings are added to the document outline with Bookmark() if Outline
+	// is true
+	Outline bool
+
+	tr      func(string) string // code page translator for non UTF-8 fonts
+	lineHt  float64             // line height of the base font size
+	family  string              // base font family
+	sizePt  float64             // base font size in points
+	clr     RGBType             // current text color
+	depth   int                 // nesting level of lists
+	level   int                 // outline level of the last heading
+	refs    map[string]string   // link reference definitions
+	started bool                // a heading has been added to the outline
+	blockSpacing
+}
+
+// Kinds of Markdown blocks
+const (
+	mdParagraph = iota
+	mdHeading
+	mdCode
+	mdQuote
+	mdList
+	mdItem
+	mdRule
+	mdTable
+)
+
+// mdBlock is a block of a Markdown document
+type mdBlock struct {
+	kind     int
+	level    int        // heading level
+	text     string     // inline text of paragraphs and headings, content of code blocks
+	children []*mdBlock // blocks of quotes and list items, items of lists
+	ordered  bool       // ordered list
+	marker   byte       // bullet character or delimiter of the numbers of a list
+	start    int        // first number of an ordered list
+	tight    bool       // list items are not separated by blank lines
+	task     int        // 1 for an unchecked and 2 for a checked task list item
+	align    []string   // column alignments of a table
+	rows     [][]string // cells of a table; the first row is the header
+}
+
+// mdRun is a span of inline content sharing the same style
+type mdRun struct {
+	text                       string
+	bold, italic, strike, code bool
+	link                       string
+	image                      string // source of an image; text holds its alternative text
+	br                         bool   // hard line break
+}
+
+// mdToken is an inline element or an emphasis delimiter run
+type mdToken struct {
+	mdRun
+	delim       byte // delimiter character, zero for other elements
+	count       int  // number of unmatched delimiter characters
+	open, close bool // the delimiter run can open or close emphasis
+}
+
+var (
+	mdATXRe      = regexp.MustCompile(`^(#{1,6})(?:[ ]+(.*?))?(?:[ ]+#+)?[ ]*$`)
+	mdRuleRe     = regexp.MustCompile(`^(?:(?:\*[ ]*){3,}|(?:-[ ]*){3,}|(?:_[ ]*){3,})$`)
+	mdFenceRe    = regexp.MustCompile("^(`{3,}|~{3,})[ ]*([^`]*)$")
+	mdOrderedRe  = regexp.MustCompile(`^(\d{1,9})([.)])`)
+	mdDelimRowRe = regexp.MustCompile(`^\|?[ ]*:?-+:?[ ]*(?:\|[ ]*:?-+:?[ ]*)*\|?[ ]*$`)
+	mdRefDefRe   = regexp.MustCompile(`^\[((?:[^\]\\]|\\.)+)\]:[ ]*<?([^\s>]+)>?(?:[ ]+(?:"[^"]*"|'[^']*'|\([^)]*\)))?[ ]*$`)
+	mdAutoRe     = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^\s<>]*)>`)
+	mdEmailRe    = regexp.MustCompile(`^<([A-Za-z0-9.!#$%&'*+/=?^_{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)*)>`)
+	mdBareRe     = regexp.MustCompile(`^(?:https?://|www\.)[^\s<]*`)
+	mdEntityRe   = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]{1,31});`)
+)
+
+// MarkdownNew returns an instance that renders Markdown in the specified PDF
+// file. It supports the CommonMark syntax, with the GitHub tables, task list
+// items, strikethrough and bare links extensions. Raw HTML is printed as
+// text.
+//
+// Headings are printed in bold with larger sizes and added to the document
+// outline. Code spans and code blocks use the monospace core font MonoFamily,
+// and code blocks are shaded with ClrCode. Links are written with
+// WriteLinkString(), images are registered with RegisterImage() unless they
+// were registered before, and tables are laid out with TableNew().
+func (f *DocPDF) MarkdownNew() (md MarkdownType) {
+	md.pdf = f
+	md.MonoFamily = "Courier"
+	md.ClrCode = RGBType{R: 240, G: 240, B: 240}
+	md.ClrLink = RGBType{R: 0, G: 0, B: 128}
+	md.ClrQuoteBar = RGBType{R: 200, G: 200, B: 200}
+	md.ClrQuoteText = RGBType{R: 96, G: 96, B: 96}
+	md.Outline = true
+	return
+}
+
+// Write prints the Markdown document mdStr at the current vertical position,
+// between the left and right margins, using the current font as the base font
+// of the text. lineHt indicates the line height of the base font in the unit
+// of measure specified in New(). Upon method exit, the current position is
+// left at the left margin below the document, and the font, colors and line
+// width are restored.
+func (md *MarkdownType) Write(lineHt float64, mdStr string) {
+	f := md.pdf
+	if f.err != nil {
+		return
+	}
+	if f.currentFont.Name == "" {
+		f.err = fmt.Errorf("font has not been set; unable to render markdown")
+		return
+	}
+	// Save the state modified while rendering
+	cMargin, lMargin := f.cMargin, f.lMargin
+	family, sizePt := f.fontFamily, f.fontSizePt
+	styleStr := f.GetFontStyle()
+	textR, textG, textB := f.GetTextColor()
+	fillR, fillG, fillB := f.GetFillColor()
+	drawR, drawG, drawB := f.GetDrawColor()
+	lineWd := f.GetLineWidth()
+	defer func() {
+		f.cMargin = cMargin
+		f.SetLeftMargin(lMargin)
+		f.SetFont(family, styleStr, sizePt)
+		f.SetTextColor(textR, textG, textB)
+		f.SetFillColor(fillR, fillG, fillB)
+		f.SetDrawColor(drawR, drawG, drawB)
+		f.SetLineWidth(lineWd)
+		f.x = f.lMargin
+	}()
+	f.cMargin = 0
+	md.lineHt = lineHt
+	md.family, md.sizePt = family, sizePt
+	md.clr = RGBType{textR, textG, textB}
+	md.space = 0
+	md.depth = 0
+	md.refs = make(map[string]string)
+
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(mdStr, "\r\n", "\n"), "\n") {
+		lines = append(lines, mdExpandTabs(strings.TrimSuffix(line, "\r")))
+	}
+	blocks := md.parse(lines)
+	f.x = f.lMargin
+	md.blocks(blocks, false)
+	md.startBlock()
+}
+
+// mdExpandTabs replaces the tabs of line with spaces up to the next multiple
+// of four columns
+func mdExpandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+// mdIndent returns the number of leading spaces of line
+func mdIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func mdBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// mdListMarker parses the list marker at the start of s. It returns the
+// width of the marker and of the spaces following it, up to the content.
+func mdListMarker(s string) (ordered bool, marker byte, start, width int, ok bool) {
+	n := 0
+	switch {
+	case len(s) > 0 && (s[0] == '-' || s[0] == '+' || s[0] == '*'):
+		marker, n = s[0], 1
+	default:
+		m := mdOrderedRe.FindStringSubmatch(s)
+		if m == nil {
+			return
+		}
+		ordered, marker, n = true, m[2][0], len(m[0])
+		start, _ = strconv.Atoi(m[1])
+	}
+	rest := s[n:]
+	if mdBlank(rest) {
+		return ordered, marker, start, n + 1, true
+	}
+	spaces := mdIndent(rest)
+	if spaces == 0 {
+		return false, 0, 0, 0, false
+	}
+	if spaces > 4 {
+		spaces = 1
+	}
+	return ordered, marker, start, n + spaces, true
+}
+
+// mdListStart reports whether line starts a list item
+func mdListStart(line string) bool {
+	ind := mdIndent(line)
+	_, _, _, _, ok := mdListMarker(line[ind:])
+	return ok && ind < 4
+}
+
+// startsBlock reports whether line starts a block that interrupts a
+// paragraph
+func (md *MarkdownType) startsBlock(line string) bool {
+	ind := mdIndent(line)
+	if ind >= 4 {
+		return false
+	}
+	s := line[ind:]
+	if mdRuleRe.MatchString(s) || mdATXRe.MatchString(s) || mdFenceRe.MatchString(s) || strings.HasPrefix(s, ">") {
+		return true
+	}
+	ordered, _, start, width, ok := mdListMarker(s)
+	return ok && !mdBlank(s[min(width, len(s)):]) && (!ordered || start == 1)
+}
+
+// parse splits lines, stripped of the markers of their containers, in blocks
+func (md *MarkdownType) parse(lines []string) (blocks []*mdBlock) {
+	var para []string
+	flush := func() {
+		// Link reference definitions start paragraphs
+		for len(para) > 0 {
+			m := mdRefDefRe.FindStringSubmatch(para[0])
+			if m == nil {
+				break
+			}
+			label := mdLabel(m[1])
+			if _, ok := md.refs[label]; !ok {
+				md.refs[label] = m[2]
+			}
+			para = para[1:]
+		}
+		if len(para) > 0 {
+			blocks = append(blocks, &mdBlock{kind: mdParagraph, text: strings.TrimRight(strings.Join(para, "\n"), " ")})
+		}
+		para = nil
+	}
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if mdBlank(line) {
+			flush()
+			i++
+			continue
+		}
+		ind := mdIndent(line)
+		s := line[ind:]
+		if ind >= 4 {
+			if len(para) > 0 {
+				para = append(para, s)
+				i++
+				continue
+			}
+			// Indented code block
+			var code []string
+			for ; i < len(lines) && (mdBlank(lines[i]) || mdIndent(lines[i]) >= 4); i++ {
+				if len(lines[i]) > 4 {
+					code = append(code, lines[i][4:])
+				} else {
+					code = append(code, "")
+				}
+			}
+			for len(code) > 0 && mdBlank(code[len(code)-1]) {
+				code = code[:len(code)-1]
+			}
+			blocks = append(blocks, &mdBlock{kind: mdCode, text: strings.Join(code, "\n")})
+			continue
+		}
+		if len(para) > 0 && (strings.Trim(s, "= ") == "" || strings.Trim(s, "- ") == "") && !strings.Contains(strings.TrimSpace(s), " ") {
+			// Setext heading
+			level := 1
+			if s[0] == '-' {
+				level = 2
+			}
+			blocks = append(blocks, &mdBlock{kind: mdHeading, level: level, text: strings.TrimSpace(strings.Join(para, "\n"))})
+			para = nil
+			i++
+			continue
+		}
+		if m := mdFenceRe.FindStringSubmatch(s); m != nil && (m[1][0] == '`' || !strings.Contains(m[2], "`")) {
+			flush()
+			fence := m[1]
+			var code []string
+			for i++; i < len(lines); i++ {
+				l := lines[i]
+				li := mdIndent(l)
+				if closing := strings.TrimRight(l[li:], " "); li < 4 && len(closing) >= len(fence) && strings.Trim(closing, fence[:1]) == "" {
+					i++
+					break
+				}
+				code = append(code, l[min(ind, mdIndent(l)):])
+			}
+			blocks = append(blocks, &mdBlock{kind: mdCode, text: strings.Join(code, "\n")})
+			continue
+		}
+		if m := mdATXRe.FindStringSubmatch(s); m != nil {
+			flush()
+			blocks = append(blocks, &mdBlock{kind: mdHeading, level: len(m[1]), text: m[2]})
+			i++
+			continue
+		}
+		if mdRuleRe.MatchString(s) {
+			flush()
+			blocks = append(blocks, &mdBlock{kind: mdRule})
+			i++
+			continue
+		}
+		if strings.HasPrefix(s, ">") {
+			flush()
+			var inner []string
+			for i < len(lines) {
+				l := lines[i]
+				li := mdIndent(l)
+				if li < 4 && strings.HasPrefix(l[li:], ">") {
+					q := l[li+1:]
+					if strings.HasPrefix(q, " ") {
+						q = q[1:]
+					}
+					inner = append(inner, q)
+					i++
+					continue
+				}
+				// lazy continuation of a paragraph
+				if !mdBlank(l) && len(inner) > 0 && !mdBlank(inner[len(inner)-1]) && !md.startsBlock(l) {
+					inner = append(inner, l)
+					i++
+					continue
+				}
+				break
+			}
+			blocks = append(blocks, &mdBlock{kind: mdQuote, children: md.parse(inner)})
+			continue
+		}
+		if ordered, marker, start, _, ok := mdListMarker(s); ok && (len(para) == 0 || md.startsBlock(line)) {
+			flush()
+			list := &mdBlock{kind: mdList, ordered: ordered, marker: marker, start: start, tight: true}
+			i = md.parseList(lines, i, list)
+			blocks = append(blocks, list)
+			continue
+		}
+		if len(para) == 0 && i+1 < len(lines) && strings.Contains(s, "|") && mdDelimRowRe.MatchString(strings.TrimSpace(lines[i+1])) {
+			header := mdCells(s)
+			delims := mdCells(strings.TrimSpace(lines[i+1]))
+			if len(header) == len(delims) {
+				tbl := &mdBlock{kind: mdTable, rows: [][]string{header}}
+				for _, d := range delims {
+					switch {
+					case strings.HasPrefix(d, ":") && strings.HasSuffix(d, ":"):
+						tbl.align = append(tbl.align, "C")
+					case strings.HasSuffix(d, ":"):
+						tbl.align = append(tbl.align, "R")
+					default:
+						tbl.align = append(tbl.align, "L")
+					}
+				}
+				for i += 2; i < len(lines) && !mdBlank(lines[i]) && !md.startsBlock(lines[i]); i++ {
+					row := mdCells(strings.TrimSpace(lines[i]))
+					for len(row) < len(header) {
+						row = append(row, "")
+					}
+					tbl.rows = append(tbl.rows, row[:len(header)])
+				}
+				blocks = append(blocks, tbl)
+				continue
+			}
+		}
+		para = append(para, s)
+		i++
+	}
+	flush()
+	return
+}
+
+// parseList adds to list the items starting at lines[i], and returns the
+// index of the line following the list
+func (md *MarkdownType) parseList(lines []string, i int, list *mdBlock) int {
+	for i < len(lines) {
+		line := lines[i]
+		ind := mdIndent(line)
+		if ind >= 4 || mdRuleRe.MatchString(line[ind:]) {
+			break
+		}
+		ordered, marker, _, width, ok := mdListMarker(line[ind:])
+		if !ok || ordered != list.ordered || marker != list.marker {
+			break
+		}
+		width += ind
+		item := []string{""}
+		if width < len(line) {
+			item[0] = line[width:]
+		}
+		for i++; i < len(lines); i++ {
+			l := lines[i]
+			switch {
+			case mdBlank(l):
+				item = append(item, "")
+				continue
+			case mdIndent(l) >= width:
+				item = append(item, l[width:])
+				continue
+			case !mdBlank(item[len(item)-1]) && !md.startsBlock(l) && !mdListStart(l) && !mdFenceRe.MatchString(item[0]):
+				// lazy continuation of a paragraph
+				item = append(item, strings.TrimLeft(l, " "))
+				continue
+			}
+			break
+		}
+		n := len(item)
+		for n > 0 && mdBlank(item[n-1]) {
+			n--
+		}
+		trailing := n < len(item)
+		item = item[:n]
+		blk := &mdBlock{kind: mdItem}
+		if len(item) > 0 {
+			for _, task := range []string{"[ ] ", "[x] ", "[X] "} {
+				if strings.HasPrefix(item[0], task) {
+					blk.task = 1
+					if task != "[ ] " {
+						blk.task = 2
+					}
+					item[0] = item[0][len(task):]
+				}
+			}
+		}
+		blk.children = md.parse(item)
+		for j := 1; j < len(item); j++ {
+			if mdBlank(item[j]) && len(blk.children) > 1 {
+				list.tight = false
+			}
+		}
+		list.children = append(list.children, blk)
+		if trailing && i < len(lines) {
+			l := lines[i]
+			li := mdIndent(l)
+			if o, m, _, _, ok := mdListMarker(l[li:]); !ok || li >= 4 || o != list.ordered || m != list.marker {
+				break
+			}
+			list.tight = false
+		}
+	}
+	return i
+}
+
+// mdCells splits a table row in cells
+func mdCells(row string) (cells []string) {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, "\\|") {
+		row = row[:len(row)-1]
+	}
+	var cell strings.Builder
+	for j := 0; j < len(row); j++ {
+		switch {
+		case row[j] == '\\' && j+1 < len(row) && row[j+1] == '|':
+			cell.WriteByte('|')
+			j++
+		case row[j] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(row[j])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// mdLabel normalizes a link label
+func mdLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+func mdPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// inline parses the inline content s in runs
+func (md *MarkdownType) inline(s string) (runs []mdRun) {
+	var toks []mdToken
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			toks = append(toks, mdToken{mdRun: mdRun{text: text.String()}})
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			flush()
+			toks = append(toks, mdToken{mdRun: mdRun{br: true}})
+			i += 2
+			for i < len(s) && s[i] == ' ' {
+				i++
+			}
+		case c == '\\' && i+1 < len(s) && s[i+1] < utf8.RuneSelf && mdPunct(rune(s[i+1])):
+			text.WriteByte(s[i+1])
+			i += 2
+		case c == '`':
+			n := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			end := -1
+			for j := i + n; j < len(s); {
+				if s[j] != '`' {
+					j++
+					continue
+				}
+				m := len(s[j:]) - len(strings.TrimLeft(s[j:], "`"))
+				if m == n {
+					end = j
+					break
+				}
+				j += m
+			}
+			if end < 0 {
+				text.WriteString(s[i : i+n])
+				i += n
+				continue
+			}
+			code := strings.ReplaceAll(s[i+n:end], "\n", " ")
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			flush()
+			toks = append(toks, mdToken{mdRun: mdRun{text: code, code: true}})
+			i = end + n
+		case c == '*' || c == '_' || c == '~':
+			n := len(s[i:]) - len(strings.TrimLeft(s[i:], string(c)))
+			prev, next := ' ', ' '
+			if i > 0 {
+				prev, _ = utf8.DecodeLastRuneInString(s[:i])
+			}
+			if i+n < len(s) {
+				next, _ = utf8.DecodeRuneInString(s[i+n:])
+			}
+			left := !unicode.IsSpace(next) && (!mdPunct(next) || unicode.IsSpace(prev) || mdPunct(prev))
+			right := !unicode.IsSpace(prev) && (!mdPunct(prev) || unicode.IsSpace(next) || mdPunct(next))
+			tok := mdToken{delim: c, count: n, open: left, close: right}
+			if c == '_' {
+				tok.open = left && (!right || mdPunct(prev))
+				tok.close = right && (!left || mdPunct(next))
+			}
+			flush()
+			toks = append(toks, tok)
+			i += n
+		case c == '[' || (c == '!' && i+1 < len(s) && s[i+1] == '['):
+			label, dest, end, ok := md.link(s, i)
+			if !ok {
+				text.WriteByte(c)
+				i++
+				continue
+			}
+			flush()
+			if c == '!' {
+				toks = append(toks, mdToken{mdRun: mdRun{text: mdPlain(md.inline(label)), image: dest}})
+			} else {
+				for _, run := range md.inline(label) {
+					if run.link == "" {
+						run.link = dest
+					}
+					toks = append(toks, mdToken{mdRun: run})
+				}
+			}
+			i = end
+		case c == '<' && (mdAutoRe.MatchString(s[i:]) || mdEmailRe.MatchString(s[i:])):
+			flush()
+			if m := mdAutoRe.FindStringSubmatch(s[i:]); m != nil {
+				toks = append(toks, mdToken{mdRun: mdRun{text: m[1], link: m[1]}})
+				i += len(m[0])
+			} else {
+				m = mdEmailRe.FindStringSubmatch(s[i:])
+				toks = append(toks, mdToken{mdRun: mdRun{text: m[1], link: "mailto:" + m[1]}})
+				i += len(m[0])
+			}
+		case c == '&' && mdEntityRe.MatchString(s[i:]):
+			m := mdEntityRe.FindString(s[i:])
+			text.WriteString(html.UnescapeString(m))
+			i += len(m)
+		case c == '\n':
+			str := text.String()
+			trimmed := strings.TrimRight(str, " ")
+			text.Reset()
+			text.WriteString(trimmed)
+			if len(str)-len(trimmed) >= 2 {
+				flush()
+				toks = append(toks, mdToken{mdRun: mdRun{br: true}})
+			} else {
+				text.WriteByte(' ')
+			}
+			for i++; i < len(s) && s[i] == ' '; i++ {
+			}
+		case (c == 'h' || c == 'w') && (i == 0 || strings.ContainsRune(" \n(*_~", rune(s[i-1]))) && mdBareRe.MatchString(s[i:]):
+			url := mdBareRe.FindString(s[i:])
+			url = strings.TrimRight(url, ".,:;!?\"'*_~")
+			for strings.HasSuffix(url, ")") && strings.Count(url, "(") < strings.Count(url, ")") {
+				url = url[:len(url)-1]
+			}
+			dest := url
+			if strings.HasPrefix(dest, "www.") {
+				dest = "http://" + dest
+			}
+			flush()
+			toks = append(toks, mdToken{mdRun: mdRun{text: url, link: dest}})
+			i += len(url)
+		default:
+			text.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+	mdEmphasis(toks)
+	for _, tok := range toks {
+		if tok.delim != 0 {
+			if tok.count == 0 {
+				continue
+			}
+			tok.text = strings.Repeat(string(tok.delim), tok.count)
+		}
+		if n := len(runs); n > 0 && tok.mdRun.image == "" && !tok.br && !runs[n-1].br && runs[n-1].image == "" {
+			prev := runs[n-1]
+			prev.text = tok.text
+			if prev == tok.mdRun {
+				runs[n-1].text += tok.text
+				continue
+			}
+		}
+		runs = append(runs, tok.mdRun)
+	}
+	return
+}
+
+// mdEmphasis matches the emphasis delimiter runs of toks and applies the
+// emphasis to the tokens between them
+func mdEmphasis(toks []mdToken) {
+	for c := range toks {
+		closer := &toks[c]
+		if closer.delim == 0 || !closer.close {
+			continue
+		}
+		for closer.count > 0 {
+			o := -1
+			for j := c - 1; j >= 0; j-- {
+				t := toks[j]
+				if t.delim != closer.delim || !t.open || t.count == 0 {
+					continue
+				}
+				if closer.delim == '~' && t.count != closer.count {
+					continue
+				}
+				// the sum of the lengths of runs that can both open and close
+				// emphasis must not be a multiple of 3
+				if (t.close || closer.open) && (t.count+closer.count)%3 == 0 && (t.count%3 != 0 || closer.count%3 != 0) {
+					continue
+				}
+				o = j
+				break
+			}
+			if o < 0 {
+				break
+			}
+			n := 1
+			if toks[o].count >= 2 && closer.count >= 2 {
+				n = 2
+			}
+			if closer.delim == '~' {
+				n = closer.count
+			}
+			for j := o + 1; j < c; j++ {
+				switch {
+				case closer.delim == '~':
+					toks[j].strike = true
+				case n == 2:
+					toks[j].bold = true
+				default:
+					toks[j].italic = true
+				}
+				// delimiters between matched runs cannot be matched anymore
+				toks[j].open, toks[j].close = false, false
+			}
+			toks[o].count -= n
+			closer.count -= n
+		}
+	}
+}
+
+// link parses the link or image starting at s[i]. It returns the link text,
+// its destination and the index following the link.
+func (md *MarkdownType) link(s string, i int) (label, dest string, end int, ok bool) {
+	if s[i] == '!' {
+		i++
+	}
+	// find the matching bracket
+	depth, j := 0, i
+	for ; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+			continue
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if j >= len(s) {
+		return
+	}
+	label = s[i+1 : j]
+	end = j + 1
+	if end < len(s) && s[end] == '(' {
+		k := end + 1
+		for k < len(s) && (s[k] == ' ' || s[k] == '\n') {
+			k++
+		}
+		if k < len(s) && s[k] == '<' {
+			e := strings.IndexByte(s[k:], '>')
+			if e < 0 {
+				return
+			}
+			dest = s[k+1 : k+e]
+			k += e + 1
+		} else {
+			start, parens := k, 0
+			for ; k < len(s) && s[k] != ' ' && s[k] != '\n'; k++ {
+				if s[k] == '(' {
+					parens++
+				} else if s[k] == ')' {
+					if parens == 0 {
+						break
+					}
+					parens--
+				}
+			}
+			dest = s[start:k]
+		}
+		for k < len(s) && (s[k] == ' ' || s[k] == '\n') {
+			k++
+		}
+		if k < len(s) && strings.IndexByte("\"'(", s[k]) >= 0 {
+			closing := map[byte]byte{'"': '"', '\'': '\'', '(': ')'}[s[k]]
+			e := strings.IndexByte(s[k+1:], closing)
+			if e < 0 {
+				return
+			}
+			k += e + 2
+			for k < len(s) && (s[k] == ' ' || s[k] == '\n') {
+				k++
+			}
+		}
+		if k >= len(s) || s[k] != ')' {
+			return
+		}
+		return label, mdUnescape(dest), k + 1, true
+	}
+	ref := label
+	if end+1 < len(s) && s[end] == '[' {
+		if e := strings.IndexByte(s[end:], ']'); e > 0 {
+			if e > 1 {
+				ref = s[end+1 : end+e]
+			}
+			end += e + 1
+		}
+	}
+	dest, ok = md.refs[mdLabel(ref)]
+	return label, mdUnescape(dest), end, ok
+}
+
+// mdUnescape removes the backslash escapes and decodes the entities of a link
+// destination
+func mdUnescape(s string) string {
+	var b strings.Builder
+	for j := 0; j < len(s); j++ {
+		if s[j] == '\\' && j+1 < len(s) && s[j+1] < utf8.RuneSelf && mdPunct(rune(s[j+1])) {
+			j++
+		}
+		b.WriteByte(s[j])
+	}
+	return html.UnescapeString(b.String())
+}
+
+// mdPlain returns the text of runs
+func mdPlain(runs []mdRun) string {
+	var b strings.Builder
+	for _, run := range runs {
+		if run.br {
+			b.WriteByte(' ')
+		}
+		b.WriteString(run.text)
+	}
+	return b.String()
+}
+
+// encode translates str for the current font
+func (md *MarkdownType) encode(str string) string {
+	f := md.pdf
+	if f.isCurrentUTF8 {
+		return str
+	}
+	if md.tr == nil {
+		md.tr = f.UnicodeTranslatorFromDescriptor("")
+	}
+	return md.tr(str)
+}
+
+// startBlock applies the pending vertical space and returns to the left
+// margin, where every Markdown block starts. Unlike the HTML renderer, which
+// positions each line within the extent of its block, Markdown indents
+// quotes and list items by moving the left margin.
+func (md *MarkdownType) startBlock() {
+	f := md.pdf
+	md.useSpace(f)
+	f.x = f.lMargin
+}
+
+// setFont selects the font and the color of run
+func (md *MarkdownType) setFont(run mdRun, sizePt float64) {
+	f := md.pdf
+	styleStr := ""
+	if run.bold {
+		styleStr += "B"
+	}
+	if run.italic {
+		styleStr += "I"
+	}
+	if run.strike {
+		styleStr += "S"
+	}
+	family := md.family
+	if run.code {
+		family = md.MonoFamily
+	}
+	clr := md.clr
+	if run.link != "" {
+		styleStr += "U"
+		clr = md.ClrLink
+	}
+	f.SetFont(family, styleStr, sizePt)
+	f.SetTextColor(clr.R, clr.G, clr.B)
+}
+
+// blocks prints blocks; paragraphs of tight lists are not followed by space
+func (md *MarkdownType) blocks(blocks []*mdBlock, tight bool) {
+	f := md.pdf
+	for _, blk := range blocks {
+		if f.err != nil {
+			return
+		}
+		switch blk.kind {
+		case mdParagraph:
+			md.startBlock()
+			md.runs(md.inline(blk.text), md.lineHt, md.sizePt, false)
+			if !tight {
+				md.addSpace(md.lineHt / 2)
+			}
+		case mdHeading:
+			md.heading(blk)
+		case mdCode:
+			md.code(blk.text)
+		case mdQuote:
+			md.quote(blk)
+		case mdList:
+			md.list(blk)
+		case mdRule:
+			md.startBlock()
+			md.breakIfNeeded(f, md.lineHt)
+			y := f.y + md.lineHt/2
+			f.SetDrawColor(md.ClrQuoteBar.R, md.ClrQuoteBar.G, md.ClrQuoteBar.B)
+			f.SetLineWidth(f.PointConvert(1))
+			f.Line(f.lMargin, y, f.w-f.rMargin, y)
+			f.SetY(f.y + md.lineHt)
+		case mdTable:
+			md.table(blk)
+		}
+	}
+}
+
+// runs writes inline content with the line height ht and the font size
+// sizePt, and moves the position to the next line
+func (md *MarkdownType) runs(runs []mdRun, ht, sizePt float64, bold bool) {
+	f := md.pdf
+	for _, run := range runs {
+		run.bold = run.bold || bold
+		switch {
+		case run.br:
+			f.Ln(ht)
+		case run.image != "":
+			if f.x > f.lMargin {
+				f.Ln(ht)
+			}
+			md.image(run)
+		default:
+			md.setFont(run, sizePt)
+			if run.link != "" {
+				f.WriteLinkString(ht, md.encode(run.text), run.link)
+			} else {
+				f.Write(ht, md.encode(run.text))
+			}
+		}
+	}
+	if f.x > f.lMargin {
+		f.Ln(ht)
+	}
+}
+
+// image places the image of run on its own line
+func (md *MarkdownType) image(run mdRun) {
+	f := md.pdf
+	info := f.GetImageInfo(run.image)
+	if info == nil {
+		info = f.RegisterImage(run.image, "")
+	}
+	if f.err != nil {
+		return
+	}
+	wd, ht := info.Extent()
+	if maxWd := f.w - f.rMargin - f.lMargin; wd > maxWd {
+		ht = ht * maxWd / wd
+		wd = maxWd
+	}
+	md.breakIfNeeded(f, ht)
+	f.ImageOptions(run.image, f.lMargin, f.y, wd, ht, false, ImageOptions{}, 0, run.link)
+	f.SetY(f.y + ht)
+}
+
+// mdHeadingScale holds the font size of headings relative to the base font
+var mdHeadingScale = [...]float64{2, 1.6, 1.3, 1.15, 1, 0.9}
+
+// heading prints a heading and adds it to the outline
+func (md *MarkdownType) heading(blk *mdBlock) {
+	f := md.pdf
+	scale := mdHeadingScale[blk.level-1]
+	ht := md.lineHt * scale
+	md.addSpace(ht * 0.6)
+	md.startBlock()
+	// keep the heading with the following line
+	md.breakIfNeeded(f, ht+md.lineHt)
+	runs := md.inline(blk.text)
+	if md.Outline {
+		level := blk.level - 1
+		if !md.started {
+			level = 0
+		} else if level > md.level+1 {
+			level = md.level + 1
+		}
+		md.started, md.level = true, level
+		f.SetFont(md.family, "B", md.sizePt*scale)
+		f.Bookmark(md.encode(mdPlain(runs)), level, -1)
+	}
+	md.runs(runs, ht, md.sizePt*scale, true)
+	if blk.level <= 2 {
+		f.SetDrawColor(md.ClrQuoteBar.R, md.ClrQuoteBar.G, md.ClrQuoteBar.B)
+		f.SetLineWidth(f.PointConvert(0.5))
+		f.Line(f.lMargin, f.y+ht*0.1, f.w-f.rMargin, f.y+ht*0.1)
+		f.y += ht * 0.2
+	}
+	md.addSpace(md.lineHt * 0.4)
+}
+
+// code prints a code block on a shaded background
+func (md *MarkdownType) code(text string) {
+	f := md.pdf
+	md.startBlock()
+	f.SetFont(md.MonoFamily, "", md.sizePt*0.9)
+	f.SetTextColor(md.clr.R, md.clr.G, md.clr.B)
+	f.SetFillColor(md.ClrCode.R, md.ClrCode.G, md.ClrCode.B)
+	pad := md.lineHt / 4
+	wd := f.w - f.rMargin - f.lMargin
+	ht := md.lineHt * 0.9
+	md.breakIfNeeded(f, ht+2*pad)
+	f.CellFormat(wd, pad, "", "", 1, "", true, 0, "")
+	f.cMargin = pad
+	for _, line := range strings.Split(text, "\n") {
+		// Long lines are wrapped
+		for {
+			n := 0
+			for j, r := range line {
+				end := j + utf8.RuneLen(r)
+				if j > 0 && f.GetStringWidth(md.encode(line[:end])) > wd-2*pad {
+					break
+				}
+				n = end
+			}
+			f.CellFormat(wd, ht, md.encode(line[:n]), "", 1, "L", true, 0, "")
+			line = line[n:]
+			if line == "" {
+				break
+			}
+		}
+	}
+	f.cMargin = 0
+	f.CellFormat(wd, pad, "", "", 1, "", true, 0, "")
+	md.addSpace(md.lineHt / 2)
+}
+
+// quote prints the blocks of a block quote, indented and with a bar on its
+// left side
+func (md *MarkdownType) quote(blk *mdBlock) {
+	f := md.pdf
+	md.startBlock()
+	left := f.lMargin
+	page, y := f.page, f.y
+	clr := md.clr
+	md.clr = md.ClrQuoteText
+	f.SetLeftMargin(left + md.lineHt)
+	md.blocks(blk.children, false)
+	f.SetLeftMargin(left)
+	md.clr = clr
+	// Draw the bar on each page spanned by the quote
+	last, bottom := f.page, f.y
+	f.SetFillColor(md.ClrQuoteBar.R, md.ClrQuoteBar.G, md.ClrQuoteBar.B)
+	for p := page; p <= last; p++ {
+		f.SetPage(p)
+		y1 := f.pageBreakTrigger
+		if p == last {
+			y1 = bottom
+		}
+		f.Rect(left, y, f.PointConvert(3), y1-y, "F")
+		y = f.tMargin
+	}
+	f.x = f.lMargin
+}
+
+// mdBullets holds the list item markers of each nesting level
+var mdBullets = [...]string{"•", "–", "·"}
+
+// list prints the items of a list
+func (md *MarkdownType) list(blk *mdBlock) {
+	f := md.pdf
+	md.startBlock()
+	f.SetFont(md.family, "", md.sizePt)
+	markers := make([]string, len(blk.children))
+	indent := md.lineHt * 1.5
+	for j := range markers {
+		if blk.ordered {
+			markers[j] = strconv.Itoa(blk.start+j) + string(blk.marker)
+		} else {
+			markers[j] = mdBullets[min(md.depth, len(mdBullets)-1)]
+		}
+		indent = math.Max(indent, f.GetStringWidth(md.encode(markers[j]))+md.lineHt/2)
+	}
+	md.depth++
+	for j, item := range blk.children {
+		md.startBlock()
+		// keep the marker with the first line of the item
+		md.breakIfNeeded(f, md.lineHt)
+		left, y := f.lMargin, f.y
+		f.SetFont(md.family, "", md.sizePt)
+		f.SetTextColor(md.clr.R, md.clr.G, md.clr.B)
+		if item.task > 0 {
+			sz := f.fontSize * 0.7
+			x, top := left+indent-md.lineHt/2-sz, y+(md.lineHt-sz)/2
+			f.SetDrawColor(md.clr.R, md.clr.G, md.clr.B)
+			f.SetLineWidth(f.PointConvert(0.6))
+			f.Rect(x, top, sz, sz, "D")
+			if item.task == 2 {
+				f.Line(x+sz*0.2, top+sz*0.5, x+sz*0.4, top+sz*0.75)
+				f.Line(x+sz*0.4, top+sz*0.75, x+sz*0.8, top+sz*0.25)
+			}
+		} else {
+			txt := md.encode(markers[j])
+			wd := f.GetStringWidth(txt)
+			f.SetXY(left+indent-md.lineHt/2-wd, y)
+			f.CellFormat(wd, md.lineHt, txt, "", 0, "L", false, 0, "")
+		}
+		f.SetLeftMargin(left + indent)
+		f.SetXY(left+indent, y)
+		if len(item.children) == 0 {
+			f.y += md.lineHt
+		}
+		md.blocks(item.children, blk.tight)
+		f.SetLeftMargin(left)
+		if !blk.tight {
+			md.addSpace(md.lineHt / 2)
+		}
+	}
+	md.depth--
+	md.addSpace(md.lineHt / 2)
+}
+
+// table prints a table with TableNew()
+func (md *MarkdownType) table(blk *mdBlock) {
+	f := md.pdf
+	md.startBlock()
+	f.SetFont(md.family, "", md.sizePt)
+	f.SetTextColor(md.clr.R, md.clr.G, md.clr.B)
+	cols := make([]TableColumnType, len(blk.align))
+	for j, alignStr := range blk.align {
+		cols[j].AlignStr = alignStr
+	}
+	tbl := f.TableNew(cols...)
+	tbl.LineHt = md.lineHt
+	for j, row := range blk.rows {
+		cells := make([]TableCellType, len(row))
+		for k, txt := range row {
+			cells[k].Text = md.encode(mdPlain(md.inline(txt)))
+		}
+		if j == 0 {
+			tbl.AddHeader(cells...)
+		} else {
+			tbl.AddRow(cells...)
+		}
+	}
+	tbl.Output()
+	md.addSpace(md.lineHt / 2)
+}
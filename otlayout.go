@@ -0,0 +1,884 @@
+package docpdf
+
+import "sort"
+
+// otData gives bounds-checked big-endian access to an OpenType table. Reads
+// outside of the table return zero so that a damaged font degrades to
+// unshaped text instead of a panic.
+type otData []byte
+
+func (d otData) u16(off int) int {
+	if off < 0 || off+2 > len(d) {
+		return 0
+	}
+	return int(d[off])<<8 | int(d[off+1])
+}
+
+func (d otData) s16(off int) int {
+	return int(int16(d.u16(off)))
+}
+
+func (d otData) u32(off int) int {
+	return d.u16(off)<<16 | d.u16(off+2)
+}
+
+func (d otData) tag(off int) string {
+	if off < 0 || off+4 > len(d) {
+		return ""
+	}
+	return string(d[off : off+4])
+}
+
+// coverage returns the coverage index of gid in the coverage table at off,
+// or -1 if the glyph is not covered.
+func (d otData) coverage(off, gid int) int {
+	switch d.u16(off) {
+	case 1:
+		n := d.u16(off + 2)
+		j := sort.Search(n, func(j int) bool { return d.u16(off+4+2*j) >= gid })
+		if j < n && d.u16(off+4+2*j) == gid {
+			return j
+		}
+	case 2:
+		n := d.u16(off + 2)
+		j := sort.Search(n, func(j int) bool { return d.u16(off+4+6*j+2) >= gid })
+		if j < n {
+			rec := off + 4 + 6*j
+			if start := d.u16(rec); gid >= start {
+				return d.u16(rec+4) + gid - start
+			}
+		}
+	}
+	return -1
+}
+
+// classDef returns the class of gid in the class definition table at off.
+func (d otData) classDef(off, gid int) int {
+	if off == 0 {
+		return 0
+	}
+	switch d.u16(off) {
+	case 1:
+		start := d.u16(off + 2)
+		if gid >= start && gid < start+d.u16(off+4) {
+			return d.u16(off + 6 + 2*(gid-start))
+		}
+	case 2:
+		n := d.u16(off + 2)
+		j := sort.Search(n, func(j int) bool { return d.u16(off+4+6*j+2) >= gid })
+		if j < n {
+			rec := off + 4 + 6*j
+			if gid >= d.u16(rec) {
+				return d.u16(rec + 4)
+			}
+		}
+	}
+	return 0
+}
+
+// Glyph classes of the GDEF table
+const (
+	otClassBase = 1 + iota
+	otClassLigature
+	otClassMark
+	otClassComponent
+)
+
+// Lookup flags
+const (
+	otIgnoreBase      = 0x02
+	otIgnoreLigatures = 0x04
+	otIgnoreMarks     = 0x08
+	otUseMarkFilter   = 0x10
+)
+
+type otGlyph struct {
+	gid      int
+	cluster  int    // index of the first rune of the cluster in the shaped text
+	mask     uint32 // features that apply to the glyph
+	class    int    // GDEF glyph class
+	cat      byte   // category assigned by the script shaper
+	syllable int    // syllable number for Indic scripts
+	ipos     byte   // position in the syllable for Indic scripts
+	ligID    int    // identifies the glyphs of a ligature and its marks
+	ligComp  int    // ligature component a mark belongs to, 1-based
+	ligComps int    // number of components of a ligature glyph
+	xAdv     int    // advance, in font units
+	xOff     int    // placement, in font units
+	yOff     int
+	attach   int // index of the glyph a mark is attached to, or -1
+	attachDx int // anchor offset relative to the attachment glyph
+	attachDy int
+	zwj      bool // default ignorable, not rendered
+}
+
+type otLookup struct {
+	typ        int
+	flag       int
+	markFilter int
+	subtables  []int
+}
+
+// otLayout holds a parsed GSUB or GPOS table.
+type otLayout struct {
+	data        otData
+	gpos        bool
+	scriptList  int
+	featureList int
+	lookups     []otLookup
+}
+
+func parseOTLayout(data []byte, gpos bool) *otLayout {
+	d := otData(data)
+	if len(d) < 10 {
+		return nil
+	}
+	t := &otLayout{data: d, gpos: gpos, scriptList: d.u16(4), featureList: d.u16(6)}
+	ll := d.u16(8)
+	extType := 7
+	if gpos {
+		extType = 9
+	}
+	n := d.u16(ll)
+	t.lookups = make([]otLookup, n)
+	for j := 0; j < n; j++ {
+		lo := ll + d.u16(ll+2+2*j)
+		lk := otLookup{typ: d.u16(lo), flag: d.u16(lo + 2)}
+		ns := d.u16(lo + 4)
+		for k := 0; k < ns; k++ {
+			st := lo + d.u16(lo+6+2*k)
+			if lk.typ == extType {
+				// Extension: the actual subtable is referenced with a 32-bit offset
+				lk.typ = d.u16(st + 2)
+				st += d.u32(st + 4)
+			}
+			lk.subtables = append(lk.subtables, st)
+		}
+		if lk.flag&otUseMarkFilter != 0 {
+			lk.markFilter = d.u16(lo + 6 + 2*ns)
+		}
+		t.lookups[j] = lk
+	}
+	return t
+}
+
+// hasScript reports whether the table has an entry for the script tag.
+func (t *otLayout) hasScript(script string) bool {
+	return t != nil && t.langSys(script) != 0
+}
+
+// langSys returns the offset of the default language system of script.
+func (t *otLayout) langSys(script string) int {
+	d := t.data
+	sl := t.scriptList
+	for j := 0; j < d.u16(sl); j++ {
+		rec := sl + 2 + 6*j
+		if d.tag(rec) == script {
+			so := sl + d.u16(rec+4)
+			if def := d.u16(so); def != 0 {
+				return so + def
+			}
+			if d.u16(so+2) > 0 {
+				return so + d.u16(so+4+4)
+			}
+		}
+	}
+	return 0
+}
+
+// featureLookups returns the indices of the lookups of feature in script.
+func (t *otLayout) featureLookups(script, feature string) []int {
+	if t == nil {
+		return nil
+	}
+	ls := t.langSys(script)
+	if ls == 0 {
+		return nil
+	}
+	d := t.data
+	fl := t.featureList
+	var res []int
+	indices := []int{}
+	if req := d.u16(ls + 2); req != 0xFFFF {
+		indices = append(indices, req)
+	}
+	for j := 0; j < d.u16(ls+4); j++ {
+		indices = append(indices, d.u16(ls+6+2*j))
+	}
+	for _, fi := range indices {
+		rec := fl + 2 + 6*fi
+		if d.tag(rec) != feature {
+			continue
+		}
+		fo := fl + d.u16(rec+4)
+		for k := 0; k < d.u16(fo+2); k++ {
+			res = append(res, d.u16(fo+4+2*k))
+		}
+	}
+	return res
+}
+
+// otGDEF holds the glyph classes of the GDEF table.
+type otGDEF struct {
+	data        otData
+	glyphClass  int
+	markClass   int
+	markFilters int
+}
+
+func parseGDEF(data []byte) *otGDEF {
+	d := otData(data)
+	if len(d) < 12 {
+		return nil
+	}
+	g := &otGDEF{data: d, glyphClass: d.u16(4), markClass: d.u16(10)}
+	if d.u16(0) == 1 && d.u16(2) >= 2 && len(d) >= 14 {
+		g.markFilters = d.u16(12)
+	}
+	return g
+}
+
+// otFeatureStage is a group of features whose lookups are applied together
+// in lookup order. mask is the bit tested on the glyphs for each feature.
+type otFeatureStage struct {
+	features []string
+	masks    []uint32
+}
+
+// otApplier applies the lookups of a layout table to a glyph buffer.
+type otApplier struct {
+	t      *otLayout
+	gdef   *otGDEF
+	buf    []otGlyph
+	lookup *otLookup
+	mask   uint32
+	// nextLigID numbers ligatures for mark attachment
+	nextLigID *int
+}
+
+// applyStages applies the lookups of the features of each stage, for
+// script, to buf and returns the resulting buffer.
+func (t *otLayout) applyStages(gdef *otGDEF, script string, stages []otFeatureStage, buf []otGlyph, ligID *int) []otGlyph {
+	if t == nil {
+		return buf
+	}
+	a := &otApplier{t: t, gdef: gdef, buf: buf, nextLigID: ligID}
+	for _, st := range stages {
+		masks := map[int]uint32{}
+		for j, feat := range st.features {
+			for _, li := range t.featureLookups(script, feat) {
+				masks[li] |= st.masks[j]
+			}
+		}
+		idx := make([]int, 0, len(masks))
+		for li := range masks {
+			idx = append(idx, li)
+		}
+		sort.Ints(idx)
+		for _, li := range idx {
+			if li >= len(t.lookups) {
+				continue
+			}
+			a.lookup = &t.lookups[li]
+			a.mask = masks[li]
+			a.applyLookup()
+		}
+	}
+	return a.buf
+}
+
+func (a *otApplier) applyLookup() {
+	for i := 0; i < len(a.buf); {
+		g := &a.buf[i]
+		if g.mask&a.mask == 0 || a.ignored(i, a.lookup.flag) {
+			i++
+			continue
+		}
+		if n, ok := a.applyAt(a.lookup, i); ok {
+			i += n
+		} else {
+			i++
+		}
+	}
+}
+
+// ignored reports whether the glyph at i is skipped by a lookup with flag.
+func (a *otApplier) ignored(i, flag int) bool {
+	g := &a.buf[i]
+	switch g.class {
+	case otClassBase:
+		return flag&otIgnoreBase != 0
+	case otClassLigature:
+		return flag&otIgnoreLigatures != 0
+	case otClassMark:
+		if flag&otIgnoreMarks != 0 {
+			return true
+		}
+		if a.gdef != nil {
+			if flag&otUseMarkFilter != 0 && a.gdef.markFilters != 0 {
+				d := a.gdef.data
+				mf := a.gdef.markFilters
+				if a.lookup.markFilter < d.u16(mf+2) {
+					return d.coverage(mf+d.u32(mf+4+4*a.lookup.markFilter), g.gid) < 0
+				}
+			}
+			if mat := flag >> 8; mat != 0 {
+				return a.gdef.data.classDef(a.gdef.markClass, g.gid) != mat
+			}
+		}
+	}
+	return false
+}
+
+// next returns the index of the next glyph after i not ignored by flag, or
+// -1.
+func (a *otApplier) next(i, flag int) int {
+	for i++; i < len(a.buf); i++ {
+		if !a.ignored(i, flag) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *otApplier) prev(i, flag int) int {
+	for i--; i >= 0; i-- {
+		if !a.ignored(i, flag) {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyAt applies the subtables of lk at glyph i. It returns the number of
+// glyphs to advance and whether a subtable applied.
+func (a *otApplier) applyAt(lk *otLookup, i int) (int, bool) {
+	for _, st := range lk.subtables {
+		var n int
+		var ok bool
+		if a.t.gpos {
+			n, ok = a.applyGPOS(lk, st, i)
+		} else {
+			n, ok = a.applyGSUB(lk, st, i)
+		}
+		if ok {
+			return n, true
+		}
+	}
+	return 1, false
+}
+
+func (a *otApplier) setGlyph(i, gid int) {
+	a.buf[i].gid = gid
+	a.buf[i].class = a.glyphClass(gid, a.buf[i].class)
+}
+
+// glyphClass returns the GDEF class of gid, or def if the font has no class
+// definition.
+func (a *otApplier) glyphClass(gid, def int) int {
+	if a.gdef != nil && a.gdef.glyphClass != 0 {
+		return a.gdef.data.classDef(a.gdef.glyphClass, gid)
+	}
+	return def
+}
+
+func (a *otApplier) applyGSUB(lk *otLookup, st, i int) (int, bool) {
+	d := a.t.data
+	g := &a.buf[i]
+	switch lk.typ {
+	case 1: // single
+		ci := d.coverage(st+d.u16(st+2), g.gid)
+		if ci < 0 {
+			return 0, false
+		}
+		if d.u16(st) == 1 {
+			a.setGlyph(i, (g.gid+d.s16(st+4))&0xFFFF)
+		} else if ci < d.u16(st+4) {
+			a.setGlyph(i, d.u16(st+6+2*ci))
+		} else {
+			return 0, false
+		}
+		return 1, true
+	case 2: // multiple
+		ci := d.coverage(st+d.u16(st+2), g.gid)
+		if ci < 0 || ci >= d.u16(st+4) {
+			return 0, false
+		}
+		seq := st + d.u16(st+6+2*ci)
+		n := d.u16(seq)
+		if n == 0 {
+			a.buf = append(a.buf[:i], a.buf[i+1:]...)
+			return 0, true
+		}
+		gl := make([]otGlyph, n)
+		for k := range gl {
+			gl[k] = *g
+			gl[k].gid = d.u16(seq + 2 + 2*k)
+			gl[k].class = a.glyphClass(gl[k].gid, g.class)
+		}
+		a.buf = append(a.buf[:i], append(gl, a.buf[i+1:]...)...)
+		return n, true
+	case 3: // alternate, the first alternate is used
+		ci := d.coverage(st+d.u16(st+2), g.gid)
+		if ci < 0 || ci >= d.u16(st+4) {
+			return 0, false
+		}
+		set := st + d.u16(st+6+2*ci)
+		if d.u16(set) == 0 {
+			return 0, false
+		}
+		a.setGlyph(i, d.u16(set+2))
+		return 1, true
+	case 4: // ligature
+		ci := d.coverage(st+d.u16(st+2), g.gid)
+		if ci < 0 || ci >= d.u16(st+4) {
+			return 0, false
+		}
+		set := st + d.u16(st+6+2*ci)
+		for k := 0; k < d.u16(set); k++ {
+			lig := set + d.u16(set+2+2*k)
+			nc := d.u16(lig + 2)
+			pos := []int{i}
+			j := i
+			for c := 1; c < nc; c++ {
+				j = a.next(j, lk.flag)
+				if j < 0 || a.buf[j].gid != d.u16(lig+4+2*(c-1)) {
+					pos = nil
+					break
+				}
+				pos = append(pos, j)
+			}
+			if pos == nil {
+				continue
+			}
+			a.ligate(pos, d.u16(lig))
+			return 1, true
+		}
+		return 0, false
+	case 5:
+		return a.applyContext(lk, st, i)
+	case 6:
+		return a.applyChainContext(lk, st, i)
+	}
+	return 0, false
+}
+
+// ligate replaces the glyphs at pos by the ligature glyph lig. Marks skipped
+// between the components are kept after the ligature and remember the
+// component they follow.
+func (a *otApplier) ligate(pos []int, lig int) {
+	*a.nextLigID++
+	id := *a.nextLigID
+	first := pos[0]
+	cluster := a.buf[first].cluster
+	for _, p := range pos {
+		if a.buf[p].cluster < cluster {
+			cluster = a.buf[p].cluster
+		}
+	}
+	comps := 0
+	for _, p := range pos {
+		if a.buf[p].ligComps > 0 {
+			comps += a.buf[p].ligComps
+		} else {
+			comps++
+		}
+	}
+	comp := 1
+	for j := first + 1; j <= pos[len(pos)-1]; j++ {
+		if a.buf[j].class == otClassMark {
+			a.buf[j].ligID = id
+			a.buf[j].ligComp = comp
+		} else {
+			comp++
+		}
+	}
+	a.buf[first].cluster = cluster
+	a.buf[first].ligID = id
+	a.buf[first].ligComps = comps
+	a.setGlyph(first, lig)
+	if a.gdef == nil || a.gdef.glyphClass == 0 {
+		a.buf[first].class = otClassLigature
+	}
+	for k := len(pos) - 1; k > 0; k-- {
+		p := pos[k]
+		a.buf = append(a.buf[:p], a.buf[p+1:]...)
+	}
+}
+
+// otRule is a matched contextual rule: the positions of the input glyphs and
+// the lookup records to apply.
+type otRule struct {
+	pos     []int
+	records int // offset of the lookup records
+	count   int
+}
+
+// matchInput matches n-1 input glyphs after i with match, skipping glyphs
+// ignored by the lookup.
+func (a *otApplier) matchInput(i, n, flag int, match func(k, gid int) bool) []int {
+	pos := []int{i}
+	j := i
+	for k := 1; k < n; k++ {
+		j = a.next(j, flag)
+		if j < 0 || !match(k, a.buf[j].gid) {
+			return nil
+		}
+		pos = append(pos, j)
+	}
+	return pos
+}
+
+func (a *otApplier) matchBacktrack(i, n, flag int, match func(k, gid int) bool) bool {
+	j := i
+	for k := 0; k < n; k++ {
+		j = a.prev(j, flag)
+		if j < 0 || !match(k, a.buf[j].gid) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *otApplier) matchLookahead(i, n, flag int, match func(k, gid int) bool) bool {
+	j := i
+	for k := 0; k < n; k++ {
+		j = a.next(j, flag)
+		if j < 0 || !match(k, a.buf[j].gid) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *otApplier) applyContext(lk *otLookup, st, i int) (int, bool) {
+	d := a.t.data
+	gid := a.buf[i].gid
+	switch d.u16(st) {
+	case 1, 2:
+		cov := st + d.u16(st+2)
+		if d.coverage(cov, gid) < 0 {
+			return 0, false
+		}
+		var set int
+		var cls func(gid int) int
+		if d.u16(st) == 1 {
+			ci := d.coverage(cov, gid)
+			if ci >= d.u16(st+4) {
+				return 0, false
+			}
+			set = st + d.u16(st+6+2*ci)
+			cls = func(gid int) int { return gid }
+		} else {
+			cd := d.offset(st, st+4)
+			c := d.classDef(cd, gid)
+			if c >= d.u16(st+6) || d.u16(st+8+2*c) == 0 {
+				return 0, false
+			}
+			set = st + d.u16(st+8+2*c)
+			cls = func(gid int) int { return d.classDef(cd, gid) }
+		}
+		for k := 0; k < d.u16(set); k++ {
+			r := set + d.u16(set+2+2*k)
+			n := d.u16(r)
+			pos := a.matchInput(i, n, lk.flag, func(k, gid int) bool { return cls(gid) == d.u16(r+4+2*(k-1)) })
+			if pos != nil {
+				return a.applyRecords(otRule{pos, r + 4 + 2*(n-1), d.u16(r + 2)}), true
+			}
+		}
+	case 3:
+		n := d.u16(st + 2)
+		if d.coverage(st+d.u16(st+6), gid) < 0 {
+			return 0, false
+		}
+		pos := a.matchInput(i, n, lk.flag, func(k, gid int) bool { return d.coverage(st+d.u16(st+6+2*k), gid) >= 0 })
+		if pos != nil {
+			return a.applyRecords(otRule{pos, st + 6 + 2*n, d.u16(st + 4)}), true
+		}
+	}
+	return 0, false
+}
+
+func (a *otApplier) applyChainContext(lk *otLookup, st, i int) (int, bool) {
+	d := a.t.data
+	gid := a.buf[i].gid
+	flag := lk.flag
+	switch d.u16(st) {
+	case 1, 2:
+		cov := st + d.u16(st+2)
+		if d.coverage(cov, gid) < 0 {
+			return 0, false
+		}
+		var set int
+		var bcls, icls, lcls func(gid int) int
+		if d.u16(st) == 1 {
+			ci := d.coverage(cov, gid)
+			if ci >= d.u16(st+4) {
+				return 0, false
+			}
+			set = st + d.u16(st+6+2*ci)
+			id := func(gid int) int { return gid }
+			bcls, icls, lcls = id, id, id
+		} else {
+			bd, id, ld := d.offset(st, st+4), d.offset(st, st+6), d.offset(st, st+8)
+			c := d.classDef(id, gid)
+			if c >= d.u16(st+10) || d.u16(st+12+2*c) == 0 {
+				return 0, false
+			}
+			set = st + d.u16(st+12+2*c)
+			bcls = func(gid int) int { return d.classDef(bd, gid) }
+			icls = func(gid int) int { return d.classDef(id, gid) }
+			lcls = func(gid int) int { return d.classDef(ld, gid) }
+		}
+		for k := 0; k < d.u16(set); k++ {
+			r := set + d.u16(set+2+2*k)
+			nb := d.u16(r)
+			in := r + 2 + 2*nb
+			ni := d.u16(in)
+			la := in + 2 + 2*(ni-1)
+			nl := d.u16(la)
+			pos := a.matchInput(i, ni, flag, func(k, gid int) bool { return icls(gid) == d.u16(in+2+2*(k-1)) })
+			if pos == nil {
+				continue
+			}
+			if !a.matchBacktrack(i, nb, flag, func(k, gid int) bool { return bcls(gid) == d.u16(r+2+2*k) }) {
+				continue
+			}
+			if !a.matchLookahead(pos[len(pos)-1], nl, flag, func(k, gid int) bool { return lcls(gid) == d.u16(la+2+2*k) }) {
+				continue
+			}
+			rec := la + 2 + 2*nl
+			return a.applyRecords(otRule{pos, rec + 2, d.u16(rec)}), true
+		}
+	case 3:
+		nb := d.u16(st + 2)
+		in := st + 4 + 2*nb
+		ni := d.u16(in)
+		la := in + 2 + 2*ni
+		nl := d.u16(la)
+		if ni == 0 || d.coverage(st+d.u16(in+2), gid) < 0 {
+			return 0, false
+		}
+		pos := a.matchInput(i, ni, flag, func(k, gid int) bool { return d.coverage(st+d.u16(in+2+2*k), gid) >= 0 })
+		if pos == nil {
+			return 0, false
+		}
+		if !a.matchBacktrack(i, nb, flag, func(k, gid int) bool { return d.coverage(st+d.u16(st+4+2*k), gid) >= 0 }) {
+			return 0, false
+		}
+		if !a.matchLookahead(pos[len(pos)-1], nl, flag, func(k, gid int) bool { return d.coverage(st+d.u16(la+2+2*k), gid) >= 0 }) {
+			return 0, false
+		}
+		rec := la + 2 + 2*nl
+		return a.applyRecords(otRule{pos, rec + 2, d.u16(rec)}), true
+	}
+	return 0, false
+}
+
+// applyRecords applies the nested lookups of a matched rule and returns the
+// number of glyphs to advance past the input sequence.
+func (a *otApplier) applyRecords(r otRule) int {
+	d := a.t.data
+	pos := r.pos
+	end := pos[len(pos)-1] + 1
+	saved, savedMask := a.lookup, a.mask
+	for k := 0; k < r.count; k++ {
+		seq := d.u16(r.records + 4*k)
+		li := d.u16(r.records + 4*k + 2)
+		if seq >= len(pos) || li >= len(a.t.lookups) {
+			continue
+		}
+		before := len(a.buf)
+		a.lookup = &a.t.lookups[li]
+		a.applyAt(a.lookup, pos[seq])
+		delta := len(a.buf) - before
+		if delta != 0 {
+			at := pos[seq]
+			for j := range pos {
+				if pos[j] > at {
+					pos[j] += delta
+				}
+			}
+			end += delta
+		}
+	}
+	a.lookup, a.mask = saved, savedMask
+	n := end - r.pos[0]
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// valueRecord reads a GPOS value record at off and returns its size.
+func (d otData) valueRecord(off, format int) (dx, dy, adv, size int) {
+	for bit := 0; bit < 8; bit++ {
+		if format&(1<<bit) == 0 {
+			continue
+		}
+		v := d.s16(off + size)
+		switch bit {
+		case 0:
+			dx = v
+		case 1:
+			dy = v
+		case 2:
+			adv = v
+		}
+		size += 2
+	}
+	return
+}
+
+// offset returns the offset relative to base read at off, or zero for a
+// null offset.
+func (d otData) offset(base, off int) int {
+	if v := d.u16(off); v != 0 {
+		return base + v
+	}
+	return 0
+}
+
+func (d otData) anchor(off int) (x, y int) {
+	return d.s16(off + 2), d.s16(off + 4)
+}
+
+func (a *otApplier) adjust(i, dx, dy, adv int) {
+	a.buf[i].xOff += dx
+	a.buf[i].yOff += dy
+	a.buf[i].xAdv += adv
+}
+
+func (a *otApplier) applyGPOS(lk *otLookup, st, i int) (int, bool) {
+	d := a.t.data
+	g := &a.buf[i]
+	switch lk.typ {
+	case 1: // single adjustment
+		ci := d.coverage(st+d.u16(st+2), g.gid)
+		if ci < 0 {
+			return 0, false
+		}
+		vf := d.u16(st + 4)
+		off := st + 6
+		if d.u16(st) == 2 {
+			_, _, _, size := d.valueRecord(0, vf)
+			off = st + 8 + ci*size
+		}
+		dx, dy, adv, _ := d.valueRecord(off, vf)
+		a.adjust(i, dx, dy, adv)
+		return 1, true
+	case 2: // pair adjustment
+		ci := d.coverage(st+d.u16(st+2), g.gid)
+		if ci < 0 {
+			return 0, false
+		}
+		j := a.next(i, lk.flag)
+		if j < 0 {
+			return 0, false
+		}
+		vf1, vf2 := d.u16(st+4), d.u16(st+6)
+		_, _, _, s1 := d.valueRecord(0, vf1)
+		_, _, _, s2 := d.valueRecord(0, vf2)
+		var rec int
+		if d.u16(st) == 1 {
+			if ci >= d.u16(st+8) {
+				return 0, false
+			}
+			set := st + d.u16(st+10+2*ci)
+			n := d.u16(set)
+			size := 2 + s1 + s2
+			k := sort.Search(n, func(k int) bool { return d.u16(set+2+k*size) >= a.buf[j].gid })
+			if k >= n || d.u16(set+2+k*size) != a.buf[j].gid {
+				return 0, false
+			}
+			rec = set + 2 + k*size + 2
+		} else {
+			c1 := d.classDef(st+d.u16(st+8), g.gid)
+			c2 := d.classDef(st+d.u16(st+10), a.buf[j].gid)
+			n1, n2 := d.u16(st+12), d.u16(st+14)
+			if c1 >= n1 || c2 >= n2 {
+				return 0, false
+			}
+			rec = st + 16 + (c1*n2+c2)*(s1+s2)
+		}
+		dx, dy, adv, _ := d.valueRecord(rec, vf1)
+		a.adjust(i, dx, dy, adv)
+		dx, dy, adv, _ = d.valueRecord(rec+s1, vf2)
+		a.adjust(j, dx, dy, adv)
+		if vf2 != 0 {
+			return j - i + 1, true
+		}
+		return j - i, true
+	case 4, 5, 6: // mark to base, ligature or mark
+		mi := d.coverage(st+d.u16(st+2), g.gid)
+		if mi < 0 {
+			return 0, false
+		}
+		classes := d.u16(st + 6)
+		markArray := st + d.u16(st+8)
+		baseArray := st + d.u16(st+10)
+		if mi >= d.u16(markArray) {
+			return 0, false
+		}
+		class := d.u16(markArray + 2 + 4*mi)
+		mx, my := d.anchor(markArray + d.u16(markArray+2+4*mi+2))
+		if class >= classes {
+			return 0, false
+		}
+		var j int
+		switch lk.typ {
+		case 6:
+			j = a.prev(i, lk.flag&^otIgnoreMarks)
+			if j < 0 || a.buf[j].class != otClassMark {
+				return 0, false
+			}
+			if a.buf[j].ligID != a.buf[i].ligID || a.buf[j].ligComp != a.buf[i].ligComp {
+				return 0, false
+			}
+		default:
+			// the preceding glyph that is not a mark
+			for j = i - 1; j >= 0 && a.buf[j].class == otClassMark; j-- {
+			}
+			if j < 0 {
+				return 0, false
+			}
+		}
+		bi := d.coverage(st+d.u16(st+4), a.buf[j].gid)
+		if bi < 0 || bi >= d.u16(baseArray) {
+			return 0, false
+		}
+		var ao int
+		if lk.typ == 5 {
+			la := baseArray + d.u16(baseArray+2+2*bi)
+			nc := d.u16(la)
+			if nc == 0 {
+				return 0, false
+			}
+			comp := nc
+			if a.buf[i].ligID == a.buf[j].ligID && a.buf[i].ligComp > 0 && a.buf[i].ligComp <= nc {
+				comp = a.buf[i].ligComp
+			}
+			rec := la + 2 + 2*classes*(comp-1) + 2*class
+			if d.u16(rec) == 0 {
+				return 0, false
+			}
+			ao = la + d.u16(rec)
+		} else {
+			rec := baseArray + 2 + 2*classes*bi + 2*class
+			if d.u16(rec) == 0 {
+				return 0, false
+			}
+			ao = baseArray + d.u16(rec)
+		}
+		bx, by := d.anchor(ao)
+		a.buf[i].attach = j
+		a.buf[i].attachDx = bx - mx
+		a.buf[i].attachDy = by - my
+		return 1, true
+	case 7:
+		return a.applyContext(lk, st, i)
+	case 8:
+		return a.applyChainContext(lk, st, i)
+	}
+	return 0, false
+}
@@ -0,0 +1,579 @@
+package docpdf
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// ParagraphLayout holds the settings of the paragraph layout set with
+// SetParagraphLayout().
+type ParagraphLayout struct {
+	// Language is the name of the hyphenation patterns added with
+	// AddHyphenationPatterns() used to hyphenate the words. If it is empty,
+	// words are only hyphenated at their soft hyphens (U+00AD).
+	Language string
+	// HyphenMinLeft and HyphenMinRight are the minimum numbers of characters
+	// kept before and after a hyphen added by the patterns, 2 and 3 if zero.
+	HyphenMinLeft, HyphenMinRight int
+	// Tolerance is the maximum badness of a line, measured as in TeX: 100
+	// when the spaces are stretched or shrunk by their full allowance. If no
+	// set of breaks respects it, lines as loose as needed are accepted. The
+	// tolerance is 200 if zero.
+	Tolerance float64
+	// Orphans is the minimum number of lines of a paragraph left at the
+	// bottom of a page and Widows the minimum number of lines of a paragraph
+	// carried over to the next page.
+	Orphans, Widows int
+}
+
+// Paragraph layout items and parameters of the Knuth-Plass algorithm
+const (
+	layoutBox = iota
+	layoutGlue
+	layoutPenalty
+
+	layoutInfinity        = 10000 // penalty forbidding or forcing a break
+	layoutFil             = 1e6   // stretch of the glue ending a paragraph
+	layoutLinePenalty     = 10
+	layoutHyphenPenalty   = 50
+	layoutFlaggedDemerits = 3000 // demerits of consecutive hyphenated lines
+	layoutFitnessDemerits = 3000 // demerits of adjacent lines of different tightness
+)
+
+// layoutItem is a box, glue or penalty item of a paragraph. Widths are in
+// thousandths of the font size. A box shows the characters from to to of
+// the paragraph.
+type layoutItem struct {
+	kind                   int
+	width, stretch, shrink float64
+	penalty                float64
+	flagged                bool
+	from, to               int
+}
+
+// layoutNode is a feasible break of the Knuth-Plass algorithm.
+type layoutNode struct {
+	pos, fitness           int
+	width, stretch, shrink float64 // totals after the break
+	demerits               float64
+	flagged                bool
+	prev                   *layoutNode
+}
+
+// layoutLine is a line of a paragraph laid out by layoutParagraph().
+type layoutLine struct {
+	text   []rune
+	width  int  // natural width, in thousandths of the font size
+	spaces int  // number of spaces of the line
+	last   bool // line ending a paragraph, which is not justified
+}
+
+// SetParagraphLayout sets the layout of the text output by MultiCell() and
+// split by SplitText(). By default, lines are filled one after the other
+// and only broken at spaces. With a paragraph layout, each paragraph is
+// broken into lines at once with the Knuth-Plass algorithm used by TeX,
+// which chooses the breaks giving the most even word spacing over the whole
+// paragraph, or the most even line lengths when the text is not justified.
+// Lines can be broken wherever the Unicode line breaking algorithm allows
+// it, such as between CJK ideographs, and words are hyphenated at their
+// soft hyphens and with the hyphenation patterns of the layout language.
+// MultiCell() also moves lines to the next page to respect the minimum
+// numbers of widow and orphan lines. Use a nil layout to restore the
+// default behavior.
+func (f *DocPDF) SetParagraphLayout(layout *ParagraphLayout) {
+	if f.err != nil {
+		return
+	}
+	if layout != nil && layout.Language != "" && f.hyphenators[layout.Language] == nil {
+		f.err = fmt.Errorf("hyphenation patterns %q have not been added", layout.Language)
+		return
+	}
+	f.paragraphLayout = layout
+}
+
+// layoutParagraph breaks the text of a paragraph, without line feeds, into
+// lines of at most wmax thousandths of the font size, or of about wmax if
+// the lines are justified.
+func (f *DocPDF) layoutParagraph(text []rune, wmax int, justify bool) []layoutLine {
+	layout := f.paragraphLayout
+	hyphenMin := func(v, def int) int {
+		if v <= 0 {
+			return def
+		}
+		return v
+	}
+	left, right := hyphenMin(layout.HyphenMinLeft, 2), hyphenMin(layout.HyphenMinRight, 3)
+	h := f.hyphenators[layout.Language]
+	toString := func(s []rune) string {
+		if f.isCurrentUTF8 {
+			return string(s)
+		}
+		b := make([]byte, len(s))
+		for j, r := range s {
+			b[j] = byte(r)
+		}
+		return string(b)
+	}
+	width := func(s []rune) float64 {
+		return float64(f.GetStringSymbolWidth(toString(s)))
+	}
+
+	// soft hyphens are removed and their positions kept
+	soft := make(map[int]bool)
+	var s []rune
+	for _, r := range text {
+		if r == 0xAD {
+			soft[len(s)] = true
+		} else {
+			s = append(s, r)
+		}
+	}
+	space := width([]rune{' '})
+	hyphen := width([]rune{'-'})
+	rag := 3 * space // stretch of the glue at the end of ragged lines
+
+	var items []layoutItem
+	// addBreak adds a break of width w, which is the width of the spaces
+	// removed by the break if spaces is true and the width of the hyphen
+	// added by the break otherwise
+	addBreak := func(w float64, spaces bool, penalty float64, flagged bool) {
+		if !justify {
+			// the glue stretches the line only if the break is taken
+			items = append(items, layoutItem{kind: layoutGlue, stretch: rag})
+			if spaces {
+				items = append(items, layoutItem{kind: layoutPenalty, penalty: penalty, flagged: flagged})
+				items = append(items, layoutItem{kind: layoutGlue, width: w, stretch: -rag})
+			} else {
+				items = append(items, layoutItem{kind: layoutPenalty, width: w, penalty: penalty, flagged: flagged})
+				items = append(items, layoutItem{kind: layoutGlue, stretch: -rag})
+			}
+			return
+		}
+		if spaces {
+			items = append(items, layoutItem{kind: layoutGlue, width: w, stretch: w / 2, shrink: w / 3})
+		} else {
+			items = append(items, layoutItem{kind: layoutPenalty, width: w, penalty: penalty, flagged: flagged})
+		}
+	}
+	addBox := func(from, to int) {
+		w := width(s[from:to])
+		if w <= float64(wmax) || to-from < 2 {
+			items = append(items, layoutItem{kind: layoutBox, width: w, from: from, to: to})
+			return
+		}
+		// a part of a word wider than the line is broken anywhere
+		for j := from; j < to; j++ {
+			if j > from {
+				addBreak(0, false, 0, false)
+			}
+			items = append(items, layoutItem{kind: layoutBox, width: width(s[j : j+1]), from: j, to: j + 1})
+		}
+	}
+	addWord := func(from, to int) {
+		var points []int
+		for j := from + 1; j < to; j++ {
+			if soft[j] {
+				points = append(points, j)
+			}
+		}
+		if len(points) == 0 && h != nil {
+			// hyphenation of the runs of letters
+			for j := from; j < to; {
+				k := j
+				for k < to && unicode.IsLetter(s[k]) {
+					k++
+				}
+				for _, p := range h.hyphenate(s[j:k], left, right) {
+					points = append(points, j+p)
+				}
+				j = k + 1
+			}
+		}
+		start := from
+		for _, p := range points {
+			addBox(start, p)
+			addBreak(hyphen, false, layoutHyphenPenalty, true)
+			start = p
+		}
+		addBox(start, to)
+	}
+
+	breaks := lineBreaks(s)
+	for from := 0; from < len(s); {
+		to := from + 1
+		for to < len(s) && breaks[to] == lineBreakNone {
+			to++
+		}
+		end := to
+		mandatory := to < len(s) && breaks[to] == lineBreakMandatory
+		if mandatory {
+			end-- // the line separator is not shown
+		}
+		for end > from && s[end-1] == ' ' {
+			end--
+		}
+		addWord(from, end)
+		switch {
+		case to == len(s):
+		case mandatory:
+			items = append(items, layoutItem{kind: layoutGlue, stretch: layoutFil})
+			items = append(items, layoutItem{kind: layoutPenalty, penalty: -layoutInfinity})
+		case end < to:
+			addBreak(float64(to-end)*space, true, 0, false)
+		case s[to-1] == '-' || s[to-1] == 0x2010:
+			addBreak(0, false, layoutHyphenPenalty, true)
+		default:
+			addBreak(0, false, 0, false)
+		}
+		from = to
+	}
+	items = append(items, layoutItem{kind: layoutGlue, stretch: layoutFil})
+	items = append(items, layoutItem{kind: layoutPenalty, penalty: -layoutInfinity})
+
+	tolerance := layout.Tolerance
+	if tolerance <= 0 {
+		tolerance = 200
+	}
+	positions := layoutBreaks(items, float64(wmax), tolerance, 0)
+	if positions == nil {
+		// lines too loose are ranked as if they could stretch by a quarter
+		// of the line width, like the TeX emergency stretch
+		positions = layoutBreaks(items, float64(wmax), math.Inf(1), float64(wmax)/4)
+	}
+	if positions == nil {
+		positions = layoutFirstFit(items, float64(wmax))
+	}
+
+	var lines []layoutLine
+	start := 0
+	for _, b := range positions {
+		for start < b && items[start].kind != layoutBox {
+			start++
+		}
+		line := layoutLine{last: items[b].kind == layoutPenalty && items[b].penalty <= -layoutInfinity}
+		var w float64
+		from, to := -1, -1
+		for j := start; j < b; j++ {
+			it := items[j]
+			if it.kind == layoutBox {
+				if from < 0 {
+					from = it.from
+				}
+				to = it.to
+				w += it.width
+			} else if it.kind == layoutGlue && to >= 0 {
+				w += it.width
+			}
+		}
+		if from >= 0 {
+			line.text = append(line.text, s[from:to]...)
+		}
+		// trailing glue is not part of the line
+		for j := b - 1; j >= start && items[j].kind != layoutBox; j-- {
+			if items[j].kind == layoutGlue {
+				w -= items[j].width
+			}
+		}
+		if it := items[b]; it.kind == layoutPenalty && it.width > 0 {
+			line.text = append(line.text, '-')
+			w += it.width
+		}
+		line.width = int(math.Round(w))
+		for _, r := range line.text {
+			if r == ' ' {
+				line.spaces++
+			}
+		}
+		lines = append(lines, line)
+		start = b + 1
+	}
+	return lines
+}
+
+// layoutBreaks returns the positions of the items ending the lines of the
+// optimal breaking of the paragraph items into lines of width lineWidth, or
+// nil if the lines cannot respect the tolerance. Each line can stretch by
+// emergency in addition to the stretch of its glue.
+func layoutBreaks(items []layoutItem, lineWidth, tolerance, emergency float64) []int {
+	var sumWidth, sumStretch, sumShrink float64
+	active := []*layoutNode{{pos: -1, fitness: 1}}
+	tryBreak := func(b int) {
+		it := items[b]
+		var best [4]*layoutNode
+		kept := active[:0]
+		for _, a := range active {
+			w := sumWidth - a.width
+			if it.kind == layoutPenalty {
+				w += it.width
+			}
+			r := 0.0
+			switch {
+			case w < lineWidth:
+				if stretch := sumStretch - a.stretch + emergency; stretch > 0 {
+					r = (lineWidth - w) / stretch
+				} else {
+					r = math.Inf(1)
+				}
+			case w > lineWidth:
+				if shrink := sumShrink - a.shrink; shrink > 0 {
+					r = (lineWidth - w) / shrink
+				} else {
+					r = math.Inf(-1)
+				}
+			}
+			forced := it.kind == layoutPenalty && it.penalty <= -layoutInfinity
+			if r >= -1 && !forced {
+				kept = append(kept, a)
+			}
+			badness := math.Min(100*math.Pow(math.Abs(r), 3), layoutInfinity)
+			if r < -1 || badness > tolerance {
+				continue
+			}
+			d := math.Pow(layoutLinePenalty+badness, 2)
+			switch {
+			case it.kind != layoutPenalty:
+			case it.penalty >= 0:
+				d += it.penalty * it.penalty
+			case !forced:
+				d -= it.penalty * it.penalty
+			}
+			if it.flagged && a.flagged {
+				d += layoutFlaggedDemerits
+			}
+			fitness := 3
+			switch {
+			case r < -0.5:
+				fitness = 0
+			case r <= 0.5:
+				fitness = 1
+			case r <= 1:
+				fitness = 2
+			}
+			if fitness-a.fitness > 1 || a.fitness-fitness > 1 {
+				d += layoutFitnessDemerits
+			}
+			d += a.demerits
+			if best[fitness] == nil || d < best[fitness].demerits {
+				best[fitness] = &layoutNode{pos: b, fitness: fitness, demerits: d, flagged: it.flagged, prev: a}
+			}
+		}
+		active = kept
+		// totals after the break, without the glue and penalties removed
+		// at the start of the next line
+		width, stretch, shrink := sumWidth, sumStretch, sumShrink
+		for j := b; j < len(items); j++ {
+			next := items[j]
+			if next.kind == layoutBox || (j > b && next.kind == layoutPenalty && next.penalty <= -layoutInfinity) {
+				break
+			}
+			if next.kind == layoutGlue {
+				width += next.width
+				stretch += next.stretch
+				shrink += next.shrink
+			}
+		}
+		for _, n := range best {
+			if n != nil {
+				n.width, n.stretch, n.shrink = width, stretch, shrink
+				active = append(active, n)
+			}
+		}
+	}
+	for b, it := range items {
+		switch it.kind {
+		case layoutBox:
+			sumWidth += it.width
+		case layoutGlue:
+			if b > 0 && items[b-1].kind == layoutBox {
+				tryBreak(b)
+			}
+			sumWidth += it.width
+			sumStretch += it.stretch
+			sumShrink += it.shrink
+		case layoutPenalty:
+			if it.penalty < layoutInfinity {
+				tryBreak(b)
+			}
+		}
+		if len(active) == 0 {
+			return nil
+		}
+	}
+	var best *layoutNode
+	for _, n := range active {
+		if n.pos == len(items)-1 && (best == nil || n.demerits < best.demerits) {
+			best = n
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	var positions []int
+	for n := best; n.pos >= 0; n = n.prev {
+		positions = append([]int{n.pos}, positions...)
+	}
+	return positions
+}
+
+// layoutFirstFit returns the positions of the items ending the lines of
+// the paragraph items filled one after the other, which lets lines wider
+// than lineWidth hold the characters that do not fit on a line alone.
+func layoutFirstFit(items []layoutItem, lineWidth float64) (positions []int) {
+	var sumWidth, startWidth float64
+	last := -1
+	var lastWidth float64 // total width after the last possible break
+	forced := func(b int) bool {
+		return items[b].kind == layoutPenalty && items[b].penalty <= -layoutInfinity
+	}
+	// take ends a line at b. The glue and penalties following a break are
+	// discarded, so a break without a box since the previous one, which
+	// would make an empty line, moves the previous break instead.
+	take := func(b int) {
+		if n := len(positions); n > 0 && !forced(positions[n-1]) {
+			empty := true
+			for j := positions[n-1] + 1; j < b && empty; j++ {
+				empty = items[j].kind != layoutBox
+			}
+			if empty {
+				positions[n-1] = b
+				return
+			}
+		}
+		positions = append(positions, b)
+	}
+	for b, it := range items {
+		legal := (it.kind == layoutGlue && b > 0 && items[b-1].kind == layoutBox) ||
+			(it.kind == layoutPenalty && it.penalty < layoutInfinity)
+		if legal {
+			w := sumWidth - startWidth
+			if it.kind == layoutPenalty {
+				w += it.width
+			}
+			if w > lineWidth && last >= 0 {
+				take(last)
+				startWidth, last = lastWidth, -1
+			}
+			if forced(b) {
+				take(b)
+				startWidth, last = sumWidth, -1
+			} else {
+				last = b
+				lastWidth = sumWidth
+				if it.kind == layoutGlue {
+					lastWidth += it.width
+				}
+			}
+		}
+		if it.kind != layoutPenalty {
+			sumWidth += it.width
+		}
+	}
+	return
+}
+
+// paragraphText splits txt into paragraphs at its line feeds.
+func paragraphText(txt []rune) (paragraphs [][]rune) {
+	start := 0
+	for j, r := range txt {
+		if r == '\n' {
+			paragraphs = append(paragraphs, txt[start:j])
+			start = j + 1
+		}
+	}
+	return append(paragraphs, txt[start:])
+}
+
+// layoutPageBreak returns the number of lines of a paragraph that remain to
+// be output, made of n lines of height h, to output before a page break so
+// that the paragraph respects the minimum numbers of widow and orphan
+// lines, or -1 if the lines fit on the page. first is true if no line of
+// the paragraph has been output.
+func (f *DocPDF) layoutPageBreak(n int, h float64, first bool) int {
+	if !f.autoPageBreak || f.inHeader || f.inFooter || h <= 0 {
+		return -1
+	}
+	fit := int(math.Floor((f.pageBreakTrigger-f.y)/h + 1e-9))
+	if fit >= n {
+		return -1
+	}
+	split := max(fit, 0)
+	if n-split < f.paragraphLayout.Widows {
+		split = n - f.paragraphLayout.Widows
+	}
+	if first && split < f.paragraphLayout.Orphans {
+		split = 0
+	}
+	if !first && split < 1 {
+		split = max(fit, 1)
+	}
+	return max(split, 0)
+}
+
+// multiCellLayout outputs the lines of MultiCell() with the paragraph
+// layout. b is the border of the first line and b2 the border of the next
+// ones.
+func (f *DocPDF) multiCellLayout(w, h float64, txt []rune, borderStr, b, b2, alignStr string, fill bool) {
+	wmax := int(math.Ceil((w - 2*f.cMargin) * 1000 / f.fontSize))
+	toString := func(s []rune) string {
+		if f.isCurrentUTF8 {
+			return string(s)
+		}
+		buf := make([]byte, len(s))
+		for j, r := range s {
+			buf[j] = byte(r)
+		}
+		return string(buf)
+	}
+	paragraphs := paragraphText(txt)
+	nl := 1
+	for pi, paragraph := range paragraphs {
+		lines := f.layoutParagraph(paragraph, wmax, alignStr == "J")
+		split := f.layoutPageBreak(len(lines), h, true)
+		for li, line := range lines {
+			if li == split {
+				// page break kept by the widow and orphan control
+				if f.acceptPageBreak() {
+					x := f.x
+					f.AddPageFormat(f.curOrientation, f.curPageSize)
+					if f.err != nil {
+						return
+					}
+					f.x = x
+				}
+				split = f.layoutPageBreak(len(lines)-li, h, false)
+				if split >= 0 {
+					split += li
+				}
+			}
+			border := b
+			if pi == len(paragraphs)-1 && li == len(lines)-1 && strings.Contains(borderStr, "B") {
+				border += "B"
+			}
+			align := alignStr
+			if alignStr == "J" {
+				switch {
+				case line.last && f.isRTL:
+					align = "R"
+				case line.last:
+					align = "L"
+				case line.spaces > 0:
+					f.ws = float64(wmax-line.width) * f.fontSize / 1000 / float64(line.spaces)
+					f.putF64(f.ws*f.k, 3)
+					f.put(" Tw\n")
+				}
+			}
+			f.CellFormat(w, h, toString(line.text), border, 2, align, fill, 0, "")
+			if f.ws != 0 {
+				f.ws = 0
+				f.out("0 Tw")
+			}
+			nl++
+			if len(borderStr) > 0 && nl == 2 {
+				b = b2
+			}
+		}
+	}
+	f.x = f.lMargin
+}
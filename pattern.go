@@ -0,0 +1,145 @@
+package docpdf
+
+import (
+	"fmt"
+	"math"
+)
+
+// AddTilingPattern defines a tiling pattern whose cell, of width w and height
+// h, is drawn by fn on a template, as with CreateTemplateCustom(). The
+// returned identifier is used with SetFillPattern() and SetTextPattern() to
+// fill shapes and text with copies of the cell repeated horizontally and
+// vertically. The copies are aligned on the upper left corner of the page and
+// do not follow the transformations set with TransformBegin().
+//
+// The cell is drawn with its own colors, starting with the current draw color
+// and fill color unless the latter is a pattern. The areas of the cell left
+// unpainted let the background show through.
+func (f *DocPDF) AddTilingPattern(w, h float64, fn func(*Tpl)) (id int) {
+	if f.err != nil {
+		return
+	}
+	if w <= 0 || h <= 0 {
+		f.err = fmt.Errorf("unacceptable pattern cell size %.2f x %.2f", w, h)
+		return
+	}
+	t := f.CreateTemplateCustom(PointType{}, SizeType{Wd: w, Ht: h}, fn)
+	f.templateUse(t)
+	f.patternList = append(f.patternList, patternType{tpl: t,
+		matrix: TransformMatrix{A: 1, D: 1, F: math.Mod(f.h-h, h) * f.k}})
+	return len(f.patternList) - 1
+}
+
+// AddHatchPattern defines a tiling pattern of lines or dots drawn with the
+// current draw color, and returns its identifier for use with
+// SetFillPattern() and SetTextPattern(). styleStr selects the hatching: "/"
+// and "\" for diagonal lines, "-" for horizontal lines, "|" for vertical
+// lines, "+" for horizontal and vertical lines, "x" for crossed diagonal lines
+// and "." for dots. The lines are separated by spacing and are of width
+// lineWd, which is also the diameter of the dots. Hatched areas are not
+// painted between the lines; fill them with a color first to obtain a colored
+// background.
+func (f *DocPDF) AddHatchPattern(styleStr string, spacing, lineWd float64) (id int) {
+	if f.err != nil {
+		return
+	}
+	switch styleStr {
+	case "/", "\\", "-", "|", "+", "x", ".":
+	default:
+		f.err = fmt.Errorf("unrecognized hatch style \"%s\"", styleStr)
+		return
+	}
+	s := spacing
+	return f.AddTilingPattern(s, s, func(tpl *Tpl) {
+		tpl.SetLineWidth(lineWd)
+		tpl.SetLineCapStyle("butt")
+		// Diagonals are drawn in the neighboring cells too, so that they join
+		// at the corners of the cell
+		for _, dx := range []float64{-s, 0, s} {
+			if styleStr == "/" || styleStr == "x" {
+				tpl.Line(dx, s, dx+s, 0)
+			}
+			if styleStr == "\\" || styleStr == "x" {
+				tpl.Line(dx, 0, dx+s, s)
+			}
+		}
+		if styleStr == "-" || styleStr == "+" {
+			tpl.Line(0, s/2, s, s/2)
+		}
+		if styleStr == "|" || styleStr == "+" {
+			tpl.Line(s/2, 0, s/2, s)
+		}
+		if styleStr == "." {
+			// A round cap turns a line of no length into a dot
+			tpl.SetLineCapStyle("round")
+			tpl.Line(s/2, s/2, s/2, s/2)
+		}
+	})
+}
+
+// pattern returns the color string of the pattern id
+func (f *DocPDF) pattern(id int) (clr colorType, ok bool) {
+	if f.err != nil {
+		return
+	}
+	if id <= 0 || id >= len(f.patternList) {
+		f.err = fmt.Errorf("pattern %d is not defined", id)
+		return
+	}
+	clr = colorType{mode: colorModePattern, str: sprintf("/Pattern cs /P%d scn", id)}
+	return clr, true
+}
+
+// SetFillPattern sets the fill color to the pattern id returned by
+// AddTilingPattern() or AddHatchPattern(). The pattern fills the shapes drawn
+// until the fill color is set again, such as those of Rect(), Circle(),
+// Polygon() and DrawPath() and the background of cells. GetFillColor()
+// returns black while a pattern is the fill color.
+func (f *DocPDF) SetFillPattern(id int) {
+	clr, ok := f.pattern(id)
+	if ok {
+		f.color.fill = clr
+		f.colorFlag = f.color.fill.str != f.color.text.str
+		if f.page > 0 {
+			f.out(f.color.fill.str)
+		}
+	}
+}
+
+// SetTextPattern sets the text color to the pattern id returned by
+// AddTilingPattern() or AddHatchPattern(). GetTextColor() returns black while
+// a pattern is the text color.
+//
+// The SetFillPattern() example demonstrates this method.
+func (f *DocPDF) SetTextPattern(id int) {
+	clr, ok := f.pattern(id)
+	if ok {
+		f.color.text = clr
+		f.colorFlag = f.color.fill.str != f.color.text.str
+	}
+}
+
+func (f *DocPDF) putPatterns() {
+	for j := 1; j < len(f.patternList); j++ {
+		p := f.patternList[j]
+		m := p.matrix
+		f.newobj()
+		if p.tpl == nil {
+			f.outf("<</Type /Pattern /PatternType 2 /Shading %d 0 R /Matrix [%.5f %.5f %.5f %.5f %.5f %.5f]>>",
+				f.gradientList[p.gradient].objNum, m.A, m.B, m.C, m.D, m.E, m.F)
+		} else {
+			_, size := p.tpl.Size()
+			id := p.tpl.ID()
+			content := sprintf("/TPL%s Do", id)
+			f.outf("<</Type /Pattern /PatternType 1 /PaintType 1 /TilingType 1 /BBox [0 0 %.5f %.5f]",
+				size.Wd*f.k, size.Ht*f.k)
+			f.outf("/XStep %.5f /YStep %.5f /Matrix [%.5f %.5f %.5f %.5f %.5f %.5f]",
+				size.Wd*f.k, size.Ht*f.k, m.A, m.B, m.C, m.D, m.E, m.F)
+			f.outf("/Resources <</XObject <</TPL%s %d 0 R>>>>", id, f.templateObjects[id])
+			f.outf("/Length %d>>", f.protect.encryptedLen(len(content)))
+			f.putstream([]byte(content))
+		}
+		f.out("endobj")
+		f.patternList[j].objNum = f.n
+	}
+}
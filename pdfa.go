@@ -0,0 +1,269 @@
+package docpdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// SetPDFAConformance enables a conformance mode in which the document is
+// output as a PDF/A document of the level levelStr: "PDF/A-1b", "PDF/A-2b"
+// or "PDF/A-3b". An empty string disables the mode.
+//
+// When the document is output, XMP metadata identifying the level and
+// mirroring the title, author, subject, keywords, creator, producer and
+// dates of the document is generated, unless metadata has been set with
+// SetXmpMetadata(). An sRGB output intent is added unless an output intent of
+// subtype OutputIntent_GTS_PDFA1 has been added with AddOutputIntent().
+//
+// Output fails with a descriptive error if the document uses a feature the
+// level forbids: core fonts and other fonts whose program is not embedded,
+// encryption, JavaScript (including form field actions), output intents with
+// different ICC profiles and, for PDF/A-1b, transparency (alpha and blend
+// modes other than Normal, and images with an alpha channel) and layers.
+// PDF/A-1b forbids attachments and PDF/A-2b only accepts PDF files, which
+// should themselves be PDF/A documents. PDF/A-3b accepts any attachment and
+// records its media type and its relationship to the document, see
+// Attachment.
+func (f *DocPDF) SetPDFAConformance(levelStr string) {
+	if f.err != nil {
+		return
+	}
+	switch levelStr {
+	case "":
+		f.pdfaPart = 0
+	case "PDF/A-1b":
+		f.pdfaPart = 1
+		if f.pdfVersion < pdfVers1_4 {
+			f.pdfVersion = pdfVers1_4
+		}
+	case "PDF/A-2b", "PDF/A-3b":
+		f.pdfaPart = int(levelStr[6] - '0')
+		if f.pdfVersion < pdfVers1_7 {
+			f.pdfVersion = pdfVers1_7
+		}
+	default:
+		f.err = fmt.Errorf("unsupported PDF/A conformance level \"%s\"", levelStr)
+	}
+}
+
+// GetPDFAConformance returns the PDF/A conformance level set with
+// SetPDFAConformance(), or an empty string if the mode is disabled.
+func (f *DocPDF) GetPDFAConformance() string {
+	if f.pdfaPart == 0 {
+		return ""
+	}
+	return sprintf("PDF/A-%db", f.pdfaPart)
+}
+
+// pdfaPrepare checks that the document conforms to its PDF/A level, and adds
+// the output intent and the XMP metadata the level requires
+func (f *DocPDF) pdfaPrepare() {
+	levelStr := f.GetPDFAConformance()
+	if f.protect.encrypted {
+		f.err = fmt.Errorf("%s forbids encryption", levelStr)
+		return
+	}
+	if f.javascript != nil {
+		f.err = fmt.Errorf("%s forbids JavaScript", levelStr)
+		return
+	}
+	for _, fld := range f.formFields {
+		if fld.opts.JavaScript != "" {
+			f.err = fmt.Errorf("%s forbids JavaScript; form field %s runs a script", levelStr, fld.name)
+			return
+		}
+	}
+	keys := make([]string, 0, len(f.fonts))
+	for key := range f.fonts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		font := f.fonts[key]
+		if font.Tp == "Core" || (font.Tp != "UTF8" && font.File == "") {
+			f.err = fmt.Errorf("%s requires embedded fonts; font %s is not embedded, "+
+				"use AddUTF8Font() or AddFont() with a font file instead", levelStr, font.Name)
+			return
+		}
+	}
+	if f.pdfaPart == 1 {
+		if len(f.layer.list) > 0 {
+			f.err = fmt.Errorf("%s forbids optional content; layer %s is defined", levelStr, f.layer.list[0].name)
+			return
+		}
+		for _, bl := range f.blendList[1:] {
+			if bl.fillStr != "1.000" || bl.strokeStr != "1.000" || (bl.modeStr != "Normal" && bl.modeStr != "Compatible") {
+				f.err = fmt.Errorf("%s forbids transparency; alpha %s and blend mode %s are used",
+					levelStr, bl.fillStr, bl.modeStr)
+				return
+			}
+		}
+		for _, key := range f.imageKeys() {
+			if len(f.images[key].smask) > 0 {
+				f.err = fmt.Errorf("%s forbids transparency; image %s has an alpha channel", levelStr, key)
+				return
+			}
+		}
+	}
+	for _, a := range f.pdfaAttachments() {
+		switch {
+		case f.pdfaPart == 1:
+			f.err = fmt.Errorf("%s forbids attachments; %s is attached", levelStr, a.Filename)
+		case f.pdfaPart == 2 && !bytes.HasPrefix(a.Content, []byte("%PDF-")):
+			f.err = fmt.Errorf("%s only accepts PDF/A attachments; %s is not a PDF file", levelStr, a.Filename)
+		case f.pdfaPart == 3 && afRelationship(a.Relationship) == "":
+			f.err = fmt.Errorf("%s: unknown relationship \"%s\" of attachment %s", levelStr, a.Relationship, a.Filename)
+		}
+		if f.err != nil {
+			return
+		}
+	}
+	// An sRGB output intent, unless the caller provided one for PDF/A
+	found := false
+	for _, oi := range f.outputIntents {
+		found = found || oi.SubtypeIdent == OutputIntent_GTS_PDFA1
+	}
+	if !found {
+		f.outputIntents = append(f.outputIntents, OutputIntentType{
+			SubtypeIdent:              OutputIntent_GTS_PDFA1,
+			OutputConditionIdentifier: "sRGB IEC61966-2.1",
+			Info:                      "sRGB IEC61966-2.1",
+			ICCProfile:                srgbProfile,
+		})
+	}
+	for _, oi := range f.outputIntents[1:] {
+		if !bytes.Equal(oi.ICCProfile, f.outputIntents[0].ICCProfile) {
+			f.err = fmt.Errorf("%s requires the output intents to share the same ICC profile", levelStr)
+			return
+		}
+	}
+	if len(f.xmp) == 0 {
+		f.xmp = f.pdfaXmp()
+	}
+}
+
+// imageKeys returns the sorted keys of the registered images
+func (f *DocPDF) imageKeys() []string {
+	keys := make([]string, 0, len(f.images))
+	for key := range f.images {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pdfaAttachments returns the document attachments followed by the distinct
+// attachments of the annotations
+func (f *DocPDF) pdfaAttachments() (list []*Attachment) {
+	for j := range f.attachments {
+		list = append(list, &f.attachments[j])
+	}
+	seen := make(map[*Attachment]bool)
+	for _, l := range f.pageAttachments {
+		for _, an := range l {
+			if !seen[an.Attachment] {
+				seen[an.Attachment] = true
+				list = append(list, an.Attachment)
+			}
+		}
+	}
+	return
+}
+
+// pdfaXmp returns the XMP metadata of the PDF/A document, whose entries match
+// those of the document information dictionary
+func (f *DocPDF) pdfaXmp() []byte {
+	var b bytes.Buffer
+	esc := func(s string) string {
+		var e bytes.Buffer
+		xml.EscapeText(&e, []byte(infoText(s)))
+		return e.String()
+	}
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.WriteString("<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:pdfaid=\"http://www.aiim.org/pdfa/ns/id/\">\n")
+	b.WriteString(sprintf("<pdfaid:part>%d</pdfaid:part>\n", f.pdfaPart))
+	b.WriteString("<pdfaid:conformance>B</pdfaid:conformance>\n")
+	b.WriteString("</rdf:Description>\n")
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\">\n")
+	b.WriteString("<dc:format>application/pdf</dc:format>\n")
+	if len(f.title) > 0 {
+		b.WriteString(sprintf("<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n",
+			esc(f.title)))
+	}
+	if len(f.author) > 0 {
+		b.WriteString(sprintf("<dc:creator><rdf:Seq><rdf:li>%s</rdf:li></rdf:Seq></dc:creator>\n", esc(f.author)))
+	}
+	if len(f.subject) > 0 {
+		b.WriteString(sprintf("<dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n",
+			esc(f.subject)))
+	}
+	b.WriteString("</rdf:Description>\n")
+	creation, mod := f.documentDates()
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\">\n")
+	b.WriteString(sprintf("<xmp:CreateDate>%s</xmp:CreateDate>\n", creation.Format(time.RFC3339)))
+	b.WriteString(sprintf("<xmp:ModifyDate>%s</xmp:ModifyDate>\n", mod.Format(time.RFC3339)))
+	b.WriteString(sprintf("<xmp:MetadataDate>%s</xmp:MetadataDate>\n", mod.Format(time.RFC3339)))
+	if len(f.creator) > 0 {
+		b.WriteString(sprintf("<xmp:CreatorTool>%s</xmp:CreatorTool>\n", esc(f.creator)))
+	}
+	b.WriteString("</rdf:Description>\n")
+	b.WriteString("<rdf:Description rdf:about=\"\" xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\">\n")
+	if len(f.producer) > 0 {
+		b.WriteString(sprintf("<pdf:Producer>%s</pdf:Producer>\n", esc(f.producer)))
+	}
+	if len(f.keywords) > 0 {
+		b.WriteString(sprintf("<pdf:Keywords>%s</pdf:Keywords>\n", esc(f.keywords)))
+	}
+	b.WriteString("</rdf:Description>\n")
+	b.WriteString("</rdf:RDF>\n")
+	b.WriteString("</x:xmpmeta>\n")
+	b.WriteString("<?xpacket end=\"w\"?>")
+	return b.Bytes()
+}
+
+// infoText returns the UTF-8 version of the document information string s,
+// stored as UTF-16BE with a byte order mark or as ISO-8859-1
+func infoText(s string) string {
+	if strings.HasPrefix(s, "\xfe\xff") {
+		units := make([]uint16, 0, len(s)/2)
+		for j := 2; j+1 < len(s); j += 2 {
+			units = append(units, uint16(s[j])<<8|uint16(s[j+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(s))
+	for j := 0; j < len(s); j++ {
+		runes[j] = rune(s[j])
+	}
+	return string(runes)
+}
+
+// infoDate returns the date tm formatted for the document information
+// dictionary. The time zone is included in PDF/A documents, where the date
+// must match the XMP metadata.
+func (f *DocPDF) infoDate(tm time.Time) string {
+	s := "D:" + tm.Format("20060102150405")
+	if f.pdfaPart > 0 {
+		s += strings.Replace(tm.Format("-07:00"), ":", "'", 1) + "'"
+	}
+	return s
+}
+
+// afRelationship returns the name of the relationship of an attachment to a
+// PDF/A-3 document, or an empty string if relStr is unknown
+func afRelationship(relStr string) string {
+	switch relStr {
+	case "":
+		return "Unspecified"
+	case "Source", "Data", "Alternative", "Supplement", "Unspecified":
+		return relStr
+	}
+	return ""
+}
@@ -24,8 +24,14 @@
 package docpdf
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/md5"
+	crand "crypto/rand"
 	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/binary"
 	"math/rand"
 )
@@ -36,26 +42,58 @@ const (
 	CnProtectModify     = 8
 	CnProtectCopy       = 16
 	CnProtectAnnotForms = 32
+	// The following flags are only available with SetProtectionAES128() and
+	// SetProtectionAES256().
+	CnProtectFillForms    = 256  // fill in form fields, even without CnProtectAnnotForms
+	CnProtectExtract      = 512  // extract text and graphics for accessibility
+	CnProtectAssemble     = 1024 // insert, rotate and delete pages, create bookmarks
+	CnProtectPrintHighRes = 2048 // print at full quality, with CnProtectPrint
 )
 
+// protectPadding is the padding string of the standard security handler
+var protectPadding = []byte{
+	0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41,
+	0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
+	0x2E, 0x2E, 0x00, 0xB6, 0xD0, 0x68, 0x3E, 0x80,
+	0x2F, 0x0C, 0xA9, 0xFE, 0x64, 0x53, 0x69, 0x7A,
+}
+
 type protectType struct {
 	encrypted     bool
+	revision      int // 2: RC4 40-bit, 4: AES-128, 6: AES-256
 	uValue        []byte
 	oValue        []byte
+	ueValue       []byte // revision 6 only
+	oeValue       []byte // revision 6 only
+	perms         []byte // revision 6 only
 	pValue        int
 	padding       []byte
 	encryptionKey []byte
+	fileID        []byte // first element of the trailer /ID, nil for revision 2
 	objNum        int
-	rc4cipher     *rc4.Cipher
-	rc4n          uint32 // Object number associated with rc4 cipher
 }
 
-func (p *protectType) rc4(n uint32, buf *[]byte) {
-	if p.rc4cipher == nil || p.rc4n != n {
-		p.rc4cipher, _ = rc4.NewCipher(p.objectKey(n))
-		p.rc4n = n
+// encrypt returns buf encrypted for object n. buf itself is not modified.
+func (p *protectType) encrypt(n uint32, buf []byte) []byte {
+	switch p.revision {
+	case 4:
+		return aesEncrypt(p.aesObjectKey(n), buf)
+	case 6:
+		return aesEncrypt(p.encryptionKey, buf)
+	}
+	b := make([]byte, len(buf))
+	c, _ := rc4.NewCipher(p.objectKey(n))
+	c.XORKeyStream(b, buf)
+	return b
+}
+
+// encryptedLen returns the length of n bytes of stream data once encrypted.
+func (p *protectType) encryptedLen(n int) int {
+	if p.encrypted && p.revision >= 4 {
+		// Initialization vector and padded data
+		return aes.BlockSize + (n/aes.BlockSize+1)*aes.BlockSize
 	}
-	p.rc4cipher.XORKeyStream(*buf, *buf)
+	return n
 }
 
 func (p *protectType) objectKey(n uint32) []byte {
@@ -68,6 +106,38 @@ func (p *protectType) objectKey(n uint32) []byte {
 	return s[0:10]
 }
 
+// aesObjectKey returns the AESV2 key of object n, algorithm 1 of the PDF
+// specification.
+func (p *protectType) aesObjectKey(n uint32) []byte {
+	b := append([]byte{}, p.encryptionKey...)
+	b = append(b, byte(n), byte(n>>8), byte(n>>16), 0, 0, 's', 'A', 'l', 'T')
+	s := md5.Sum(b)
+	return s[:16]
+}
+
+// aesEncrypt returns buf encrypted in CBC mode, preceded by a random
+// initialization vector and padded as described in RFC 2898.
+func aesEncrypt(key, buf []byte) []byte {
+	block, _ := aes.NewCipher(key)
+	pad := aes.BlockSize - len(buf)%aes.BlockSize
+	out := make([]byte, aes.BlockSize+len(buf)+pad)
+	iv := out[:aes.BlockSize]
+	crand.Read(iv)
+	copy(out[aes.BlockSize:], buf)
+	for j := len(out) - pad; j < len(out); j++ {
+		out[j] = byte(pad)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out[aes.BlockSize:], out[aes.BlockSize:])
+	return out
+}
+
+// randomBytes returns n bytes from the cryptographic random source.
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	crand.Read(b)
+	return b
+}
+
 func oValueGen(userPass, ownerPass []byte) (v []byte) {
 	var c *rc4.Cipher
 	tmp := md5.Sum(ownerPass)
@@ -89,12 +159,9 @@ func (p *protectType) uValueGen() (v []byte) {
 
 func (p *protectType) setProtection(privFlag byte, userPassStr, ownerPassStr string) {
 	privFlag = 192 | (privFlag & (CnProtectCopy | CnProtectModify | CnProtectPrint | CnProtectAnnotForms))
-	p.padding = []byte{
-		0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41,
-		0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
-		0x2E, 0x2E, 0x00, 0xB6, 0xD0, 0x68, 0x3E, 0x80,
-		0x2F, 0x0C, 0xA9, 0xFE, 0x64, 0x53, 0x69, 0x7A,
-	}
+	p.padding = protectPadding
+	p.revision = 2
+	p.fileID = nil
 	userPass := []byte(userPassStr)
 	var ownerPass []byte
 	if ownerPassStr == "" {
@@ -116,3 +183,122 @@ func (p *protectType) setProtection(privFlag byte, userPassStr, ownerPassStr str
 	p.uValue = p.uValueGen()
 	p.pValue = -(int(privFlag^255) + 1)
 }
+
+// permissions returns the /P value of revisions 4 and 6 for the CnProtect
+// flags in actionFlag.
+func permissions(actionFlag int) int {
+	const all = CnProtectPrint | CnProtectModify | CnProtectCopy | CnProtectAnnotForms |
+		CnProtectFillForms | CnProtectExtract | CnProtectAssemble | CnProtectPrintHighRes
+	// Bits 7, 8 and 13 to 32 are reserved and must be set
+	return int(int32(0xfffff0c0 | uint32(actionFlag&all)))
+}
+
+// ownerPassword returns ownerPassStr, or a random password if it is empty.
+func ownerPassword(ownerPassStr string) []byte {
+	if ownerPassStr == "" {
+		return randomBytes(16)
+	}
+	return []byte(ownerPassStr)
+}
+
+// setProtectionAES128 sets up the standard security handler revision 4 with
+// the AESV2 crypt filter, algorithms 2, 3 and 5 of the PDF specification.
+func (p *protectType) setProtectionAES128(actionFlag int, userPassStr, ownerPassStr string) {
+	rc4Rounds := func(key, buf []byte) []byte {
+		out := append([]byte{}, buf...)
+		k := make([]byte, len(key))
+		for j := 0; j < 20; j++ {
+			for i := range key {
+				k[i] = key[i] ^ byte(j)
+			}
+			c, _ := rc4.NewCipher(k)
+			c.XORKeyStream(out, out)
+		}
+		return out
+	}
+	md5Rounds := func(buf []byte) []byte {
+		s := md5.Sum(buf)
+		for j := 0; j < 50; j++ {
+			s = md5.Sum(s[:16])
+		}
+		return s[:16]
+	}
+	userPass := append([]byte(userPassStr), protectPadding...)[0:32]
+	ownerPass := append(ownerPassword(ownerPassStr), protectPadding...)[0:32]
+	p.encrypted = true
+	p.revision = 4
+	p.padding = protectPadding
+	p.pValue = permissions(actionFlag)
+	p.fileID = randomBytes(16)
+	p.oValue = rc4Rounds(md5Rounds(ownerPass), userPass)
+	var buf []byte
+	buf = append(buf, userPass...)
+	buf = append(buf, p.oValue...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(p.pValue))
+	buf = append(buf, p.fileID...)
+	p.encryptionKey = md5Rounds(buf)
+	s := md5.Sum(append(append([]byte{}, protectPadding...), p.fileID...))
+	p.uValue = append(rc4Rounds(p.encryptionKey, s[:]), randomBytes(16)...)
+}
+
+// setProtectionAES256 sets up the standard security handler revision 6 with
+// the AESV3 crypt filter, algorithms 8, 9 and 10 of the PDF specification.
+func (p *protectType) setProtectionAES256(actionFlag int, userPassStr, ownerPassStr string) {
+	// Passwords are limited to 127 bytes of UTF-8
+	userPass := []byte(userPassStr)
+	userPass = userPass[:min(len(userPass), 127)]
+	ownerPass := ownerPassword(ownerPassStr)
+	ownerPass = ownerPass[:min(len(ownerPass), 127)]
+	wrap := func(key []byte) []byte {
+		block, _ := aes.NewCipher(key)
+		out := make([]byte, 32)
+		cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, p.encryptionKey)
+		return out
+	}
+	p.encrypted = true
+	p.revision = 6
+	p.pValue = permissions(actionFlag)
+	p.fileID = randomBytes(16)
+	p.encryptionKey = randomBytes(32)
+	salt := randomBytes(32)
+	p.uValue = append(hashR6(userPass, salt[0:8], nil), salt[0:16]...)
+	p.ueValue = wrap(hashR6(userPass, salt[8:16], nil))
+	p.oValue = append(hashR6(ownerPass, salt[16:24], p.uValue), salt[16:32]...)
+	p.oeValue = wrap(hashR6(ownerPass, salt[24:32], p.uValue))
+	perms := binary.LittleEndian.AppendUint32(nil, uint32(p.pValue))
+	perms = append(perms, 0xff, 0xff, 0xff, 0xff, 'T', 'a', 'd', 'b')
+	perms = append(perms, randomBytes(4)...)
+	block, _ := aes.NewCipher(p.encryptionKey)
+	p.perms = make([]byte, aes.BlockSize)
+	block.Encrypt(p.perms, perms)
+}
+
+// hashR6 returns the hash of a password, algorithm 2.B of the PDF
+// specification. udata is the /U value when hashing the owner password.
+func hashR6(pass, salt, udata []byte) []byte {
+	sum := sha256.Sum256(bytes.Join([][]byte{pass, salt, udata}, nil))
+	k := sum[:]
+	var e []byte
+	for j := 0; j < 64 || int(e[len(e)-1]) > j-32; j++ {
+		k1 := bytes.Repeat(bytes.Join([][]byte{pass, k, udata}, nil), 64)
+		block, _ := aes.NewCipher(k[:16])
+		e = make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+		var mod int
+		for _, b := range e[:16] {
+			mod += int(b)
+		}
+		switch mod % 3 {
+		case 0:
+			s := sha256.Sum256(e)
+			k = s[:]
+		case 1:
+			s := sha512.Sum384(e)
+			k = s[:]
+		default:
+			s := sha512.Sum512(e)
+			k = s[:]
+		}
+	}
+	return k[:32]
+}
@@ -0,0 +1,121 @@
+package docpdf
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/cdvelop/docpdf/env"
+)
+
+// SetResourceFS sets the file system from which fonts, font descriptor maps,
+// images, SVG files and attachments are read. When fsys is nil, resources
+// are read through the env package as usual. Paths passed to the loading
+// methods are then interpreted relative to the root of fsys, so an
+// embed.FS can be used directly without setting a RootDirectoryType:
+//
+//	//go:embed fonts image
+//	var assets embed.FS
+//
+//	pdf := docpdf.New(fs.FS(assets))
+//
+// The font directory is resolved within fsys using the FontsDirName value
+// ("fonts" by default).
+func (f *DocPDF) SetResourceFS(fsys fs.FS) {
+	f.resourceFS = fsys
+	if fsys != nil {
+		f.fontsPath = string(f.fontsDirName)
+	} else {
+		f.fontsPath = f.rootDirectory.MakePath(string(f.fontsDirName))
+	}
+}
+
+// GetResourceFS returns the file system set with SetResourceFS() or passed
+// to New(), or nil if resources are read through the env package.
+func (f *DocPDF) GetResourceFS() fs.FS {
+	return f.resourceFS
+}
+
+// readFile returns the content of the named resource, reading it from the
+// resource file system when one is set.
+func (f *DocPDF) readFile(name string) ([]byte, error) {
+	if f.resourceFS == nil || env.IsURL(name) {
+		return env.FileExists(name)
+	}
+	return fs.ReadFile(f.resourceFS, fsPath(name))
+}
+
+// fsPath converts name into a path valid for fs.FS: slash separated,
+// unrooted and without "." or ".." elements.
+func fsPath(name string) string {
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimLeft(name, "/")
+	for strings.HasPrefix(name, "../") {
+		name = name[3:]
+	}
+	if name == "" || name == ".." {
+		return "."
+	}
+	return name
+}
+
+// UnicodeTranslatorFromFile returns a function that can be used to translate,
+// where possible, utf-8 strings to a form that is compatible with the
+// specified code page. It behaves like the package level
+// UnicodeTranslatorFromFile() but reads fileStr from the resource file
+// system when one is set.
+func (f *DocPDF) UnicodeTranslatorFromFile(fileStr string) (rep func(string) string, err error) {
+	var data []byte
+	data, err = f.readFile(fileStr)
+	if err == nil {
+		rep, err = UnicodeTranslator(bytes.NewReader(data))
+	} else {
+		rep = doNothing
+	}
+	return
+}
+
+// SVGBasicFileParse parses a simple scalable vector graphics (SVG) file into a
+// basic descriptor. It behaves like the package level SVGBasicFileParse() but
+// reads svgFileStr from the resource file system when one is set.
+func (f *DocPDF) SVGBasicFileParse(svgFileStr string) (sig SVGBasicType, err error) {
+	var buf []byte
+	buf, err = f.readFile(svgFileStr)
+	if err == nil {
+		sig, err = SVGBasicParse(buf)
+	}
+	return
+}
+
+// SVGFileParse parses a scalable vector graphics (SVG) file into a descriptor
+// that can be rendered with SVGWrite(). It behaves like the package level
+// SVGFileParse() but reads svgFileStr from the resource file system when one
+// is set.
+func (f *DocPDF) SVGFileParse(svgFileStr string) (svg SVGType, err error) {
+	var buf []byte
+	buf, err = f.readFile(svgFileStr)
+	if err == nil {
+		svg, err = SVGParse(buf)
+	}
+	return
+}
+
+// AttachmentFromFile returns an Attachment whose content is read from
+// fileStr. The resource file system is used when one is set. The base name
+// of fileStr is used as the attachment file name. If an error occurs, it is
+// stored in the document and an empty attachment is returned.
+func (f *DocPDF) AttachmentFromFile(fileStr, description string) (a Attachment) {
+	if f.err != nil {
+		return
+	}
+	data, err := f.readFile(fileStr)
+	if err != nil {
+		f.err = err
+		return
+	}
+	a.Content = data
+	a.Filename = path.Base(strings.ReplaceAll(fileStr, "\\", "/"))
+	a.Description = description
+	return
+}
@@ -0,0 +1,334 @@
+package docpdf
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// TextSpanType describes a run of text of a paragraph created with
+// ParagraphNew(). FontFamily, FontSize and TextColor take the values current
+// when Output() is called if they are left empty.
+type TextSpanType struct {
+	Text       string
+	FontFamily string  // see SetFont()
+	FontStyle  string  // see SetFont(); "U" underlines and "S" strikes out the text
+	FontSize   float64 // in points
+	TextColor  *RGBType
+	Highlight  *RGBType // background color painted behind the text
+	// Vertical offset of the baseline in points; positive values raise the
+	// text, as for a superscript, and negative values lower it
+	Offset  float64
+	Link    int    // internal link, see AddLink()
+	LinkStr string // external link URL
+}
+
+// ParagraphType assists with the layout of a paragraph made of text spans of
+// different styles. See ParagraphNew() to create a paragraph that is
+// associated with a PDF document instance. The exported fields can be
+// modified prior to calling Output().
+type ParagraphType struct {
+	pdf   *DocPDF
+	spans []TextSpanType
+	// Paragraph width in user units; zero extends the paragraph from the
+	// current position to the right margin
+	Width float64
+	// Line height as a multiple of the largest font size of each line; zero
+	// uses 1.25
+	LineSpacing float64
+	// Horizontal alignment: "L", "C", "R" or "J"; "L" if empty. The last line
+	// of a justified paragraph, and lines ended by a newline, are aligned
+	// left.
+	AlignStr string
+}
+
+// paragraphPiece is a measured word or space of a paragraph
+type paragraphPiece struct {
+	text  string
+	span  int
+	w     float64
+	space bool
+	br    bool // forced line break
+}
+
+// ParagraphNew returns an instance that facilitates writing a paragraph of
+// text spans with mixed fonts, sizes and colors in the specified PDF file.
+// The spans are wrapped together at spaces, and the text of each line shares
+// a common baseline.
+func (f *DocPDF) ParagraphNew() (para ParagraphType) {
+	para.pdf = f
+	return
+}
+
+// AddSpan appends the specified spans to the paragraph. A newline in the
+// text of a span ends the line.
+func (para *ParagraphType) AddSpan(spans ...TextSpanType) {
+	para.spans = append(para.spans, spans...)
+}
+
+// Output prints the paragraph at the current position. Automatic page breaks
+// are performed according to SetAutoPageBreak() and SetAcceptPageBreakFunc(),
+// and the paragraph flows in the frames set with SetColumns() or
+// SetFrames(). Upon method exit, the current position is left at the
+// paragraph's left edge below the last line, and the font and colors are
+// restored.
+func (para *ParagraphType) Output() {
+	f := para.pdf
+	if f.err != nil {
+		return
+	}
+	if f.currentFont.Name == "" {
+		f.err = fmt.Errorf("font has not been set; unable to render paragraph")
+		return
+	}
+	if f.tagAuto("P", "") {
+		defer f.tagPop()
+	}
+	// Save the state modified while rendering
+	cMargin := f.cMargin
+	family, sizePt := f.fontFamily, f.fontSizePt
+	styleStr := f.GetFontStyle()
+	textR, textG, textB := f.GetTextColor()
+	fillR, fillG, fillB := f.GetFillColor()
+	defer func() {
+		f.cMargin = cMargin
+		f.SetFont(family, styleStr, sizePt)
+		f.SetTextColor(textR, textG, textB)
+		f.SetFillColor(fillR, fillG, fillB)
+	}()
+	f.cMargin = 0
+	spans := make([]TextSpanType, len(para.spans))
+	for j, sp := range para.spans {
+		if sp.FontFamily == "" {
+			sp.FontFamily = family
+		}
+		if sp.FontSize <= 0 {
+			sp.FontSize = sizePt
+		}
+		if sp.TextColor == nil {
+			sp.TextColor = &RGBType{R: textR, G: textG, B: textB}
+		}
+		spans[j] = sp
+	}
+
+	// Measure the words and spaces of the spans
+	var pieces []paragraphPiece
+	for j, sp := range spans {
+		f.SetFont(sp.FontFamily, sp.FontStyle, sp.FontSize)
+		if f.err != nil {
+			return
+		}
+		for n, line := range strings.Split(strings.ReplaceAll(sp.Text, "\r", ""), "\n") {
+			if n > 0 {
+				pieces = append(pieces, paragraphPiece{span: j, br: true})
+			}
+			for len(line) > 0 {
+				space := line[0] == ' '
+				end := strings.IndexFunc(line, func(r rune) bool { return (r == ' ') != space })
+				if end < 0 {
+					end = len(line)
+				}
+				pieces = append(pieces, paragraphPiece{text: line[:end], span: j, w: f.GetStringWidth(line[:end]), space: space})
+				line = line[end:]
+			}
+		}
+	}
+
+	dx := f.x - f.lMargin
+	width := func() float64 {
+		if para.Width > 0 {
+			return para.Width
+		}
+		return f.w - f.rMargin - f.lMargin - dx
+	}
+	for len(pieces) > 0 && f.err == nil {
+		maxWd := width()
+		line, rest := para.fillLine(spans, pieces, maxWd)
+		ht, bottom := para.lineHeight(spans, line)
+		if f.y+bottom > f.pageBreakTrigger && !f.inHeader && !f.inFooter {
+			if f.acceptPageBreak() {
+				f.AddPageFormat(f.curOrientation, f.curPageSize)
+			}
+			// the width may differ in the next frame or page
+			maxWd = width()
+			line, rest = para.fillLine(spans, pieces, maxWd)
+			ht, _ = para.lineHeight(spans, line)
+		}
+		last := len(rest) == 0 || line[len(line)-1].br
+		para.drawLine(spans, line, f.lMargin+dx, maxWd, ht, last)
+		pieces = rest
+	}
+}
+
+// fillLine returns the pieces of the line starting with the first piece and
+// the remaining pieces. Trailing spaces are removed from the line, and words
+// longer than maxWd are broken.
+func (para *ParagraphType) fillLine(spans []TextSpanType, pieces []paragraphPiece, maxWd float64) (line, rest []paragraphPiece) {
+	f := para.pdf
+	lineWd := 0.0
+	words := false
+	j := 0
+	for j < len(pieces) && !pieces[j].br {
+		if pieces[j].space {
+			lineWd += pieces[j].w
+			j++
+			continue
+		}
+		// a word may be made of pieces of several spans
+		end, wd := j, 0.0
+		for ; end < len(pieces) && !pieces[end].space && !pieces[end].br; end++ {
+			wd += pieces[end].w
+		}
+		if lineWd+wd > maxWd && words {
+			break
+		}
+		if wd > maxWd && !words {
+			// Break the word where it overflows the line
+			start := j
+			for wd = lineWd; j < end && wd+pieces[j].w <= maxWd; j++ {
+				wd += pieces[j].w
+			}
+			if j > start {
+				break
+			}
+			pc := pieces[j]
+			sp := spans[pc.span]
+			f.SetFont(sp.FontFamily, sp.FontStyle, sp.FontSize)
+			n := fitWord(f, pc.text, maxWd-wd)
+			if n == len(pc.text) {
+				j++
+				break
+			}
+			head, tail := pc, pc
+			head.text, tail.text = pc.text[:n], pc.text[n:]
+			head.w, tail.w = f.GetStringWidth(head.text), f.GetStringWidth(tail.text)
+			line = append(append(line, pieces[:j]...), head)
+			rest = append([]paragraphPiece{tail}, pieces[j+1:]...)
+			return
+		}
+		lineWd += wd
+		words = true
+		j = end
+	}
+	line, rest = pieces[:j:j], pieces[j:]
+	for len(line) > 0 && line[len(line)-1].space {
+		line = line[:len(line)-1]
+	}
+	if len(rest) > 0 && rest[0].br {
+		line = append(line, rest[0])
+		rest = rest[1:]
+	}
+	// Spaces at the start of a wrapped line are not printed
+	for len(rest) > 0 && rest[0].space {
+		rest = rest[1:]
+	}
+	return
+}
+
+// fitWord returns the byte length of the longest prefix of word, cut at a
+// rune boundary, that fits in wd with the current font. At least one rune is
+// returned.
+func fitWord(f *DocPDF, word string, wd float64) int {
+	n := 0
+	for j, r := range word {
+		if j > 0 && f.GetStringWidth(word[:j+len(string(r))]) > wd {
+			break
+		}
+		n = j + len(string(r))
+	}
+	return n
+}
+
+// lineHeight returns the height of line, and the distance from its top to the
+// bottom of its lowest piece
+func (para *ParagraphType) lineHeight(spans []TextSpanType, line []paragraphPiece) (ht, bottom float64) {
+	f := para.pdf
+	k := para.spacing()
+	maxSize := 0.0
+	for _, pc := range line {
+		maxSize = math.Max(maxSize, spans[pc.span].FontSize/f.k)
+	}
+	ht, bottom = k*maxSize, k*maxSize
+	for _, pc := range line {
+		sp := spans[pc.span]
+		size := sp.FontSize / f.k
+		bottom = math.Max(bottom, (maxSize-size)*(k/2+0.3)-sp.Offset/f.k+k*size)
+	}
+	return
+}
+
+func (para *ParagraphType) spacing() float64 {
+	if para.LineSpacing > 0 {
+		return para.LineSpacing
+	}
+	return 1.25
+}
+
+// drawLine prints the pieces of a line at the position left, within a width
+// of maxWd, and advances the position below the line
+func (para *ParagraphType) drawLine(spans []TextSpanType, line []paragraphPiece, left, maxWd, ht float64, last bool) {
+	f := para.pdf
+	x := left
+	k := para.spacing()
+	maxSize := 0.0
+	lineWd := 0.0
+	spaces := 0
+	for _, pc := range line {
+		maxSize = math.Max(maxSize, spans[pc.span].FontSize/f.k)
+		lineWd += pc.w
+		if pc.space {
+			spaces++
+		}
+	}
+	extra := 0.0
+	switch para.AlignStr {
+	case "C":
+		x += (maxWd - lineWd) / 2
+	case "R":
+		x += maxWd - lineWd
+	case "J":
+		if !last && spaces > 0 {
+			extra = (maxWd - lineWd) / float64(spaces)
+		}
+	}
+	y := f.y
+	// The line is recorded as a whole in the current frame
+	flow := f.flow
+	f.flow = nil
+	for j := 0; j < len(line); j++ {
+		pc := line[j]
+		if pc.br {
+			continue
+		}
+		if extra == 0 {
+			// Print adjacent pieces of the same span at once
+			for j+1 < len(line) && line[j+1].span == pc.span && !line[j+1].br {
+				j++
+				pc.text += line[j].text
+				pc.w += line[j].w
+			}
+		}
+		sp := spans[pc.span]
+		wd := pc.w
+		if pc.space {
+			wd += extra
+		}
+		size := sp.FontSize / f.k
+		// Align the baselines of text of different sizes
+		f.SetXY(x, y+(maxSize-size)*(k/2+0.3)-sp.Offset/f.k)
+		f.SetFont(sp.FontFamily, sp.FontStyle, sp.FontSize)
+		f.SetTextColor(sp.TextColor.R, sp.TextColor.G, sp.TextColor.B)
+		if sp.Highlight != nil {
+			f.SetFillColor(sp.Highlight.R, sp.Highlight.G, sp.Highlight.B)
+		}
+		f.CellFormat(wd, k*size, pc.text, "", 0, "L", sp.Highlight != nil, sp.Link, sp.LinkStr)
+		x += wd
+	}
+	f.flow = flow
+	f.y = y
+	if f.flow != nil && !f.inHeader && !f.inFooter {
+		f.frameRow(ht)
+	}
+	f.lasth = ht
+	f.SetXY(left, y+ht)
+}
@@ -0,0 +1,931 @@
+package docpdf
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Feature masks of the shaped glyphs
+const (
+	maskGlobal uint32 = 1 << iota
+	maskIsol
+	maskFina
+	maskMedi
+	maskInit
+	maskRphf
+	maskHalf
+	maskBlwf
+	maskPstf
+)
+
+// Scripts with a dedicated shaper
+const (
+	scriptCommon = iota
+	scriptLatin
+	scriptGreek
+	scriptCyrillic
+	scriptArabic
+	scriptHebrew
+	scriptThai
+	scriptLao
+	scriptIndic
+	scriptOther
+)
+
+// shapedGlyph is a positioned glyph of a shaped line, in font units.
+type shapedGlyph struct {
+	cid        int
+	dAdv       int // adjustment of the advance width of the glyph
+	xOff, yOff int
+	space      bool // the glyph is a word space
+}
+
+// shaperType holds the OpenType layout tables of a UTF-8 font, and the
+// character identifiers allocated for the glyphs that have no Unicode
+// value, such as ligatures and contextual forms.
+type shaperType struct {
+	gsub, gpos *otLayout
+	gdef       *otGDEF
+	cmap       map[int]int // rune to glyph
+	glyphRune  map[int]int // glyph to the smallest rune mapped to it
+	advances   []int       // advance width of each glyph
+	upem       int
+	cw         []int       // character widths of the font definition
+	nextCID    int         // next private use character identifier
+	cidGlyphs  map[int]int // allocated character identifier to glyph
+	glyphCID   map[int]int
+	cidText    map[int]string
+	cache      map[string][]shapedGlyph
+	kern       map[[2]int]int // kern table pairs
+	kernCache  map[[2]int]int
+	nextLigID  int
+}
+
+// SetTextShaping enables or disables the shaping of text written with UTF-8
+// fonts. When shaping is enabled, the text output by Cell(), CellFormat(),
+// MultiCell(), Write() and Text() is laid out with the OpenType GSUB and GPOS
+// tables of the font: ligatures, contextual forms, Arabic joining, Indic
+// conjuncts and reordering and mark positioning are applied, and each line
+// is ordered according to the Unicode bidirectional algorithm. The base
+// direction of a line is right-to-left if RTL() is in effect, otherwise it is
+// given by its first strong character.
+//
+// Indic shaping covers the Devanagari, Bengali, Gurmukhi and Gujarati
+// scripts. Text written with core and TrueType fonts is not affected.
+func (f *DocPDF) SetTextShaping(enabled bool) {
+	f.shaping = enabled
+}
+
+// shapingActive reports whether the text written with the current font is
+// shaped.
+func (f *DocPDF) shapingActive() bool {
+	return f.shaping && f.isCurrentUTF8 && f.currentFont.utf8File != nil
+}
+
+// shaper returns the shaper of the font, loading its layout tables on first
+// use.
+func (utf *utf8FontFile) shaper() *shaperType {
+	if utf.shaperState != nil {
+		return utf.shaperState
+	}
+	data := utf.fileReader.array
+	table := func(name string) []byte {
+		td, ok := utf.tableDescriptions[name]
+		if !ok || td.position+td.size > len(data) {
+			return nil
+		}
+		return data[td.position : td.position+td.size]
+	}
+	sh := &shaperType{
+		cmap:      make(map[int]int),
+		glyphRune: make(map[int]int),
+		upem:      utf.fontElementSize,
+		cw:        utf.CharWidths,
+		nextCID:   0xE000,
+		cidGlyphs: make(map[int]int),
+		glyphCID:  make(map[int]int),
+		cidText:   make(map[int]string),
+		cache:     make(map[string][]shapedGlyph),
+		kern:      parseKernTable(table("kern")),
+		kernCache: make(map[[2]int]int),
+	}
+	if sh.upem <= 0 {
+		sh.upem = 1000
+	}
+	if t := table("GSUB"); t != nil {
+		sh.gsub = parseOTLayout(t, false)
+	}
+	if t := table("GPOS"); t != nil {
+		sh.gpos = parseOTLayout(t, true)
+	}
+	if t := table("GDEF"); t != nil {
+		sh.gdef = parseGDEF(t)
+	}
+	if pos := utf.parseCMAPTable(0); pos != 0 {
+		symbolChar := make(map[int][]int)
+		utf.generateSCCSDictionaries(pos, symbolChar, sh.cmap)
+	}
+	for r, gid := range sh.cmap {
+		if gid == 0 {
+			continue
+		}
+		if old, ok := sh.glyphRune[gid]; !ok || r < old {
+			sh.glyphRune[gid] = r
+		}
+	}
+	hhea, maxp, hmtx := otData(table("hhea")), otData(table("maxp")), otData(table("hmtx"))
+	numMetrics, numGlyphs := hhea.u16(34), maxp.u16(4)
+	sh.advances = make([]int, numGlyphs)
+	last := 0
+	for gid := range sh.advances {
+		if gid < numMetrics {
+			last = hmtx.u16(4 * gid)
+		}
+		sh.advances[gid] = last
+	}
+	utf.shaperState = sh
+	return sh
+}
+
+func (sh *shaperType) advance(gid int) int {
+	if gid >= 0 && gid < len(sh.advances) {
+		return sh.advances[gid]
+	}
+	return 0
+}
+
+// scriptOf returns the script of r, scriptCommon for characters shared by
+// several scripts.
+func scriptOf(r rune) int {
+	switch {
+	case r < 0x41, unicode.In(r, unicode.Common, unicode.Inherited):
+		return scriptCommon
+	case unicode.Is(unicode.Latin, r):
+		return scriptLatin
+	case unicode.Is(unicode.Arabic, r):
+		return scriptArabic
+	case unicode.Is(unicode.Hebrew, r):
+		return scriptHebrew
+	case unicode.Is(unicode.Greek, r):
+		return scriptGreek
+	case unicode.Is(unicode.Cyrillic, r):
+		return scriptCyrillic
+	case unicode.Is(unicode.Thai, r):
+		return scriptThai
+	case unicode.Is(unicode.Lao, r):
+		return scriptLao
+	case indicScriptOf(r) != nil:
+		return scriptIndic
+	}
+	return scriptOther
+}
+
+// scriptTag returns the OpenType script tag used for script in the font.
+func (sh *shaperType) scriptTag(script int, first rune) string {
+	var tags []string
+	switch script {
+	case scriptLatin:
+		tags = []string{"latn"}
+	case scriptGreek:
+		tags = []string{"grek"}
+	case scriptCyrillic:
+		tags = []string{"cyrl"}
+	case scriptArabic:
+		tags = []string{"arab"}
+	case scriptHebrew:
+		tags = []string{"hebr"}
+	case scriptThai:
+		tags = []string{"thai"}
+	case scriptLao:
+		tags = []string{"lao "}
+	case scriptIndic:
+		if is := indicScriptOf(first); is != nil {
+			tags = is.tags[:]
+		}
+	}
+	for _, tag := range append(tags, "DFLT", "latn") {
+		if sh.gsub.hasScript(tag) || sh.gpos.hasScript(tag) {
+			return tag
+		}
+	}
+	return "DFLT"
+}
+
+// shape lays out a line of text and returns its glyphs in visual order.
+// Pair kerning is applied if kern is true.
+func (sh *shaperType) shape(text string, rtl, kern bool) []shapedGlyph {
+	key := text
+	if rtl {
+		key = "\x00" + key
+	}
+	if kern {
+		key = "\x01" + key
+	}
+	if gl, ok := sh.cache[key]; ok {
+		return gl
+	}
+	runes := []rune(text)
+	levels, _ := bidiLevels(runes, rtl)
+	// Split the line into runs of the same level and script
+	type runType struct {
+		start, end, level, script int
+	}
+	var runs []runType
+	scripts := make([]int, len(runes))
+	cur := scriptCommon
+	for j, r := range runes {
+		if s := scriptOf(r); s != scriptCommon {
+			cur = s
+		}
+		scripts[j] = cur
+	}
+	// leading common characters take the script that follows them
+	for j := len(runes) - 2; j >= 0 && scripts[j] == scriptCommon; j-- {
+		scripts[j] = scripts[j+1]
+	}
+	for j := range runes {
+		if j == 0 || levels[j] != levels[j-1] || scripts[j] != scripts[j-1] {
+			runs = append(runs, runType{start: j, level: levels[j], script: scripts[j]})
+		}
+		runs[len(runs)-1].end = j + 1
+	}
+	runLevels := make([]int, len(runs))
+	for j, r := range runs {
+		runLevels[j] = r.level
+	}
+	var out []shapedGlyph
+	for _, ri := range bidiReorder(runLevels) {
+		r := runs[ri]
+		odd := r.level%2 == 1
+		seg := make([]rune, r.end-r.start)
+		copy(seg, runes[r.start:r.end])
+		if odd {
+			for j, c := range seg {
+				if m, ok := bidiMirror[c]; ok {
+					seg[j] = m
+				}
+			}
+		}
+		buf := sh.shapeRun(seg, r.script, odd, kern)
+		glyphs := make([]shapedGlyph, 0, len(buf))
+		for j, g := range buf {
+			if g.zwj {
+				continue
+			}
+			end := len(seg)
+			for k := j + 1; k < len(buf); k++ {
+				if buf[k].cluster != g.cluster {
+					end = buf[k].cluster
+					break
+				}
+			}
+			if end <= g.cluster {
+				end = g.cluster + 1
+			}
+			src := runes[r.start+g.cluster : r.start+end]
+			if end > len(seg) {
+				src = runes[r.start+g.cluster:]
+			}
+			glyphs = append(glyphs, shapedGlyph{
+				cid:   sh.cidFor(g.gid, src),
+				dAdv:  g.xAdv - sh.advance(g.gid),
+				xOff:  g.xOff,
+				yOff:  g.yOff,
+				space: len(src) == 1 && src[0] == ' ',
+			})
+		}
+		if odd {
+			for a, b := 0, len(glyphs)-1; a < b; a, b = a+1, b-1 {
+				glyphs[a], glyphs[b] = glyphs[b], glyphs[a]
+			}
+		}
+		out = append(out, glyphs...)
+	}
+	if len(sh.cache) > 4096 {
+		sh.cache = make(map[string][]shapedGlyph)
+	}
+	sh.cache[key] = out
+	return out
+}
+
+// cidFor returns the character identifier used to show glyph gid, which
+// renders the characters src.
+func (sh *shaperType) cidFor(gid int, src []rune) int {
+	if len(src) == 1 && src[0] < 0x10000 && sh.cmap[int(src[0])] == gid {
+		return int(src[0])
+	}
+	if r, ok := sh.glyphRune[gid]; ok && r < 0x10000 {
+		return r
+	}
+	if cid, ok := sh.glyphCID[gid]; ok {
+		return cid
+	}
+	for sh.nextCID <= 0xF8FF && sh.cmap[sh.nextCID] != 0 {
+		sh.nextCID++
+	}
+	if sh.nextCID > 0xF8FF {
+		return 0
+	}
+	cid := sh.nextCID
+	sh.nextCID++
+	sh.cidGlyphs[cid] = gid
+	sh.glyphCID[gid] = cid
+	sh.cidText[cid] = string(src)
+	w := int(math.Round(float64(sh.advance(gid)) * 1000 / float64(sh.upem)))
+	if w == 0 {
+		w = 65535
+	}
+	if cid < len(sh.cw) {
+		sh.cw[cid] = w
+	}
+	return cid
+}
+
+// shapeRun shapes a run of text of a single script and direction, in
+// logical order.
+func (sh *shaperType) shapeRun(runes []rune, script int, rtl, kern bool) []otGlyph {
+	var first rune
+	for _, r := range runes {
+		if scriptOf(r) == script {
+			first = r
+			break
+		}
+	}
+	tag := sh.scriptTag(script, first)
+	var is *indicScriptType
+	switch script {
+	case scriptThai, scriptLao:
+		runes = thaiDecompose(runes, sh.cmap)
+	case scriptIndic:
+		is = indicScriptOf(first)
+		runes = is.decompose(runes)
+	}
+	buf := make([]otGlyph, len(runes))
+	for j, r := range runes {
+		gid := sh.cmap[int(r)]
+		class := 0
+		if unicode.In(r, unicode.Mn, unicode.Me) {
+			class = otClassMark
+		} else if gid != 0 {
+			class = otClassBase
+		}
+		if sh.gdef != nil && sh.gdef.glyphClass != 0 && gid != 0 {
+			class = sh.gdef.data.classDef(sh.gdef.glyphClass, gid)
+		}
+		buf[j] = otGlyph{gid: gid, cluster: j, mask: maskGlobal, class: class, attach: -1,
+			zwj: r == 0x200C || r == 0x200D || r == 0x200B || r == 0x2060 || r == 0xFEFF}
+	}
+	global := func(features ...string) otFeatureStage {
+		st := otFeatureStage{features: features}
+		for range features {
+			st.masks = append(st.masks, maskGlobal)
+		}
+		return st
+	}
+	gposStages := []otFeatureStage{global("dist", "abvm", "blwm", "mark", "mkmk")}
+	if kern {
+		gposStages[0] = global("kern", "dist", "abvm", "blwm", "mark", "mkmk")
+	}
+	switch script {
+	case scriptArabic:
+		arabicJoin(runes, buf)
+		buf = sh.gsub.applyStages(sh.gdef, tag, []otFeatureStage{
+			global("ccmp", "locl"),
+			{features: []string{"isol"}, masks: []uint32{maskIsol}},
+			{features: []string{"fina"}, masks: []uint32{maskFina}},
+			{features: []string{"medi"}, masks: []uint32{maskMedi}},
+			{features: []string{"init"}, masks: []uint32{maskInit}},
+			global("rlig"),
+			global("calt", "liga", "clig", "rclt", "mset"),
+		}, buf, &sh.nextLigID)
+	case scriptIndic:
+		is.setup(runes, buf)
+		for _, st := range []otFeatureStage{
+			global("locl", "ccmp"), global("nukt"), global("akhn"),
+			{features: []string{"rphf"}, masks: []uint32{maskRphf}},
+			global("rkrf"),
+			{features: []string{"blwf"}, masks: []uint32{maskBlwf}},
+			{features: []string{"half"}, masks: []uint32{maskHalf}},
+			{features: []string{"pstf"}, masks: []uint32{maskPstf}},
+			global("vatu"), global("cjct"),
+		} {
+			buf = sh.gsub.applyStages(sh.gdef, tag, []otFeatureStage{st}, buf, &sh.nextLigID)
+		}
+		buf = is.finalReorder(buf)
+		buf = sh.gsub.applyStages(sh.gdef, tag, []otFeatureStage{
+			global("init", "pres", "abvs", "blws", "psts", "haln", "calt", "clig", "liga"),
+		}, buf, &sh.nextLigID)
+	default:
+		buf = sh.gsub.applyStages(sh.gdef, tag, []otFeatureStage{
+			global("ccmp", "locl", "rlig"),
+			global("calt", "clig", "liga", "rclt"),
+		}, buf, &sh.nextLigID)
+	}
+	for j := range buf {
+		buf[j].xAdv = sh.advance(buf[j].gid)
+		if buf[j].class == otClassMark || buf[j].zwj {
+			buf[j].xAdv = 0
+		}
+	}
+	buf = sh.gpos.applyStages(sh.gdef, tag, gposStages, buf, &sh.nextLigID)
+	if kern && len(sh.gpos.featureLookups(tag, "kern")) == 0 && len(sh.kern) > 0 {
+		// kern table pairs are given in visual order
+		for j := 0; j+1 < len(buf); j++ {
+			left, right := buf[j].gid, buf[j+1].gid
+			if rtl {
+				left, right = right, left
+			}
+			buf[j].xAdv += sh.kern[[2]int{left, right}]
+		}
+	}
+	// Resolve the position of attached marks
+	for i := range buf {
+		j := buf[i].attach
+		if j < 0 || j >= i {
+			continue
+		}
+		d := 0
+		if rtl {
+			for k := j + 1; k <= i; k++ {
+				d -= buf[k].xAdv
+			}
+		} else {
+			for k := j; k < i; k++ {
+				d += buf[k].xAdv
+			}
+		}
+		buf[i].xOff = buf[j].xOff + buf[i].attachDx - d
+		buf[i].yOff = buf[j].yOff + buf[i].attachDy
+	}
+	return buf
+}
+
+// arabicJoiningType returns the joining type of r: 'R' right joining, 'D'
+// dual joining, 'C' join causing, 'T' transparent or 'U' non joining.
+func arabicJoiningType(r rune) byte {
+	switch {
+	case r == 0x200D || r == 0x0640:
+		return 'C'
+	case r == 0x200C:
+		return 'U'
+	case unicode.In(r, unicode.Mn, unicode.Me) || (unicode.Is(unicode.Cf, r) && r != 0x200C):
+		return 'T'
+	case r >= 0x0622 && r <= 0x0625, r == 0x0627, r == 0x0629, r >= 0x062F && r <= 0x0632,
+		r == 0x0648, r >= 0x0671 && r <= 0x0673, r >= 0x0675 && r <= 0x0677,
+		r >= 0x0688 && r <= 0x0699, r == 0x06C0, r >= 0x06C3 && r <= 0x06CB, r == 0x06CD,
+		r == 0x06CF, r == 0x06D2, r == 0x06D3, r == 0x06D5, r == 0x06EE, r == 0x06EF,
+		r >= 0x0759 && r <= 0x075B, r == 0x076B, r == 0x076C, r == 0x0771, r == 0x0773,
+		r == 0x0774, r == 0x0778, r == 0x0779:
+		return 'R'
+	case r == 0x0620, r == 0x0626, r == 0x0628, r >= 0x062A && r <= 0x062E,
+		r >= 0x0633 && r <= 0x063F, r >= 0x0641 && r <= 0x0647, r == 0x0649, r == 0x064A,
+		r == 0x066E, r == 0x066F, r >= 0x0678 && r <= 0x0687, r >= 0x069A && r <= 0x06BF,
+		r == 0x06C1, r == 0x06C2, r == 0x06CC, r == 0x06CE, r == 0x06D0, r == 0x06D1,
+		r >= 0x06FA && r <= 0x06FC, r == 0x06FF, r >= 0x0750 && r <= 0x077F:
+		return 'D'
+	}
+	return 'U'
+}
+
+// arabicJoin sets the isol, fina, medi and init masks of the glyphs of an
+// Arabic run according to the joining behavior of the characters.
+func arabicJoin(runes []rune, buf []otGlyph) {
+	types := make([]byte, len(runes))
+	for j, r := range runes {
+		types[j] = arabicJoiningType(r)
+	}
+	neighbor := func(j, step int) byte {
+		for k := j + step; k >= 0 && k < len(types); k += step {
+			if types[k] != 'T' {
+				return types[k]
+			}
+		}
+		return 'U'
+	}
+	for j, t := range types {
+		if t == 'T' || t == 'U' || t == 'C' {
+			continue
+		}
+		prev, next := neighbor(j, -1), neighbor(j, 1)
+		joinPrev := prev == 'D' || prev == 'C'
+		joinNext := t == 'D' && (next == 'D' || next == 'R' || next == 'C')
+		switch {
+		case joinPrev && joinNext:
+			buf[j].mask |= maskMedi
+		case joinPrev:
+			buf[j].mask |= maskFina
+		case joinNext:
+			buf[j].mask |= maskInit
+		default:
+			buf[j].mask |= maskIsol
+		}
+	}
+}
+
+// thaiDecompose splits SARA AM into NIKHAHIT and SARA AA, moving NIKHAHIT
+// before the preceding tone marks, if the font has both glyphs.
+func thaiDecompose(runes []rune, cmap map[int]int) []rune {
+	var out []rune
+	for _, r := range runes {
+		var nikhahit, aa rune
+		switch r {
+		case 0x0E33:
+			nikhahit, aa = 0x0E4D, 0x0E32
+		case 0x0EB3:
+			nikhahit, aa = 0x0ECD, 0x0EB2
+		}
+		if nikhahit == 0 || cmap[int(nikhahit)] == 0 || cmap[int(aa)] == 0 {
+			out = append(out, r)
+			continue
+		}
+		j := len(out)
+		for j > 0 && (out[j-1] >= 0x0E48 && out[j-1] <= 0x0E4B || out[j-1] >= 0x0EC8 && out[j-1] <= 0x0ECB) {
+			j--
+		}
+		out = append(out[:j], append([]rune{nikhahit}, out[j:]...)...)
+		out = append(out, aa)
+	}
+	return out
+}
+
+// Indic character categories
+const (
+	indicX byte = iota
+	indicC
+	indicRa
+	indicV
+	indicN
+	indicH
+	indicM
+	indicSM
+	indicZWJ
+	indicZWNJ
+)
+
+// Indic glyph positions within a syllable
+const (
+	indicPosPre byte = 1 + iota
+	indicPosBase
+	indicPosAfter
+	indicPosMatraPre
+	indicPosMatra
+	indicPosMatraPost
+	indicPosReph
+)
+
+type indicScriptType struct {
+	base    rune      // first code point of the block
+	tags    [2]string // new and old OpenType script tags
+	preBase []rune    // pre-base matras, as offsets in the block
+	reph    bool      // Ra + Halant forms a reph at the start of a syllable
+	split   map[rune][]rune
+}
+
+var indicScripts = []indicScriptType{
+	{base: 0x0900, tags: [2]string{"dev2", "deva"}, preBase: []rune{0x3F, 0x4E}, reph: true},
+	{base: 0x0980, tags: [2]string{"bng2", "beng"}, preBase: []rune{0x3F, 0x47, 0x48}, reph: true,
+		split: map[rune][]rune{0x09CB: {0x09C7, 0x09BE}, 0x09CC: {0x09C7, 0x09D7}}},
+	{base: 0x0A00, tags: [2]string{"gur2", "guru"}, preBase: []rune{0x3F}},
+	{base: 0x0A80, tags: [2]string{"gjr2", "gujr"}, preBase: []rune{0x3F}, reph: true},
+}
+
+func indicScriptOf(r rune) *indicScriptType {
+	for j := range indicScripts {
+		if is := &indicScripts[j]; r >= is.base && r < is.base+0x80 {
+			return is
+		}
+	}
+	return nil
+}
+
+// category returns the Indic category of r and, for matras, whether it is
+// a pre-base or post-base matra.
+func (is *indicScriptType) category(r rune) (cat byte, pos byte) {
+	switch r {
+	case 0x200D:
+		return indicZWJ, 0
+	case 0x200C:
+		return indicZWNJ, 0
+	}
+	if r < is.base || r >= is.base+0x80 {
+		return indicX, 0
+	}
+	o := r - is.base
+	switch {
+	case o == 0x30:
+		return indicRa, 0
+	case o >= 0x15 && o <= 0x39, o >= 0x58 && o <= 0x5F, o >= 0x78 && o <= 0x7F && is.base == 0x0900:
+		return indicC, 0
+	case o >= 0x01 && o <= 0x03, o >= 0x51 && o <= 0x54, is.base == 0x0A00 && (o == 0x70 || o == 0x71):
+		return indicSM, 0
+	case o >= 0x04 && o <= 0x14, o == 0x60, o == 0x61, o >= 0x72 && o <= 0x77 && is.base == 0x0900:
+		return indicV, 0
+	case o == 0x3C:
+		return indicN, 0
+	case o == 0x4D:
+		return indicH, 0
+	case o == 0x3A, o == 0x3B, o >= 0x3E && o <= 0x4C, o == 0x4E, o == 0x4F, o >= 0x55 && o <= 0x57, o == 0x62, o == 0x63:
+		for _, p := range is.preBase {
+			if o == p {
+				return indicM, indicPosMatraPre
+			}
+		}
+		if o == 0x3E || o == 0x40 || (o >= 0x49 && o <= 0x4C) || o == 0x3B || o == 0x4F || o == 0x57 {
+			return indicM, indicPosMatraPost
+		}
+		return indicM, indicPosMatra
+	}
+	return indicX, 0
+}
+
+// decompose splits two-part matras.
+func (is *indicScriptType) decompose(runes []rune) []rune {
+	if is == nil || is.split == nil {
+		return runes
+	}
+	var out []rune
+	for _, r := range runes {
+		if parts, ok := is.split[r]; ok {
+			out = append(out, parts...)
+		} else {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// setup finds the syllables of an Indic run, assigns the positions and the
+// feature masks of their glyphs and moves the pre-base matras to the start of
+// their syllable.
+func (is *indicScriptType) setup(runes []rune, buf []otGlyph) {
+	n := len(runes)
+	cats := make([]byte, n)
+	mpos := make([]byte, n)
+	for j, r := range runes {
+		cats[j], mpos[j] = is.category(r)
+		buf[j].cat = cats[j]
+	}
+	isCons := func(j int) bool { return j < n && (cats[j] == indicC || cats[j] == indicRa) }
+	syl := 0
+	for i := 0; i < n; {
+		start := i
+		syl++
+		j := i
+		if isCons(j) {
+			// consonant syllable: (C N? H ZW?)* C N? H?
+			for {
+				j++
+				if j < n && cats[j] == indicN {
+					j++
+				}
+				if j < n && cats[j] == indicH {
+					j++
+					if j < n && (cats[j] == indicZWJ || cats[j] == indicZWNJ) {
+						j++
+					}
+					if isCons(j) {
+						continue
+					}
+				}
+				break
+			}
+		} else if cats[j] == indicV {
+			j++
+			if j < n && cats[j] == indicN {
+				j++
+			}
+		} else {
+			j++
+		}
+		consEnd := j
+		for j < n && (cats[j] == indicM || cats[j] == indicN || cats[j] == indicH || cats[j] == indicSM) {
+			j++
+		}
+		end := j
+		for k := start; k < end; k++ {
+			buf[k].syllable = syl
+		}
+		if isCons(start) {
+			is.setupSyllable(cats, mpos, buf, start, consEnd, end)
+		}
+		i = end
+	}
+}
+
+func (is *indicScriptType) setupSyllable(cats, mpos []byte, buf []otGlyph, start, consEnd, end int) {
+	// Reph: Ra + Halant at the start of a syllable with other consonants
+	first := start
+	hasReph := is.reph && cats[start] == indicRa && start+2 < consEnd &&
+		cats[start+1] == indicH && (cats[start+2] == indicC || cats[start+2] == indicRa)
+	if hasReph {
+		buf[start].mask |= maskRphf
+		buf[start+1].mask |= maskRphf
+		buf[start].ipos = indicPosReph
+		buf[start+1].ipos = indicPosReph
+		first = start + 2
+	}
+	// The base consonant is the last consonant, unless it is a Ra with a
+	// below-base form
+	base := -1
+	for k := consEnd - 1; k >= first; k-- {
+		if cats[k] == indicC || cats[k] == indicRa {
+			if base < 0 {
+				base = k
+			}
+			if cats[k] == indicRa && k == base && k-1 > first && cats[k-1] == indicH {
+				base = -1
+				continue
+			}
+			break
+		}
+	}
+	if base < 0 {
+		base = first
+	}
+	for k := first; k < consEnd; k++ {
+		switch {
+		case k < base:
+			buf[k].mask |= maskHalf
+			buf[k].ipos = indicPosPre
+		case k == base:
+			buf[k].ipos = indicPosBase
+		default:
+			buf[k].mask |= maskBlwf | maskPstf
+			buf[k].ipos = indicPosAfter
+		}
+	}
+	for k := consEnd; k < end; k++ {
+		switch mpos[k] {
+		case indicPosMatraPre, indicPosMatraPost:
+			buf[k].ipos = mpos[k]
+		default:
+			buf[k].ipos = indicPosMatra
+		}
+	}
+	// Move the pre-base matras before the consonants, after the reph
+	var pre []otGlyph
+	var rest []otGlyph
+	for k := first; k < end; k++ {
+		if buf[k].ipos == indicPosMatraPre {
+			pre = append(pre, buf[k])
+		} else {
+			rest = append(rest, buf[k])
+		}
+	}
+	copy(buf[first:], append(pre, rest...))
+}
+
+// finalReorder moves the reph after the base consonant once the basic
+// features have been applied, and places pre-base matras after the halants
+// that did not form half forms.
+func (is *indicScriptType) finalReorder(buf []otGlyph) []otGlyph {
+	for start := 0; start < len(buf); {
+		end := start + 1
+		for end < len(buf) && buf[end].syllable == buf[start].syllable {
+			end++
+		}
+		syl := buf[start:end]
+		// A reph formed if the Ra + Halant pair became a single glyph
+		if len(syl) > 1 && syl[0].ipos == indicPosReph && syl[1].ipos != indicPosReph {
+			reph := syl[0]
+			to := len(syl)
+			for k := 1; k < len(syl); k++ {
+				if syl[k].ipos == indicPosMatraPost || syl[k].cat == indicSM {
+					to = k
+					break
+				}
+			}
+			copy(syl, syl[1:to])
+			syl[to-1] = reph
+		}
+		// Pre-base matras go after the last halant before the base
+		for k := 0; k < len(syl); k++ {
+			if syl[k].ipos != indicPosMatraPre {
+				continue
+			}
+			to := -1
+			for h := k + 1; h < len(syl) && syl[h].ipos != indicPosBase; h++ {
+				if syl[h].cat == indicH {
+					to = h
+				}
+			}
+			if to > k {
+				m := syl[k]
+				copy(syl[k:], syl[k+1:to+1])
+				syl[to] = m
+			}
+			break
+		}
+		start = end
+	}
+	return buf
+}
+
+// shapedWidth returns the width of txt shaped with the current font, in
+// thousandths of the font size.
+func (f *DocPDF) shapedWidth(txt string) int {
+	sh := f.currentFont.utf8File.shaper()
+	w := 0.0
+	for _, g := range sh.shape(txt, f.isRTL, f.kerning) {
+		w += f.glyphWidth(g.cid) + float64(g.dAdv)*1000/float64(sh.upem)
+	}
+	return int(math.Round(w))
+}
+
+// glyphWidth returns the width of the character identifier cid of the
+// current font, in thousandths of the font size.
+func (f *DocPDF) glyphWidth(cid int) float64 {
+	switch w := f.currentFont.Cw[cid]; w {
+	case 65535:
+		return 0
+	case 0:
+		return float64(f.currentFont.Desc.MissingWidth)
+	default:
+		return float64(w)
+	}
+}
+
+// shapedText returns the text showing operators of txt shaped with the
+// current font. spaceAdj is added to the width of each word space, in
+// thousandths of the font size.
+func (f *DocPDF) shapedText(txt string, spaceAdj float64) string {
+	sh := f.currentFont.utf8File.shaper()
+	glyphs := sh.shape(txt, f.isRTL, f.kerning)
+	scale := 1000 / float64(sh.upem)
+	var s fmtBuffer
+	var str []byte
+	pending, rise := 0.0, 0.0
+	s.printf("[")
+	flush := func() {
+		if len(str) > 0 {
+			s.printf("(%s)", f.escape(string(str)))
+			str = str[:0]
+		}
+	}
+	for _, g := range glyphs {
+		f.currentFont.usedRunes[g.cid] = g.cid
+		yo := math.Round(float64(g.yOff)*scale*f.fontSizePt) / 1000
+		if yo != rise {
+			flush()
+			s.printf("] TJ %.2f Ts [", yo)
+			rise = yo
+		}
+		pending -= float64(g.xOff) * scale
+		if math.Abs(pending) >= 0.005 {
+			flush()
+			s.printf(" %.2f ", pending)
+		}
+		str = append(str, byte(g.cid>>8), byte(g.cid))
+		pending = float64(g.xOff-g.dAdv) * scale
+		if g.space {
+			pending -= spaceAdj
+		}
+	}
+	flush()
+	s.printf("] TJ")
+	if rise != 0 {
+		s.printf(" 0 Ts")
+	}
+	return strings.Replace(s.String(), "[] TJ ", "", -1)
+}
+
+// toUnicodeCMap returns the ToUnicode character map of a font whose glyphs
+// without Unicode value have been given the private use identifiers of
+// cidText.
+func toUnicodeCMap(cidText map[int]string) string {
+	var s fmtBuffer
+	s.printf("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n/CIDSystemInfo\n")
+	s.printf("<</Registry (Adobe)\n/Ordering (UCS)\n/Supplement 0\n>> def\n/CMapName /Adobe-Identity-UCS def\n")
+	s.printf("/CMapType 2 def\n1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	s.printf("2 beginbfrange\n<0000> <DFFF> <0000>\n<F900> <FFFF> <F900>\nendbfrange\n")
+	cids := make([]int, 0, len(cidText))
+	for cid := range cidText {
+		cids = append(cids, cid)
+	}
+	sort.Ints(cids)
+	for len(cids) > 0 {
+		n := len(cids)
+		if n > 100 {
+			n = 100
+		}
+		s.printf("%d beginbfchar\n", n)
+		for _, cid := range cids[:n] {
+			var dst strings.Builder
+			for _, u := range []rune(cidText[cid]) {
+				if u >= 0x10000 {
+					u -= 0x10000
+					dst.WriteString(sprintf("%04X%04X", 0xD800+(u>>10), 0xDC00+(u&0x3FF)))
+				} else {
+					dst.WriteString(sprintf("%04X", u))
+				}
+			}
+			s.printf("<%04X> <%s>\n", cid, dst.String())
+		}
+		s.printf("endbfchar\n")
+		cids = cids[n:]
+	}
+	s.printf("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+	return s.String()
+}
@@ -0,0 +1,280 @@
+package docpdf
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SignatureType specifies the signer and the descriptive entries of a
+// document signature. See SetSignature() for details.
+type SignatureType struct {
+	// Signer holds the RSA or ECDSA private key matching Certificate.
+	Signer crypto.Signer
+	// Certificate is the certificate of the signer.
+	Certificate *x509.Certificate
+	// Chain holds the intermediate certificates, if any, that are embedded
+	// with the signature so that it can be validated.
+	Chain []*x509.Certificate
+	// Name of the signer; the common name of the certificate is used if
+	// empty.
+	Name string
+	// Reason, Location and ContactInfo are shown by the viewer with the
+	// signature.
+	Reason      string
+	Location    string
+	ContactInfo string
+	// SigningTime is the time the document is signed; the current time is
+	// used if it is zero.
+	SigningTime time.Time
+	// PKCS7 selects the adbe.pkcs7.detached format, which includes the
+	// signing time in the signed attributes, instead of the PAdES
+	// ETSI.CAdES.detached format.
+	PKCS7 bool
+}
+
+// signatureByteRangeLen is the room reserved for the four numbers of the
+// /ByteRange array
+const signatureByteRangeLen = 48
+
+type signatureStateType struct {
+	SignatureType
+	objNum      int
+	tm          time.Time
+	contentsLen int // bytes reserved for the CMS structure
+	byteRangeAt int // buffer offset of the /ByteRange numbers
+	contentsAt  int // buffer offset of the /Contents hexadecimal string
+}
+
+var (
+	oidData                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidSHA256               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSAEncryption        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA256      = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+// SetSignature signs the document with the key and certificate of sig when
+// it is output. A signature field is placed on the current page, see
+// SetPage() to sign on another page. If w and h are greater than zero, the
+// field is drawn on the rectangle defined by x, y, w and h with the name of
+// the signer, the signing time, the reason and the location, using the
+// current font, text color and draw color; otherwise the signature is
+// invisible.
+//
+// The signature is a CMS SignedData structure computed, with SHA-256, over
+// the whole file but the signature itself. Only one signature per document
+// is supported.
+func (f *DocPDF) SetSignature(sig SignatureType, x, y, w, h float64) {
+	if f.err != nil {
+		return
+	}
+	if f.signature != nil {
+		f.err = fmt.Errorf("document signature is already set")
+		return
+	}
+	if sig.Signer == nil || sig.Certificate == nil {
+		f.err = fmt.Errorf("document signature requires a signer and a certificate")
+		return
+	}
+	switch sig.Signer.Public().(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		f.err = fmt.Errorf("unsupported signature key type %T", sig.Signer.Public())
+		return
+	}
+	if pub, ok := sig.Signer.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(sig.Certificate.PublicKey) {
+		f.err = fmt.Errorf("signature key does not match the certificate")
+		return
+	}
+	if sig.Name == "" {
+		sig.Name = sig.Certificate.Subject.CommonName
+	}
+	st := &signatureStateType{SignatureType: sig, tm: timeOrNow(sig.SigningTime)}
+	st.contentsLen = 4096
+	for _, cert := range append([]*x509.Certificate{sig.Certificate}, sig.Chain...) {
+		st.contentsLen += len(cert.Raw)
+	}
+	fld := f.newFormField(formSignature, "Signature1", FormFieldOptions{})
+	if fld == nil {
+		return
+	}
+	f.signature = st
+	if w <= 0 || h <= 0 {
+		wd := f.addFormWidget(fld, 0, 0, 0, 0, 0)
+		wd.x, wd.y = 0, 0
+		wd.ap = []formAppearanceType{{}}
+		return
+	}
+	lines := []string{"Digitally signed by " + sig.Name,
+		"Date: " + st.tm.Format("2006-01-02 15:04:05 -07:00")}
+	if sig.Reason != "" {
+		lines = append(lines, "Reason: "+sig.Reason)
+	}
+	if sig.Location != "" {
+		lines = append(lines, "Location: "+sig.Location)
+	}
+	opts := FormFieldOptions{Border: true, Multiline: true}
+	wd := f.addFormWidget(fld, x, y, w, h, 0)
+	f.formMK(wd, opts, "")
+	wd.ap = []formAppearanceType{{content: f.formTextAppearance(wd, opts, strings.Join(lines, "\n"))}}
+}
+
+// putSignatureDict writes the signature dictionary with placeholders for
+// the byte range and the signature, which are filled in by putsignature.
+func (f *DocPDF) putSignatureDict() {
+	st := f.signature
+	subFilter := "ETSI.CAdES.detached"
+	if st.PKCS7 {
+		subFilter = "adbe.pkcs7.detached"
+	}
+	f.outf("<</Type /Sig /Filter /Adobe.PPKLite /SubFilter /%s", subFilter)
+	f.put("/ByteRange [")
+	st.byteRangeAt = f.buffer.Len()
+	f.out(strings.Repeat(" ", signatureByteRangeLen) + "]")
+	f.put("/Contents ")
+	st.contentsAt = f.buffer.Len()
+	f.out("<" + strings.Repeat("0", 2*st.contentsLen) + ">")
+	f.outf("/M %s", f.textstring("D:"+st.tm.Format("20060102150405-07'00'")))
+	f.outf("/Name %s", f.textstring(utf8toutf16(st.Name)))
+	if st.Reason != "" {
+		f.outf("/Reason %s", f.textstring(utf8toutf16(st.Reason)))
+	}
+	if st.Location != "" {
+		f.outf("/Location %s", f.textstring(utf8toutf16(st.Location)))
+	}
+	if st.ContactInfo != "" {
+		f.outf("/ContactInfo %s", f.textstring(utf8toutf16(st.ContactInfo)))
+	}
+	f.out(">>")
+}
+
+// putsignature fills in the byte range and the signature of the completed
+// document.
+func (f *DocPDF) putsignature() {
+	st := f.signature
+	if st == nil || f.err != nil {
+		return
+	}
+	buf := f.buffer.Bytes()
+	start := st.contentsAt
+	end := start + 2*st.contentsLen + 2
+	rng := sprintf("0 %d %d %d", start, end, len(buf)-end)
+	copy(buf[st.byteRangeAt:], rng)
+	h := sha256.New()
+	h.Write(buf[:start])
+	h.Write(buf[end:])
+	cms, err := st.signedData(h.Sum(nil))
+	if err != nil {
+		f.err = err
+		return
+	}
+	if len(cms) > st.contentsLen {
+		f.err = fmt.Errorf("signature of %d bytes exceeds the %d bytes reserved", len(cms), st.contentsLen)
+		return
+	}
+	hex.Encode(buf[start+1:], cms)
+}
+
+// signedData returns the DER encoded CMS SignedData, without encapsulated
+// content, of the document with the given SHA-256 digest.
+func (st *signatureStateType) signedData(digest []byte) ([]byte, error) {
+	cert := st.Certificate
+	certHash := sha256.Sum256(cert.Raw)
+	attrs := [][]byte{
+		derAttribute(oidContentType, derMarshal(oidData)),
+		derAttribute(oidMessageDigest, derTLV(0x04, digest)),
+	}
+	if st.PKCS7 {
+		attrs = append(attrs, derAttribute(oidSigningTime, derMarshal(st.tm.UTC())))
+	} else {
+		// ESS signing-certificate-v2 with the default SHA-256 hash algorithm
+		attrs = append(attrs, derAttribute(oidSigningCertificateV2,
+			derTLV(0x30, derTLV(0x30, derTLV(0x30, derTLV(0x04, certHash[:]))))))
+	}
+	// DER requires the elements of a SET OF in ascending order
+	sort.Slice(attrs, func(i, j int) bool { return string(attrs[i]) < string(attrs[j]) })
+	attrDigest := sha256.Sum256(derTLV(0x31, attrs...))
+	sig, err := st.Signer.Sign(rand.Reader, attrDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	var sigAlg []byte
+	if _, ok := st.Signer.Public().(*rsa.PublicKey); ok {
+		sigAlg = derTLV(0x30, derMarshal(oidRSAEncryption), derMarshal(asn1.NullRawValue))
+	} else {
+		sigAlg = derTLV(0x30, derMarshal(oidECDSAWithSHA256))
+	}
+	digestAlg := derTLV(0x30, derMarshal(oidSHA256))
+	signerInfo := derTLV(0x30,
+		derMarshal(1),
+		derTLV(0x30, cert.RawIssuer, derMarshal(new(big.Int).Set(cert.SerialNumber))),
+		digestAlg,
+		derTLV(0xa0, attrs...),
+		sigAlg,
+		derTLV(0x04, sig))
+	certs := [][]byte{cert.Raw}
+	for _, c := range st.Chain {
+		certs = append(certs, c.Raw)
+	}
+	signedData := derTLV(0x30,
+		derMarshal(1),
+		derTLV(0x31, digestAlg),
+		derTLV(0x30, derMarshal(oidData)),
+		derTLV(0xa0, certs...),
+		derTLV(0x31, signerInfo))
+	return derTLV(0x30, derMarshal(oidSignedData), derTLV(0xa0, signedData)), nil
+}
+
+// derAttribute returns a CMS attribute with a single value.
+func derAttribute(oid asn1.ObjectIdentifier, value []byte) []byte {
+	return derTLV(0x30, derMarshal(oid), derTLV(0x31, value))
+}
+
+// derMarshal returns the DER encoding of a value that cannot fail to
+// marshal.
+func derMarshal(v any) []byte {
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// derTLV returns the DER element with the given tag and the concatenation
+// of parts as content.
+func derTLV(tag byte, parts ...[]byte) []byte {
+	n := 0
+	for _, p := range parts {
+		n += len(p)
+	}
+	b := []byte{tag}
+	switch {
+	case n < 0x80:
+		b = append(b, byte(n))
+	case n < 0x100:
+		b = append(b, 0x81, byte(n))
+	case n < 0x10000:
+		b = append(b, 0x82, byte(n>>8), byte(n))
+	default:
+		b = append(b, 0x83, byte(n>>16), byte(n>>8), byte(n))
+	}
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
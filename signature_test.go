@@ -0,0 +1,170 @@
+package docpdf_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cdvelop/docpdf"
+)
+
+// selfSignedCert returns a certificate for the public key of signer, signed
+// by signer itself.
+func selfSignedCert(t *testing.T, signer crypto.Signer) *x509.Certificate {
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "Jane Doe", Organization: []string{"Example Corp"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, signer.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// verifySignature checks the byte range, the message digest and the
+// signature of the signed attributes of a signed PDF.
+func verifySignature(t *testing.T, doc []byte, cert *x509.Certificate, alg x509.SignatureAlgorithm) {
+	m := regexp.MustCompile(`/ByteRange \[(\d+) (\d+) (\d+) (\d+) *\]`).FindSubmatch(doc)
+	if m == nil {
+		t.Fatal("no /ByteRange in signed document")
+	}
+	var rng [4]int
+	for j := range rng {
+		rng[j], _ = strconv.Atoi(string(m[j+1]))
+	}
+	if rng[0] != 0 || rng[2]+rng[3] != len(doc) || doc[rng[1]] != '<' || doc[rng[2]-1] != '>' {
+		t.Fatalf("invalid byte range %v", rng)
+	}
+	// The CMS structure is followed by the zero padding of the placeholder
+	cms, err := hex.DecodeString(string(doc[rng[1]+1 : rng[2]-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.New()
+	h.Write(doc[:rng[1]])
+	h.Write(doc[rng[2]:])
+	digest := h.Sum(nil)
+	// ContentInfo > [0] > SignedData > signerInfos > SignerInfo
+	var contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}
+	if _, err = asn1.Unmarshal(cms, &contentInfo); err != nil {
+		t.Fatal(err)
+	}
+	var sd asn1.RawValue
+	if _, err = asn1.Unmarshal(contentInfo.Content.Bytes, &sd); err != nil {
+		t.Fatal(err)
+	}
+	var items []asn1.RawValue
+	for rest := sd.Bytes; len(rest) > 0; {
+		var v asn1.RawValue
+		if rest, err = asn1.Unmarshal(rest, &v); err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, v)
+	}
+	var si asn1.RawValue
+	if _, err = asn1.Unmarshal(items[len(items)-1].Bytes, &si); err != nil {
+		t.Fatal(err)
+	}
+	var attrs, sig []byte
+	for rest := si.Bytes; len(rest) > 0; {
+		var v asn1.RawValue
+		if rest, err = asn1.Unmarshal(rest, &v); err != nil {
+			t.Fatal(err)
+		}
+		if v.Class == asn1.ClassContextSpecific && v.Tag == 0 {
+			attrs = append([]byte{}, v.FullBytes...)
+			attrs[0] = 0x31 // signed as a SET
+		}
+		sig = v.Bytes
+	}
+	if !bytes.Contains(attrs, digest) {
+		t.Errorf("message digest does not match the byte range")
+	}
+	if err = cert.CheckSignature(alg, attrs, sig); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
+
+// Test_SetSignature signs documents with RSA and ECDSA keys, one with a
+// visible signature on the first page, and verifies the signatures.
+func Test_SetSignature(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name   string
+		signer crypto.Signer
+		alg    x509.SignatureAlgorithm
+		pkcs7  bool
+	}{
+		{"Test_SetSignature_rsa", rsaKey, x509.SHA256WithRSA, false},
+		{"Test_SetSignature_ecdsa", ecKey, x509.ECDSAWithSHA256, true},
+	} {
+		cert := selfSignedCert(t, tc.signer)
+		pdf := NewDocPdfTest()
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.AddPage()
+		pdf.MultiCell(0, 6, lorem(), "", "", false)
+		pdf.AddPage()
+		pdf.Cell(0, 10, "Second page")
+		pdf.SetPage(1)
+		pdf.SetFontSize(8)
+		sig := docpdf.SignatureType{
+			Signer:      tc.signer,
+			Certificate: cert,
+			Reason:      "Contract approval",
+			Location:    "Lisbon",
+			PKCS7:       tc.pkcs7,
+		}
+		if tc.pkcs7 {
+			pdf.SetSignature(sig, 0, 0, 0, 0)
+		} else {
+			pdf.SetSignature(sig, 120, 250, 70, 20)
+		}
+		pdf.SetSignature(sig, 0, 0, 0, 0)
+		if pdf.Err() == false {
+			t.Errorf("second signature was accepted")
+		}
+		pdf.ClearError()
+		fileStr := Filename(tc.name)
+		err = pdf.OutputFileAndClose(fileStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := os.ReadFile(fileStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifySignature(t, doc, cert, tc.alg)
+	}
+}
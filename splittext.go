@@ -12,7 +12,9 @@ import (
 // SplitText splits UTF-8 encoded text into several lines using the current
 // font. Each line has its length limited to a maximum width given by w. This
 // function can be used to determine the total height of wrapped text for
-// vertical placement purposes.
+// vertical placement purposes. With a paragraph layout set by
+// SetParagraphLayout(), the lines are those of justified text output by
+// MultiCell().
 func (f *DocPDF) SplitText(txt string, w float64) (lines []string) {
 	cw := f.currentFont.Cw
 	wmax := int(math.Ceil((w - 2*f.cMargin) * 1000 / f.fontSize))
@@ -22,6 +24,14 @@ func (f *DocPDF) SplitText(txt string, w float64) (lines []string) {
 		nb--
 	}
 	s = s[0:nb]
+	if f.paragraphLayout != nil && nb > 0 {
+		for _, paragraph := range paragraphText(s) {
+			for _, line := range f.layoutParagraph(paragraph, wmax, true) {
+				lines = append(lines, string(line.text))
+			}
+		}
+		return lines
+	}
 	sep := -1
 	i := 0
 	j := 0
@@ -30,12 +40,17 @@ func (f *DocPDF) SplitText(txt string, w float64) (lines []string) {
 	for i < nb {
 		c := s[i]
 
-		if int(c) >= len(cw) {
+		if fw, ok := f.fallbackWidth(c); ok {
+			l += fw
+		} else if int(c) >= len(cw) {
 			// Decimal representation of c is greater than the font width's array size so it can't be used as index.
 			l += cw[f.currentFont.Desc.MissingWidth]
 		} else {
 			l += cw[c]
 		}
+		if i > j {
+			l += f.kern(s[i-1], c)
+		}
 
 		if unicode.IsSpace(c) || isChinese(c) {
 			sep = i
@@ -184,5 +184,8 @@ func (f *DocPDF) spotColorPutResourceDict() {
 	for _, clr := range f.spotColorMap {
 		f.outf("/CS%d %d 0 R", clr.id, clr.objID)
 	}
+	if len(f.outputIntents) > 0 {
+		f.outf("/ICC %s", f.iccColorSpace())
+	}
 	f.out(">>")
 }
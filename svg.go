@@ -0,0 +1,1581 @@
+package docpdf
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cdvelop/docpdf/env"
+)
+
+// svgNode is an element of an SVG document, or the character data of a text
+// element if its tag is empty
+type svgNode struct {
+	tag  string
+	attr map[string]string // attributes, with the style properties applied
+	kids []*svgNode
+	text string
+}
+
+// SVGType is a scalable vector graphics image parsed with SVGParse(). Wd and
+// Ht hold the size of the image in points.
+type SVGType struct {
+	Wd, Ht float64
+	root   *svgNode
+	ids    map[string]*svgNode
+}
+
+// svgStyle holds the properties used to paint an element
+type svgStyle struct {
+	fill, stroke, color         string
+	fillOpacity, strokeOpacity  float64
+	opacity                     float64 // product of the opacity of the element and its ancestors
+	strokeWidth                 float64
+	dash                        []float64
+	dashOffset                  float64
+	lineCap, lineJoin, fillRule string
+	fontFamily                  string
+	fontSize                    float64
+	bold, italic                bool
+	textAnchor                  string
+	hidden                      bool
+}
+
+// svgRenderer holds the state of the rendering of an SVG image
+type svgRenderer struct {
+	pdf    *DocPDF
+	svg    *SVGType
+	scale  float64 // local units per SVG unit, for the precision of the output
+	vw, vh float64 // viewport extent in SVG units, for percentages
+	tr     func(string) string
+}
+
+// svgSaved holds the drawing state of the document that is restored with the
+// graphics state
+type svgSaved struct {
+	color                               struct{ draw, fill, text colorType }
+	colorFlag                           bool
+	lineWidth                           float64
+	capStyle, joinStyle                 int
+	dashArray                           []float64
+	dashPhase                           float64
+	alpha                               float64
+	blendMode                           string
+	fontFamily, fontStyle               string
+	fontSizePt, fontSize                float64
+	currentFont                         fontDefType
+	isCurrentUTF8, underline, strikeout bool
+}
+
+// svgMaxDepth limits the nesting of elements and references
+const svgMaxDepth = 64
+
+// SVGParse parses a scalable vector graphics (SVG) buffer into a descriptor
+// that can be rendered with SVGWrite(). Unlike SVGBasicParse(), the parsed
+// image keeps the structure and the styling of the document.
+//
+// The elements svg, g, a, switch, use, symbol, defs, rect, circle, ellipse,
+// line, polyline, polygon, path, text, tspan, image (with a data URL),
+// clipPath, linearGradient and radialGradient are supported, as are the
+// transform, viewBox and preserveAspectRatio attributes. Fill and stroke
+// colors, opacities, stroke widths, dashes, caps and joins, fill rules and
+// font properties are read from presentation attributes, style attributes and
+// simple style sheets whose selectors name an element type, an identifier or
+// classes. Markers, masks, patterns, filters and the opacity of gradient
+// stops are ignored.
+func SVGParse(buf []byte) (svg SVGType, err error) {
+	dec := xml.NewDecoder(bytes.NewReader(buf))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	dec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	svg.ids = make(map[string]*svgNode)
+	var stack []*svgNode
+	var all []*svgNode
+	var css strings.Builder
+	for {
+		var tok xml.Token
+		tok, err = dec.Token()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			return
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &svgNode{tag: t.Name.Local, attr: make(map[string]string)}
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+					continue
+				}
+				n.attr[a.Name.Local] = strings.TrimSpace(a.Value)
+			}
+			if id := n.attr["id"]; id != "" {
+				svg.ids[id] = n
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.kids = append(parent.kids, n)
+			} else if svg.root == nil {
+				svg.root = n
+			}
+			stack = append(stack, n)
+			all = append(all, n)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				switch n := stack[len(stack)-1]; n.tag {
+				case "text", "tspan", "textPath", "a":
+					n.kids = append(n.kids, &svgNode{text: string(t)})
+				case "style":
+					css.Write(t)
+				case "title":
+					n.text += string(t)
+				}
+			}
+		}
+	}
+	if svg.root == nil || svg.root.tag != "svg" {
+		err = fmt.Errorf("svg element not found")
+		return
+	}
+	svgApplyStyles(all, css.String())
+
+	// Size of the image, in CSS pixels of 0.75 point
+	vb, hasViewBox := svgViewBox(svg.root.attr["viewBox"])
+	wd, wdOk := svgAbsLength(svg.root.attr["width"])
+	ht, htOk := svgAbsLength(svg.root.attr["height"])
+	switch {
+	case !hasViewBox:
+		if !wdOk {
+			wd = 300
+		}
+		if !htOk {
+			ht = 150
+		}
+	case !wdOk && !htOk:
+		wd, ht = vb[2], vb[3]
+	case !wdOk:
+		wd = ht * vb[2] / vb[3]
+	case !htOk:
+		ht = wd * vb[3] / vb[2]
+	}
+	if wd <= 0 || ht <= 0 {
+		err = fmt.Errorf("unacceptable values for SVG extent: %.2f x %.2f", wd, ht)
+		return
+	}
+	svg.Wd, svg.Ht = wd*0.75, ht*0.75
+	return
+}
+
+// SVGFileParse parses a scalable vector graphics (SVG) file into a descriptor
+// that can be rendered with SVGWrite(). See SVGParse() for details.
+func SVGFileParse(svgFileStr string) (svg SVGType, err error) {
+	var buf []byte
+	buf, err = env.FileExists(svgFileStr)
+	if err == nil {
+		svg, err = SVGParse(buf)
+	}
+	return
+}
+
+// svgRule is a rule of a style sheet
+type svgRule struct {
+	tag, id     string
+	classes     []string
+	specificity int
+	decls       string
+}
+
+// svgApplyStyles applies the rules of the style sheet css and the style
+// attributes to the attributes of the nodes
+func svgApplyStyles(nodes []*svgNode, css string) {
+	var rules []svgRule
+	for {
+		start := strings.Index(css, "/*")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(css[start+2:], "*/")
+		if end < 0 {
+			css = css[:start]
+			break
+		}
+		css = css[:start] + css[start+2+end+2:]
+	}
+	for _, block := range strings.Split(css, "}") {
+		selectors, decls, ok := strings.Cut(block, "{")
+		if !ok || strings.HasPrefix(strings.TrimSpace(selectors), "@") {
+			continue
+		}
+		for _, sel := range strings.Split(selectors, ",") {
+			sel = strings.TrimSpace(sel)
+			if sel == "" || strings.ContainsAny(sel, " >+~[:") {
+				// only simple selectors are supported
+				continue
+			}
+			rule := svgRule{decls: decls}
+			for len(sel) > 0 {
+				end := strings.IndexAny(sel[1:], ".#") + 1
+				if end == 0 {
+					end = len(sel)
+				}
+				switch part := sel[:end]; part[0] {
+				case '.':
+					rule.classes = append(rule.classes, part[1:])
+					rule.specificity += 10
+				case '#':
+					rule.id = part[1:]
+					rule.specificity += 100
+				default:
+					if part != "*" {
+						rule.tag = part
+						rule.specificity++
+					}
+				}
+				sel = sel[end:]
+			}
+			rules = append(rules, rule)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].specificity < rules[j].specificity })
+	for _, n := range nodes {
+		classes := strings.Fields(n.attr["class"])
+		for _, rule := range rules {
+			if rule.matches(n, classes) {
+				svgDeclarations(n.attr, rule.decls)
+			}
+		}
+		svgDeclarations(n.attr, n.attr["style"])
+	}
+}
+
+func (rule svgRule) matches(n *svgNode, classes []string) bool {
+	if (rule.tag != "" && rule.tag != n.tag) || (rule.id != "" && rule.id != n.attr["id"]) {
+		return false
+	}
+	for _, cl := range rule.classes {
+		found := false
+		for _, c := range classes {
+			found = found || c == cl
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// svgDeclarations sets the properties of the CSS declarations decls in attr
+func svgDeclarations(attr map[string]string, decls string) {
+	for _, decl := range strings.Split(decls, ";") {
+		name, val, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(val), "!important"))
+		attr[strings.TrimSpace(name)] = val
+	}
+}
+
+// SVGWrite renders the image svg parsed with SVGParse() in the rectangle of
+// width w and height h whose upper left corner is at (x, y). If w and h are
+// both zero, the image is rendered at its natural size; if one of them is
+// zero, it is computed to keep the proportions of the image. The image is
+// fitted in the rectangle according to its viewBox and preserveAspectRatio
+// attributes, and clipped to the rectangle.
+//
+// The elements are drawn with the drawing methods of the document, with
+// transformations, alpha transparency, dash patterns and gradients. Text is
+// written with the core font matching its family (serif, sans-serif or
+// monospace) unless a font of that family has been added to the document.
+// The drawing state of the document, including colors, line style and font,
+// is restored when the method returns.
+func (f *DocPDF) SVGWrite(svg *SVGType, x, y, w, h float64) {
+	if f.err != nil {
+		return
+	}
+	if svg.root == nil {
+		f.err = fmt.Errorf("SVG image has not been parsed")
+		return
+	}
+	if f.page == 0 {
+		f.err = fmt.Errorf("SVG image needs a page")
+		return
+	}
+	switch {
+	case w == 0 && h == 0:
+		w, h = svg.Wd/f.k, svg.Ht/f.k
+	case w == 0:
+		w = h * svg.Wd / svg.Ht
+	case h == 0:
+		h = w * svg.Ht / svg.Wd
+	}
+	altText := ""
+	for _, kid := range svg.root.kids {
+		if kid.tag == "title" {
+			altText = strings.TrimSpace(kid.text)
+		}
+	}
+	if f.tagAuto("Figure", altText) {
+		defer f.tagPop()
+	}
+	if f.markBegin(true) {
+		// the image is marked as a whole
+		f.tagArtifact++
+		defer func() {
+			f.tagArtifact--
+			f.markEnd()
+		}()
+	}
+
+	r := &svgRenderer{pdf: f, svg: svg}
+	vb, ok := svgViewBox(svg.root.attr["viewBox"])
+	if !ok {
+		vb = [4]float64{0, 0, svg.Wd / 0.75, svg.Ht / 0.75}
+	}
+	r.vw, r.vh = vb[2], vb[3]
+	r.scale = 1000 / math.Max(vb[2], vb[3])
+	x0, y0 := f.x, f.y
+	saved := r.begin()
+	f.ClipRect(x, y, w, h, false)
+	// the local units are mapped to the viewport
+	m := svgAspect(vb, svg.root.attr["preserveAspectRatio"], x, y, w, h)
+	f.Transform(r.pageMatrix(m.multiply(svgScale(1 / r.scale))))
+	r.children(svg.root, r.style(svg.root, r.rootStyle()), 0)
+	f.ClipEnd()
+	r.end(saved)
+	f.x, f.y = x0, y0
+}
+
+// rootStyle returns the initial values of the properties
+func (r *svgRenderer) rootStyle() svgStyle {
+	family := r.pdf.fontFamily
+	if family == "" {
+		family = "sans-serif"
+	}
+	return svgStyle{fill: "black", stroke: "none", color: "black", fillOpacity: 1, strokeOpacity: 1,
+		opacity: 1, strokeWidth: 1, lineCap: "butt", lineJoin: "miter", fillRule: "nonzero",
+		fontFamily: family, fontSize: 16, textAnchor: "start"}
+}
+
+// begin saves the graphics state, to be restored with end()
+func (r *svgRenderer) begin() (saved svgSaved) {
+	f := r.pdf
+	saved = svgSaved{color: f.color, colorFlag: f.colorFlag, lineWidth: f.lineWidth,
+		capStyle: f.capStyle, joinStyle: f.joinStyle, dashArray: f.dashArray, dashPhase: f.dashPhase,
+		alpha: f.alpha, blendMode: f.blendMode, fontFamily: f.fontFamily, fontStyle: f.fontStyle,
+		fontSizePt: f.fontSizePt, fontSize: f.fontSize, currentFont: f.currentFont,
+		isCurrentUTF8: f.isCurrentUTF8, underline: f.underline, strikeout: f.strikeout}
+	f.TransformBegin()
+	return
+}
+
+// end restores the graphics state saved by begin(), and the corresponding
+// drawing state of the document
+func (r *svgRenderer) end(saved svgSaved) {
+	f := r.pdf
+	f.TransformEnd()
+	f.color, f.colorFlag, f.lineWidth = saved.color, saved.colorFlag, saved.lineWidth
+	f.capStyle, f.joinStyle, f.dashArray, f.dashPhase = saved.capStyle, saved.joinStyle, saved.dashArray, saved.dashPhase
+	f.alpha, f.blendMode = saved.alpha, saved.blendMode
+	f.fontFamily, f.fontStyle, f.fontSizePt, f.fontSize = saved.fontFamily, saved.fontStyle, saved.fontSizePt, saved.fontSize
+	f.currentFont, f.isCurrentUTF8 = saved.currentFont, saved.isCurrentUTF8
+	f.underline, f.strikeout = saved.underline, saved.strikeout
+}
+
+// pageMatrix returns the transformation of the page space that corresponds
+// to the transformation m of the local units
+func (r *svgRenderer) pageMatrix(m TransformMatrix) TransformMatrix {
+	f := r.pdf
+	page := TransformMatrix{f.k, 0, 0, -f.k, 0, f.h * f.k}
+	local := TransformMatrix{1 / f.k, 0, 0, -1 / f.k, 0, f.h}
+	return page.multiply(m).multiply(local)
+}
+
+// localMatrix returns the transformation of the local units that corresponds
+// to the transformation m of SVG units
+func (r *svgRenderer) localMatrix(m TransformMatrix) TransformMatrix {
+	return svgScale(r.scale).multiply(m).multiply(svgScale(1 / r.scale))
+}
+
+// multiply returns the transformation that applies n, then m
+func (m TransformMatrix) multiply(n TransformMatrix) TransformMatrix {
+	return TransformMatrix{
+		A: m.A*n.A + m.C*n.B, B: m.B*n.A + m.D*n.B,
+		C: m.A*n.C + m.C*n.D, D: m.B*n.C + m.D*n.D,
+		E: m.A*n.E + m.C*n.F + m.E, F: m.B*n.E + m.D*n.F + m.F,
+	}
+}
+
+// apply returns the transformation of the point (x, y)
+func (m TransformMatrix) apply(x, y float64) (float64, float64) {
+	return m.A*x + m.C*y + m.E, m.B*x + m.D*y + m.F
+}
+
+func svgScale(s float64) TransformMatrix {
+	return TransformMatrix{A: s, D: s}
+}
+
+var svgIdentity = TransformMatrix{A: 1, D: 1}
+
+// svgViewBox parses the value of a viewBox attribute
+func svgViewBox(val string) (vb [4]float64, ok bool) {
+	nums := svgNumbers(val)
+	if len(nums) != 4 || nums[2] <= 0 || nums[3] <= 0 {
+		return
+	}
+	copy(vb[:], nums)
+	return vb, true
+}
+
+// svgAspect returns the transformation of the viewBox vb into the viewport of
+// width w and height h at (x, y), according to the value of the
+// preserveAspectRatio attribute
+func svgAspect(vb [4]float64, aspect string, x, y, w, h float64) TransformMatrix {
+	sx, sy := w/vb[2], h/vb[3]
+	align, slice := "xMidYMid", false
+	fields := strings.Fields(aspect)
+	if len(fields) > 0 && fields[0] == "defer" {
+		fields = fields[1:]
+	}
+	if len(fields) > 0 {
+		align = fields[0]
+		slice = len(fields) > 1 && fields[1] == "slice"
+	}
+	if align != "none" {
+		if slice {
+			sx = math.Max(sx, sy)
+		} else {
+			sx = math.Min(sx, sy)
+		}
+		sy = sx
+	}
+	tx, ty := x-vb[0]*sx, y-vb[1]*sy
+	switch {
+	case strings.Contains(align, "xMid"):
+		tx += (w - vb[2]*sx) / 2
+	case strings.Contains(align, "xMax"):
+		tx += w - vb[2]*sx
+	}
+	switch {
+	case strings.Contains(align, "YMid"):
+		ty += (h - vb[3]*sy) / 2
+	case strings.Contains(align, "YMax"):
+		ty += h - vb[3]*sy
+	}
+	return TransformMatrix{A: sx, D: sy, E: tx, F: ty}
+}
+
+// svgTransform parses the value of a transform attribute
+func svgTransform(val string) (m TransformMatrix) {
+	m = svgIdentity
+	for {
+		name, rest, ok := strings.Cut(val, "(")
+		if !ok {
+			return
+		}
+		args, rest, _ := strings.Cut(rest, ")")
+		val = rest
+		a := svgNumbers(args)
+		arg := func(j int, def float64) float64 {
+			if j < len(a) {
+				return a[j]
+			}
+			return def
+		}
+		t := svgIdentity
+		switch strings.Trim(strings.TrimSpace(name), ",") {
+		case "matrix":
+			if len(a) == 6 {
+				t = TransformMatrix{a[0], a[1], a[2], a[3], a[4], a[5]}
+			}
+		case "translate":
+			t.E, t.F = arg(0, 0), arg(1, 0)
+		case "scale":
+			t.A = arg(0, 1)
+			t.D = arg(1, t.A)
+		case "rotate":
+			rad := arg(0, 0) * math.Pi / 180
+			cx, cy := arg(1, 0), arg(2, 0)
+			sin, cos := math.Sincos(rad)
+			t = TransformMatrix{A: cos, B: sin, C: -sin, D: cos,
+				E: cx - cos*cx + sin*cy, F: cy - sin*cx - cos*cy}
+		case "skewX":
+			t.C = math.Tan(arg(0, 0) * math.Pi / 180)
+		case "skewY":
+			t.B = math.Tan(arg(0, 0) * math.Pi / 180)
+		}
+		m = m.multiply(t)
+	}
+}
+
+// svgScanner reads the numbers and flags of attribute values
+type svgScanner struct {
+	s   string
+	pos int
+}
+
+func (sc *svgScanner) skip() {
+	for sc.pos < len(sc.s) && strings.IndexByte(" \t\r\n,", sc.s[sc.pos]) >= 0 {
+		sc.pos++
+	}
+}
+
+func (sc *svgScanner) digits() int {
+	start := sc.pos
+	for sc.pos < len(sc.s) && sc.s[sc.pos] >= '0' && sc.s[sc.pos] <= '9' {
+		sc.pos++
+	}
+	return sc.pos - start
+}
+
+// number reads a number such as "-1.5e3"; several numbers need not be
+// separated, as in "1.5.5" or "1-2"
+func (sc *svgScanner) number() (val float64, ok bool) {
+	sc.skip()
+	start := sc.pos
+	if sc.pos < len(sc.s) && (sc.s[sc.pos] == '+' || sc.s[sc.pos] == '-') {
+		sc.pos++
+	}
+	n := sc.digits()
+	if sc.pos < len(sc.s) && sc.s[sc.pos] == '.' {
+		sc.pos++
+		n += sc.digits()
+	}
+	if n == 0 {
+		sc.pos = start
+		return
+	}
+	if sc.pos < len(sc.s) && (sc.s[sc.pos] == 'e' || sc.s[sc.pos] == 'E') {
+		mark := sc.pos
+		sc.pos++
+		if sc.pos < len(sc.s) && (sc.s[sc.pos] == '+' || sc.s[sc.pos] == '-') {
+			sc.pos++
+		}
+		if sc.digits() == 0 {
+			sc.pos = mark
+		}
+	}
+	val, err := strconv.ParseFloat(sc.s[start:sc.pos], 64)
+	return val, err == nil
+}
+
+// flag reads an arc flag, "0" or "1"
+func (sc *svgScanner) flag() (val, ok bool) {
+	sc.skip()
+	if sc.pos < len(sc.s) && (sc.s[sc.pos] == '0' || sc.s[sc.pos] == '1') {
+		sc.pos++
+		return sc.s[sc.pos-1] == '1', true
+	}
+	return
+}
+
+// svgNumbers returns the list of numbers of val
+func svgNumbers(val string) (list []float64) {
+	sc := svgScanner{s: val}
+	for {
+		v, ok := sc.number()
+		if !ok {
+			return
+		}
+		list = append(list, v)
+	}
+}
+
+// svgPath returns the segments of the path data pathStr, with the commands
+// 'M', 'L', 'C' and 'Z' in absolute coordinates. As with SVG viewers, the
+// path is rendered up to the first error.
+func svgPath(pathStr string) (segs []SVGBasicSegmentType) {
+	sc := svgScanner{s: pathStr}
+	var cmd, prev byte
+	var x, y, startX, startY, ctrlX, ctrlY float64
+	nums := func(n int) (a []float64, ok bool) {
+		a = make([]float64, n)
+		for j := range a {
+			if a[j], ok = sc.number(); !ok {
+				return
+			}
+		}
+		return a, true
+	}
+	cubic := func(x1, y1, x2, y2, x3, y3 float64) {
+		segs = append(segs, SVGBasicSegmentType{Cmd: 'C', Arg: [6]float64{x1, y1, x2, y2, x3, y3}})
+		ctrlX, ctrlY = x2, y2
+		x, y = x3, y3
+	}
+	for {
+		sc.skip()
+		if sc.pos >= len(sc.s) {
+			return
+		}
+		if c := sc.s[sc.pos]; (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			cmd = c
+			sc.pos++
+		} else if cmd == 0 {
+			return
+		}
+		rel := cmd >= 'a'
+		dx, dy := 0.0, 0.0
+		if rel {
+			dx, dy = x, y
+		}
+		upper := cmd &^ 0x20
+		var quadX, quadY float64
+		switch upper {
+		case 'Z':
+			segs = append(segs, SVGBasicSegmentType{Cmd: 'Z'})
+			x, y = startX, startY
+		case 'M', 'L', 'T':
+			a, ok := nums(2)
+			if !ok {
+				return
+			}
+			nx, ny := a[0]+dx, a[1]+dy
+			switch {
+			case upper == 'M':
+				segs = append(segs, SVGBasicSegmentType{Cmd: 'M', Arg: [6]float64{nx, ny}})
+				startX, startY = nx, ny
+				// following pairs of coordinates are lines
+				cmd = 'L' | cmd&0x20
+			case upper == 'L':
+				segs = append(segs, SVGBasicSegmentType{Cmd: 'L', Arg: [6]float64{nx, ny}})
+			default:
+				quadX, quadY = x, y
+				if prev == 'Q' || prev == 'T' {
+					quadX, quadY = 2*x-ctrlX, 2*y-ctrlY
+				}
+				cubic(x+2*(quadX-x)/3, y+2*(quadY-y)/3, nx+2*(quadX-nx)/3, ny+2*(quadY-ny)/3, nx, ny)
+				ctrlX, ctrlY = quadX, quadY
+			}
+			x, y = nx, ny
+		case 'H', 'V':
+			a, ok := nums(1)
+			if !ok {
+				return
+			}
+			if upper == 'H' {
+				x = a[0] + dx
+			} else {
+				y = a[0] + dy
+			}
+			segs = append(segs, SVGBasicSegmentType{Cmd: 'L', Arg: [6]float64{x, y}})
+		case 'C':
+			a, ok := nums(6)
+			if !ok {
+				return
+			}
+			cubic(a[0]+dx, a[1]+dy, a[2]+dx, a[3]+dy, a[4]+dx, a[5]+dy)
+		case 'S':
+			a, ok := nums(4)
+			if !ok {
+				return
+			}
+			cx, cy := x, y
+			if prev == 'C' || prev == 'S' {
+				cx, cy = 2*x-ctrlX, 2*y-ctrlY
+			}
+			cubic(cx, cy, a[0]+dx, a[1]+dy, a[2]+dx, a[3]+dy)
+		case 'Q':
+			a, ok := nums(4)
+			if !ok {
+				return
+			}
+			quadX, quadY = a[0]+dx, a[1]+dy
+			nx, ny := a[2]+dx, a[3]+dy
+			cubic(x+2*(quadX-x)/3, y+2*(quadY-y)/3, nx+2*(quadX-nx)/3, ny+2*(quadY-ny)/3, nx, ny)
+			ctrlX, ctrlY = quadX, quadY
+		case 'A':
+			a, ok := nums(3)
+			if !ok {
+				return
+			}
+			large, ok1 := sc.flag()
+			sweep, ok2 := sc.flag()
+			b, ok3 := nums(2)
+			if !ok1 || !ok2 || !ok3 {
+				return
+			}
+			nx, ny := b[0]+dx, b[1]+dy
+			segs = svgArc(segs, x, y, a[0], a[1], a[2], large, sweep, nx, ny)
+			x, y = nx, ny
+		default:
+			return
+		}
+		if len(segs) == 0 || segs[0].Cmd != 'M' {
+			// a path begins with a moveto
+			return nil
+		}
+		prev = upper
+	}
+}
+
+// svgArc appends to segs the cubic Bézier curves of the elliptical arc from
+// (x1, y1) to (x2, y2) with radii rx and ry, rotated by phi degrees
+func svgArc(segs []SVGBasicSegmentType, x1, y1, rx, ry, phi float64, large, sweep bool, x2, y2 float64) []SVGBasicSegmentType {
+	if x1 == x2 && y1 == y2 {
+		return segs
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		return append(segs, SVGBasicSegmentType{Cmd: 'L', Arg: [6]float64{x2, y2}})
+	}
+	sin, cos := math.Sincos(phi * math.Pi / 180)
+	// Center parameterization, see the SVG implementation notes
+	hx, hy := (x1-x2)/2, (y1-y2)/2
+	px, py := cos*hx+sin*hy, -sin*hx+cos*hy
+	if l := px*px/(rx*rx) + py*py/(ry*ry); l > 1 {
+		rx, ry = rx*math.Sqrt(l), ry*math.Sqrt(l)
+	}
+	num := rx*rx*ry*ry - rx*rx*py*py - ry*ry*px*px
+	den := rx*rx*py*py + ry*ry*px*px
+	coef := 0.0
+	if num > 0 && den > 0 {
+		coef = math.Sqrt(num / den)
+	}
+	if large == sweep {
+		coef = -coef
+	}
+	cxp, cyp := coef*rx*py/ry, -coef*ry*px/rx
+	cx, cy := cos*cxp-sin*cyp+(x1+x2)/2, sin*cxp+cos*cyp+(y1+y2)/2
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	t := angle(1, 0, (px-cxp)/rx, (py-cyp)/ry)
+	dt := angle((px-cxp)/rx, (py-cyp)/ry, (-px-cxp)/rx, (-py-cyp)/ry)
+	if !sweep && dt > 0 {
+		dt -= 2 * math.Pi
+	} else if sweep && dt < 0 {
+		dt += 2 * math.Pi
+	}
+	n := int(math.Ceil(math.Abs(dt)/(math.Pi/2) - 1e-9))
+	if n < 1 {
+		n = 1
+	}
+	d := dt / float64(n)
+	kappa := 4.0 / 3.0 * math.Tan(d/4)
+	point := func(t float64) (x, y, dx, dy float64) {
+		st, ct := math.Sincos(t)
+		ex, ey := rx*ct, ry*st
+		tx, ty := -rx*st, ry*ct
+		return cx + cos*ex - sin*ey, cy + sin*ex + cos*ey, cos*tx - sin*ty, sin*tx + cos*ty
+	}
+	for j := 0; j < n; j++ {
+		x0, y0, dx0, dy0 := point(t)
+		t += d
+		x3, y3, dx3, dy3 := point(t)
+		if j == n-1 {
+			x3, y3 = x2, y2
+		}
+		segs = append(segs, SVGBasicSegmentType{Cmd: 'C', Arg: [6]float64{
+			x0 + kappa*dx0, y0 + kappa*dy0, x3 - kappa*dx3, y3 - kappa*dy3, x3, y3}})
+	}
+	return segs
+}
+
+// svgEllipse returns the segments of an ellipse
+func svgEllipse(cx, cy, rx, ry float64) []SVGBasicSegmentType {
+	segs := []SVGBasicSegmentType{{Cmd: 'M', Arg: [6]float64{cx + rx, cy}}}
+	segs = svgArc(segs, cx+rx, cy, rx, ry, 0, false, true, cx-rx, cy)
+	segs = svgArc(segs, cx-rx, cy, rx, ry, 0, false, true, cx+rx, cy)
+	return append(segs, SVGBasicSegmentType{Cmd: 'Z'})
+}
+
+// svgUnits holds the number of CSS pixels of the absolute units of length
+var svgUnits = map[string]float64{"": 1, "px": 1, "pt": 4.0 / 3.0, "pc": 16, "mm": 96 / 25.4,
+	"cm": 96 / 2.54, "in": 96, "q": 96 / 101.6}
+
+// svgLength parses a length that may be relative to the font size em or, for
+// percentages, to ref
+func svgLength(val string, em, ref float64) (u float64, ok bool) {
+	sc := svgScanner{s: val}
+	if u, ok = sc.number(); !ok {
+		return
+	}
+	switch unit := strings.ToLower(strings.TrimSpace(val[sc.pos:])); unit {
+	case "%":
+		u *= ref / 100
+	case "em":
+		u *= em
+	case "ex":
+		u *= em / 2
+	default:
+		factor, found := svgUnits[unit]
+		if !found {
+			return 0, false
+		}
+		u *= factor
+	}
+	return u, true
+}
+
+// svgAbsLength parses a length with an absolute unit
+func svgAbsLength(val string) (u float64, ok bool) {
+	if strings.HasSuffix(val, "%") {
+		return
+	}
+	return svgLength(val, 16, 0)
+}
+
+// length returns the length of the attribute name of n, relative to the
+// viewport width, height or diagonal if dim is 'x', 'y' or 'd'
+func (r *svgRenderer) length(n *svgNode, name string, dim byte, st svgStyle, def float64) float64 {
+	if u, ok := r.lengthValue(n.attr[name], dim, st); ok {
+		return u
+	}
+	return def
+}
+
+// lengthValue parses the length val as length() does
+func (r *svgRenderer) lengthValue(val string, dim byte, st svgStyle) (float64, bool) {
+	ref := math.Hypot(r.vw, r.vh) / math.Sqrt2
+	switch dim {
+	case 'x':
+		ref = r.vw
+	case 'y':
+		ref = r.vh
+	}
+	return svgLength(val, st.fontSize, ref)
+}
+
+// svgOpacity parses an opacity, as a number or a percentage
+func svgOpacity(val string, def float64) float64 {
+	v, ok := svgLength(val, 0, 1)
+	if !ok {
+		return def
+	}
+	return math.Max(0, math.Min(1, v))
+}
+
+// style returns the style of n, which inherits the style st of its parent
+func (r *svgRenderer) style(n *svgNode, st svgStyle) svgStyle {
+	st.dash = append([]float64(nil), st.dash...)
+	for name, val := range n.attr {
+		if val == "inherit" {
+			continue
+		}
+		switch name {
+		case "fill":
+			st.fill = val
+		case "stroke":
+			st.stroke = val
+		case "color":
+			st.color = val
+		case "fill-opacity":
+			st.fillOpacity = svgOpacity(val, 1)
+		case "stroke-opacity":
+			st.strokeOpacity = svgOpacity(val, 1)
+		case "opacity":
+			st.opacity *= svgOpacity(val, 1)
+		case "fill-rule":
+			st.fillRule = val
+		case "stroke-linecap":
+			st.lineCap = val
+		case "stroke-linejoin":
+			st.lineJoin = val
+		case "stroke-dasharray":
+			st.dash = nil
+			for _, part := range strings.FieldsFunc(val, func(r rune) bool { return r == ',' || r == ' ' }) {
+				if u, ok := svgLength(part, st.fontSize, r.vw); ok && u >= 0 {
+					st.dash = append(st.dash, u)
+				}
+			}
+			if len(st.dash)%2 == 1 {
+				st.dash = append(st.dash, st.dash...)
+			}
+		case "font-family":
+			st.fontFamily = val
+		case "font-weight":
+			w, err := strconv.Atoi(val)
+			st.bold = val == "bold" || val == "bolder" || (err == nil && w >= 600)
+		case "font-style":
+			st.italic = val == "italic" || val == "oblique"
+		case "text-anchor":
+			st.textAnchor = val
+		case "visibility":
+			st.hidden = val == "hidden" || val == "collapse"
+		}
+	}
+	// The lengths depend on the font size
+	if val, ok := n.attr["font-size"]; ok {
+		if u, ok := svgLength(val, st.fontSize, st.fontSize); ok {
+			st.fontSize = u
+		}
+	}
+	if _, ok := n.attr["stroke-width"]; ok {
+		st.strokeWidth = r.length(n, "stroke-width", 'd', st, st.strokeWidth)
+	}
+	if _, ok := n.attr["stroke-dashoffset"]; ok {
+		st.dashOffset = r.length(n, "stroke-dashoffset", 'd', st, 0)
+	}
+	return st
+}
+
+// children renders the child elements of n
+func (r *svgRenderer) children(n *svgNode, st svgStyle, depth int) {
+	for _, kid := range n.kids {
+		if kid.tag != "" {
+			r.node(kid, st, depth+1)
+			if n.tag == "switch" {
+				// the first element is rendered
+				return
+			}
+		}
+	}
+}
+
+// node renders the element n
+func (r *svgRenderer) node(n *svgNode, st svgStyle, depth int) {
+	f := r.pdf
+	if depth > svgMaxDepth || f.err != nil || n.attr["display"] == "none" {
+		return
+	}
+	switch n.tag {
+	case "g", "a", "switch", "svg", "use", "rect", "circle", "ellipse", "line", "polyline",
+		"polygon", "path", "text", "image":
+	default:
+		return
+	}
+	st = r.style(n, st)
+	transform, hasTransform := n.attr["transform"]
+	clip := r.ref(n.attr["clip-path"])
+	if hasTransform || (clip != nil && clip.tag == "clipPath") || n.tag == "svg" || n.tag == "use" {
+		saved := r.begin()
+		defer r.end(saved)
+		if hasTransform {
+			f.Transform(r.pageMatrix(r.localMatrix(svgTransform(transform))))
+		}
+		if clip != nil && clip.tag == "clipPath" {
+			r.clip(clip, st, depth)
+		}
+	}
+	switch n.tag {
+	case "g", "a", "switch":
+		r.children(n, st, depth)
+	case "svg":
+		r.viewport(n, n, st, depth)
+	case "use":
+		ref := r.ref(n.attr["href"])
+		if ref == nil {
+			return
+		}
+		x, y := r.length(n, "x", 'x', st, 0), r.length(n, "y", 'y', st, 0)
+		f.Transform(r.pageMatrix(r.localMatrix(TransformMatrix{A: 1, D: 1, E: x, F: y})))
+		switch ref.tag {
+		case "symbol", "svg":
+			r.viewport(ref, n, st, depth)
+		default:
+			r.node(ref, st, depth+1)
+		}
+	case "text":
+		r.text(n, st)
+	case "image":
+		r.image(n, st)
+	default:
+		r.paint(r.shape(n, st), st)
+	}
+}
+
+// ref returns the element referenced by val, a fragment such as "#id" or a
+// functional notation such as "url(#id)"
+func (r *svgRenderer) ref(val string) *svgNode {
+	if rest, ok := strings.CutPrefix(val, "url("); ok {
+		val, _, _ = strings.Cut(rest, ")")
+		val = strings.Trim(val, `'" `)
+	}
+	if id, ok := strings.CutPrefix(val, "#"); ok {
+		return r.svg.ids[id]
+	}
+	return nil
+}
+
+// viewport renders the content of the element n, an svg or symbol element,
+// in the viewport defined by the attributes of vp, n itself or a use element
+func (r *svgRenderer) viewport(n, vp *svgNode, st svgStyle, depth int) {
+	f := r.pdf
+	x, y := 0.0, 0.0
+	if vp == n {
+		x, y = r.length(vp, "x", 'x', st, 0), r.length(vp, "y", 'y', st, 0)
+	}
+	w := r.length(vp, "width", 'x', st, r.length(n, "width", 'x', st, r.vw))
+	h := r.length(vp, "height", 'y', st, r.length(n, "height", 'y', st, r.vh))
+	if w <= 0 || h <= 0 {
+		return
+	}
+	s := r.scale
+	f.ClipRect(x*s, y*s, w*s, h*s, false)
+	defer f.ClipEnd()
+	vw, vh := r.vw, r.vh
+	defer func() { r.vw, r.vh = vw, vh }()
+	if vb, ok := svgViewBox(n.attr["viewBox"]); ok {
+		f.Transform(r.pageMatrix(r.localMatrix(svgAspect(vb, n.attr["preserveAspectRatio"], x, y, w, h))))
+		r.vw, r.vh = vb[2], vb[3]
+	} else {
+		f.Transform(r.pageMatrix(r.localMatrix(TransformMatrix{A: 1, D: 1, E: x, F: y})))
+		r.vw, r.vh = w, h
+	}
+	if n != vp {
+		st = r.style(n, st)
+	}
+	r.children(n, st, depth)
+}
+
+// shape returns the segments of the basic shape or path n
+func (r *svgRenderer) shape(n *svgNode, st svgStyle) (segs []SVGBasicSegmentType) {
+	seg := func(cmd byte, args ...float64) {
+		s := SVGBasicSegmentType{Cmd: cmd}
+		copy(s.Arg[:], args)
+		segs = append(segs, s)
+	}
+	switch n.tag {
+	case "path":
+		segs = svgPath(n.attr["d"])
+	case "rect":
+		x, y := r.length(n, "x", 'x', st, 0), r.length(n, "y", 'y', st, 0)
+		w, h := r.length(n, "width", 'x', st, 0), r.length(n, "height", 'y', st, 0)
+		if w <= 0 || h <= 0 {
+			return
+		}
+		rx, ry := r.length(n, "rx", 'x', st, -1), r.length(n, "ry", 'y', st, -1)
+		if rx < 0 {
+			rx = ry
+		}
+		if ry < 0 {
+			ry = rx
+		}
+		rx, ry = math.Max(0, math.Min(rx, w/2)), math.Max(0, math.Min(ry, h/2))
+		if rx == 0 || ry == 0 {
+			seg('M', x, y)
+			seg('L', x+w, y)
+			seg('L', x+w, y+h)
+			seg('L', x, y+h)
+			seg('Z')
+			return
+		}
+		seg('M', x+rx, y)
+		seg('L', x+w-rx, y)
+		segs = svgArc(segs, x+w-rx, y, rx, ry, 0, false, true, x+w, y+ry)
+		seg('L', x+w, y+h-ry)
+		segs = svgArc(segs, x+w, y+h-ry, rx, ry, 0, false, true, x+w-rx, y+h)
+		seg('L', x+rx, y+h)
+		segs = svgArc(segs, x+rx, y+h, rx, ry, 0, false, true, x, y+h-ry)
+		seg('L', x, y+ry)
+		segs = svgArc(segs, x, y+ry, rx, ry, 0, false, true, x+rx, y)
+		seg('Z')
+	case "circle", "ellipse":
+		cx, cy := r.length(n, "cx", 'x', st, 0), r.length(n, "cy", 'y', st, 0)
+		rx, ry := r.length(n, "r", 'd', st, 0), 0.0
+		if n.tag == "ellipse" {
+			rx, ry = r.length(n, "rx", 'x', st, 0), r.length(n, "ry", 'y', st, 0)
+		} else {
+			ry = rx
+		}
+		if rx > 0 && ry > 0 {
+			segs = svgEllipse(cx, cy, rx, ry)
+		}
+	case "line":
+		seg('M', r.length(n, "x1", 'x', st, 0), r.length(n, "y1", 'y', st, 0))
+		seg('L', r.length(n, "x2", 'x', st, 0), r.length(n, "y2", 'y', st, 0))
+	case "polyline", "polygon":
+		pts := svgNumbers(n.attr["points"])
+		for j := 0; j+1 < len(pts); j += 2 {
+			if j == 0 {
+				seg('M', pts[j], pts[j+1])
+			} else {
+				seg('L', pts[j], pts[j+1])
+			}
+		}
+		if n.tag == "polygon" && len(segs) > 0 {
+			seg('Z')
+		}
+	}
+	return
+}
+
+// paintServer returns the color or the gradient element of the fill or
+// stroke property val, or nil if nothing is painted
+func (r *svgRenderer) paintServer(val string, st svgStyle) (clr *RGBType, grad *svgNode) {
+	if strings.HasPrefix(val, "url(") {
+		ref := r.ref(val)
+		if ref != nil && (ref.tag == "linearGradient" || ref.tag == "radialGradient") {
+			return nil, ref
+		}
+		// a fallback color may follow the reference
+		_, val, _ = strings.Cut(val, ")")
+		val = strings.TrimSpace(val)
+	}
+	if val == "currentColor" {
+		val = st.color
+	}
+	if c, ok := htmlColor(strings.ToLower(val)); ok {
+		clr = &c
+	}
+	return
+}
+
+// alpha sets the opacity of the following painting operations
+func (r *svgRenderer) alpha(a float64) {
+	f := r.pdf
+	if a != f.alpha || f.blendMode != "Normal" {
+		f.SetAlpha(a, "Normal")
+	}
+}
+
+// path outputs the path of segs
+func (r *svgRenderer) path(segs []SVGBasicSegmentType) {
+	f := r.pdf
+	s := r.scale
+	for _, seg := range segs {
+		a := seg.Arg
+		switch seg.Cmd {
+		case 'M':
+			f.MoveTo(a[0]*s, a[1]*s)
+		case 'L':
+			f.LineTo(a[0]*s, a[1]*s)
+		case 'C':
+			f.CurveBezierCubicTo(a[0]*s, a[1]*s, a[2]*s, a[3]*s, a[4]*s, a[5]*s)
+		case 'Z':
+			f.ClosePath()
+		}
+	}
+}
+
+// paint fills and strokes the path of segs
+func (r *svgRenderer) paint(segs []SVGBasicSegmentType, st svgStyle) {
+	f := r.pdf
+	if st.hidden || len(segs) == 0 {
+		return
+	}
+	evenOdd := st.fillRule == "evenodd"
+	fillClr, fillGrad := r.paintServer(st.fill, st)
+	strokeClr, strokeGrad := r.paintServer(st.stroke, st)
+	fa, sa := st.fillOpacity*st.opacity, st.strokeOpacity*st.opacity
+	if fillGrad != nil {
+		fillClr = r.gradient(fillGrad, segs, evenOdd, fa)
+	}
+	if strokeGrad != nil {
+		// strokes are painted with the first color of a gradient
+		if stops := r.stops(strokeGrad, 0); len(stops) > 0 {
+			strokeClr = &stops[0].clr
+		}
+	}
+	fill := fillClr != nil
+	stroke := strokeClr != nil && st.strokeWidth > 0
+	if fill {
+		f.SetFillColor(fillClr.R, fillClr.G, fillClr.B)
+	}
+	if stroke {
+		f.SetDrawColor(strokeClr.R, strokeClr.G, strokeClr.B)
+		f.SetLineWidth(st.strokeWidth * r.scale)
+		if f.GetLineCapStyle() != st.lineCap {
+			f.SetLineCapStyle(st.lineCap)
+		}
+		if f.GetLineJoinStyle() != st.lineJoin {
+			f.SetLineJoinStyle(st.lineJoin)
+		}
+		var dash []float64
+		for _, d := range st.dash {
+			if d > 0 {
+				dash = st.dash
+			}
+		}
+		if len(dash) > 0 || len(f.dashArray) > 0 {
+			scaled := make([]float64, len(dash))
+			for j, d := range dash {
+				scaled[j] = d * r.scale
+			}
+			f.SetDashPattern(scaled, st.dashOffset*r.scale)
+		}
+	}
+	if fill && stroke && fa == sa {
+		r.alpha(fa)
+		r.path(segs)
+		f.DrawPath(strIf(evenOdd, "B*", "B"))
+		return
+	}
+	if fill {
+		r.alpha(fa)
+		r.path(segs)
+		f.DrawPath(strIf(evenOdd, "f*", "f"))
+	}
+	if stroke {
+		r.alpha(sa)
+		r.path(segs)
+		f.DrawPath("S")
+	}
+}
+
+// svgBounds returns the bounding box of the points of segs
+func svgBounds(segs []SVGBasicSegmentType) (x0, y0, x1, y1 float64) {
+	x0, y0, x1, y1 = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, seg := range segs {
+		n := map[byte]int{'M': 2, 'L': 2, 'C': 6}[seg.Cmd]
+		for j := 0; j < n; j += 2 {
+			x0, x1 = math.Min(x0, seg.Arg[j]), math.Max(x1, seg.Arg[j])
+			y0, y1 = math.Min(y0, seg.Arg[j+1]), math.Max(y1, seg.Arg[j+1])
+		}
+	}
+	return
+}
+
+// svgStop is a color of a gradient
+type svgStop struct {
+	offset float64
+	clr    RGBType
+}
+
+// gradientAttr returns the attribute name of the gradient element n, which
+// may be inherited from the gradient it references
+func (r *svgRenderer) gradientAttr(n *svgNode, name string) string {
+	for depth := 0; n != nil && depth < svgMaxDepth; depth++ {
+		if val, ok := n.attr[name]; ok {
+			return val
+		}
+		n = r.ref(n.attr["href"])
+	}
+	return ""
+}
+
+// stops returns the stops of the gradient element n, or of the gradient it
+// references if it has none
+func (r *svgRenderer) stops(n *svgNode, depth int) (stops []svgStop) {
+	if n == nil || depth > svgMaxDepth {
+		return
+	}
+	offset := 0.0
+	for _, kid := range n.kids {
+		if kid.tag != "stop" {
+			continue
+		}
+		offset = math.Max(offset, svgOpacity(kid.attr["offset"], 0))
+		clr := RGBType{}
+		if c, ok := htmlColor(strings.ToLower(kid.attr["stop-color"])); ok {
+			clr = c
+		}
+		stops = append(stops, svgStop{offset, clr})
+	}
+	if len(stops) == 0 {
+		return r.stops(r.ref(n.attr["href"]), depth+1)
+	}
+	return
+}
+
+// gradient fills the path of segs with the gradient element n. If the
+// gradient has a single color, that color is returned instead.
+func (r *svgRenderer) gradient(n *svgNode, segs []SVGBasicSegmentType, evenOdd bool, alpha float64) *RGBType {
+	f := r.pdf
+	stops := r.stops(n, 0)
+	switch len(stops) {
+	case 0:
+		return nil
+	case 1:
+		return &stops[0].clr
+	}
+	m := svgTransform(r.gradientAttr(n, "gradientTransform"))
+	bbox := r.gradientAttr(n, "gradientUnits") != "userSpaceOnUse"
+	if bbox {
+		x0, y0, x1, y1 := svgBounds(segs)
+		if x1 <= x0 || y1 <= y0 {
+			return nil
+		}
+		m = TransformMatrix{A: x1 - x0, D: y1 - y0, E: x0, F: y0}.multiply(m)
+	}
+	coord := func(name string, dim byte, def string) float64 {
+		val := r.gradientAttr(n, name)
+		if val == "" {
+			val = def
+		}
+		var u float64
+		if bbox {
+			u, _ = svgLength(val, 0, 1)
+		} else {
+			u, _ = r.lengthValue(val, dim, svgStyle{fontSize: 16})
+		}
+		return u
+	}
+	list := make([]gradientStopType, 0, len(stops)+2)
+	if stops[0].offset > 0 {
+		list = append(list, gradientStopType{0, f.rgbColorValue(stops[0].clr.R, stops[0].clr.G, stops[0].clr.B, "", "").str})
+	}
+	for _, stop := range stops {
+		list = append(list, gradientStopType{math.Min(stop.offset, 1), f.rgbColorValue(stop.clr.R, stop.clr.G, stop.clr.B, "", "").str})
+	}
+	if last := stops[len(stops)-1]; last.offset < 1 {
+		list = append(list, gradientStopType{1, f.rgbColorValue(last.clr.R, last.clr.G, last.clr.B, "", "").str})
+	}
+
+	saved := r.begin()
+	r.path(segs)
+	f.out(strIf(evenOdd, "W* n", "W n"))
+	r.alpha(alpha)
+	page := TransformMatrix{f.k, 0, 0, -f.k, 0, f.h * f.k}
+	f.Transform(page.multiply(svgScale(r.scale)).multiply(m))
+	if n.tag == "linearGradient" {
+		f.gradientStops(2, list, coord("x1", 'x', "0%"), coord("y1", 'y', "0%"),
+			coord("x2", 'x', "100%"), coord("y2", 'y', "0%"), 0)
+	} else {
+		cx, cy := coord("cx", 'x', "50%"), coord("cy", 'y', "50%")
+		fx, fy := cx, cy
+		if r.gradientAttr(n, "fx") != "" {
+			fx = coord("fx", 'x', "50%")
+		}
+		if r.gradientAttr(n, "fy") != "" {
+			fy = coord("fy", 'y', "50%")
+		}
+		f.gradientStops(3, list, fx, fy, cx, cy, coord("r", 'd', "50%"))
+	}
+	r.end(saved)
+	return nil
+}
+
+// clip intersects the clipping path with the shapes of the clipPath element
+// n, in user space units
+func (r *svgRenderer) clip(n *svgNode, st svgStyle, depth int) {
+	f := r.pdf
+	var all []SVGBasicSegmentType
+	evenOdd := false
+	base := svgTransform(n.attr["transform"])
+	for _, kid := range n.kids {
+		if kid.tag == "" || kid.attr["display"] == "none" {
+			continue
+		}
+		kst := r.style(kid, st)
+		m := base.multiply(svgTransform(kid.attr["transform"]))
+		shape := kid
+		if kid.tag == "use" {
+			if shape = r.ref(kid.attr["href"]); shape == nil {
+				continue
+			}
+			m = m.multiply(TransformMatrix{A: 1, D: 1, E: r.length(kid, "x", 'x', kst, 0), F: r.length(kid, "y", 'y', kst, 0)})
+			m = m.multiply(svgTransform(shape.attr["transform"]))
+			kst = r.style(shape, kst)
+		}
+		segs := r.shape(shape, kst)
+		for j := range segs {
+			for k := 0; k < 6; k += 2 {
+				segs[j].Arg[k], segs[j].Arg[k+1] = m.apply(segs[j].Arg[k], segs[j].Arg[k+1])
+			}
+		}
+		evenOdd = kst.fillRule == "evenodd" || shape.attr["clip-rule"] == "evenodd"
+		all = append(all, segs...)
+	}
+	if len(all) == 0 {
+		// an empty clipping path hides the element
+		f.out("0 0 0 0 re W n")
+		return
+	}
+	r.path(all)
+	f.out(strIf(evenOdd, "W* n", "W n"))
+}
+
+// svgFontFamilies maps the generic and common font families to the core fonts
+var svgFontFamilies = map[string]string{"serif": "times", "times": "times", "times new roman": "times",
+	"sans-serif": "helvetica", "arial": "helvetica", "helvetica": "helvetica", "system-ui": "helvetica",
+	"monospace": "courier", "courier": "courier", "courier new": "courier"}
+
+// font selects the font of st
+func (r *svgRenderer) font(st svgStyle) {
+	f := r.pdf
+	styleStr := ""
+	if st.bold {
+		styleStr += "B"
+	}
+	if st.italic {
+		styleStr += "I"
+	}
+	family := "helvetica"
+	for _, name := range strings.Split(st.fontFamily, ",") {
+		name = strings.ToLower(strings.Trim(strings.TrimSpace(name), `'"`))
+		if fam, ok := svgFontFamilies[name]; ok {
+			family = fam
+			break
+		}
+		if _, ok := f.fonts[fontFamilyEscape(name)+styleStr]; ok {
+			family = name
+			break
+		}
+		if _, ok := f.fonts[fontFamilyEscape(name)]; ok {
+			family, styleStr = name, ""
+			break
+		}
+	}
+	f.SetFont(family, styleStr, st.fontSize*r.scale*f.k)
+}
+
+// svgTextRun is a piece of text of a text element
+type svgTextRun struct {
+	x, y  float64
+	text  string
+	st    svgStyle
+	chunk int
+}
+
+// text renders the text element n
+func (r *svgRenderer) text(n *svgNode, st svgStyle) {
+	f := r.pdf
+	var runs []svgTextRun
+	var x, y float64
+	chunk := 0
+	space := true // leading spaces are removed
+	// only the first value of the position lists is used
+	first := func(n *svgNode, name string, dim byte, st svgStyle) (float64, bool) {
+		fields := strings.FieldsFunc(n.attr[name], func(r rune) bool { return r == ',' || r == ' ' })
+		if len(fields) == 0 {
+			return 0, false
+		}
+		return r.lengthValue(fields[0], dim, st)
+	}
+	var walk func(n *svgNode, st svgStyle)
+	walk = func(n *svgNode, st svgStyle) {
+		if u, ok := first(n, "x", 'x', st); ok {
+			x = u
+			chunk++
+		}
+		if u, ok := first(n, "y", 'y', st); ok {
+			y = u
+			chunk++
+		}
+		if u, ok := first(n, "dx", 'x', st); ok {
+			x += u
+		}
+		if u, ok := first(n, "dy", 'y', st); ok {
+			y += u
+		}
+		for _, kid := range n.kids {
+			switch kid.tag {
+			case "":
+				var b strings.Builder
+				for _, c := range strings.ReplaceAll(kid.text, "\n", "") {
+					if c == ' ' || c == '\t' || c == '\r' {
+						if space {
+							continue
+						}
+						c = ' '
+					}
+					space = c == ' '
+					b.WriteRune(c)
+				}
+				if b.Len() > 0 {
+					r.font(st)
+					runs = append(runs, svgTextRun{x, y, b.String(), st, chunk})
+					x += f.GetStringWidth(r.encode(b.String())) / r.scale
+				}
+			case "tspan", "a", "textPath":
+				if kid.attr["display"] != "none" {
+					walk(kid, r.style(kid, st))
+				}
+			}
+		}
+	}
+	walk(n, st)
+	if len(runs) == 0 {
+		return
+	}
+	last := &runs[len(runs)-1]
+	last.text = strings.TrimSuffix(last.text, " ")
+
+	// Align the chunks of text
+	for j := 0; j < len(runs); {
+		end := j
+		for end < len(runs) && runs[end].chunk == runs[j].chunk {
+			end++
+		}
+		r.font(runs[end-1].st)
+		wd := runs[end-1].x - runs[j].x + f.GetStringWidth(r.encode(runs[end-1].text))/r.scale
+		shift := 0.0
+		switch runs[j].st.textAnchor {
+		case "middle":
+			shift = -wd / 2
+		case "end":
+			shift = -wd
+		}
+		for ; j < end; j++ {
+			runs[j].x += shift
+		}
+	}
+	for _, run := range runs {
+		clr, grad := r.paintServer(run.st.fill, run.st)
+		if grad != nil {
+			if stops := r.stops(grad, 0); len(stops) > 0 {
+				clr = &stops[0].clr
+			}
+		}
+		if clr == nil || run.st.hidden || run.text == "" {
+			continue
+		}
+		r.font(run.st)
+		f.SetTextColor(clr.R, clr.G, clr.B)
+		r.alpha(run.st.fillOpacity * run.st.opacity)
+		f.Text(run.x*r.scale, run.y*r.scale, r.encode(run.text))
+	}
+}
+
+// encode translates str for the current font
+func (r *svgRenderer) encode(str string) string {
+	f := r.pdf
+	if f.isCurrentUTF8 {
+		return str
+	}
+	if r.tr == nil {
+		r.tr = f.UnicodeTranslatorFromDescriptor("")
+	}
+	return r.tr(str)
+}
+
+// image renders the image element n, whose content is a PNG, JPEG or GIF
+// data URL
+func (r *svgRenderer) image(n *svgNode, st svgStyle) {
+	f := r.pdf
+	meta, data, ok := strings.Cut(strings.TrimPrefix(n.attr["href"], "data:"), ",")
+	if !ok || st.hidden || !strings.HasSuffix(meta, ";base64") {
+		return
+	}
+	var tp string
+	switch strings.TrimSuffix(meta, ";base64") {
+	case "image/png":
+		tp = "png"
+	case "image/jpeg", "image/jpg":
+		tp = "jpg"
+	case "image/gif":
+		tp = "gif"
+	default:
+		return
+	}
+	buf, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(data), ""))
+	if err != nil {
+		f.err = err
+		return
+	}
+	name := fmt.Sprintf("svg%x", sha1.Sum(buf))
+	options := ImageOptions{ImageType: tp}
+	info := f.RegisterImageOptionsReader(name, options, bytes.NewReader(buf))
+	if f.err != nil {
+		return
+	}
+	x, y := r.length(n, "x", 'x', st, 0), r.length(n, "y", 'y', st, 0)
+	w, h := r.length(n, "width", 'x', st, info.w), r.length(n, "height", 'y', st, info.h)
+	if w <= 0 || h <= 0 {
+		return
+	}
+	m := svgAspect([4]float64{0, 0, info.w, info.h}, n.attr["preserveAspectRatio"], x, y, w, h)
+	s := r.scale
+	saved := r.begin()
+	f.ClipRect(x*s, y*s, w*s, h*s, false)
+	r.alpha(st.opacity)
+	f.ImageOptions(name, m.E*s, m.F*s, info.w*m.A*s, info.h*m.D*s, false, options, 0, "")
+	f.ClipEnd()
+	r.end(saved)
+}
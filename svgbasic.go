@@ -23,9 +23,10 @@ package docpdf
 import (
 	"encoding/xml"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
+
+	"github.com/cdvelop/docpdf/env"
 )
 
 var pathCmdSub *strings.Replacer
@@ -323,7 +324,7 @@ func SVGBasicParse(buf []byte) (sig SVGBasicType, err error) {
 // basic descriptor. The SVGBasicWrite() example demonstrates this method.
 func SVGBasicFileParse(svgFileStr string) (sig SVGBasicType, err error) {
 	var buf []byte
-	buf, err = os.ReadFile(svgFileStr)
+	buf, err = env.FileExists(svgFileStr)
 	if err == nil {
 		sig, err = SVGBasicParse(buf)
 	}
@@ -0,0 +1,600 @@
+package docpdf
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// TableColumnType describes a column of a table created with TableNew(). A
+// column with a positive Width has a fixed width in the unit of measure
+// specified in New(). Otherwise a positive Percent sizes the column as a
+// percentage of the table width. When both are zero the column width is
+// fitted automatically to its content using GetStringWidth().
+type TableColumnType struct {
+	Width    float64 // fixed width in user units
+	Percent  float64 // width as percentage (0..100) of the table width
+	AlignStr string  // default cell alignment, see CellFormat(); "L" if empty
+}
+
+// TableCellType describes a cell of a table row. ColSpan and RowSpan specify
+// the number of columns and rows covered by the cell; values less than 1 are
+// treated as 1. AlignStr, when not empty, overrides the column alignment. It
+// is composed of one horizontal ("L", "C" or "R") and one vertical ("T", "M"
+// or "B") component. The default vertical alignment is top.
+type TableCellType struct {
+	Text     string
+	ColSpan  int
+	RowSpan  int
+	AlignStr string
+}
+
+// TableType assists with the layout of tables made of multi-line cells. See
+// TableNew() to create a table that is associated with a PDF document
+// instance. The exported fields can be modified prior to calling Output().
+type TableType struct {
+	pdf    *DocPDF
+	cols   []TableColumnType
+	header [][]TableCellType
+	body   [][]TableCellType
+	// Table width in user units; zero extends the table from the current
+	// position to the right margin
+	Width float64
+	// Height of a text line in user units; zero uses 1.25 times the font size
+	LineHt float64
+	// Distance between the cell border and its text in user units
+	Padding float64
+	// Cell border, see CellFormat(); "1" draws a full grid, "" no border
+	BorderStr string
+	// Font style of header cells, see SetFont()
+	HeaderStyleStr string
+	// Header cells are painted with ClrHeader if HeaderFill is true
+	HeaderFill bool
+	ClrHeader  RGBType
+	// Every other body row is painted with ClrZebra if Zebra is true
+	Zebra    bool
+	ClrZebra RGBType
+	// Header rows are repeated at the top of each page the table continues on
+	RepeatHeader bool
+	// Rows may be split across pages; otherwise a row (or a group of rows
+	// joined by a rowspan) is moved as a whole to the next page. A single
+	// row taller than a page is split in any case.
+	SplitRows bool
+}
+
+// tableCell is a cell placed in the table grid
+type tableCell struct {
+	TableCellType
+	row, col int
+	w        float64
+	lines    []string
+	header   bool
+}
+
+// tableSection holds the placed cells and the row heights of the header or
+// of the body of a table
+type tableSection struct {
+	cells   []*tableCell
+	heights []float64
+}
+
+// TableNew returns an instance that facilitates writing a table in the
+// specified PDF file. The cols argument describes the table columns. If it is
+// omitted, the number of columns is taken from the widest row and every
+// column is sized automatically.
+//
+// By default, cells are framed, header cells are printed in bold on a light
+// gray background and header rows are repeated after automatic page breaks.
+func (f *DocPDF) TableNew(cols ...TableColumnType) (tbl TableType) {
+	tbl.pdf = f
+	tbl.cols = cols
+	tbl.Padding = f.cMargin
+	tbl.BorderStr = "1"
+	tbl.HeaderStyleStr = "B"
+	tbl.HeaderFill = true
+	tbl.ClrHeader = RGBType{R: 220, G: 220, B: 220}
+	tbl.ClrZebra = RGBType{R: 242, G: 242, B: 242}
+	tbl.RepeatHeader = true
+	return
+}
+
+// AddHeader appends a header row made of the specified cells. Header rows are
+// printed before the body rows and, if RepeatHeader is true, at the top of
+// each page the table continues on.
+func (tbl *TableType) AddHeader(cells ...TableCellType) {
+	tbl.header = append(tbl.header, cells)
+}
+
+// AddHeaderText appends a header row with one single-column cell per string.
+func (tbl *TableType) AddHeaderText(txtList ...string) {
+	tbl.AddHeader(tableCellList(txtList)...)
+}
+
+// AddRow appends a body row made of the specified cells. Positions covered by
+// a rowspan from a previous row are skipped when placing the cells.
+func (tbl *TableType) AddRow(cells ...TableCellType) {
+	tbl.body = append(tbl.body, cells)
+}
+
+// AddRowText appends a body row with one single-column cell per string.
+func (tbl *TableType) AddRowText(txtList ...string) {
+	tbl.AddRow(tableCellList(txtList)...)
+}
+
+// tableCellList returns a list of single-column cells holding txtList
+func tableCellList(txtList []string) (cells []TableCellType) {
+	cells = make([]TableCellType, len(txtList))
+	for j, txt := range txtList {
+		cells[j].Text = txt
+	}
+	return
+}
+
+// Output prints the table at the current position using the current font,
+// draw color and line width. Column widths are resolved first, then the rows
+// are laid out so that all cells of a row share the height of the tallest
+// one. Automatic page breaks are performed according to SetAutoPageBreak()
+// and SetAcceptPageBreakFunc(), so that the header and footer functions are
+// honoured. Upon method exit, the current position is left at the table's
+// left edge below the last row.
+func (tbl *TableType) Output() {
+	f := tbl.pdf
+	if f.err != nil {
+		return
+	}
+	if f.currentFont.Name == "" {
+		f.err = fmt.Errorf("font has not been set; unable to render table")
+		return
+	}
+	// Save the state modified while laying out the table
+	cMargin := f.cMargin
+	styleStr := f.fontStyle
+	if f.underline {
+		styleStr += "U"
+	}
+	if f.strikeout {
+		styleStr += "S"
+	}
+	fillR, fillG, fillB := f.GetFillColor()
+	defer func() {
+		f.cMargin = cMargin
+		f.SetFont("", styleStr, 0)
+		f.SetFillColor(fillR, fillG, fillB)
+	}()
+	f.cMargin = tbl.Padding
+	lineHt := tbl.LineHt
+	if lineHt <= 0 {
+		lineHt = f.fontSize * 1.25
+	}
+	x := f.x
+	tableWd := tbl.Width
+	if tableWd <= 0 {
+		tableWd = f.w - f.rMargin - x
+	}
+
+	head := tbl.place(tbl.header, true)
+	body := tbl.place(tbl.body, false)
+	if f.err != nil {
+		return
+	}
+	widths := tbl.columnWidths(tableWd, styleStr, head, body)
+	if f.err != nil {
+		return
+	}
+	tbl.measure(&head, widths, lineHt, styleStr)
+	tbl.measure(&body, widths, lineHt, styleStr)
+
+	// breakOk reports whether an automatic page break would occur for
+	// content of height ht starting at the current position
+	breakOk := func(ht float64) bool {
+		return f.y+ht > f.pageBreakTrigger && !f.inHeader && !f.inFooter && f.acceptPageBreak()
+	}
+	newPage := func() {
+		f.AddPageFormat(f.curOrientation, f.curPageSize)
+		f.x = x
+	}
+
+	drawRows := func(sec tableSection, r0, r1 int) {
+		tbl.drawRows(sec, r0, r1, x, widths, lineHt, styleStr)
+	}
+	headHt := sum(head.heights)
+	drawHeader := func() {
+		if len(head.heights) > 0 {
+			drawRows(head, 0, len(head.heights))
+		}
+	}
+
+	// fits reports whether content of height ht fits on a new page below the
+	// repeated header
+	fits := func(ht float64) bool {
+		room := f.pageBreakTrigger - f.tMargin
+		if tbl.RepeatHeader {
+			room -= headHt
+		}
+		return ht <= room
+	}
+
+	blocks := body.blocks()
+	firstHt := headHt
+	if len(blocks) > 0 {
+		firstBlk := blocks[0]
+		if tbl.SplitRows || !fits(sum(body.heights[firstBlk[0]:firstBlk[1]])) {
+			// Keep at least one line of the first row with the header
+			firstHt += lineHt + 2*tbl.Padding
+		} else {
+			firstHt += sum(body.heights[firstBlk[0]:firstBlk[1]])
+		}
+	}
+	if breakOk(firstHt) && f.y > f.tMargin {
+		newPage()
+	}
+	drawHeader()
+	for _, blk := range blocks {
+		if f.err != nil {
+			return
+		}
+		ht := sum(body.heights[blk[0]:blk[1]])
+		if breakOk(ht) {
+			// Rows taller than a page are split even if SplitRows is false
+			if (tbl.SplitRows || !fits(ht)) && blk[1]-blk[0] == 1 {
+				tbl.splitRow(body, blk[0], x, widths, lineHt, styleStr, func() {
+					newPage()
+					if tbl.RepeatHeader {
+						drawHeader()
+					}
+				})
+				continue
+			}
+			if !fits(ht) {
+				f.err = fmt.Errorf("table rows %d to %d joined by a rowspan do not fit on a page", blk[0]+1, blk[1])
+				return
+			}
+			newPage()
+			if tbl.RepeatHeader {
+				drawHeader()
+			}
+		}
+		drawRows(body, blk[0], blk[1])
+	}
+	f.x = x
+}
+
+// place assigns each cell of rows to its position in the table grid, taking
+// into account the positions covered by rowspans of preceding rows.
+func (tbl *TableType) place(rows [][]TableCellType, header bool) (sec tableSection) {
+	colCount := len(tbl.cols)
+	occupied := make([][]bool, len(rows))
+	use := func(r, c int) {
+		for len(occupied[r]) <= c {
+			occupied[r] = append(occupied[r], false)
+		}
+		occupied[r][c] = true
+	}
+	busy := func(r, c int) bool {
+		return c < len(occupied[r]) && occupied[r][c]
+	}
+	for r, row := range rows {
+		c := 0
+		for _, cell := range row {
+			for busy(r, c) {
+				c++
+			}
+			if cell.ColSpan < 1 {
+				cell.ColSpan = 1
+			}
+			if cell.RowSpan < 1 {
+				cell.RowSpan = 1
+			}
+			if colCount > 0 && c+cell.ColSpan > colCount {
+				cell.ColSpan = colCount - c
+				if cell.ColSpan < 1 {
+					tbl.pdf.err = fmt.Errorf("table row %d has more cells than columns", r+1)
+					return
+				}
+			}
+			if r+cell.RowSpan > len(rows) {
+				cell.RowSpan = len(rows) - r
+			}
+			for rr := r; rr < r+cell.RowSpan; rr++ {
+				for cc := c; cc < c+cell.ColSpan; cc++ {
+					use(rr, cc)
+				}
+			}
+			sec.cells = append(sec.cells, &tableCell{TableCellType: cell, row: r, col: c, header: header})
+			c += cell.ColSpan
+		}
+	}
+	sec.heights = make([]float64, len(rows))
+	return
+}
+
+// columnCount returns the number of columns of the table, either as declared
+// in TableNew() or as used by the widest row.
+func (tbl *TableType) columnCount(secs ...tableSection) (count int) {
+	if len(tbl.cols) > 0 {
+		return len(tbl.cols)
+	}
+	for _, sec := range secs {
+		for _, cell := range sec.cells {
+			if cell.col+cell.ColSpan > count {
+				count = cell.col + cell.ColSpan
+			}
+		}
+	}
+	return
+}
+
+// setStyle selects the font style of cell
+func (tbl *TableType) setStyle(cell *tableCell, styleStr string) {
+	if cell.header {
+		tbl.pdf.SetFont("", tbl.HeaderStyleStr, 0)
+	} else {
+		tbl.pdf.SetFont("", styleStr, 0)
+	}
+}
+
+// columnWidths resolves the width of each column. Fixed and percentage widths
+// are honoured; the remaining width is shared by the automatic columns
+// according to the natural (unwrapped) and minimum (longest word) widths of
+// their content.
+func (tbl *TableType) columnWidths(tableWd float64, styleStr string, secs ...tableSection) (widths []float64) {
+	f := tbl.pdf
+	count := tbl.columnCount(secs...)
+	if count == 0 {
+		f.err = fmt.Errorf("table has no columns")
+		return
+	}
+	widths = make([]float64, count)
+	natural := make([]float64, count)
+	minimum := make([]float64, count)
+	auto := make([]bool, count)
+	avail := tableWd
+	for j := range widths {
+		var col TableColumnType
+		if j < len(tbl.cols) {
+			col = tbl.cols[j]
+		}
+		switch {
+		case col.Width > 0:
+			widths[j] = col.Width
+		case col.Percent > 0:
+			widths[j] = tableWd * col.Percent / 100
+		default:
+			auto[j] = true
+			natural[j] = 2 * tbl.Padding
+			minimum[j] = 2 * tbl.Padding
+			continue
+		}
+		avail -= widths[j]
+	}
+	for _, sec := range secs {
+		for _, cell := range sec.cells {
+			if cell.ColSpan != 1 || !auto[cell.col] {
+				continue
+			}
+			tbl.setStyle(cell, styleStr)
+			for _, line := range strings.Split(cell.Text, "\n") {
+				natural[cell.col] = math.Max(natural[cell.col], f.GetStringWidth(line)+2*tbl.Padding)
+				for _, word := range strings.Fields(line) {
+					minimum[cell.col] = math.Max(minimum[cell.col], f.GetStringWidth(word)+2*tbl.Padding)
+				}
+			}
+		}
+	}
+	var natSum, minSum float64
+	for j := range widths {
+		if auto[j] {
+			natSum += natural[j]
+			minSum += minimum[j]
+		}
+	}
+	if natSum == 0 {
+		return
+	}
+	if avail <= 0 {
+		f.err = fmt.Errorf("table columns leave no room for automatically sized columns")
+		return
+	}
+	for j := range widths {
+		if !auto[j] {
+			continue
+		}
+		switch {
+		case natSum <= avail:
+			// Everything fits: stretch in proportion to the content
+			widths[j] = natural[j] * avail / natSum
+		case minSum < avail:
+			// Give each column its longest word, then share the rest
+			widths[j] = minimum[j] + (avail-minSum)*(natural[j]-minimum[j])/(natSum-minSum)
+		default:
+			widths[j] = minimum[j] * avail / minSum
+		}
+	}
+	return
+}
+
+// measure wraps the text of each cell of sec and computes the row heights so
+// that all cells of a row share the height of the tallest one. The height of
+// a cell spanning several rows is added to the last row it covers when
+// needed.
+func (tbl *TableType) measure(sec *tableSection, widths []float64, lineHt float64, styleStr string) {
+	f := tbl.pdf
+	minHt := lineHt + 2*tbl.Padding
+	for j := range sec.heights {
+		sec.heights[j] = minHt
+	}
+	for _, cell := range sec.cells {
+		cell.w = sum(widths[cell.col : cell.col+cell.ColSpan])
+		tbl.setStyle(cell, styleStr)
+		if cell.Text != "" {
+			if f.isCurrentUTF8 {
+				cell.lines = f.SplitText(cell.Text, cell.w)
+			} else {
+				for _, line := range f.SplitLines([]byte(cell.Text), cell.w) {
+					cell.lines = append(cell.lines, string(line))
+				}
+			}
+		}
+		if cell.RowSpan == 1 {
+			sec.heights[cell.row] = math.Max(sec.heights[cell.row], cell.height(lineHt, tbl.Padding))
+		}
+	}
+	for _, cell := range sec.cells {
+		if cell.RowSpan > 1 {
+			last := cell.row + cell.RowSpan - 1
+			if ht := sum(sec.heights[cell.row : last+1]); ht < cell.height(lineHt, tbl.Padding) {
+				sec.heights[last] += cell.height(lineHt, tbl.Padding) - ht
+			}
+		}
+	}
+}
+
+// height returns the height needed by the wrapped text of cell
+func (cell *tableCell) height(lineHt, padding float64) float64 {
+	return float64(max(len(cell.lines), 1))*lineHt + 2*padding
+}
+
+// blocks returns the row ranges [start, end) of sec that must be kept on the
+// same page because they are joined by rowspans.
+func (sec tableSection) blocks() (list [][2]int) {
+	ends := make([]int, len(sec.heights))
+	for j := range ends {
+		ends[j] = j + 1
+	}
+	for _, cell := range sec.cells {
+		ends[cell.row] = max(ends[cell.row], cell.row+cell.RowSpan)
+	}
+	for r := 0; r < len(ends); {
+		end := ends[r]
+		for j := r; j < end; j++ {
+			end = max(end, ends[j])
+		}
+		list = append(list, [2]int{r, end})
+		r = end
+	}
+	return
+}
+
+// drawRows prints rows r0 up to, but not including, r1 of sec at the current
+// vertical position and advances it below the last row.
+func (tbl *TableType) drawRows(sec tableSection, r0, r1 int, x float64, widths []float64,
+	lineHt float64, styleStr string) {
+	f := tbl.pdf
+	y := f.y
+	for _, cell := range sec.cells {
+		if cell.row < r0 || cell.row >= r1 {
+			continue
+		}
+		cy := y + sum(sec.heights[r0:cell.row])
+		ht := sum(sec.heights[cell.row : cell.row+cell.RowSpan])
+		tbl.drawCell(cell, cell.lines, x+sum(widths[:cell.col]), cy, ht, lineHt, styleStr)
+	}
+	f.SetXY(x, y+sum(sec.heights[r0:r1]))
+}
+
+// drawCell prints the frame, background and the specified lines of cell in
+// the rectangle of height ht with its upper left corner at (x, y).
+func (tbl *TableType) drawCell(cell *tableCell, lines []string, x, y, ht, lineHt float64, styleStr string) {
+	f := tbl.pdf
+	fill := false
+	switch {
+	case cell.header:
+		if tbl.HeaderFill {
+			fill = true
+			f.SetFillColor(tbl.ClrHeader.R, tbl.ClrHeader.G, tbl.ClrHeader.B)
+		}
+	case tbl.Zebra && cell.row%2 == 1:
+		fill = true
+		f.SetFillColor(tbl.ClrZebra.R, tbl.ClrZebra.G, tbl.ClrZebra.B)
+	}
+	// Cell content is positioned by the table itself, so automatic page
+	// breaks are suppressed while drawing
+	acceptPageBreak := f.acceptPageBreak
+	f.acceptPageBreak = func() bool { return false }
+	defer func() { f.acceptPageBreak = acceptPageBreak }()
+	f.SetXY(x, y)
+	if fill || tbl.BorderStr != "" {
+		f.CellFormat(cell.w, ht, "", tbl.BorderStr, 0, "", fill, 0, "")
+	}
+	alignStr := strings.ToUpper(cell.AlignStr)
+	if alignStr == "" && cell.col < len(tbl.cols) {
+		alignStr = strings.ToUpper(tbl.cols[cell.col].AlignStr)
+	}
+	textHt := float64(len(lines)) * lineHt
+	dy := tbl.Padding
+	switch {
+	case strings.Contains(alignStr, "M"):
+		dy = (ht - textHt) / 2
+	case strings.Contains(alignStr, "B"):
+		dy = ht - tbl.Padding - textHt
+	}
+	hAlignStr := "L"
+	switch {
+	case strings.Contains(alignStr, "C"):
+		hAlignStr = "C"
+	case strings.Contains(alignStr, "R"):
+		hAlignStr = "R"
+	}
+	tbl.setStyle(cell, styleStr)
+	for j, line := range lines {
+		f.SetXY(x, y+dy+float64(j)*lineHt)
+		f.CellFormat(cell.w, lineHt, line, "", 0, hAlignStr, false, 0, "")
+	}
+}
+
+// splitRow prints body row r across as many pages as needed, breaking the
+// cell text between lines. newPage is called before continuing on the next
+// page.
+func (tbl *TableType) splitRow(sec tableSection, r int, x float64, widths []float64,
+	lineHt float64, styleStr string, newPage func()) {
+	f := tbl.pdf
+	var cells []*tableCell
+	for _, cell := range sec.cells {
+		if cell.row == r {
+			cells = append(cells, cell)
+		}
+	}
+	done := make([]int, len(cells)) // lines already printed for each cell
+	fresh := false                  // a new page has just been started
+	for f.err == nil {
+		remaining := 0
+		for j, cell := range cells {
+			remaining = max(remaining, len(cell.lines)-done[j])
+		}
+		ht := float64(max(remaining, 1))*lineHt + 2*tbl.Padding
+		count := remaining
+		if f.y+ht > f.pageBreakTrigger {
+			count = int((f.pageBreakTrigger - f.y - 2*tbl.Padding) / lineHt)
+			if count < 1 {
+				if fresh {
+					f.err = fmt.Errorf("table row %d: no line fits on a new page below the header", r+1)
+					return
+				}
+				newPage()
+				fresh = true
+				continue
+			}
+			ht = f.pageBreakTrigger - f.y
+		}
+		y := f.y
+		for j, cell := range cells {
+			end := min(done[j]+count, len(cell.lines))
+			tbl.drawCell(cell, cell.lines[done[j]:end], x+sum(widths[:cell.col]), y, ht, lineHt, styleStr)
+			done[j] = end
+		}
+		f.SetXY(x, y+ht)
+		if count >= remaining {
+			return
+		}
+		newPage()
+		fresh = true
+	}
+}
+
+// sum returns the sum of the values in list
+func sum(list []float64) (total float64) {
+	for _, val := range list {
+		total += val
+	}
+	return
+}
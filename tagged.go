@@ -0,0 +1,287 @@
+package docpdf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// structRoles lists the standard structure types accepted by BeginTag().
+var structRoles = map[string]bool{
+	"Document": true, "Part": true, "Art": true, "Sect": true, "Div": true,
+	"BlockQuote": true, "Caption": true, "TOC": true, "TOCI": true,
+	"Index": true, "NonStruct": true, "Private": true,
+	"P": true, "H": true, "H1": true, "H2": true, "H3": true, "H4": true,
+	"H5": true, "H6": true,
+	"L": true, "LI": true, "Lbl": true, "LBody": true,
+	"Table": true, "TR": true, "TH": true, "TD": true, "THead": true,
+	"TBody": true, "TFoot": true,
+	"Span": true, "Quote": true, "Note": true, "Reference": true,
+	"BibEntry": true, "Code": true, "Link": true,
+	"Figure": true, "Formula": true, "Form": true,
+	"Artifact": true,
+}
+
+type structKidType struct {
+	elem       *structElemType // child element, or nil for marked content
+	page, mcid int
+}
+
+type structElemType struct {
+	role   string
+	alt    string
+	parent *structElemType
+	kids   []structKidType
+	objNum int
+}
+
+// SetTagged enables or disables the tagging of the document content. When
+// tagging is enabled, the text output by Cell(), CellFormat(), MultiCell(),
+// Write(), Text() and the images output by Image() and ImageOptions() are
+// enclosed in marked-content sequences that are attached to a logical
+// structure tree. This structure, together with SetLang() and SetTitle(),
+// lets screen readers and other assistive technology read the document in
+// the intended order, as required by PDF/UA.
+//
+// Content output outside of any element opened with BeginTag() is attached
+// to a paragraph (P) element created for each call, and images outside of a
+// Figure element to a Figure element with the alternate text given in
+// ImageOptions. Content of the header and footer functions, as well as cell
+// backgrounds and borders without text, is marked as artifact. Other drawings
+// are left untagged; enclose them in an Artifact element if they are
+// decorative.
+//
+// Tagging should be enabled before the first page is added.
+func (f *DocPDF) SetTagged(tagged bool) {
+	f.tagged = tagged
+	if tagged {
+		if f.structRoot == nil {
+			f.structRoot = &structElemType{role: "Document"}
+			f.structParents = make(map[int][]*structElemType)
+		}
+		if f.pdfVersion < pdfVers1_4 {
+			f.pdfVersion = pdfVers1_4
+		}
+	}
+}
+
+// BeginTag opens a structure element with the given role, which must be one
+// of the standard structure types, for instance "P", "H1" to "H6", "L",
+// "LI", "Table", "TR", "TH", "TD" or "Figure". The element is nested within
+// the element currently open, if any, and receives the content output until
+// the matching call to EndTag(). altText is the alternate description of the
+// element, required for figures, or empty.
+//
+// The "Artifact" role marks everything drawn until EndTag() as artifact, that
+// is content such as page decorations that is not part of the document
+// structure.
+//
+// BeginTag has no effect unless tagging is enabled with SetTagged().
+func (f *DocPDF) BeginTag(role, altText string) {
+	if f.err != nil || !f.tagged {
+		return
+	}
+	if !structRoles[role] {
+		f.err = fmt.Errorf("unknown structure type %q", role)
+		return
+	}
+	if role == "Artifact" {
+		if f.state != 2 {
+			f.err = fmt.Errorf("artifact requires a current page")
+			return
+		}
+		f.tagStack = append(f.tagStack, &structElemType{role: role})
+		f.artifactBegin()
+		return
+	}
+	f.tagPush(role, altText)
+}
+
+// EndTag closes the structure element opened by the last call to BeginTag().
+func (f *DocPDF) EndTag() {
+	if f.err != nil || !f.tagged {
+		return
+	}
+	if len(f.tagStack) == 0 {
+		f.err = fmt.Errorf("EndTag called without matching BeginTag")
+		return
+	}
+	if f.tagStack[len(f.tagStack)-1].role == "Artifact" {
+		f.artifactEnd()
+	}
+	f.tagStack = f.tagStack[:len(f.tagStack)-1]
+}
+
+// tagPush opens a structure element as child of the innermost open element.
+func (f *DocPDF) tagPush(role, altText string) {
+	parent := f.structRoot
+	if n := len(f.tagStack); n > 0 {
+		parent = f.tagStack[n-1]
+	}
+	e := &structElemType{role: role, alt: altText, parent: parent}
+	parent.kids = append(parent.kids, structKidType{elem: e})
+	f.tagStack = append(f.tagStack, e)
+}
+
+// tagAuto opens an element with the given role for the content about to be
+// output if required: a paragraph when no element is open, a figure when
+// the innermost element is not a figure. It returns true if an element has
+// been opened, in which case the caller closes it with tagPop().
+func (f *DocPDF) tagAuto(role, altText string) bool {
+	if !f.tagged || f.tagArtifact > 0 || f.err != nil {
+		return false
+	}
+	n := len(f.tagStack)
+	if n > 0 && (role != "Figure" || f.tagStack[n-1].role == "Figure") {
+		return false
+	}
+	f.tagPush(role, altText)
+	return true
+}
+
+func (f *DocPDF) tagPop() {
+	f.tagStack = f.tagStack[:len(f.tagStack)-1]
+}
+
+// markBegin opens a marked-content sequence attached to the innermost open
+// element for the content about to be output, or an artifact sequence if
+// content is false. It returns true if a sequence has been opened, in which
+// case the caller closes it with markEnd().
+func (f *DocPDF) markBegin(content bool) bool {
+	if !f.tagged || f.tagArtifact > 0 || f.state != 2 {
+		return false
+	}
+	n := len(f.tagStack)
+	if !content || n == 0 {
+		f.out("/Artifact BMC")
+		return true
+	}
+	e := f.tagStack[n-1]
+	mcid := len(f.structParents[f.page])
+	f.structParents[f.page] = append(f.structParents[f.page], e)
+	e.kids = append(e.kids, structKidType{page: f.page, mcid: mcid})
+	f.outf("/%s <</MCID %d>> BDC", e.role, mcid)
+	return true
+}
+
+func (f *DocPDF) markEnd() {
+	f.out("EMC")
+}
+
+// artifactBegin opens an artifact sequence, used for header, footer and
+// explicit artifact content.
+func (f *DocPDF) artifactBegin() {
+	if f.tagged {
+		f.tagArtifact++
+		f.out("/Artifact BMC")
+	}
+}
+
+func (f *DocPDF) artifactEnd() {
+	if f.tagged && f.tagArtifact > 0 {
+		f.tagArtifact--
+		f.out("EMC")
+	}
+}
+
+// tagEndPage closes the artifact sequences open at the end of a page, which
+// are reopened on the next page by tagBeginPage.
+func (f *DocPDF) tagEndPage() {
+	for j := 0; j < f.tagArtifact; j++ {
+		f.out("EMC")
+	}
+}
+
+func (f *DocPDF) tagBeginPage() {
+	for j := 0; j < f.tagArtifact; j++ {
+		f.out("/Artifact BMC")
+	}
+}
+
+// putStructTree writes the structure elements, the structure tree root and
+// the parent tree. pageObjs holds the object numbers of the pages.
+func (f *DocPDF) putStructTree(pageObjs []int) {
+	if !f.tagged {
+		return
+	}
+	if len(f.tagStack) > 0 {
+		f.err = fmt.Errorf("%d structure elements are not closed", len(f.tagStack))
+		return
+	}
+	// Number the objects: root, elements in document order, parent tree
+	f.nStructTreeRoot = f.n + 1
+	var elems []*structElemType
+	var walk func(e *structElemType)
+	walk = func(e *structElemType) {
+		e.objNum = f.nStructTreeRoot + 1 + len(elems)
+		elems = append(elems, e)
+		for _, k := range e.kids {
+			if k.elem != nil {
+				walk(k.elem)
+			}
+		}
+	}
+	walk(f.structRoot)
+	nParentTree := f.nStructTreeRoot + 1 + len(elems)
+	f.newobj()
+	f.outf("<</Type /StructTreeRoot /K %d 0 R /ParentTree %d 0 R /ParentTreeNextKey %d>>",
+		f.structRoot.objNum, nParentTree, f.page)
+	f.out("endobj")
+	for _, e := range elems {
+		f.newobj()
+		parent := f.nStructTreeRoot
+		if e.parent != nil {
+			parent = e.parent.objNum
+		}
+		var s fmtBuffer
+		s.printf("<</Type /StructElem /S /%s /P %d 0 R", e.role, parent)
+		pg := 0
+		for _, k := range e.kids {
+			if k.elem == nil {
+				pg = k.page
+				s.printf(" /Pg %d 0 R", pageObjs[pg])
+				break
+			}
+		}
+		if e.alt != "" {
+			s.printf(" /Alt %s", f.textstring(utf8toutf16(e.alt)))
+		}
+		s.printf(" /K [")
+		for _, k := range e.kids {
+			switch {
+			case k.elem != nil:
+				s.printf("%d 0 R ", k.elem.objNum)
+			case k.page == pg:
+				s.printf("%d ", k.mcid)
+			default:
+				s.printf("<</Type /MCR /Pg %d 0 R /MCID %d>> ", pageObjs[k.page], k.mcid)
+			}
+		}
+		f.out(strings.TrimSuffix(s.String(), " ") + "]>>")
+		f.out("endobj")
+	}
+	f.newobj()
+	var nums fmtBuffer
+	nums.printf("<</Nums [")
+	for n := 1; n <= f.page; n++ {
+		if len(f.structParents[n]) == 0 {
+			continue
+		}
+		nums.printf("%d [", n-1)
+		for _, e := range f.structParents[n] {
+			nums.printf("%d 0 R ", e.objNum)
+		}
+		nums.printf("] ")
+	}
+	f.out(nums.String() + "]>>")
+	f.out("endobj")
+}
+
+// putStructTreeCatalog writes the catalog entries of a tagged document.
+func (f *DocPDF) putStructTreeCatalog() {
+	if !f.tagged {
+		return
+	}
+	f.out("/MarkInfo <</Marked true>>")
+	f.outf("/StructTreeRoot %d 0 R", f.nStructTreeRoot)
+	f.out("/ViewerPreferences <</DisplayDocTitle true>>")
+}
@@ -61,8 +61,22 @@ func (f *DocPDF) UseTemplateScaled(t Template, corner PointType, size SizeType)
 		return
 	}
 
-	// make a note of the fact that we actually use this template, as well as any other templates,
-	// images or fonts it uses
+	f.templateUse(t)
+
+	// template data
+	_, templateSize := t.Size()
+	scaleX := size.Wd / templateSize.Wd
+	scaleY := size.Ht / templateSize.Ht
+	tx := corner.X * f.k
+	ty := (f.curPageSize.Ht - corner.Y - size.Ht) * f.k
+
+	f.outf("q %.4f 0 0 %.4f %.4f %.4f cm", scaleX, scaleY, tx, ty) // Translate
+	f.outf("/TPL%s Do Q", t.ID())
+}
+
+// templateUse makes a note of the fact that we actually use this template, as
+// well as any other templates, images or fonts it uses
+func (f *DocPDF) templateUse(t Template) {
 	f.templates[t.ID()] = t
 	for _, tt := range t.Templates() {
 		f.templates[tt.ID()] = tt
@@ -82,16 +96,6 @@ func (f *DocPDF) UseTemplateScaled(t Template, corner PointType, size SizeType)
 		name = sprintf("t%s-%s", t.ID(), name)
 		f.images[name] = ti
 	}
-
-	// template data
-	_, templateSize := t.Size()
-	scaleX := size.Wd / templateSize.Wd
-	scaleY := size.Ht / templateSize.Ht
-	tx := corner.X * f.k
-	ty := (f.curPageSize.Ht - corner.Y - size.Ht) * f.k
-
-	f.outf("q %.4f 0 0 %.4f %.4f %.4f cm", scaleX, scaleY, tx, ty) // Translate
-	f.outf("/TPL%s Do Q", t.ID())
 }
 
 // Template is an object that can be written to, then used and re-used any number of times within a document.
@@ -154,6 +158,7 @@ func (f *DocPDF) putTemplates() {
 		f.out("<</ProcSet [/PDF /Text /ImageB /ImageC /ImageI]")
 
 		f.templateFontCatalog()
+		f.spotColorPutResourceDict()
 
 		tImages := t.Images()
 		tTemplates := t.Templates()
@@ -194,7 +199,7 @@ func (f *DocPDF) putTemplates() {
 			mem = xmem.compress(buffer)
 			buffer = mem.bytes()
 		}
-		f.outf("/Length %d >>", len(buffer))
+		f.outf("/Length %d >>", f.protect.encryptedLen(len(buffer)))
 		f.putstream(buffer)
 		f.out("endobj")
 		if mem != nil {
@@ -290,9 +290,17 @@ func (t *Tpl) loadParamsFromFpdf(f *DocPDF) {
 	t.DocPDF.capStyle = f.capStyle
 	t.DocPDF.joinStyle = f.joinStyle
 
+	t.DocPDF.cmykConversion = f.cmykConversion
+	t.DocPDF.iccColors = f.iccColors
+	t.DocPDF.outputIntents = f.outputIntents
 	t.DocPDF.color.draw = f.color.draw
-	t.DocPDF.color.fill = f.color.fill
-	t.DocPDF.color.text = f.color.text
+	// Patterns are not part of the resources of templates
+	if f.color.fill.mode != colorModePattern {
+		t.DocPDF.color.fill = f.color.fill
+	}
+	if f.color.text.mode != colorModePattern {
+		t.DocPDF.color.text = f.color.text
+	}
 
 	t.DocPDF.fonts = f.fonts
 	t.DocPDF.currentFont = f.currentFont
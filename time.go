@@ -47,3 +47,16 @@ func timeOrNow(tm time.Time) time.Time {
 	}
 	return tm
 }
+
+// documentDates returns the creation and modification dates of the document,
+// which default to the time the document is output
+func (f *DocPDF) documentDates() (creation, mod time.Time) {
+	creation, mod = f.creationDate, f.modDate
+	if creation.IsZero() {
+		creation = f.outputTime
+	}
+	if mod.IsZero() {
+		mod = f.outputTime
+	}
+	return
+}
@@ -0,0 +1,231 @@
+package docpdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tocType holds the table of contents reserved with ReserveTOC()
+type tocType struct {
+	page     int // page after which the table is inserted
+	pages    int // number of reserved pages
+	title    string
+	maxLevel int
+	family   string
+	style    string
+	sizePt   float64
+	first    int // first page of the table once printed
+}
+
+// tocEntryType locates the page number of a table of contents entry
+type tocEntryType struct {
+	page   int     // page of the table
+	x, y   float64 // end of the title and top of its last line
+	target int     // page of the bookmark
+}
+
+// ReserveTOC reserves a table of contents after the current page. The table
+// lists the bookmarks set with Bookmark(), including the headings of the
+// Markdown renderer, whose level does not exceed maxLevel. Each entry is
+// linked to its bookmark and ends with dot leaders and the right-aligned
+// number of the bookmarked page. titleStr, if not empty, is printed in bold
+// at the top of the table.
+//
+// pages blank pages are added to the document, so that the pages that follow
+// are numbered as they will be in the final document if the table fills that
+// number of pages. Call AddPage() to continue the document. The table is
+// printed when the document is closed, on new pages of the default size
+// using the font current when ReserveTOC() is called, and its pages then
+// replace the reserved ones. The page numbers listed in the table, and those
+// returned by PageNo() while the table is printed, are the final ones. The
+// page numbers printed by the header and footer functions on the pages that
+// follow the table are not updated when the table and the reserved pages
+// differ in length.
+func (f *DocPDF) ReserveTOC(pages int, titleStr string, maxLevel int) {
+	if f.err != nil {
+		return
+	}
+	if f.toc != nil {
+		f.err = fmt.Errorf("a table of contents is already reserved")
+		return
+	}
+	if f.currentFont.Name == "" {
+		f.err = fmt.Errorf("font has not been set; unable to reserve table of contents")
+		return
+	}
+	f.toc = &tocType{page: f.page, pages: pages, title: titleStr, maxLevel: maxLevel,
+		family: f.fontFamily, style: f.GetFontStyle(), sizePt: f.fontSizePt}
+	for j := 0; j < pages; j++ {
+		f.AddPageFormat(f.defOrientation, f.defPageSize)
+	}
+}
+
+// tocPage returns the final page number of page p, when the table of contents
+// is moved to its reserved position
+func (toc *tocType) tocPage(p, count int) int {
+	switch {
+	case p <= toc.page:
+		return p
+	case p <= toc.page+toc.pages || p >= toc.first:
+		return toc.page + 1
+	}
+	return p - toc.pages + count
+}
+
+// putTOC prints the table of contents on new pages at the end of the
+// document.
+func (f *DocPDF) putTOC() {
+	toc := f.toc
+	f.EndFrames()
+	// the header of the first page of the table already gets its final number
+	toc.first = len(f.pages)
+	f.AddPageFormat(f.defOrientation, f.defPageSize)
+	if toc.title != "" {
+		f.SetFont(toc.family, "B", toc.sizePt*1.4)
+		f.CellFormat(0, 2*f.fontSize, toc.title, "", 1, "L", false, 0, "")
+		f.Ln(f.fontSize)
+	}
+	f.SetFont(toc.family, toc.style, toc.sizePt)
+	if f.err != nil {
+		return
+	}
+	lineHt := 1.5 * f.fontSize
+	right := f.w - f.rMargin
+	space := f.GetStringWidth(" ")
+	// room left for the widest page number
+	numWd := f.GetStringWidth(strconv.Itoa(10*len(f.pages))) + 2*space
+	var entries []tocEntryType
+	for _, o := range f.outlines {
+		if o.level > toc.maxLevel {
+			continue
+		}
+		left := f.lMargin + float64(o.level)*2*f.fontSize
+		link := f.AddLink()
+		f.SetLink(link, o.y, o.p)
+		var lines []string
+		if f.isCurrentUTF8 {
+			lines = f.SplitText(o.txt, right-left-numWd)
+		} else {
+			for _, line := range f.SplitLines([]byte(o.txt), right-left-numWd) {
+				lines = append(lines, string(line))
+			}
+		}
+		for j, line := range lines {
+			if f.y+lineHt > f.pageBreakTrigger {
+				f.AddPageFormat(f.defOrientation, f.defPageSize)
+			}
+			f.SetX(left)
+			f.CellFormat(f.GetStringWidth(line), lineHt, line, "", 0, "L", false, 0, "")
+			f.Link(left, f.y, right-left, lineHt, link)
+			if j == len(lines)-1 {
+				entries = append(entries, tocEntryType{page: f.page, x: f.x, y: f.y, target: o.p})
+			}
+			f.Ln(lineHt)
+		}
+	}
+
+	// The page numbers are known once the length of the table is known
+	last, x, y := f.page, f.x, f.y
+	count := last - toc.first + 1
+	dotWd := f.GetStringWidth(".")
+	for _, e := range entries {
+		f.page = e.page
+		numStr := strconv.Itoa(toc.tocPage(e.target, count))
+		wd := f.GetStringWidth(numStr)
+		n := int((right - wd - space - e.x - space) / dotWd)
+		if n > 0 {
+			f.SetXY(right-wd-space-float64(n)*dotWd, e.y)
+			f.CellFormat(float64(n)*dotWd, lineHt, strings.Repeat(".", n), "", 0, "L", false, 0, "")
+		}
+		f.SetXY(right-wd, e.y)
+		f.CellFormat(wd, lineHt, numStr, "", 0, "L", false, 0, "")
+	}
+	f.page = last
+	f.SetXY(x, y)
+}
+
+// moveTOC moves the pages of the table of contents in place of the reserved
+// pages.
+func (f *DocPDF) moveTOC() {
+	toc := f.toc
+	// Links to the reserved pages lead to the table
+	for j, l := range f.links {
+		if l.page > toc.page && l.page <= toc.page+toc.pages {
+			f.links[j] = intLinkType{page: toc.first, y: 0}
+		}
+	}
+	order := make([]int, 0, len(f.pages))
+	for p := 1; p <= toc.page; p++ {
+		order = append(order, p)
+	}
+	for p := toc.first; p < len(f.pages); p++ {
+		order = append(order, p)
+	}
+	for p := toc.page + toc.pages + 1; p < toc.first; p++ {
+		order = append(order, p)
+	}
+	f.reorderPages(order)
+	f.toc = nil
+}
+
+// reorderPages arranges the pages in the order of the page numbers listed in
+// order, and renumbers the links, bookmarks, annotations and marked content
+// of the pages. Pages missing from order are removed.
+func (f *DocPDF) reorderPages(order []int) {
+	renum := make([]int, len(f.pages))
+	for j, p := range order {
+		renum[p] = j + 1
+	}
+	pages := f.pages[:1:1]
+	pageLinks := f.pageLinks[:1:1]
+	pageAttachments := f.pageAttachments[:1:1]
+	pageWidgets := f.pageWidgets[:1:1]
+	pageSizes := make(map[int]PageSize)
+	pageBoxes := make(map[int]map[string]PageBox)
+	var structParents map[int][]*structElemType
+	if f.structParents != nil {
+		structParents = make(map[int][]*structElemType)
+	}
+	for j, p := range order {
+		n := j + 1
+		pages = append(pages, f.pages[p])
+		pageLinks = append(pageLinks, f.pageLinks[p])
+		pageAttachments = append(pageAttachments, f.pageAttachments[p])
+		pageWidgets = append(pageWidgets, f.pageWidgets[p])
+		for _, wd := range f.pageWidgets[p] {
+			wd.page = n
+		}
+		if size, ok := f.pageSizes[p]; ok {
+			pageSizes[n] = size
+		}
+		if boxes, ok := f.pageBoxes[p]; ok {
+			pageBoxes[n] = boxes
+		}
+		if elems, ok := f.structParents[p]; ok {
+			structParents[n] = elems
+		}
+	}
+	f.pages, f.pageLinks, f.pageAttachments, f.pageWidgets = pages, pageLinks, pageAttachments, pageWidgets
+	f.pageSizes, f.pageBoxes, f.structParents = pageSizes, pageBoxes, structParents
+	for j := range f.links {
+		f.links[j].page = renum[f.links[j].page]
+	}
+	for j := range f.outlines {
+		f.outlines[j].p = renum[f.outlines[j].p]
+	}
+	var walk func(e *structElemType)
+	walk = func(e *structElemType) {
+		for j := range e.kids {
+			if e.kids[j].elem != nil {
+				walk(e.kids[j].elem)
+			} else {
+				e.kids[j].page = renum[e.kids[j].page]
+			}
+		}
+	}
+	if f.structRoot != nil {
+		walk(f.structRoot)
+	}
+	f.page = len(order)
+}
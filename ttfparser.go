@@ -27,12 +27,14 @@ package docpdf
 // Port to Go: Kurt Jung, 2013-07-15
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
-	"os"
 	"regexp"
 	"strings"
+
+	"github.com/cdvelop/docpdf/env"
 )
 
 // TtfType contains metrics of a TrueType font.
@@ -51,12 +53,17 @@ type TtfType struct {
 	CapHeight              int16
 	Widths                 []uint16
 	Chars                  map[uint16]uint16
+	kern                   map[[2]int]int // kern table pairs
+	gpos                   *otLayout
+	gdef                   *otGDEF
 }
 
 type ttfParser struct {
 	rec              TtfType
-	f                *os.File
+	f                *bytes.Reader
+	data             []byte
 	tables           map[string]uint32
+	lengths          map[string]uint32
 	numberOfHMetrics uint16
 	numGlyphs        uint16
 }
@@ -64,10 +71,13 @@ type ttfParser struct {
 // TtfParse extracts various metrics from a TrueType font file.
 func TtfParse(fileStr string) (TtfRec TtfType, err error) {
 	var t ttfParser
-	t.f, err = os.Open(fileStr)
+	var data []byte
+	data, err = env.FileExists(fileStr)
 	if err != nil {
 		return
 	}
+	t.f = bytes.NewReader(data)
+	t.data = data
 	version, err := t.ReadStr(4)
 	if err != nil {
 		return
@@ -83,6 +93,7 @@ func TtfParse(fileStr string) (TtfRec TtfType, err error) {
 	numTables := int(t.ReadUShort())
 	t.Skip(3 * 2) // searchRange, entrySelector, rangeShift
 	t.tables = make(map[string]uint32)
+	t.lengths = make(map[string]uint32)
 	var tag string
 	for j := 0; j < numTables; j++ {
 		tag, err = t.ReadStr(4)
@@ -91,14 +102,13 @@ func TtfParse(fileStr string) (TtfRec TtfType, err error) {
 		}
 		t.Skip(4) // checkSum
 		offset := t.ReadULong()
-		t.Skip(4) // length
 		t.tables[tag] = offset
+		t.lengths[tag] = t.ReadULong()
 	}
 	err = t.ParseComponents()
 	if err != nil {
 		return
 	}
-	t.f.Close()
 	TtfRec = t.rec
 	return
 }
@@ -119,6 +129,9 @@ func (t *ttfParser) ParseComponents() (err error) {
 							err = t.ParseOS2()
 							if err == nil {
 								err = t.ParsePost()
+								if err == nil {
+									t.ParseKerning()
+								}
 							}
 						}
 					}
@@ -345,6 +358,31 @@ func (t *ttfParser) ParsePost() (err error) {
 	return
 }
 
+// ParseKerning loads the kern table and the GPOS and GDEF tables, which hold
+// the kerning pairs of the font.
+func (t *ttfParser) ParseKerning() {
+	table := func(tag string) []byte {
+		ofs, ok := t.tables[tag]
+		if !ok || int(ofs)+int(t.lengths[tag]) > len(t.data) {
+			return nil
+		}
+		return t.data[ofs : ofs+t.lengths[tag]]
+	}
+	t.rec.kern = parseKernTable(table("kern"))
+	if data := table("GPOS"); data != nil {
+		t.rec.gpos = parseOTLayout(data, true)
+	}
+	if data := table("GDEF"); data != nil {
+		t.rec.gdef = parseGDEF(data)
+	}
+}
+
+// KernPair returns the kerning adjustment between the glyphs left and right,
+// in font units. Negative values bring the glyphs closer.
+func (t *TtfType) KernPair(left, right uint16) int {
+	return pairKern(t.gpos, t.gdef, t.kern, int(left), int(right))
+}
+
 func (t *ttfParser) Seek(tag string) (err error) {
 	ofs, ok := t.tables[tag]
 	if !ok {
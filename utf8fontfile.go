@@ -59,6 +59,10 @@ type utf8FontFile struct {
 	DefaultWidth         float64
 	symbolData           map[int]map[string][]int
 	CodeSymbolDictionary map[int]int
+	shaperState          *shaperType
+	faceIndex            int
+	faceOffset           int
+	cff                  *cffFont
 }
 
 type tableDescription struct {
@@ -105,17 +109,35 @@ func (utf *utf8FontFile) parseFile() error {
 	utf.outTablesData = make(map[string][]byte)
 	utf.Ascent = 0
 	utf.Descent = 0
+	utf.faceOffset = 0
 	codeType := uint32(utf.readUint32())
-	if codeType == 0x4F54544F {
-		return fmt.Errorf("not supported\n ")
-	}
-	if codeType == 0x74746366 {
-		return fmt.Errorf("not supported\n ")
-	}
-	if codeType != 0x00010000 && codeType != 0x74727565 {
+	if codeType == 0x74746366 { // TrueType collection
+		utf.skip(4)
+		numFonts := utf.readUint32()
+		if utf.faceIndex < 0 || utf.faceIndex >= numFonts {
+			return fmt.Errorf("font collection has no face %d", utf.faceIndex)
+		}
+		utf.skip(4 * utf.faceIndex)
+		utf.faceOffset = utf.readUint32()
+		utf.seek(utf.faceOffset)
+		codeType = uint32(utf.readUint32())
+	} else if utf.faceIndex != 0 {
+		return fmt.Errorf("font is not a collection, it has no face %d", utf.faceIndex)
+	}
+	if codeType != 0x00010000 && codeType != 0x74727565 && codeType != 0x4F54544F {
 		return fmt.Errorf("not a TrueType font: codeType=%v\n ", codeType)
 	}
 	utf.generateTableDescriptions()
+	if codeType == 0x4F54544F { // OpenType with CFF outlines
+		if _, ok := utf.tableDescriptions["CFF "]; !ok {
+			return fmt.Errorf("OpenType font has no CFF table")
+		}
+		cff, err := parseCFF(utf.getTableData("CFF "))
+		if err != nil {
+			return err
+		}
+		utf.cff = cff
+	}
 	utf.parseTables()
 	return nil
 }
@@ -637,7 +659,10 @@ func (utf *utf8FontFile) generateCMAPTable(cidSymbolPairCollection map[int]int,
 
 // GenerateCutFont fill utf8FontFile from .utf file, only with runes from usedRunes
 func (utf *utf8FontFile) GenerateCutFont(usedRunes map[int]int) []byte {
-	utf.fileReader.readerPosition = 0
+	if utf.cff != nil {
+		return utf.generateCutCFF(usedRunes)
+	}
+	utf.fileReader.readerPosition = int64(utf.faceOffset)
 	utf.symbolPosition = make([]int, 0)
 	utf.charSymbolDictionary = make(map[int]int)
 	utf.tableDescriptions = make(map[string]*tableDescription)
@@ -665,6 +690,12 @@ func (utf *utf8FontFile) GenerateCutFont(usedRunes map[int]int) []byte {
 	if symbolCharDictionary == nil {
 		return nil
 	}
+	if utf.shaperState != nil {
+		// glyphs without Unicode value produced by text shaping
+		for cid, gid := range utf.shaperState.cidGlyphs {
+			utf.charSymbolDictionary[cid] = gid
+		}
+	}
 
 	utf.parseHMTXTable(metricsCount, numSymbols, symbolCharDictionary, 1.0)
 
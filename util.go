@@ -6,9 +6,9 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"os"
-	"path/filepath"
 	"strings"
+
+	"github.com/cdvelop/docpdf/env"
 )
 
 func must(n int, err error) {
@@ -36,22 +36,17 @@ func sprintf(fmtStr string, args ...interface{}) string {
 
 // fileExist returns true if the specified normal file exists
 func fileExist(filename string) (ok bool) {
-	info, err := os.Stat(filename)
-	if err == nil {
-		if ^os.ModePerm&info.Mode() == 0 {
-			ok = true
-		}
-	}
-	return ok
+	_, err := env.GetSize(filename)
+	return err == nil
 }
 
 // fileSize returns the size of the specified file; ok will be false
 // if the file does not exist or is not an ordinary file
 func fileSize(filename string) (size int64, ok bool) {
-	info, err := os.Stat(filename)
+	size, err := env.GetSize(filename)
 	ok = err == nil
-	if ok {
-		size = info.Size()
+	if !ok {
+		size = 0
 	}
 	return
 }
@@ -195,11 +190,10 @@ func UnicodeTranslator(r io.Reader) (f func(string) string, err error) {
 // If an error occurs reading the file, the returned function is valid but does
 // not perform any rune translation.
 func UnicodeTranslatorFromFile(fileStr string) (f func(string) string, err error) {
-	var fl *os.File
-	fl, err = os.Open(fileStr)
+	var data []byte
+	data, err = env.FileExists(fileStr)
 	if err == nil {
-		f, err = UnicodeTranslator(fl)
-		fl.Close()
+		f, err = UnicodeTranslator(bytes.NewReader(data))
 	} else {
 		f = doNothing
 	}
@@ -229,7 +223,7 @@ func (f *DocPDF) UnicodeTranslatorFromDescriptor(cpStr string) (rep func(string)
 			defer emb.Close()
 			rep, f.err = UnicodeTranslator(emb)
 		} else {
-			rep, f.err = UnicodeTranslatorFromFile(filepath.Join(f.fontsPath, cpStr) + ".map")
+			rep, f.err = f.UnicodeTranslatorFromFile(env.JoinPath(f.fontsPath, cpStr+".map"))
 		}
 	} else {
 		rep = doNothing
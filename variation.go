@@ -0,0 +1,827 @@
+package docpdf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// varAxis is a variation axis of the fvar table of a variable font.
+type varAxis struct {
+	tag           string
+	min, def, max float64
+}
+
+// varGlyph is the outline of a glyph of a variable font being instantiated.
+// Simple glyphs have points, composite glyphs have components, whose
+// offsets are varied as points.
+type varGlyph struct {
+	contours   int
+	endPts     []int
+	flags      []byte
+	xs, ys     []float64
+	instrs     []byte
+	components []varComponent
+	bbox       [4]int
+	done       bool
+}
+
+// varComponent is a component of a composite glyph.
+type varComponent struct {
+	flags     int
+	gid       int
+	arg1      int
+	arg2      int
+	transform []byte
+}
+
+// composite glyph flags
+const (
+	varArgWords   = 0x0001
+	varArgsXY     = 0x0002
+	varScale      = 0x0008
+	varMore       = 0x0020
+	varXYScale    = 0x0040
+	varTwoByTwo   = 0x0080
+	varHaveInstrs = 0x0100
+)
+
+// variationTables are the tables that describe the variations of a font,
+// which are dropped from its instances, along with the device metrics that
+// no longer match the outlines.
+var variationTables = []string{"fvar", "gvar", "avar", "HVAR", "VVAR", "MVAR", "cvar", "STAT", "hdmx", "LTSH", "VDMX"}
+
+func (d otData) u8(off int) int {
+	if off < 0 || off >= len(d) {
+		return 0
+	}
+	return int(d[off])
+}
+
+// f2dot14 returns the signed 2.14 fixed point number at off.
+func (d otData) f2dot14(off int) float64 {
+	return float64(d.s16(off)) / 16384
+}
+
+// sfntTables returns the tables of the font of data, or of the face face if
+// data is a font collection.
+func sfntTables(data []byte, face int) (map[string]otData, error) {
+	d := otData(data)
+	off := 0
+	if d.tag(0) == "ttcf" {
+		if face < 0 || face >= d.u32(8) {
+			return nil, fmt.Errorf("font collection has no face %d", face)
+		}
+		off = d.u32(12 + 4*face)
+	} else if face != 0 {
+		return nil, fmt.Errorf("font is not a collection, it has no face %d", face)
+	}
+	tables := make(map[string]otData)
+	for j := 0; j < d.u16(off+4); j++ {
+		rec := off + 12 + 16*j
+		pos, size := d.u32(rec+8), d.u32(rec+12)
+		if pos+size > len(d) {
+			return nil, fmt.Errorf("invalid font table %q", d.tag(rec))
+		}
+		tables[d.tag(rec)] = d[pos : pos+size]
+	}
+	return tables, nil
+}
+
+// normalizedCoords returns the normalized coordinates, from -1 to 1, of the
+// design coordinates coords given by axis tag, with the avar mapping.
+// Missing axes take their default value.
+func normalizedCoords(fvar, avar otData, coords map[string]float64) ([]float64, []varAxis, error) {
+	axesOff, count, size := fvar.u16(4), fvar.u16(8), fvar.u16(10)
+	fixed := func(off int) float64 { return float64(int32(fvar.u32(off))) / 65536 }
+	axes := make([]varAxis, count)
+	norm := make([]float64, count)
+	for j := range axes {
+		rec := axesOff + j*size
+		axes[j] = varAxis{tag: fvar.tag(rec), min: fixed(rec + 4), def: fixed(rec + 8), max: fixed(rec + 12)}
+	}
+	for tag := range coords {
+		found := false
+		for _, axis := range axes {
+			found = found || axis.tag == tag
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("font has no variation axis %q", tag)
+		}
+	}
+	for j, axis := range axes {
+		v, ok := coords[axis.tag]
+		if !ok {
+			continue
+		}
+		v = math.Max(axis.min, math.Min(axis.max, v))
+		switch {
+		case v < axis.def && axis.def > axis.min:
+			norm[j] = (v - axis.def) / (axis.def - axis.min)
+		case v > axis.def && axis.max > axis.def:
+			norm[j] = (v - axis.def) / (axis.max - axis.def)
+		}
+	}
+	// avar segment maps
+	if len(avar) > 0 && avar.u16(6) == count {
+		off := 8
+		for j := range norm {
+			n := avar.u16(off)
+			maps := off + 2
+			off += 2 + 4*n
+			for k := 1; k < n; k++ {
+				from0, to0 := avar.f2dot14(maps+4*(k-1)), avar.f2dot14(maps+4*(k-1)+2)
+				from1, to1 := avar.f2dot14(maps+4*k), avar.f2dot14(maps+4*k+2)
+				if norm[j] <= from1 {
+					if from1 > from0 {
+						norm[j] = to0 + (to1-to0)*(norm[j]-from0)/(from1-from0)
+					} else {
+						norm[j] = to1
+					}
+					break
+				}
+			}
+		}
+	}
+	for j := range norm {
+		norm[j] = math.Round(norm[j]*16384) / 16384
+	}
+	return norm, axes, nil
+}
+
+// tupleScalar returns the factor applied to the deltas of a tuple variation
+// with the given peak, and intermediate region if start is not nil, at the
+// normalized coordinates.
+func tupleScalar(coords, peak, start, end []float64) float64 {
+	scalar := 1.0
+	for j, p := range peak {
+		v := coords[j]
+		switch {
+		case p == 0 || v == p:
+		case start != nil:
+			if v <= start[j] || v >= end[j] {
+				return 0
+			}
+			if v < p {
+				scalar *= (v - start[j]) / (p - start[j])
+			} else {
+				scalar *= (end[j] - v) / (end[j] - p)
+			}
+		case v == 0 || v < math.Min(0, p) || v > math.Max(0, p):
+			return 0
+		default:
+			scalar *= v / p
+		}
+	}
+	return scalar
+}
+
+// regionScalar returns the factor applied to the deltas of a region of an
+// item variation store at the normalized coordinates.
+func regionScalar(coords, start, peak, end []float64) float64 {
+	scalar := 1.0
+	for j, p := range peak {
+		v := coords[j]
+		switch {
+		case p == 0 || start[j] > p || p > end[j] || (start[j] < 0 && end[j] > 0) || v == p:
+		case v <= start[j] || v >= end[j]:
+			return 0
+		case v < p:
+			scalar *= (v - start[j]) / (p - start[j])
+		default:
+			scalar *= (end[j] - v) / (end[j] - p)
+		}
+	}
+	return scalar
+}
+
+// packedPoints returns the packed point numbers at off, or nil for all the
+// points, and the offset that follows them.
+func packedPoints(d otData, off int) ([]int, int) {
+	count := d.u8(off)
+	off++
+	if count == 0 {
+		return nil, off
+	}
+	if count&0x80 != 0 {
+		count = (count&0x7F)<<8 | d.u8(off)
+		off++
+	}
+	points := make([]int, 0, count)
+	p := 0
+	for len(points) < count && off < len(d) {
+		ctl := d.u8(off)
+		off++
+		for k := 0; k <= ctl&0x7F && len(points) < count; k++ {
+			if ctl&0x80 != 0 {
+				p += d.u16(off)
+				off += 2
+			} else {
+				p += d.u8(off)
+				off++
+			}
+			points = append(points, p)
+		}
+	}
+	return points, off
+}
+
+// packedDeltas returns count packed deltas at off and the offset that
+// follows them.
+func packedDeltas(d otData, off, count int) ([]float64, int) {
+	deltas := make([]float64, 0, count)
+	for len(deltas) < count && off < len(d) {
+		ctl := d.u8(off)
+		off++
+		for k := 0; k <= ctl&0x3F && len(deltas) < count; k++ {
+			switch {
+			case ctl&0x80 != 0:
+				deltas = append(deltas, 0)
+			case ctl&0x40 != 0:
+				deltas = append(deltas, float64(d.s16(off)))
+				off += 2
+			default:
+				deltas = append(deltas, float64(int8(d.u8(off))))
+				off++
+			}
+		}
+	}
+	for len(deltas) < count {
+		deltas = append(deltas, 0)
+	}
+	return deltas, off
+}
+
+// inferDeltas sets the deltas of the points of a contour, from first to
+// last, that have no explicit delta by interpolating those of the
+// neighbouring points that have one (IUP).
+func inferDeltas(orig, deltas []float64, touched []bool, first, last int) {
+	var refs []int
+	for j := first; j <= last; j++ {
+		if touched[j] {
+			refs = append(refs, j)
+		}
+	}
+	if len(refs) == 0 {
+		return
+	}
+	for r, i1 := range refs {
+		i2 := refs[(r+1)%len(refs)]
+		for j := i1 + 1; ; j++ {
+			if j > last {
+				j = first
+			}
+			if j == i2 {
+				break
+			}
+			c1, c2, d1, d2 := orig[i1], orig[i2], deltas[i1], deltas[i2]
+			if c1 > c2 {
+				c1, c2, d1, d2 = c2, c1, d2, d1
+			}
+			c := orig[j]
+			switch {
+			case c1 == c2:
+				if d1 == d2 {
+					deltas[j] = d1
+				} else {
+					deltas[j] = 0
+				}
+			case c <= c1:
+				deltas[j] = d1
+			case c >= c2:
+				deltas[j] = d2
+			default:
+				deltas[j] = d1 + (c-c1)*(d2-d1)/(c2-c1)
+			}
+		}
+	}
+}
+
+// parseVarGlyph parses the glyph data g.
+func parseVarGlyph(g otData) *varGlyph {
+	gl := &varGlyph{}
+	if len(g) < 10 {
+		return gl
+	}
+	gl.contours = g.s16(0)
+	if gl.contours < 0 {
+		off := 10
+		for flags := varMore; flags&varMore != 0 && off+4 <= len(g); {
+			flags = g.u16(off)
+			c := varComponent{flags: flags, gid: g.u16(off + 2)}
+			off += 4
+			switch {
+			case flags&varArgWords != 0 && flags&varArgsXY != 0:
+				c.arg1, c.arg2 = g.s16(off), g.s16(off+2)
+				off += 4
+			case flags&varArgWords != 0:
+				c.arg1, c.arg2 = g.u16(off), g.u16(off+2)
+				off += 4
+			case flags&varArgsXY != 0:
+				c.arg1, c.arg2 = int(int8(g.u8(off))), int(int8(g.u8(off+1)))
+				off += 2
+			default:
+				c.arg1, c.arg2 = g.u8(off), g.u8(off+1)
+				off += 2
+			}
+			n := 0
+			switch {
+			case flags&varScale != 0:
+				n = 2
+			case flags&varXYScale != 0:
+				n = 4
+			case flags&varTwoByTwo != 0:
+				n = 8
+			}
+			if off+n <= len(g) {
+				c.transform = g[off : off+n]
+			}
+			off += n
+			gl.components = append(gl.components, c)
+		}
+		if gl.components[len(gl.components)-1].flags&varHaveInstrs != 0 && off < len(g) {
+			gl.instrs = g[off:]
+		}
+		return gl
+	}
+	off := 10
+	for j := 0; j < gl.contours; j++ {
+		gl.endPts = append(gl.endPts, g.u16(off))
+		off += 2
+	}
+	count := 0
+	if gl.contours > 0 {
+		count = gl.endPts[gl.contours-1] + 1
+	}
+	n := g.u16(off)
+	if off+2+n > len(g) {
+		return &varGlyph{}
+	}
+	gl.instrs = g[off+2 : off+2+n]
+	off += 2 + n
+	for len(gl.flags) < count && off < len(g) {
+		fl := g[off]
+		off++
+		gl.flags = append(gl.flags, fl)
+		if fl&8 != 0 {
+			for k := g.u8(off); k > 0; k-- {
+				gl.flags = append(gl.flags, fl)
+			}
+			off++
+		}
+	}
+	for len(gl.flags) < count {
+		gl.flags = append(gl.flags, 1)
+	}
+	gl.flags = gl.flags[:count]
+	coords := func(short, same byte) []float64 {
+		v, values := 0, make([]float64, count)
+		for j, fl := range gl.flags {
+			switch {
+			case fl&short != 0 && fl&same != 0:
+				v += g.u8(off)
+				off++
+			case fl&short != 0:
+				v -= g.u8(off)
+				off++
+			case fl&same == 0:
+				v += g.s16(off)
+				off += 2
+			}
+			values[j] = float64(v)
+		}
+		return values
+	}
+	gl.xs = coords(2, 16)
+	gl.ys = coords(4, 32)
+	return gl
+}
+
+// bytes returns the glyph data of the glyph, with the coordinates written as
+// words.
+func (gl *varGlyph) bytes() []byte {
+	if gl.contours == 0 && gl.components == nil {
+		return nil
+	}
+	var buf []byte
+	put16 := func(v int) { buf = binary.BigEndian.AppendUint16(buf, uint16(v)) }
+	put16(gl.contours)
+	for _, v := range gl.bbox {
+		put16(v)
+	}
+	if gl.components != nil {
+		for _, c := range gl.components {
+			put16(c.flags | varArgWords)
+			put16(c.gid)
+			put16(c.arg1)
+			put16(c.arg2)
+			buf = append(buf, c.transform...)
+		}
+		return append(buf, gl.instrs...)
+	}
+	for _, e := range gl.endPts {
+		put16(e)
+	}
+	put16(len(gl.instrs))
+	buf = append(buf, gl.instrs...)
+	for j, fl := range gl.flags {
+		// on curve and, for the first point, overlap flags only
+		fl &= 0x01
+		if j == 0 {
+			fl |= gl.flags[0] & 0x40
+		}
+		buf = append(buf, fl)
+	}
+	for _, values := range [][]float64{gl.xs, gl.ys} {
+		prev := 0
+		for _, v := range values {
+			put16(int(v) - prev)
+			prev = int(v)
+		}
+	}
+	return buf
+}
+
+// instantiateVariation returns a static TrueType font with the outlines and
+// advance widths of the variable TrueType font data, or of the face face of
+// a collection, at the design coordinates coords given by axis tag, such as
+// "wght" or "wdth".
+func instantiateVariation(data []byte, face int, coords map[string]float64) ([]byte, error) {
+	tables, err := sfntTables(data, face)
+	if err != nil {
+		return nil, err
+	}
+	if tables["fvar"] == nil {
+		return nil, fmt.Errorf("font is not a variable font")
+	}
+	if tables["glyf"] == nil || tables["loca"] == nil {
+		return nil, fmt.Errorf("only variable fonts with TrueType outlines are supported")
+	}
+	norm, axes, err := normalizedCoords(tables["fvar"], tables["avar"], coords)
+	if err != nil {
+		return nil, err
+	}
+	head, hhea, glyf := tables["head"], tables["hhea"], tables["glyf"]
+	numGlyphs := tables["maxp"].u16(4)
+	numMetrics := hhea.u16(34)
+	if numMetrics == 0 || numMetrics > numGlyphs {
+		return nil, fmt.Errorf("invalid horizontal metrics")
+	}
+	loca := make([]int, numGlyphs+1)
+	for j := range loca {
+		if head.s16(50) == 0 {
+			loca[j] = 2 * tables["loca"].u16(2*j)
+		} else {
+			loca[j] = tables["loca"].u32(4 * j)
+		}
+	}
+	adv := make([]int, numGlyphs)
+	lsb := make([]int, numGlyphs)
+	hmtx := tables["hmtx"]
+	for gid := range adv {
+		if gid < numMetrics {
+			adv[gid], lsb[gid] = hmtx.u16(4*gid), hmtx.s16(4*gid+2)
+		} else {
+			adv[gid], lsb[gid] = adv[numMetrics-1], hmtx.s16(4*numMetrics+2*(gid-numMetrics))
+		}
+	}
+
+	glyphs := make([]*varGlyph, numGlyphs)
+	newAdv := make([]int, numGlyphs)
+	gvar := tables["gvar"]
+	hvarDeltas := advanceDeltas(tables["HVAR"], norm)
+	for gid := range glyphs {
+		if loca[gid] > loca[gid+1] || loca[gid+1] > len(glyf) {
+			return nil, fmt.Errorf("invalid glyph %d", gid)
+		}
+		gl := parseVarGlyph(glyf[loca[gid]:loca[gid+1]])
+		glyphs[gid] = gl
+		// points and phantom points
+		n := len(gl.xs)
+		xs, ys := append([]float64{}, gl.xs...), append([]float64{}, gl.ys...)
+		if gl.components != nil {
+			n = len(gl.components)
+			xs, ys = make([]float64, n), make([]float64, n)
+			for j, c := range gl.components {
+				xs[j], ys[j] = float64(c.arg1), float64(c.arg2)
+			}
+		}
+		xMin := 0
+		if len(glyf) >= loca[gid]+10 && loca[gid] < loca[gid+1] {
+			xMin = glyf.s16(loca[gid] + 2)
+		}
+		pp1 := float64(xMin - lsb[gid])
+		xs = append(xs, pp1, pp1+float64(adv[gid]), 0, 0)
+		ys = append(ys, 0, 0, 0, 0)
+		dx, dy := glyphDeltas(gvar, gid, norm, gl, xs, ys)
+		for j := range xs {
+			xs[j] += dx[j]
+			ys[j] += dy[j]
+		}
+		// the origin stays at the first phantom point
+		shift := -math.Round(xs[n])
+		if gl.components != nil {
+			for j := range gl.components {
+				if gl.components[j].flags&varArgsXY != 0 {
+					gl.components[j].arg1 = int(math.Round(xs[j] + shift))
+					gl.components[j].arg2 = int(math.Round(ys[j]))
+				}
+			}
+		} else {
+			for j := range gl.xs {
+				gl.xs[j] = math.Round(xs[j] + shift)
+				gl.ys[j] = math.Round(ys[j])
+			}
+		}
+		if d, ok := hvarDeltas(gid); ok {
+			newAdv[gid] = int(math.Round(float64(adv[gid]) + d))
+		} else {
+			newAdv[gid] = int(math.Round(xs[n+1] - xs[n]))
+		}
+		newAdv[gid] = max(newAdv[gid], 0)
+	}
+
+	// glyph boxes, and the glyph, loca and metrics tables
+	var newGlyf, newLoca, newHmtx []byte
+	maxAdv := 0
+	for gid, gl := range glyphs {
+		glyphBox(glyphs, gid, 0)
+		newLoca = binary.BigEndian.AppendUint32(newLoca, uint32(len(newGlyf)))
+		newGlyf = append(newGlyf, gl.bytes()...)
+		for len(newGlyf)%4 != 0 {
+			newGlyf = append(newGlyf, 0)
+		}
+		newHmtx = binary.BigEndian.AppendUint16(newHmtx, uint16(newAdv[gid]))
+		newHmtx = binary.BigEndian.AppendUint16(newHmtx, uint16(gl.bbox[0]))
+		maxAdv = max(maxAdv, newAdv[gid])
+	}
+	newLoca = binary.BigEndian.AppendUint32(newLoca, uint32(len(newGlyf)))
+
+	utf := &utf8FontFile{outTablesData: make(map[string][]byte)}
+	for name, table := range tables {
+		utf.outTablesData[name] = table
+	}
+	for _, name := range variationTables {
+		delete(utf.outTablesData, name)
+	}
+	utf.outTablesData["glyf"] = newGlyf
+	utf.outTablesData["loca"] = newLoca
+	utf.outTablesData["hmtx"] = newHmtx
+	newHead := append([]byte{}, head...)
+	binary.BigEndian.PutUint32(newHead[8:], 0)
+	binary.BigEndian.PutUint16(newHead[50:], 1)
+	utf.outTablesData["head"] = newHead
+	newHhea := append([]byte{}, hhea...)
+	binary.BigEndian.PutUint16(newHhea[10:], uint16(maxAdv))
+	binary.BigEndian.PutUint16(newHhea[34:], uint16(numGlyphs))
+	utf.outTablesData["hhea"] = newHhea
+	// weight class of the instance
+	for _, axis := range axes {
+		if os2 := tables["OS/2"]; axis.tag == "wght" && len(os2) > 6 {
+			w := axis.def
+			if v, ok := coords["wght"]; ok {
+				w = math.Max(axis.min, math.Min(axis.max, v))
+			}
+			newOS2 := append([]byte{}, os2...)
+			binary.BigEndian.PutUint16(newOS2[4:], uint16(math.Max(1, math.Min(1000, math.Round(w)))))
+			utf.outTablesData["OS/2"] = newOS2
+		}
+	}
+	return utf.assembleTables(), nil
+}
+
+// glyphDeltas returns the deltas of the points xs, ys of the glyph gid,
+// including the four phantom points, given by the gvar table at the
+// normalized coordinates.
+func glyphDeltas(gvar otData, gid int, coords []float64, gl *varGlyph, xs, ys []float64) (dx, dy []float64) {
+	n := len(xs)
+	dx, dy = make([]float64, n), make([]float64, n)
+	axisCount := gvar.u16(4)
+	if len(gvar) == 0 || axisCount != len(coords) || gid >= gvar.u16(12) {
+		return
+	}
+	sharedTuples := gvar.u32(8)
+	var start, end int
+	if gvar.u16(14)&1 != 0 {
+		start, end = gvar.u32(20+4*gid), gvar.u32(24+4*gid)
+	} else {
+		start, end = 2*gvar.u16(20+2*gid), 2*gvar.u16(22+2*gid)
+	}
+	if start >= end {
+		return
+	}
+	base := gvar.u32(16) + start
+	d := gvar[min(base, len(gvar)):min(gvar.u32(16)+end, len(gvar))]
+	count := d.u16(0)
+	dataOff := d.u16(2)
+	var sharedPoints []int
+	if count&0x8000 != 0 {
+		sharedPoints, dataOff = packedPoints(d, dataOff)
+	}
+	tuple := func(off int) []float64 {
+		t := make([]float64, axisCount)
+		for j := range t {
+			t[j] = d.f2dot14(off + 2*j)
+		}
+		return t
+	}
+	hdr := 4
+	for k := 0; k < count&0x0FFF; k++ {
+		size, index := d.u16(hdr), d.u16(hdr+2)
+		hdr += 4
+		var peak, iStart, iEnd []float64
+		if index&0x8000 != 0 {
+			peak = tuple(hdr)
+			hdr += 2 * axisCount
+		} else {
+			peak = make([]float64, axisCount)
+			for j := range peak {
+				peak[j] = gvar.f2dot14(sharedTuples + 2*((index&0x0FFF)*axisCount+j))
+			}
+		}
+		if index&0x4000 != 0 {
+			iStart, iEnd = tuple(hdr), tuple(hdr+2*axisCount)
+			hdr += 4 * axisCount
+		}
+		off := dataOff
+		dataOff += size
+		scalar := tupleScalar(coords, peak, iStart, iEnd)
+		if scalar == 0 {
+			continue
+		}
+		points := sharedPoints
+		if index&0x2000 != 0 {
+			points, off = packedPoints(d, off)
+		}
+		m := n
+		if points != nil {
+			m = len(points)
+		}
+		tx, off := packedDeltas(d, off, m)
+		ty, _ := packedDeltas(d, off, m)
+		if points == nil {
+			for j := 0; j < n; j++ {
+				dx[j] += scalar * tx[j]
+				dy[j] += scalar * ty[j]
+			}
+			continue
+		}
+		// explicit points, the others of simple glyphs are inferred
+		ex, ey := make([]float64, n), make([]float64, n)
+		touched := make([]bool, n)
+		for j, p := range points {
+			if p < n {
+				ex[p], ey[p] = tx[j], ty[j]
+				touched[p] = true
+			}
+		}
+		if gl.components == nil {
+			first := 0
+			for _, last := range gl.endPts {
+				if last >= len(gl.xs) {
+					break
+				}
+				inferDeltas(gl.xs, ex, touched, first, last)
+				inferDeltas(gl.ys, ey, touched, first, last)
+				first = last + 1
+			}
+		}
+		for j := 0; j < n; j++ {
+			dx[j] += scalar * ex[j]
+			dy[j] += scalar * ey[j]
+		}
+	}
+	return
+}
+
+// advanceDeltas returns a function giving the advance width delta of a glyph
+// from the HVAR table at the normalized coordinates, with false if the font
+// has no HVAR table.
+func advanceDeltas(hvar otData, coords []float64) func(gid int) (float64, bool) {
+	if len(hvar) == 0 {
+		return func(int) (float64, bool) { return 0, false }
+	}
+	store := hvar.u32(4)
+	mapping := hvar.u32(8)
+	regions := store + hvar.u32(store+2)
+	axisCount, regionCount := hvar.u16(regions), hvar.u16(regions+2)
+	scalars := make([]float64, regionCount)
+	if axisCount == len(coords) {
+		for r := range scalars {
+			s, p, e := make([]float64, axisCount), make([]float64, axisCount), make([]float64, axisCount)
+			for j := 0; j < axisCount; j++ {
+				rec := regions + 4 + 6*(r*axisCount+j)
+				s[j], p[j], e[j] = hvar.f2dot14(rec), hvar.f2dot14(rec+2), hvar.f2dot14(rec+4)
+			}
+			scalars[r] = regionScalar(coords, s, p, e)
+		}
+	}
+	return func(gid int) (float64, bool) {
+		outer, inner := 0, gid
+		if mapping != 0 {
+			format, entry := hvar.u8(mapping), hvar.u8(mapping+1)
+			count, off := hvar.u16(mapping+2), mapping+4
+			if format == 1 {
+				count, off = hvar.u32(mapping+2), mapping+6
+			}
+			if count == 0 {
+				return 0, true
+			}
+			size, innerBits := (entry>>4&3)+1, (entry&0xF)+1
+			v := 0
+			for k := 0; k < size; k++ {
+				v = v<<8 | hvar.u8(off+size*min(gid, count-1)+k)
+			}
+			outer, inner = v>>innerBits, v&(1<<innerBits-1)
+		}
+		if outer >= hvar.u16(store+6) {
+			return 0, true
+		}
+		data := store + hvar.u32(store+8+4*outer)
+		itemCount, wordCount, regionIndexCount := hvar.u16(data), hvar.u16(data+2), hvar.u16(data+4)
+		if inner >= itemCount {
+			return 0, true
+		}
+		long := wordCount&0x8000 != 0
+		wordCount &= 0x7FFF
+		wordSize, byteSize := 2, 1
+		if long {
+			wordSize, byteSize = 4, 2
+		}
+		rowSize := wordCount*wordSize + (regionIndexCount-wordCount)*byteSize
+		off := data + 6 + 2*regionIndexCount + inner*rowSize
+		delta := 0.0
+		for k := 0; k < regionIndexCount; k++ {
+			var v int
+			switch {
+			case k < wordCount && long:
+				v = int(int32(hvar.u32(off)))
+				off += 4
+			case k < wordCount || long:
+				v = hvar.s16(off)
+				off += 2
+			default:
+				v = int(int8(hvar.u8(off)))
+				off++
+			}
+			if r := hvar.u16(data + 6 + 2*k); r < regionCount {
+				delta += scalars[r] * float64(v)
+			}
+		}
+		return delta, true
+	}
+}
+
+// glyphBox sets the bounding box of the glyph gid, and of the components of
+// composite glyphs.
+func glyphBox(glyphs []*varGlyph, gid, depth int) [4]int {
+	gl := glyphs[gid]
+	if gl.done || depth > 8 {
+		return gl.bbox
+	}
+	gl.done = true
+	var xs, ys []float64
+	if gl.components == nil {
+		xs, ys = gl.xs, gl.ys
+	}
+	for _, c := range gl.components {
+		if c.gid >= len(glyphs) {
+			continue
+		}
+		box := glyphBox(glyphs, c.gid, depth+1)
+		if glyphs[c.gid].contours == 0 && glyphs[c.gid].components == nil {
+			continue
+		}
+		a, b, cc, d := 1.0, 0.0, 0.0, 1.0
+		t := otData(c.transform)
+		switch len(t) {
+		case 2:
+			a, d = t.f2dot14(0), t.f2dot14(0)
+		case 4:
+			a, d = t.f2dot14(0), t.f2dot14(2)
+		case 8:
+			a, b, cc, d = t.f2dot14(0), t.f2dot14(2), t.f2dot14(4), t.f2dot14(6)
+		}
+		ox, oy := 0.0, 0.0
+		if c.flags&varArgsXY != 0 {
+			ox, oy = float64(c.arg1), float64(c.arg2)
+		}
+		for _, p := range [][2]int{{box[0], box[1]}, {box[2], box[1]}, {box[0], box[3]}, {box[2], box[3]}} {
+			x, y := float64(p[0]), float64(p[1])
+			xs = append(xs, a*x+cc*y+ox)
+			ys = append(ys, b*x+d*y+oy)
+		}
+	}
+	if len(xs) == 0 {
+		return gl.bbox
+	}
+	box := [4]float64{xs[0], ys[0], xs[0], ys[0]}
+	for j := range xs {
+		box[0], box[1] = math.Min(box[0], xs[j]), math.Min(box[1], ys[j])
+		box[2], box[3] = math.Max(box[2], xs[j]), math.Max(box[3], ys[j])
+	}
+	for j, v := range box {
+		gl.bbox[j] = int(math.Round(v))
+	}
+	return gl.bbox
+}
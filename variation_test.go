@@ -0,0 +1,212 @@
+package docpdf_test
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+// packedTestDeltas returns the gvar encoding of deltas, as words.
+func packedTestDeltas(deltas []int) (buf []byte) {
+	for len(deltas) > 0 {
+		n := min(len(deltas), 64)
+		buf = append(buf, 0x40|byte(n-1))
+		for _, d := range deltas[:n] {
+			buf = binary.BigEndian.AppendUint16(buf, uint16(d))
+		}
+		deltas = deltas[n:]
+	}
+	return
+}
+
+// packedTestPoints returns the gvar encoding of point numbers, as words.
+func packedTestPoints(points []int) []byte {
+	buf := []byte{0x80 | byte(len(points)>>8), byte(len(points))}
+	prev := 0
+	for len(points) > 0 {
+		n := min(len(points), 128)
+		buf = append(buf, 0x80|byte(n-1))
+		for _, p := range points[:n] {
+			buf = binary.BigEndian.AppendUint16(buf, uint16(p-prev))
+			prev = p
+		}
+		points = points[n:]
+	}
+	return buf
+}
+
+// variableFromTrueType returns a variable font made of the TrueType font
+// ttf, with a wght axis from 100 to 900 whose maximum widens the glyphs by
+// 20%, and a wdth axis from 75 to 100 whose minimum narrows them by 25%.
+// The wdth deltas are given for every other point of the glyphs, and the
+// advance width deltas are also given by an HVAR table if hvar is true.
+func variableFromTrueType(ttf []byte, hvar bool) []byte {
+	tables := sfntTables(ttf, 0)
+	u16 := func(table string, off int) int { return int(binary.BigEndian.Uint16(tables[table][off:])) }
+	numGlyphs, numMetrics := u16("maxp", 4), u16("hhea", 34)
+	loca := func(gid int) int {
+		if u16("head", 50) == 0 {
+			return 2 * u16("loca", 2*gid)
+		}
+		return int(binary.BigEndian.Uint32(tables["loca"][4*gid:]))
+	}
+	scaled := func(v int, f float64) int { return int(math.Round(float64(v) * f)) }
+
+	fixed := func(buf []byte, v float64) []byte { return binary.BigEndian.AppendUint32(buf, uint32(int32(v*65536))) }
+	fvar := []byte{0, 1, 0, 0, 0, 16, 0, 2, 0, 2, 0, 20, 0, 0, 0, 12}
+	for _, axis := range []struct {
+		tag           string
+		min, def, max float64
+	}{{"wght", 100, 400, 900}, {"wdth", 75, 100, 100}} {
+		fvar = append(fvar, axis.tag...)
+		fvar = fixed(fixed(fixed(fvar, axis.min), axis.def), axis.max)
+		fvar = append(fvar, 0, 0, 1, 0)
+	}
+	// wght 650 is mapped to 0.6 instead of 0.5
+	avar := []byte{0, 1, 0, 0, 0, 0, 0, 2, 0, 4, 0xC0, 0, 0xC0, 0, 0, 0, 0, 0, 0x20, 0, 0x26, 0x66, 0x40, 0, 0x40, 0,
+		0, 3, 0xC0, 0, 0xC0, 0, 0, 0, 0, 0, 0x40, 0, 0x40, 0}
+
+	gvar := []byte{0, 1, 0, 0, 0, 2, 0, 1}
+	gvar = binary.BigEndian.AppendUint32(gvar, uint32(20+4*(numGlyphs+1)))
+	gvar = binary.BigEndian.AppendUint16(gvar, uint16(numGlyphs))
+	gvar = append(gvar, 0, 1)
+	gvar = binary.BigEndian.AppendUint32(gvar, uint32(24+4*(numGlyphs+1)))
+	var data []byte
+	var hvarRows []byte
+	for gid := 0; gid < numGlyphs; gid++ {
+		gvar = binary.BigEndian.AppendUint32(gvar, uint32(len(data)))
+		adv, lsb := u16("hmtx", 4*(numMetrics-1)), 0
+		if gid < numMetrics {
+			adv, lsb = u16("hmtx", 4*gid), int(int16(u16("hmtx", 4*gid+2)))
+		} else {
+			lsb = int(int16(u16("hmtx", 4*numMetrics+2*(gid-numMetrics))))
+		}
+		hvarRows = binary.BigEndian.AppendUint16(hvarRows, uint16(scaled(adv, 0.2)))
+		hvarRows = binary.BigEndian.AppendUint16(hvarRows, uint16(scaled(adv, -0.25)))
+		var xs []int
+		xMin := 0
+		if g := tables["glyf"][loca(gid):loca(gid+1)]; len(g) > 0 {
+			xMin = int(int16(binary.BigEndian.Uint16(g[2:])))
+			if n := int(int16(binary.BigEndian.Uint16(g))); n >= 0 {
+				for _, contour := range ttfContours(tables["glyf"], []int{loca(gid), loca(gid + 1)}, 0, 0) {
+					for _, p := range contour {
+						xs = append(xs, int(p.x))
+					}
+				}
+			} else {
+				for off, flags := 10, 0x20; flags&0x20 != 0; {
+					flags = int(binary.BigEndian.Uint16(g[off:]))
+					off += 4
+					if flags&1 != 0 {
+						xs = append(xs, int(int16(binary.BigEndian.Uint16(g[off:]))))
+						off += 4
+					} else {
+						xs = append(xs, int(int8(g[off])))
+						off += 2
+					}
+					off += map[int]int{0x08: 2, 0x40: 4, 0x80: 8}[flags&0xC8]
+				}
+			}
+		}
+		n := len(xs)
+		xs = append(xs, xMin-lsb, xMin-lsb+adv, 0, 0)
+		wght, wdth := make([]int, n+4), []int{}
+		var points []int
+		for j, x := range xs {
+			wght[j] = scaled(x, 0.2)
+			if j%2 == 0 || j >= n {
+				points = append(points, j)
+				wdth = append(wdth, scaled(x, -0.25))
+			}
+		}
+		tuple1 := append(append([]byte{0}, packedTestDeltas(wght)...), packedTestDeltas(make([]int, n+4))...)
+		tuple2 := append(packedTestPoints(points), packedTestDeltas(wdth)...)
+		tuple2 = append(tuple2, packedTestDeltas(make([]int, len(points)))...)
+		glyph := []byte{0, 2, 0, 16}
+		glyph = binary.BigEndian.AppendUint16(glyph, uint16(len(tuple1)))
+		glyph = append(glyph, 0x20, 0)
+		glyph = binary.BigEndian.AppendUint16(glyph, uint16(len(tuple2)))
+		glyph = append(glyph, 0xA0, 0, 0, 0, 0xC0, 0)
+		glyph = append(append(glyph, tuple1...), tuple2...)
+		for len(glyph)%2 != 0 {
+			glyph = append(glyph, 0)
+		}
+		data = append(data, glyph...)
+	}
+	gvar = binary.BigEndian.AppendUint32(gvar, uint32(len(data)))
+	gvar = append(gvar, 0x40, 0, 0, 0) // shared tuple: wght 1
+	gvar = append(gvar, data...)
+
+	tables["fvar"], tables["avar"], tables["gvar"] = fvar, avar, gvar
+	if hvar {
+		store := []byte{0, 1, 0, 0, 0, 0, 0, 20, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			0, 1, 0, 0, 0, 12, 0, 1, 0, 0, 0, 40,
+			0, 2, 0, 2, 0, 0, 0x40, 0, 0x40, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xC0, 0, 0xC0, 0, 0, 0}
+		store = binary.BigEndian.AppendUint16(store, uint16(numGlyphs))
+		store = append(store, 0, 2, 0, 2, 0, 0, 0, 1)
+		tables["HVAR"] = append(store, hvarRows...)
+	}
+	return sfntFonts("\x00\x01\x00\x00", tables)
+}
+
+func Test_AddUTF8FontVariation(t *testing.T) {
+	ttf, err := os.ReadFile(FontFile("DejaVuSansCondensed.ttf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := NewDocPdfTest()
+	pdf.AddUTF8Font("dejavu", "", FontFile("DejaVuSansCondensed.ttf"))
+	fonts := map[string]map[string]float64{
+		"default": nil, "semibold": {"wght": 650}, "condensed": {"wdth": 75},
+		"black": {"wght": 900, "wdth": 75}, "clamped": {"wght": 2000},
+	}
+	for name, variation := range fonts {
+		pdf.AddUTF8FontVariationFromBytes("gvar"+name, "", variableFromTrueType(ttf, false), variation)
+		pdf.AddUTF8FontVariationFromBytes("hvar"+name, "", variableFromTrueType(ttf, true), variation)
+	}
+	if pdf.Err() {
+		t.Fatal(pdf.Error())
+	}
+	pdf.AddPage()
+	const txt = "Variable fonts: Hamburgefonstiv ÀÉÎÕÜ åéîõü"
+	pdf.SetFont("dejavu", "", 14)
+	static := pdf.GetStringWidth(txt)
+	factors := map[string]float64{"default": 1, "semibold": 1.12, "condensed": 0.75, "black": 0.95, "clamped": 1.2}
+	for _, name := range []string{"default", "semibold", "condensed", "black", "clamped"} {
+		for _, kind := range []string{"gvar", "hvar"} {
+			pdf.SetFont(kind+name, "", 14)
+			w := pdf.GetStringWidth(txt)
+			if want := static * factors[name]; math.Abs(w-want) > want*0.01 {
+				t.Errorf("%s %s: width %.2f, want %.2f", kind, name, w, want)
+			}
+			pdf.Cell(0, 10, txt)
+			pdf.Ln(10)
+		}
+	}
+
+	for _, test := range []struct {
+		font      []byte
+		variation map[string]float64
+		err       string
+	}{
+		{variableFromTrueType(ttf, true), map[string]float64{"slnt": -10}, "no variation axis"},
+		{ttf, map[string]float64{"wght": 700}, "not a variable font"},
+	} {
+		pdf := NewDocPdfTest()
+		pdf.AddUTF8FontVariationFromBytes("font", "", test.font, test.variation)
+		if !pdf.Err() || !strings.Contains(pdf.Error().Error(), test.err) {
+			t.Errorf("error %v, want %q", pdf.Error(), test.err)
+		}
+	}
+
+	fileStr := Filename("Test_AddUTF8FontVariation")
+	err = pdf.OutputFileAndClose(fileStr)
+	SummaryCompare(err, fileStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Output:
+	// Successfully generated pdf/Test_AddUTF8FontVariation.pdf
+}